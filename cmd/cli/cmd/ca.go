@@ -0,0 +1,25 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// caCmd represents the ca command, offline operations with the root of the ca
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Offline operations with the root of the micro-vault ca",
+	Long: `Creating an offline root ca and signing the intermediate ca of micro-vault with it. 
+These commands work only on local files, the root key never has to be on a micro-vault server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("ca called")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(caCmd)
+}
@@ -4,17 +4,28 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
 )
 
+// linuxCADir the folder of the local ca certificates on debian based linux systems
+const linuxCADir = "/usr/local/share/ca-certificates"
+
 // cacertCmd represents the cacert command
 var cacertCmd = &cobra.Command{
 	Use:   "cacert",
 	Short: "Getting the root certificate of the ca",
-	Long:  `Getting the root certificate of the micro-vault certificate authority`,
+	Long: `Getting the trust bundle of the micro-vault certificate authority.
+Normally this is only the root certificate, after a rollover of the ca key the bundle contains the actual and the previous certificates.
+With --out the bundle is saved to a file, with --install the bundle is installed into the trust store of the system (linux and windows, needs admin rights).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		adm, err := cmdutils.AdminClient()
 		if err != nil {
@@ -24,7 +35,27 @@ var cacertCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Println(p)
+		out, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+		inst, err := cmd.Flags().GetBool("install")
+		if err != nil {
+			return err
+		}
+		if out != "" {
+			err = os.WriteFile(out, []byte(p), 0644)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("trust bundle saved to %s\r\n", out)
+		}
+		if inst {
+			return installBundle(p)
+		}
+		if out == "" {
+			fmt.Println(p)
+		}
 		return nil
 	},
 }
@@ -34,4 +65,67 @@ func init() {
 
 	cacertCmd.Flags().String("url", "https://localhost:8443", "insert the url to the mv service")
 	cacertCmd.MarkFlagRequired("url")
+	cacertCmd.Flags().StringP("out", "o", "", "save the trust bundle to this file")
+	cacertCmd.Flags().Bool("install", false, "install the trust bundle into the trust store of the system")
+}
+
+// installBundle installing all certificates of the bundle, previously installed certificates of micro-vault are replaced
+func installBundle(bundle string) error {
+	crts := make([][]byte, 0)
+	b := []byte(bundle)
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			break
+		}
+		if p.Type == "CERTIFICATE" {
+			crts = append(crts, pem.EncodeToMemory(p))
+		}
+	}
+	if len(crts) == 0 {
+		return errors.New("no certificate found in the trust bundle")
+	}
+	switch runtime.GOOS {
+	case "linux":
+		old, err := filepath.Glob(filepath.Join(linuxCADir, "micro-vault-*.crt"))
+		if err != nil {
+			return err
+		}
+		for _, f := range old {
+			err = os.Remove(f)
+			if err != nil {
+				return err
+			}
+		}
+		for i, c := range crts {
+			err = os.WriteFile(filepath.Join(linuxCADir, fmt.Sprintf("micro-vault-%d.crt", i)), c, 0644)
+			if err != nil {
+				return err
+			}
+		}
+		return run("update-ca-certificates")
+	case "windows":
+		for i, c := range crts {
+			f := filepath.Join(os.TempDir(), fmt.Sprintf("micro-vault-%d.crt", i))
+			err := os.WriteFile(f, c, 0644)
+			if err != nil {
+				return err
+			}
+			err = run("certutil", "-addstore", "-f", "Root", f)
+			_ = os.Remove(f)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("install is not supported on %s, please use --out and install the bundle manually", runtime.GOOS)
+}
+
+func run(name string, args ...string) error {
+	c := exec.Command(name, args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
 }
@@ -0,0 +1,96 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+	"github.com/willie68/micro-vault/pkg/crypt"
+)
+
+// caRootCmd represents the ca root command
+var caRootCmd = &cobra.Command{
+	Use:   "root",
+	Short: "Create a new offline root ca",
+	Long: `Create a new private key and a self signed root certificate for an offline root ca. 
+Keep the key offline, only the certificate is configured in the micro-vault service (cacert.root).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kf, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		cf, err := cmd.Flags().GetString("cert")
+		if err != nil {
+			return err
+		}
+		cn, err := cmd.Flags().GetString("cn")
+		if err != nil {
+			return err
+		}
+		o, err := cmd.Flags().GetString("org")
+		if err != nil {
+			return err
+		}
+		c, err := cmd.Flags().GetString("country")
+		if err != nil {
+			return err
+		}
+		y, err := cmd.Flags().GetInt("years")
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(kf); err == nil {
+			return fmt.Errorf("key file %s already exists", kf)
+		}
+		rsk, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return err
+		}
+		subj := pkix.Name{CommonName: cn}
+		if o != "" {
+			subj.Organization = []string{o}
+		}
+		if c != "" {
+			subj.Country = []string{c}
+		}
+		der, err := keyman.CreateRootCA(subj, rsk, time.Until(time.Now().AddDate(y, 0, 0)))
+		if err != nil {
+			return err
+		}
+		kp, err := crypt.Prv2Pem(rsk)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(kf, kp, 0600)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(cf, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("root key        : %s\r\n", kf)
+		fmt.Printf("root certificate: %s\r\n", cf)
+		return nil
+	},
+}
+
+func init() {
+	caCmd.AddCommand(caRootCmd)
+
+	caRootCmd.Flags().String("key", "root.key", "file of the new private root key")
+	caRootCmd.Flags().String("cert", "root.pem", "file of the new root certificate")
+	caRootCmd.Flags().String("cn", "micro-vault root ca", "common name of the root")
+	caRootCmd.Flags().String("org", "", "organization of the root")
+	caRootCmd.Flags().String("country", "", "country of the root")
+	caRootCmd.Flags().Int("years", 20, "validity of the root in years")
+}
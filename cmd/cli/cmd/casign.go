@@ -0,0 +1,103 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+	"github.com/willie68/micro-vault/pkg/crypt"
+)
+
+// caSignCmd represents the ca sign command
+var caSignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign an intermediate ca with the offline root",
+	Long: `Sign the certificate request of a micro-vault intermediate ca with the offline root. 
+micro-vault writes the request on startup next to the missing intermediate certificate (<certificate>.csr).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kf, err := cmd.Flags().GetString("key")
+		if err != nil {
+			return err
+		}
+		rf, err := cmd.Flags().GetString("root")
+		if err != nil {
+			return err
+		}
+		cf, err := cmd.Flags().GetString("csr")
+		if err != nil {
+			return err
+		}
+		of, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+		d, err := cmd.Flags().GetInt("days")
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(kf)
+		if err != nil {
+			return err
+		}
+		rsk, err := crypt.Pem2Prv(string(b))
+		if err != nil {
+			return err
+		}
+		root, err := readPEM(rf, "CERTIFICATE")
+		if err != nil {
+			return err
+		}
+		rc, err := x509.ParseCertificate(root)
+		if err != nil {
+			return err
+		}
+		req, err := readPEM(cf, "CERTIFICATE REQUEST")
+		if err != nil {
+			return err
+		}
+		csr, err := x509.ParseCertificateRequest(req)
+		if err != nil {
+			return err
+		}
+		der, err := keyman.SignIntermediate(csr, rc, rsk, time.Hour*24*time.Duration(d))
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(of, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("intermediate certificate for \"%s\": %s\r\n", csr.Subject.CommonName, of)
+		return nil
+	},
+}
+
+func init() {
+	caCmd.AddCommand(caSignCmd)
+
+	caSignCmd.Flags().String("key", "root.key", "file of the private root key")
+	caSignCmd.Flags().String("root", "root.pem", "file of the root certificate")
+	caSignCmd.Flags().String("csr", "certificate.pem.csr", "file of the certificate request of the intermediate")
+	caSignCmd.Flags().String("out", "certificate.pem", "file of the new intermediate certificate")
+	caSignCmd.Flags().Int("days", 5*365, "validity of the intermediate in days")
+}
+
+func readPEM(f, typ string) ([]byte, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := pem.Decode(b)
+	if p == nil || p.Type != typ {
+		return nil, errors.New("no pem block of type " + typ + " found in " + f)
+	}
+	return p.Bytes, nil
+}
@@ -1,7 +1,10 @@
 package cmdutils
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -76,7 +79,7 @@ func ClientLogin(accesskey, secret, url string) (*Conf, error) {
 	return &d, nil
 }
 
-// ClientLogout invalidates an admin session
+// ClientLogout invalidates a client session, the tokens are revoked on the server
 func ClientLogout() error {
 	cl, ok := ReadCLConf()
 	if !ok {
@@ -89,6 +92,7 @@ func ClientLogout() error {
 			URL:      "https://localhost:8443",
 		}
 	}
+	revokeSession(*cl)
 	cl.Token = ""
 	cl.Refresh = ""
 	return writeCLConf(*cl)
@@ -119,7 +123,7 @@ func Client() (*client.Client, error) {
 	return cli, err
 }
 
-// AdminLogout invalidates an admin session
+// AdminLogout invalidates an admin session, the tokens are revoked on the server
 func AdminLogout() error {
 	cl, ok := ReadCLConf()
 	if !ok {
@@ -132,11 +136,34 @@ func AdminLogout() error {
 			URL:      "https://localhost:8443",
 		}
 	}
+	revokeSession(*cl)
 	cl.Token = ""
 	cl.Refresh = ""
 	return writeCLConf(*cl)
 }
 
+// revokeSession revoking the tokens of the session on the server, errors are only logged
+func revokeSession(cl Conf) {
+	if cl.Token == "" && cl.Refresh == "" {
+		return
+	}
+	if cl.Admin {
+		adm, err := client.LoginAdminCli(cl.Token, cl.Refresh, cl.URL, nil)
+		if err != nil {
+			logging.Root.Errorf("error revoking session: %v", err)
+			return
+		}
+		adm.Logout()
+		return
+	}
+	cli, err := client.LoginClientCli(cl.Token, cl.Refresh, cl.URL, nil)
+	if err != nil {
+		logging.Root.Errorf("error revoking session: %v", err)
+		return
+	}
+	cli.Logout()
+}
+
 // AdminClient creates a new admin client with the specifig stored configuration
 func AdminClient() (*client.AdminCl, error) {
 	cfg, ok := ReadCLConf()
@@ -224,7 +251,7 @@ func expires(t string) int64 {
 }
 
 // OutputCertificate writes the given certificate and private key to the desired files
-func OutputCertificate(c x509.Certificate, p rsa.PrivateKey, certFile, privFile string) error {
+func OutputCertificate(c x509.Certificate, p crypto.PrivateKey, certFile, privFile string) error {
 	certOut, err := os.Create(certFile)
 	if err != nil {
 		return err
@@ -241,7 +268,7 @@ func OutputCertificate(c x509.Certificate, p rsa.PrivateKey, certFile, privFile
 	if err != nil {
 		return err
 	}
-	privBytes, err := x509.MarshalPKCS8PrivateKey(&p)
+	privBytes, err := x509.MarshalPKCS8PrivateKey(p)
 	if err != nil {
 		return err
 	}
@@ -254,3 +281,68 @@ func OutputCertificate(c x509.Certificate, p rsa.PrivateKey, certFile, privFile
 	log.Print("wrote key.pem\n")
 	return nil
 }
+
+// OutputChain writing the certificate followed by the chain of the ca into the file
+func OutputChain(xcs []*x509.Certificate, certFile string) error {
+	certOut, err := os.Create(certFile)
+	if err != nil {
+		return err
+	}
+	for _, c := range xcs {
+		if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			certOut.Close()
+			return err
+		}
+	}
+	if err := certOut.Close(); err != nil {
+		return err
+	}
+	log.Printf("wrote %s\n", certFile)
+	return nil
+}
+
+// LoadOrCreateKey loading the pem encoded private key from the file,
+// if the file doesn't exists, a new ecdsa P-256 key is generated and written to the file
+func LoadOrCreateKey(keyFile string) (crypto.Signer, error) {
+	b, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		privBytes, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}), 0600)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("wrote %s\n", keyFile)
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p, _ := pem.Decode(b)
+	if p == nil {
+		return nil, fmt.Errorf("no pem block found in %s", keyFile)
+	}
+	var k any
+	switch p.Type {
+	case "RSA PRIVATE KEY":
+		k, err = x509.ParsePKCS1PrivateKey(p.Bytes)
+	case "EC PRIVATE KEY":
+		k, err = x509.ParseECPrivateKey(p.Bytes)
+	default:
+		k, err = x509.ParsePKCS8PrivateKey(p.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key in %s", keyFile)
+	}
+	return s, nil
+}
@@ -3,6 +3,9 @@ package cmdutils
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
 )
 
 // Labels2String converts the labels map to a single console string
@@ -42,3 +45,22 @@ func Slice2Map(ls []string) map[string]string {
 	}
 	return m
 }
+
+// ParseTime parses a point in time, given as RFC3339 time, as date (2006-01-02) or as duration from now (e.g. 90d).
+// never or an empty string returns nil
+func ParseTime(s string) (*time.Time, error) {
+	if s == "" || s == "never" {
+		return nil, nil
+	}
+	for _, l := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(l, s); err == nil {
+			return &t, nil
+		}
+	}
+	d, err := str2duration.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("neither a time nor a duration: %s", s)
+	}
+	t := time.Now().Add(d)
+	return &t, nil
+}
@@ -7,20 +7,30 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+	"github.com/willie68/micro-vault/pkg/client"
+	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
 // createCertificateCmd represents the certificate command
 var createCertificateCmd = &cobra.Command{
 	Use:   "certificate",
 	Short: "Create a signed certificate from the mv ca",
-	Long:  `Create a signed certificate from the mv ca`,
+	Long: `Create a signed certificate from the mv ca.
+With --mode client (default) the certificate is created for the private key of the client, held by micro-vault.
+With --mode csr the certificate is created for your own private key (--key), the private key never leaves this machine.
+If the key file doesn't exists, a new ECDSA P-256 key is generated.
+With --mode generate the mv service generates a new key (--keytype) and returns a bundle (--format pem, pkcs12 or pkcs12-legacy),
+protected with the --password.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cli, err := cmdutils.Client()
 		if err != nil {
@@ -78,6 +88,14 @@ var createCertificateCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		prf, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			return err
+		}
+		mode, err := cmd.Flags().GetString("mode")
+		if err != nil {
+			return err
+		}
 
 		emailAddress := uem
 		subj := pkix.Name{
@@ -138,15 +156,41 @@ var createCertificateCmd = &cobra.Command{
 			URIs:           nuris,
 		}
 
-		cert, err := cli.CreateCertificate(template)
+		switch mode {
+		case "csr":
+			kf, err := cmd.Flags().GetString("key")
+			if err != nil {
+				return err
+			}
+			if kf == "" {
+				kf = filepath.Join(o, "key.pem")
+			}
+			k, err := cmdutils.LoadOrCreateKey(kf)
+			if err != nil {
+				return err
+			}
+			xcs, err := cli.CreateKeyCertificate(template, k, prf)
+			if err != nil {
+				return err
+			}
+			return cmdutils.OutputChain(xcs, filepath.Join(o, "cert.pem"))
+		case "generate":
+			return createBundle(cmd, cli, template, prf, o)
+		case "client":
+		default:
+			return fmt.Errorf("unknown mode: %s", mode)
+		}
+
+		xcs, err := cli.CreateProfileCertificate(template, prf)
 		if err != nil {
 			return err
 		}
+		cert := xcs[0]
 		p, err := cli.PrivateKey()
 		if err != nil {
 			return err
 		}
-		err = cmdutils.OutputCertificate(*cert, *p, filepath.Join(o, "cert.pem"), filepath.Join(o, "key.pem"))
+		err = cmdutils.OutputCertificate(*cert, p, filepath.Join(o, "cert.pem"), filepath.Join(o, "key.pem"))
 		if err != nil {
 			return err
 		}
@@ -170,4 +214,48 @@ func init() {
 	createCertificateCmd.Flags().StringArray("dns", []string{}, "insert the dnsnames")
 	createCertificateCmd.Flags().StringArray("ip", []string{}, "insert the ip addresses")
 	createCertificateCmd.Flags().StringArray("uri", []string{}, "insert the uris")
+	createCertificateCmd.Flags().String("profile", "", "certificate profile, default is the first profile of the client")
+	createCertificateCmd.Flags().String("mode", "client", "client: key of the client, csr: your own key, generate: new key generated by the mv service")
+	createCertificateCmd.Flags().String("key", "", "mode csr: the file of your private key, default key.pem in the output path")
+	createCertificateCmd.Flags().String("keytype", "ECDSA-P256", "mode generate: ECDSA-P256, ECDSA-P384, RSA-2048, RSA-3072 or RSA-4096")
+	createCertificateCmd.Flags().String("format", "pem", "mode generate: pem, pkcs12 or pkcs12-legacy")
+	createCertificateCmd.Flags().String("password", "", "mode generate: the password of the bundle")
+}
+
+// createBundle creating a certificate with a key generated by the mv service and writing the bundle
+func createBundle(cmd *cobra.Command, cli *client.Client, template x509.CertificateRequest, prf, o string) error {
+	kt, err := cmd.Flags().GetString("keytype")
+	if err != nil {
+		return err
+	}
+	f, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	pwd, err := cmd.Flags().GetString("password")
+	if err != nil {
+		return err
+	}
+	if pwd == "" {
+		return errors.New("a password for the bundle is required, please use --password")
+	}
+	b, err := cli.CreateCertificateBundle(template, pmodel.CertBundleRequest{
+		Profile:  prf,
+		KeyType:  kt,
+		Format:   f,
+		Password: pwd,
+	})
+	if err != nil {
+		return err
+	}
+	bf := filepath.Join(o, "bundle.pem")
+	if strings.HasPrefix(f, "pkcs12") {
+		bf = filepath.Join(o, "bundle.p12")
+	}
+	err = os.WriteFile(bf, b, 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\r\n", bf)
+	return nil
 }
@@ -27,7 +27,14 @@ Attention: The secret will never be shown again and nowhere stored on the mv ins
 			return err
 		}
 		gs, err := cmd.Flags().GetStringSlice("groups")
-		cl, err := adm.NewClient(n, gs)
+		if err != nil {
+			return err
+		}
+		kt, err := cmd.Flags().GetString("keytype")
+		if err != nil {
+			return err
+		}
+		cl, err := adm.NewClientWithKeyType(n, gs, kt)
 		if err != nil {
 			return err
 		}
@@ -36,6 +43,7 @@ Attention: The secret will never be shown again and nowhere stored on the mv ins
 		fmt.Println("Secret    :", cl.Secret)
 		fmt.Println("Groups    :", cmdutils.Slice2String(cl.Groups))
 		fmt.Println("KID       :", cl.KID)
+		fmt.Println("Key Type  :", cl.KeyType)
 		return nil
 	},
 }
@@ -46,4 +54,5 @@ func init() {
 	createClientCmd.Flags().StringP("name", "n", "", "Name of the client")
 	createClientCmd.MarkFlagRequired("name")
 	createClientCmd.Flags().StringSliceP("groups", "g", []string{}, "Groups to which the clients belong to.")
+	createClientCmd.Flags().String("keytype", "", "key type of the client: RSA-2048, RSA-3072, RSA-4096 (default), ECDSA-P256, ECDSA-P384 or ED25519")
 }
@@ -0,0 +1,38 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// createEABCmd represents the eab command
+var createEABCmd = &cobra.Command{
+	Use:   "eab",
+	Short: "Create acme external account binding credentials",
+	Long: `Create external account binding credentials for the acme server of micro-vault.
+With these an acme client (certbot, lego, cert-manager...) can create an acme account bound to the logged in client.
+The credentials can only be used once.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := cmdutils.Client()
+		if err != nil {
+			return err
+		}
+		eab, err := cli.ACMEEAB()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Directory: %s\r\n", eab.Directory)
+		fmt.Printf("EAB KID  : %s\r\n", eab.KID)
+		fmt.Printf("EAB Key  : %s\r\n", eab.Key)
+		return nil
+	},
+}
+
+func init() {
+	createCmd.AddCommand(createEABCmd)
+}
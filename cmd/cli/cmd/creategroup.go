@@ -28,13 +28,31 @@ or you can optionally add some labels to it.`,
 			return err
 		}
 		ls, err := cmd.Flags().GetStringSlice("labels")
+		if err != nil {
+			return err
+		}
+		kr, err := cmd.Flags().GetString("keyrotation")
+		if err != nil {
+			return err
+		}
+		ttl, err := cmd.Flags().GetString("tokenttl")
+		if err != nil {
+			return err
+		}
+		rttl, err := cmd.Flags().GetString("refreshttl")
+		if err != nil {
+			return err
+		}
 		fmt.Println("Name: ", n)
 		fmt.Println("Labels: ", cmdutils.Slice2String(ls))
 		lm := cmdutils.Slice2Map(ls)
 		g := pmodel.Group{
-			Name:     n,
-			Label:    lm,
-			IsClient: false,
+			Name:        n,
+			Label:       lm,
+			IsClient:    false,
+			KeyRotation: kr,
+			TokenTTL:    ttl,
+			RefreshTTL:  rttl,
 		}
 		err = adm.AddGroup(g)
 		if err != nil {
@@ -50,4 +68,7 @@ func init() {
 	createGroupCmd.Flags().StringP("name", "n", "", "Name of the group")
 	createGroupCmd.MarkFlagRequired("name")
 	createGroupCmd.Flags().StringSliceP("labels", "l", []string{}, "Labels of the group, each label must be formatted as <lgn>:<Label> e.g. en:Group")
+	createGroupCmd.Flags().StringP("keyrotation", "k", "", "Period for the automatic rotation of the group key, e.g. 90d")
+	createGroupCmd.Flags().String("tokenttl", "", "lifetime of the access tokens of the clients of the group, e.g. 1h")
+	createGroupCmd.Flags().String("refreshttl", "", "lifetime of the refresh tokens of the clients of the group, e.g. 8h")
 }
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
@@ -33,6 +34,20 @@ var getClientCmd = &cobra.Command{
 		fmt.Printf("Secret    : %s\r\n", c.Secret)
 		fmt.Printf("Groups    : %s\r\n", cmdutils.Slice2String(c.Groups))
 		fmt.Printf("KID       : %s\r\n", c.KID)
+		fmt.Printf("Key Type  : %s\r\n", c.KeyType)
+		fmt.Printf("Disabled  : %t\r\n", c.Disabled)
+		if c.TokenTTL != "" {
+			fmt.Printf("Token TTL : %s\r\n", c.TokenTTL)
+		}
+		if c.RefreshTTL != "" {
+			fmt.Printf("Refresh TTL: %s\r\n", c.RefreshTTL)
+		}
+		if c.NotBefore != nil {
+			fmt.Printf("Not Before: %s\r\n", c.NotBefore.Format(time.RFC3339))
+		}
+		if c.ExpiresAt != nil {
+			fmt.Printf("Expires At: %s\r\n", c.ExpiresAt.Format(time.RFC3339))
+		}
 		return nil
 	},
 }
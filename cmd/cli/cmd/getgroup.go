@@ -31,6 +31,9 @@ var getGroupCmd = &cobra.Command{
 		fmt.Printf("Name      : %s\r\n", g.Name)
 		fmt.Printf("is Client : %t\r\n", g.IsClient)
 		fmt.Printf("Label      : %s\r\n", cmdutils.Labels2String(g.Label))
+		fmt.Printf("Key rotation: %s\r\n", g.KeyRotation)
+		fmt.Printf("Token TTL   : %s\r\n", g.TokenTTL)
+		fmt.Printf("Refresh TTL : %s\r\n", g.RefreshTTL)
 		return nil
 	},
 }
@@ -0,0 +1,25 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// kvCmd represents the kv command, working with the key/value secret store
+var kvCmd = &cobra.Command{
+	Use:   "kv",
+	Short: "Working with the key/value secret store of your micro-vault instance",
+	Long: `Reading and writing versioned secrets of the key/value store. 
+Secrets are addressed by the group and a path, e.g. db/password. You need a client login with access to the group.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("kv called")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(kvCmd)
+}
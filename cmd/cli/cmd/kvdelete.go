@@ -0,0 +1,71 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// kvDeleteCmd represents the kv delete command
+var kvDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a secret",
+	Long: `Soft delete versions of a secret, default is the current version. 
+Deleted versions can be restored with --undelete, with --destroy the secret is removed with all versions permanently.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := cmdutils.Client()
+		if err != nil {
+			return err
+		}
+		g, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		p, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		vs, err := cmd.Flags().GetIntSlice("versions")
+		if err != nil {
+			return err
+		}
+		ud, err := cmd.Flags().GetBool("undelete")
+		if err != nil {
+			return err
+		}
+		ds, err := cmd.Flags().GetBool("destroy")
+		if err != nil {
+			return err
+		}
+		switch {
+		case ds:
+			err = cli.KVDestroy(g, p)
+		case ud:
+			err = cli.KVUndelete(g, p, vs...)
+		default:
+			err = cli.KVDelete(g, p, vs...)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("secret %s of group %s changed\r\n", p, g)
+		return nil
+	},
+}
+
+func init() {
+	kvCmd.AddCommand(kvDeleteCmd)
+
+	kvDeleteCmd.Flags().StringP("group", "g", "", "group of the secret")
+	kvDeleteCmd.MarkFlagRequired("group")
+	kvDeleteCmd.Flags().StringP("path", "p", "", "path of the secret")
+	kvDeleteCmd.MarkFlagRequired("path")
+	kvDeleteCmd.Flags().IntSliceP("versions", "v", []int{}, "versions to delete or undelete, comma separated")
+	kvDeleteCmd.Flags().Bool("undelete", false, "restore the deleted versions")
+	kvDeleteCmd.Flags().Bool("destroy", false, "remove the secret with all versions permanently")
+	kvDeleteCmd.MarkFlagsMutuallyExclusive("undelete", "destroy")
+}
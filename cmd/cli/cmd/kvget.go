@@ -0,0 +1,58 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// kvGetCmd represents the kv get command
+var kvGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Get a secret",
+	Long:  `Get the current or a specific version of a secret`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := cmdutils.Client()
+		if err != nil {
+			return err
+		}
+		g, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		p, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		v, err := cmd.Flags().GetInt("version")
+		if err != nil {
+			return err
+		}
+		kv, err := cli.KVGet(g, p, v)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Group     : %s\r\n", kv.Group)
+		fmt.Printf("Path      : %s\r\n", kv.Path)
+		fmt.Printf("Version   : %d\r\n", kv.Version)
+		fmt.Printf("Created   : %s\r\n", kv.Created.Format(time.RFC3339))
+		fmt.Printf("Created by: %s\r\n", kv.CreatedBy)
+		fmt.Printf("Value     : %s\r\n", kv.Value)
+		return nil
+	},
+}
+
+func init() {
+	kvCmd.AddCommand(kvGetCmd)
+
+	kvGetCmd.Flags().StringP("group", "g", "", "group of the secret")
+	kvGetCmd.MarkFlagRequired("group")
+	kvGetCmd.Flags().StringP("path", "p", "", "path of the secret")
+	kvGetCmd.MarkFlagRequired("path")
+	kvGetCmd.Flags().IntP("version", "v", 0, "version of the secret, default is the current version")
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// kvListCmd represents the kv list command
+var kvListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the secrets of a group",
+	Long:  `List the keys of a group below the path, sub folders are ending with a /`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := cmdutils.Client()
+		if err != nil {
+			return err
+		}
+		g, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		p, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		ks, err := cli.KVList(g, p)
+		if err != nil {
+			return err
+		}
+		for _, k := range ks {
+			fmt.Printf("%s\r\n", k)
+		}
+		return nil
+	},
+}
+
+func init() {
+	kvCmd.AddCommand(kvListCmd)
+
+	kvListCmd.Flags().StringP("group", "g", "", "group of the secrets")
+	kvListCmd.MarkFlagRequired("group")
+	kvListCmd.Flags().StringP("path", "p", "", "folder of the secrets")
+}
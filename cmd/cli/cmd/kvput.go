@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// kvPutCmd represents the kv put command
+var kvPutCmd = &cobra.Command{
+	Use:   "put",
+	Short: "Write a secret",
+	Long: `Write a new version of a secret. 
+With --cas the secret is only written, if the given version is the current version (0 for a new secret).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli, err := cmdutils.Client()
+		if err != nil {
+			return err
+		}
+		g, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		p, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		v, err := cmd.Flags().GetString("value")
+		if err != nil {
+			return err
+		}
+		var md *pmodel.KVMetadata
+		if cmd.Flags().Changed("cas") {
+			cas, err := cmd.Flags().GetInt("cas")
+			if err != nil {
+				return err
+			}
+			md, err = cli.KVPutCAS(g, p, v, cas)
+			if err != nil {
+				return err
+			}
+		} else {
+			md, err = cli.KVPut(g, p, v)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Group     : %s\r\n", md.Group)
+		fmt.Printf("Path      : %s\r\n", md.Path)
+		fmt.Printf("Version   : %d\r\n", md.CurrentVersion)
+		fmt.Printf("Updated   : %s\r\n", md.Updated.Format(time.RFC3339))
+		return nil
+	},
+}
+
+func init() {
+	kvCmd.AddCommand(kvPutCmd)
+
+	kvPutCmd.Flags().StringP("group", "g", "", "group of the secret")
+	kvPutCmd.MarkFlagRequired("group")
+	kvPutCmd.Flags().StringP("path", "p", "", "path of the secret")
+	kvPutCmd.MarkFlagRequired("path")
+	kvPutCmd.Flags().StringP("value", "v", "", "value of the secret")
+	kvPutCmd.MarkFlagRequired("value")
+	kvPutCmd.Flags().Int("cas", 0, "check-and-set, the current version of the secret, 0 for a new secret")
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+	"github.com/willie68/micro-vault/pkg/client"
+)
+
+// listCertificateCmd represents the certificate command
+var listCertificateCmd = &cobra.Command{
+	Use:     "certificate",
+	Short:   "list the issued certificates",
+	Long:    `listing of the issued certificates of this mv instance, sorted by the expiry`,
+	Aliases: []string{"certificates", "certs"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adm, err := cmdutils.AdminClient()
+		if err != nil {
+			return err
+		}
+		opts := make([]client.CertificatesOption, 0)
+		c, err := cmd.Flags().GetString("client")
+		if err != nil {
+			return err
+		}
+		if c != "" {
+			opts = append(opts, client.WithClientFilter(c))
+		}
+		e, err := cmd.Flags().GetString("expiring")
+		if err != nil {
+			return err
+		}
+		if e != "" {
+			opts = append(opts, client.WithExpiringFilter(e))
+		}
+		if cmd.Flags().Changed("revoked") {
+			r, err := cmd.Flags().GetBool("revoked")
+			if err != nil {
+				return err
+			}
+			opts = append(opts, client.WithRevokedFilter(r))
+		}
+		cs, err := adm.Certificates(opts...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-34s %-20s %-25s %-25s %-20s %s\r\n", "SERIAL", "CLIENT", "NOT AFTER", "REVOKED", "REASON", "SUBJECT")
+		for _, ci := range cs {
+			rv := ""
+			if ci.Revoked != nil {
+				rv = ci.Revoked.Format(time.RFC3339)
+			}
+			fmt.Printf("%-34s %-20s %-25s %-25s %-20s %s\r\n", ci.Serial, ci.Client, ci.NotAfter.Format(time.RFC3339), rv, ci.Reason, ci.Subject)
+		}
+		return nil
+	},
+}
+
+func init() {
+	listCmd.AddCommand(listCertificateCmd)
+
+	listCertificateCmd.Flags().StringP("client", "c", "", "list only certificates of that client")
+	listCertificateCmd.Flags().StringP("expiring", "e", "", "list only valid certificates expiring within this duration, e.g. 30d")
+	listCertificateCmd.Flags().Bool("revoked", false, "list only revoked certificates, with --revoked=false only not revoked certificates")
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+	"github.com/willie68/micro-vault/pkg/client"
+)
+
+// listKeyCmd represents the key command
+var listKeyCmd = &cobra.Command{
+	Use:     "key",
+	Short:   "list all group keys",
+	Long:    `listing of all group keys of this mv instance with their lifecycle state`,
+	Aliases: []string{"keys"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adm, err := cmdutils.AdminClient()
+		if err != nil {
+			return err
+		}
+		g, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		ks, err := adm.Keys(client.WithGroupFilter(g))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s %-32s %-7s %-25s %-25s %s\r\n", "KID", "GROUP", "VERSION", "STATE", "CREATED", "DESTROY AT")
+		for _, k := range ks {
+			da := ""
+			if k.DestroyAt != nil {
+				da = k.DestroyAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-20s %-32s %-7d %-25s %-25s %s\r\n", k.ID, k.Group, k.Version, k.State, k.Created.Format(time.RFC3339), da)
+		}
+		return nil
+	},
+}
+
+func init() {
+	listCmd.AddCommand(listKeyCmd)
+
+	listKeyCmd.Flags().StringP("group", "g", "", "list only keys belonging to that group")
+}
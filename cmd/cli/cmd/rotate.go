@@ -0,0 +1,24 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// rotateCmd represents the rotate command, rotating keys in the microvault system
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate a key in your micro-vault instance",
+	Long:  `Rotating keys in your micro-vault instance, groupkey.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("rotate called")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// rotateClientKeyCmd represents the clientkey command
+var rotateClientKeyCmd = &cobra.Command{
+	Use:   "clientkey",
+	Short: "Rotate the private key of a client",
+	Long: `Rotate the private key of a client, creating a new key pair. 
+The previous key can still be used for signature checks and decryption during the grace period of the service.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adm, err := cmdutils.AdminClient()
+		if err != nil {
+			return err
+		}
+		n, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		kt, err := cmd.Flags().GetString("keytype")
+		if err != nil {
+			return err
+		}
+		cl, err := adm.RotateClientKey(n, kt)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Name      : %s\r\n", cl.Name)
+		fmt.Printf("KID       : %s\r\n", cl.KID)
+		fmt.Printf("Key Type  : %s\r\n", cl.KeyType)
+		return nil
+	},
+}
+
+func init() {
+	rotateCmd.AddCommand(rotateClientKeyCmd)
+
+	rotateClientKeyCmd.Flags().StringP("name", "n", "", "Name of the client")
+	rotateClientKeyCmd.Flags().String("keytype", "", "key type of the new key: RSA-2048, RSA-3072, RSA-4096, ECDSA-P256, ECDSA-P384 or ED25519, default is the key type of the client")
+	rotateClientKeyCmd.MarkFlagRequired("name")
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// rotateGroupKeyCmd represents the groupkey command
+var rotateGroupKeyCmd = &cobra.Command{
+	Use:   "groupkey",
+	Short: "Rotate the key of a group",
+	Long: `Rotate the key of a group, creating the next key version. 
+Older key versions can still be used for decryption.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adm, err := cmdutils.AdminClient()
+		if err != nil {
+			return err
+		}
+		n, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		ek, err := adm.RotateGroupKey(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Group     : %s\r\n", ek.Group)
+		fmt.Printf("KID       : %s\r\n", ek.ID)
+		fmt.Printf("Version   : %d\r\n", ek.Version)
+		fmt.Printf("Created   : %s\r\n", ek.Created.Format(time.RFC3339))
+		return nil
+	},
+}
+
+func init() {
+	rotateCmd.AddCommand(rotateGroupKeyCmd)
+
+	rotateGroupKeyCmd.Flags().StringP("name", "n", "", "Name of the group")
+	rotateGroupKeyCmd.MarkFlagRequired("name")
+}
@@ -5,16 +5,23 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+	"github.com/willie68/micro-vault/pkg/client"
+	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
 // updateClientCmd represents the client command
 var updateClientCmd = &cobra.Command{
 	Use:   "client",
-	Short: "Updates the groups of the named client",
-	Long:  `Updates the groups of the named client`,
+	Short: "Updates the groups, the secrets or the state of the named client",
+	Long: `Updates the groups of the named client.
+With --rotate-secret a new secret is issued and printed once, the old secrets are still valid until revoked with --revoke-secret.
+With --disable, --enable, --notbefore and --expires the state of the client is changed, a disabled or expired client can't login.
+With --tokenttl and --refreshttl the token lifetimes of the client are changed, an empty value uses the lifetimes of the groups or the service.
+With --revoke-tokens all access and refresh tokens of the client issued until now or --tokens-before are revoked on all nodes.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		adm, err := cmdutils.AdminClient()
 		if err != nil {
@@ -24,7 +31,67 @@ var updateClientCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		rs, err := cmd.Flags().GetBool("rotate-secret")
+		if err != nil {
+			return err
+		}
+		rid, err := cmd.Flags().GetString("revoke-secret")
+		if err != nil {
+			return err
+		}
+		if rs {
+			exp, err := cmd.Flags().GetString("secret-expires")
+			if err != nil {
+				return err
+			}
+			s, err := adm.RotateClientSecret(n, exp)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Secret ID :", s.ID)
+			fmt.Println("Secret    :", s.Secret)
+			if s.Expires != nil {
+				fmt.Println("Expires   :", s.Expires.Format(time.RFC3339))
+			}
+		}
+		if rid != "" {
+			err = adm.RevokeClientSecret(n, rid)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Revoked   :", rid)
+		}
+		st := cmd.Flags().Changed("disable") || cmd.Flags().Changed("enable") || cmd.Flags().Changed("notbefore") || cmd.Flags().Changed("expires")
+		if st {
+			err = updateClientState(cmd, adm, n)
+			if err != nil {
+				return err
+			}
+		}
+		ttl := cmd.Flags().Changed("tokenttl") || cmd.Flags().Changed("refreshttl")
+		if ttl {
+			err = updateClientTokenTTL(cmd, adm, n)
+			if err != nil {
+				return err
+			}
+		}
+		rt, err := cmd.Flags().GetBool("revoke-tokens")
+		if err != nil {
+			return err
+		}
+		if rt {
+			err = revokeClientTokens(cmd, adm, n)
+			if err != nil {
+				return err
+			}
+		}
+		if (rs || rid != "" || st || ttl || rt) && !cmd.Flags().Changed("groups") {
+			return nil
+		}
 		gs, err := cmd.Flags().GetStringSlice("groups")
+		if err != nil {
+			return err
+		}
 		cl, err := adm.UpdateClient(n, gs)
 		if err != nil {
 			return err
@@ -43,4 +110,102 @@ func init() {
 	updateClientCmd.Flags().StringP("name", "n", "", "Name of the client")
 	updateClientCmd.MarkFlagRequired("name")
 	updateClientCmd.Flags().StringSliceP("groups", "g", []string{}, "Groups to which the clients belong to.")
+	updateClientCmd.Flags().Bool("rotate-secret", false, "issue a new secret for the client, the old secrets are still valid")
+	updateClientCmd.Flags().String("secret-expires", "", "validity of the new secret, e.g. 90d, default is no expiration")
+	updateClientCmd.Flags().String("revoke-secret", "", "id of the secret to revoke, primary for the secret given at the creation of the client")
+	updateClientCmd.Flags().Bool("disable", false, "disable the client, the client can't login or use its tokens")
+	updateClientCmd.Flags().Bool("enable", false, "enable the client")
+	updateClientCmd.Flags().String("notbefore", "", "the client can't login before, time (RFC3339), date (2006-01-02), duration from now (e.g. 1d) or never")
+	updateClientCmd.Flags().String("expires", "", "the client can't login after, time (RFC3339), date (2006-01-02), duration from now (e.g. 90d) or never")
+	updateClientCmd.Flags().String("tokenttl", "", "lifetime of the access tokens of the client, e.g. 1h, empty for the lifetime of the groups or the service")
+	updateClientCmd.Flags().String("refreshttl", "", "lifetime of the refresh tokens of the client, e.g. 8h, empty for the lifetime of the groups or the service")
+	updateClientCmd.Flags().Bool("revoke-tokens", false, "revoke all access and refresh tokens of the client, e.g. for a leaked credential")
+	updateClientCmd.Flags().String("tokens-before", "", "revoke only the tokens issued before, time (RFC3339), date (2006-01-02), default is now")
+	updateClientCmd.MarkFlagsMutuallyExclusive("disable", "enable")
+}
+
+func revokeClientTokens(cmd *cobra.Command, adm *client.AdminCl, n string) error {
+	var before *time.Time
+	if cmd.Flags().Changed("tokens-before") {
+		tb, _ := cmd.Flags().GetString("tokens-before")
+		t, err := cmdutils.ParseTime(tb)
+		if err != nil {
+			return err
+		}
+		before = t
+	}
+	err := adm.RevokeClientTokens(n, before)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Tokens revoked")
+	return nil
+}
+
+func updateClientTokenTTL(cmd *cobra.Command, adm *client.AdminCl, n string) error {
+	c, err := adm.Client(n)
+	if err != nil {
+		return err
+	}
+	tt := pmodel.TokenTTL{
+		TokenTTL:   c.TokenTTL,
+		RefreshTTL: c.RefreshTTL,
+	}
+	if cmd.Flags().Changed("tokenttl") {
+		tt.TokenTTL, _ = cmd.Flags().GetString("tokenttl")
+	}
+	if cmd.Flags().Changed("refreshttl") {
+		tt.RefreshTTL, _ = cmd.Flags().GetString("refreshttl")
+	}
+	cl, err := adm.SetClientTokenTTL(n, tt)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Token TTL  :", cl.TokenTTL)
+	fmt.Println("Refresh TTL:", cl.RefreshTTL)
+	return nil
+}
+
+func updateClientState(cmd *cobra.Command, adm *client.AdminCl, n string) error {
+	c, err := adm.Client(n)
+	if err != nil {
+		return err
+	}
+	st := pmodel.ClientState{
+		Disabled:  c.Disabled,
+		NotBefore: c.NotBefore,
+		ExpiresAt: c.ExpiresAt,
+	}
+	if cmd.Flags().Changed("disable") {
+		st.Disabled = true
+	}
+	if cmd.Flags().Changed("enable") {
+		st.Disabled = false
+	}
+	if cmd.Flags().Changed("notbefore") {
+		nb, _ := cmd.Flags().GetString("notbefore")
+		st.NotBefore, err = cmdutils.ParseTime(nb)
+		if err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("expires") {
+		ex, _ := cmd.Flags().GetString("expires")
+		st.ExpiresAt, err = cmdutils.ParseTime(ex)
+		if err != nil {
+			return err
+		}
+	}
+	cl, err := adm.SetClientState(n, st)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Disabled  :", cl.Disabled)
+	if cl.NotBefore != nil {
+		fmt.Println("Not Before:", cl.NotBefore.Format(time.RFC3339))
+	}
+	if cl.ExpiresAt != nil {
+		fmt.Println("Expires At:", cl.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
 }
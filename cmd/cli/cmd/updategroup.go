@@ -8,14 +8,13 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
-	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
 // updateGroupCmd represents the group command
 var updateGroupCmd = &cobra.Command{
 	Use:   "group",
-	Short: "Update a group with new labels",
-	Long:  `Update a group with new labels`,
+	Short: "Update a group with new labels, key rotation period or token lifetimes",
+	Long:  `Update a group with new labels, key rotation period or token lifetimes`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		adm, err := cmdutils.AdminClient()
 		if err != nil {
@@ -25,16 +24,36 @@ var updateGroupCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		ls, err := cmd.Flags().GetStringSlice("labels")
+		g, err := adm.Group(n)
 		if err != nil {
 			return err
 		}
-		lm := cmdutils.Slice2Map(ls)
-		g := pmodel.Group{
-			Name:  n,
-			Label: lm,
+		if cmd.Flags().Changed("labels") {
+			ls, err := cmd.Flags().GetStringSlice("labels")
+			if err != nil {
+				return err
+			}
+			g.Label = cmdutils.Slice2Map(ls)
 		}
-		err = adm.UpdateGroup(g)
+		if cmd.Flags().Changed("keyrotation") {
+			g.KeyRotation, err = cmd.Flags().GetString("keyrotation")
+			if err != nil {
+				return err
+			}
+		}
+		if cmd.Flags().Changed("tokenttl") {
+			g.TokenTTL, err = cmd.Flags().GetString("tokenttl")
+			if err != nil {
+				return err
+			}
+		}
+		if cmd.Flags().Changed("refreshttl") {
+			g.RefreshTTL, err = cmd.Flags().GetString("refreshttl")
+			if err != nil {
+				return err
+			}
+		}
+		err = adm.UpdateGroup(*g)
 		if err != nil {
 			return err
 		}
@@ -46,6 +65,9 @@ var updateGroupCmd = &cobra.Command{
 		fmt.Printf("Name      : %s\r\n", gl.Name)
 		fmt.Printf("is Client : %t\r\n", gl.IsClient)
 		fmt.Printf("Label      : %s\r\n", cmdutils.Labels2String(gl.Label))
+		fmt.Printf("Key rotation: %s\r\n", gl.KeyRotation)
+		fmt.Printf("Token TTL   : %s\r\n", gl.TokenTTL)
+		fmt.Printf("Refresh TTL : %s\r\n", gl.RefreshTTL)
 		return nil
 	},
 }
@@ -56,5 +78,7 @@ func init() {
 	updateGroupCmd.Flags().StringP("name", "n", "", "Name of the group")
 	updateGroupCmd.MarkFlagRequired("name")
 	updateGroupCmd.Flags().StringSliceP("labels", "l", []string{}, "Labels of the group, each label must be formatted as <lgn>:<Label> e.g. en:Group")
-	updateGroupCmd.MarkFlagRequired("labels")
+	updateGroupCmd.Flags().StringP("keyrotation", "k", "", "Period for the automatic rotation of the group key, e.g. 90d, empty for no rotation")
+	updateGroupCmd.Flags().String("tokenttl", "", "lifetime of the access tokens of the clients of the group, e.g. 1h, empty for the lifetime of the service")
+	updateGroupCmd.Flags().String("refreshttl", "", "lifetime of the refresh tokens of the clients of the group, e.g. 8h, empty for the lifetime of the service")
 }
@@ -0,0 +1,54 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/willie68/micro-vault/cmd/cli/cmd/cmdutils"
+)
+
+// updateKeyCmd represents the key command
+var updateKeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Update the lifecycle state of a group key",
+	Long: `Update the lifecycle state of a group key. 
+Possible states are enabled, disabled and scheduled-for-destruction.
+A key scheduled for destruction will be destroyed after the configured grace period.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adm, err := cmdutils.AdminClient()
+		if err != nil {
+			return err
+		}
+		id, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		s, err := cmd.Flags().GetString("state")
+		if err != nil {
+			return err
+		}
+		k, err := adm.SetKeyState(id, s)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("KID       : %s\r\n", k.ID)
+		fmt.Printf("Group     : %s\r\n", k.Group)
+		fmt.Printf("State     : %s\r\n", k.State)
+		if k.DestroyAt != nil {
+			fmt.Printf("Destroy at: %s\r\n", k.DestroyAt)
+		}
+		return nil
+	},
+}
+
+func init() {
+	updateCmd.AddCommand(updateKeyCmd)
+
+	updateKeyCmd.Flags().StringP("id", "i", "", "ID of the key")
+	updateKeyCmd.MarkFlagRequired("id")
+	updateKeyCmd.Flags().StringP("state", "s", "", "new state of the key: enabled, disabled or scheduled-for-destruction")
+	updateKeyCmd.MarkFlagRequired("state")
+}
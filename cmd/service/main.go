@@ -119,6 +119,7 @@ func main() {
 	<-c
 
 	sh.ShutdownServers()
+	services.ShutdownServices()
 	log.Root.Info("finished")
 
 	os.Exit(0)
@@ -0,0 +1,424 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/samber/do"
+	"github.com/willie68/micro-vault/internal/api"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/services/acme"
+	"github.com/willie68/micro-vault/internal/utils/httputils"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// maxACMERequest the max size of an acme request
+const maxACMERequest = 64 * 1024
+
+// ACME handler for the acme server (RFC 8555)
+type ACME struct {
+	am acme.ACME
+}
+
+// NewACMEHandler returning a new REST API Handler for the acme server
+func NewACMEHandler() api.Handler {
+	return &ACME{
+		am: do.MustInvoke[acme.ACME](nil),
+	}
+}
+
+// Routes getting all routes for the acme server
+func (a *ACME) Routes() (string, *chi.Mux) {
+	router := chi.NewRouter()
+	router.Use(a.replayNonce)
+	router.Post("/eab", a.PostEAB)
+	router.Get("/directory", a.GetDirectory)
+	router.Head("/new-nonce", a.NewNonce)
+	router.Get("/new-nonce", a.NewNonce)
+	router.Post("/new-account", a.PostNewAccount)
+	router.Post("/account/{id}", a.PostAccount)
+	router.Post("/account/{id}/orders", a.PostAccountOrders)
+	router.Post("/new-order", a.PostNewOrder)
+	router.Post("/order/{id}", a.PostOrder)
+	router.Post("/order/{id}/finalize", a.PostFinalize)
+	router.Post("/authz/{id}/{idx}", a.PostAuthorization)
+	router.Post("/chall/{id}/{idx}/{type}", a.PostChallenge)
+	router.Post("/cert/{id}", a.PostCertificate)
+	router.Post("/revoke-cert", a.PostRevokeCert)
+	return BaseURL + acmeSubpath, router
+}
+
+// replayNonce every response of the acme server carries a fresh nonce and the link to the directory
+func (a *ACME) replayNonce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		n, err := a.am.NewNonce()
+		if err != nil {
+			a.problem(response, err)
+			return
+		}
+		response.Header().Set("Replay-Nonce", n)
+		response.Header().Add("Link", `<`+a.am.URL("/directory")+`>;rel="index"`)
+		next.ServeHTTP(response, request)
+	})
+}
+
+// PostEAB creating external account binding credentials for the client of the token
+// @Summary creating external account binding credentials for the client of the token, used to create an acme account
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Success 201 {object} pmodel.ACMEEAB "the eab credentials"
+// @Failure 401 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /acme/eab [post]
+func (a *ACME) PostEAB(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	e, err := a.am.NewEAB(tk)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusUnauthorized))
+		return
+	}
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, pmodel.ACMEEAB{
+		KID:       e.KID,
+		Key:       e.Key,
+		Directory: a.am.URL("/directory"),
+	})
+}
+
+// GetDirectory returning the directory of the acme server
+// @Summary returning the directory of the acme server
+// @Tags configs
+// @Produce  json
+// @Success 200 {object} acme.Directory "the directory"
+// @Router /acme/directory [get]
+func (a *ACME) GetDirectory(response http.ResponseWriter, request *http.Request) {
+	a.write(response, http.StatusOK, a.am.Directory())
+}
+
+// NewNonce returning a new replay nonce, which is already set by the middleware
+// @Summary returning a new replay nonce in the header Replay-Nonce
+// @Tags configs
+// @Success 200 {object} nothing
+// @Router /acme/new-nonce [head]
+func (a *ACME) NewNonce(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Cache-Control", "no-store")
+	if request.Method == http.MethodGet {
+		response.WriteHeader(http.StatusNoContent)
+		return
+	}
+	response.WriteHeader(http.StatusOK)
+}
+
+// PostNewAccount creating a new account with an external account binding
+// @Summary creating a new account with an external account binding, or returning the existing account of the key
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Success 201 {object} acme.Account "the new account"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/new-account [post]
+func (a *ACME) PostNewAccount(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	acc, created, err := a.am.NewAccount(*req)
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	response.Header().Set("Location", a.am.AccountURL(acc.ID))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	a.write(response, status, a.am.AccountObject(*acc))
+}
+
+// PostAccount returning or updating the account
+// @Summary returning or updating the account, the account can be deactivated
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Param id path string true "id of the account"
+// @Success 200 {object} acme.Account "the account"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/account/{id} [post]
+func (a *ACME) PostAccount(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	acc, err := a.am.UpdateAccount(*req, chi.URLParam(request, "id"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	a.write(response, http.StatusOK, a.am.AccountObject(*acc))
+}
+
+// PostAccountOrders returning the orders of the account
+// @Summary returning the urls of the actual orders of the account
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Param id path string true "id of the account"
+// @Success 200 {object} acme.OrderList "the orders"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/account/{id}/orders [post]
+func (a *ACME) PostAccountOrders(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	ol, err := a.am.AccountOrders(*req, chi.URLParam(request, "id"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	a.write(response, http.StatusOK, ol)
+}
+
+// PostNewOrder creating a new order
+// @Summary creating a new order, only identifiers of the certificate template of the client are allowed
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Success 201 {object} acme.Order "the new order"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/new-order [post]
+func (a *ACME) PostNewOrder(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	o, err := a.am.NewOrder(*req)
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	response.Header().Set("Location", a.am.OrderURL(o.ID))
+	a.write(response, http.StatusCreated, a.am.OrderObject(*o))
+}
+
+// PostOrder returning the order
+// @Summary returning the order
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Param id path string true "id of the order"
+// @Success 200 {object} acme.Order "the order"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/order/{id} [post]
+func (a *ACME) PostOrder(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	o, err := a.am.Order(*req, chi.URLParam(request, "id"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	a.write(response, http.StatusOK, a.am.OrderObject(*o))
+}
+
+// PostFinalize finalizing the order with a certificate request
+// @Summary finalizing the order with a certificate request, the certificate is issued immediately
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Param id path string true "id of the order"
+// @Success 200 {object} acme.Order "the order"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/order/{id}/finalize [post]
+func (a *ACME) PostFinalize(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	o, err := a.am.Finalize(*req, chi.URLParam(request, "id"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	response.Header().Set("Location", a.am.OrderURL(o.ID))
+	a.write(response, http.StatusOK, a.am.OrderObject(*o))
+}
+
+// PostAuthorization returning the authorization of the order
+// @Summary returning the authorization of the order
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Param id path string true "id of the order"
+// @Param idx path int true "index of the authorization"
+// @Success 200 {object} acme.Authorization "the authorization"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/authz/{id}/{idx} [post]
+func (a *ACME) PostAuthorization(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	idx, err := strconv.Atoi(chi.URLParam(request, "idx"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	o, err := a.am.Authorization(*req, chi.URLParam(request, "id"), idx)
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	a.write(response, http.StatusOK, a.am.AuthzObject(*o, idx))
+}
+
+// PostChallenge returning the challenge or starting the validation of the challenge
+// @Summary returning the challenge, with a non empty payload the validation of the challenge is started
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  json
+// @Param id path string true "id of the order"
+// @Param idx path int true "index of the authorization"
+// @Param type path string true "type of the challenge"
+// @Success 200 {object} acme.Challenge "the challenge"
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/chall/{id}/{idx}/{type} [post]
+func (a *ACME) PostChallenge(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	idx, err := strconv.Atoi(chi.URLParam(request, "idx"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	o, ch, err := a.am.Challenge(*req, chi.URLParam(request, "id"), idx, chi.URLParam(request, "type"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	response.Header().Add("Link", `<`+a.am.AuthzURL(o.ID, idx)+`>;rel="up"`)
+	a.write(response, http.StatusOK, a.am.ChallengeObject(*o, idx, *ch))
+}
+
+// PostCertificate returning the issued certificate chain of the order
+// @Summary returning the issued certificate of the order, together with the ca certificate
+// @Tags configs
+// @Accept  application/jose+json
+// @Produce  application/pem-certificate-chain
+// @Param id path string true "id of the order"
+// @Success 200 {object} nothing
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/cert/{id} [post]
+func (a *ACME) PostCertificate(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	crt, err := a.am.Certificate(*req, chi.URLParam(request, "id"))
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	response.Header().Set("Content-Type", "application/pem-certificate-chain")
+	response.WriteHeader(http.StatusOK)
+	_, err = response.Write([]byte(crt))
+	if err != nil {
+		logger.Errorf("error writing certificate: %v", err)
+	}
+}
+
+// PostRevokeCert revoking a certificate of the client of the account
+// @Summary revoking a certificate of the client of the account
+// @Tags configs
+// @Accept  application/jose+json
+// @Success 200 {object} nothing
+// @Failure 400 {object} acme.Problem "acme problem document"
+// @Router /acme/revoke-cert [post]
+func (a *ACME) PostRevokeCert(response http.ResponseWriter, request *http.Request) {
+	req, ok := a.verify(response, request)
+	if !ok {
+		return
+	}
+	err := a.am.RevokeCert(*req)
+	if err != nil {
+		a.problem(response, err)
+		return
+	}
+	response.WriteHeader(http.StatusOK)
+}
+
+// verify reading and verifying the jws of the request, writing a problem if not valid
+func (a *ACME) verify(response http.ResponseWriter, request *http.Request) (*acme.Request, bool) {
+	mt, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || mt != "application/jose+json" {
+		a.problem(response, &acme.Problem{
+			Type:   acme.ErrMalformed,
+			Detail: "content type must be application/jose+json",
+			Status: http.StatusUnsupportedMediaType,
+		})
+		return nil, false
+	}
+	b, err := io.ReadAll(io.LimitReader(request.Body, maxACMERequest+1))
+	if err != nil {
+		a.problem(response, err)
+		return nil, false
+	}
+	if len(b) > maxACMERequest {
+		a.problem(response, &acme.Problem{
+			Type:   acme.ErrMalformed,
+			Detail: "acme request too large",
+			Status: http.StatusRequestEntityTooLarge,
+		})
+		return nil, false
+	}
+	req, err := a.am.Verify(b, strings.TrimPrefix(request.URL.Path, BaseURL+acmeSubpath))
+	if err != nil {
+		a.problem(response, err)
+		return nil, false
+	}
+	return req, true
+}
+
+func (a *ACME) write(response http.ResponseWriter, status int, v any) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	err := json.NewEncoder(response).Encode(v)
+	if err != nil {
+		logger.Errorf("error writing acme response: %v", err)
+	}
+}
+
+// problem writing the error as acme problem document
+func (a *ACME) problem(response http.ResponseWriter, err error) {
+	var p *acme.Problem
+	if !errors.As(err, &p) {
+		logger.Errorf("acme error: %v", err)
+		p = &acme.Problem{
+			Type:   acme.ErrServerInternal,
+			Detail: err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+	response.Header().Set("Content-Type", "application/problem+json")
+	response.WriteHeader(p.Status)
+	err = json.NewEncoder(response).Encode(p)
+	if err != nil {
+		logger.Errorf("error writing acme problem: %v", err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/cloudflare/cfssl/certinfo"
 	"github.com/go-chi/chi/v5"
@@ -18,6 +20,7 @@ import (
 	"github.com/willie68/micro-vault/internal/services/admin"
 	"github.com/willie68/micro-vault/internal/services/clients"
 	"github.com/willie68/micro-vault/internal/utils/httputils"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
 	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
@@ -53,8 +56,26 @@ func (a *AdminHandler) Routes() (string, *chi.Mux) {
 	router.Delete(rtClientName, a.DeleteClient)
 	router.Post(rtClientName, a.PostClient)
 	router.Get(rtClientName, a.GetClient)
+	router.Post(rtClientName+"/key/rotate", a.PostRotateClientKey)
+	router.Post(rtClientName+"/state", a.PostClientState)
+	router.Post(rtClientName+"/tokenttl", a.PostClientTokenTTL)
+	router.Post(rtClientName+"/tokens/revoke", a.PostRevokeClientTokens)
+	router.Get(rtClientName+"/secrets", a.GetClientSecrets)
+	router.Post(rtClientName+"/secrets/rotate", a.PostRotateClientSecret)
+	router.Delete(rtClientName+"/secrets/{id}", a.DeleteClientSecret)
 	router.Get("/groupkeys", a.GetKeys)
 	router.Post("/groupkeys", a.PostKey)
+	router.Post("/groupkeys/{group}/rotate", a.PostRotateKey)
+	router.Post("/groupkeys/{id}/state", a.PostKeyState)
+	router.Delete("/groupkeys/{id}", a.DeleteKey)
+	router.Get("/certificates", a.GetCertificates)
+	router.Get("/certificates/{serial}", a.GetCertificate)
+	router.Post("/certificates/{serial}/revoke", a.PostRevokeCertificate)
+	rtProfiles := "/profiles"
+	router.Get(rtProfiles, a.GetProfiles)
+	router.Post(rtProfiles, a.PostProfile)
+	router.Get(rtProfiles+"/{name}", a.GetProfile)
+	router.Delete(rtProfiles+"/{name}", a.DeleteProfile)
 	router.Post("/utils/decodecert", a.PostDecodeCertificate)
 	router.Get("/info", a.GetInfo)
 	return BaseURL + adminSubpath, router
@@ -125,9 +146,12 @@ func (a *AdminHandler) GetGroups(response http.ResponseWriter, request *http.Req
 	ngs := make([]model.Group, 0)
 	for _, g := range gs {
 		ng := model.Group{
-			Name:     g.Name,
-			Label:    g.Label,
-			IsClient: g.IsClient,
+			Name:        g.Name,
+			Label:       g.Label,
+			IsClient:    g.IsClient,
+			KeyRotation: g.KeyRotation,
+			TokenTTL:    g.TokenTTL,
+			RefreshTTL:  g.RefreshTTL,
 		}
 		ngs = append(ngs, ng)
 	}
@@ -170,9 +194,12 @@ func (a *AdminHandler) GetGroup(response http.ResponseWriter, request *http.Requ
 		return
 	}
 	gs := pmodel.Group{
-		Name:     g.Name,
-		Label:    g.Label,
-		IsClient: g.IsClient,
+		Name:        g.Name,
+		Label:       g.Label,
+		IsClient:    g.IsClient,
+		KeyRotation: g.KeyRotation,
+		TokenTTL:    g.TokenTTL,
+		RefreshTTL:  g.RefreshTTL,
 	}
 	render.Status(request, http.StatusOK)
 	render.JSON(response, request, gs)
@@ -214,8 +241,11 @@ func (a *AdminHandler) PostGroup(response http.ResponseWriter, request *http.Req
 		return
 	}
 	g := model.Group{
-		Name:  du.Name,
-		Label: du.Label,
+		Name:        du.Name,
+		Label:       du.Label,
+		KeyRotation: du.KeyRotation,
+		TokenTTL:    du.TokenTTL,
+		RefreshTTL:  du.RefreshTTL,
 	}
 	n, err = a.adm.UpdateGroup(tk, g)
 	if err != nil {
@@ -228,9 +258,12 @@ func (a *AdminHandler) PostGroup(response http.ResponseWriter, request *http.Req
 		return
 	}
 	gs := pmodel.Group{
-		Name:     g.Name,
-		Label:    g.Label,
-		IsClient: g.IsClient,
+		Name:        g.Name,
+		Label:       g.Label,
+		IsClient:    g.IsClient,
+		KeyRotation: g.KeyRotation,
+		TokenTTL:    g.TokenTTL,
+		RefreshTTL:  g.RefreshTTL,
 	}
 	render.Status(request, http.StatusOK)
 	render.JSON(response, request, gs)
@@ -268,9 +301,12 @@ func (a *AdminHandler) PostGroups(response http.ResponseWriter, request *http.Re
 		return
 	}
 	g := model.Group{
-		Name:     pg.Name,
-		Label:    pg.Label,
-		IsClient: false,
+		Name:        pg.Name,
+		Label:       pg.Label,
+		IsClient:    false,
+		KeyRotation: pg.KeyRotation,
+		TokenTTL:    pg.TokenTTL,
+		RefreshTTL:  pg.RefreshTTL,
 	}
 	n, err := a.adm.AddGroup(tk, g)
 	if err != nil {
@@ -279,9 +315,12 @@ func (a *AdminHandler) PostGroups(response http.ResponseWriter, request *http.Re
 	}
 	g, err = a.adm.Group(tk, n)
 	gs := pmodel.Group{
-		Name:     g.Name,
-		Label:    g.Label,
-		IsClient: g.IsClient,
+		Name:        g.Name,
+		Label:       g.Label,
+		IsClient:    g.IsClient,
+		KeyRotation: g.KeyRotation,
+		TokenTTL:    g.TokenTTL,
+		RefreshTTL:  g.RefreshTTL,
 	}
 	render.Status(request, http.StatusCreated)
 	render.JSON(response, request, gs)
@@ -399,17 +438,135 @@ func (a *AdminHandler) GetClient(response http.ResponseWriter, request *http.Req
 		return
 	}
 	cs := pmodel.Client{
-		Name:      c.Name,
-		AccessKey: c.AccessKey,
-		Secret:    "",
-		Groups:    c.Groups,
-		KID:       c.KID,
-		Key:       c.Key,
+		Name:       c.Name,
+		AccessKey:  c.AccessKey,
+		Secret:     "",
+		Groups:     c.Groups,
+		KID:        c.KID,
+		Key:        c.Key,
+		KeyType:    c.KeyType,
+		Disabled:   c.Disabled,
+		TokenTTL:   c.TokenTTL,
+		RefreshTTL: c.RefreshTTL,
+	}
+	if !c.NotBefore.IsZero() {
+		nb := c.NotBefore
+		cs.NotBefore = &nb
+	}
+	if !c.ExpiresAt.IsZero() {
+		ea := c.ExpiresAt
+		cs.ExpiresAt = &ea
 	}
 	render.Status(request, http.StatusOK)
 	render.JSON(response, request, cs)
 }
 
+// PostClientState enabling or disabling a client and setting its validity period
+// @Summary enabling or disabling a client and setting its validity period, a disabled client can't login or use its tokens
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Param payload body pmodel.ClientState true "the new state of the client, e.g. {"disabled": true}"
+// @Success 200 {object} pmodel.Client "the changed client"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/state [post]
+func (a *AdminHandler) PostClientState(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var st pmodel.ClientState
+	err = json.NewDecoder(request.Body).Decode(&st)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	n := chi.URLParam(request, "name")
+	cl, err := a.adm.SetClientState(tk, n, st)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, cl)
+}
+
+// PostClientTokenTTL setting the token lifetimes of a client
+// @Summary setting the token lifetimes of a client, empty values are using the lifetimes of the groups or the service
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Param payload body pmodel.TokenTTL true "the token lifetimes of the client, e.g. {"tokenttl": "1h", "refreshttl": "8h"}"
+// @Success 200 {object} pmodel.Client "the changed client"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/tokenttl [post]
+func (a *AdminHandler) PostClientTokenTTL(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var tt pmodel.TokenTTL
+	err = json.NewDecoder(request.Body).Decode(&tt)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	n := chi.URLParam(request, "name")
+	cl, err := a.adm.SetClientTokenTTL(tk, n, tt)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, cl)
+}
+
+// PostRevokeClientTokens revoking all tokens of a client
+// @Summary revoking all access and refresh tokens of a client issued until before, without before all tokens issued until now
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Param payload body pmodel.TokenRevocation false "optional time until the tokens are revoked, e.g. {"before": "2024-01-01T00:00:00Z"}"
+// @Success 200 {object} pmodel.TokenRevocation "the time until the tokens are revoked"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/tokens/revoke [post]
+func (a *AdminHandler) PostRevokeClientTokens(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var tr pmodel.TokenRevocation
+	err = json.NewDecoder(request.Body).Decode(&tr)
+	if err != nil && !errors.Is(err, io.EOF) {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	before := time.Now()
+	if tr.Before != nil {
+		before = *tr.Before
+	}
+	n := chi.URLParam(request, "name")
+	err = a.adm.RevokeClientTokens(tk, n, before)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, pmodel.TokenRevocation{Before: &before})
+}
+
 // PostNewClient creating a new client
 // @Summary creating a new client
 // @Tags configs
@@ -435,8 +592,9 @@ func (a *AdminHandler) PostNewClient(response http.ResponseWriter, request *http
 		return
 	}
 	du := struct {
-		Name   string   `json:"name"`
-		Groups []string `json:"groups"`
+		Name    string   `json:"name"`
+		Groups  []string `json:"groups"`
+		KeyType string   `json:"keytype"`
 	}{}
 
 	err = json.Unmarshal(b, &du)
@@ -444,7 +602,7 @@ func (a *AdminHandler) PostNewClient(response http.ResponseWriter, request *http
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
 	}
-	cl, err := a.adm.NewClient(tk, du.Name, du.Groups)
+	cl, err := a.adm.NewClientWithKeyType(tk, du.Name, du.Groups, du.KeyType)
 	if err != nil {
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
@@ -454,6 +612,8 @@ func (a *AdminHandler) PostNewClient(response http.ResponseWriter, request *http
 		AccessKey: cl.AccessKey,
 		Secret:    cl.Secret,
 		Groups:    cl.Groups,
+		KID:       cl.KID,
+		KeyType:   cl.KeyType,
 	}
 	render.Status(request, http.StatusCreated)
 	render.JSON(response, request, ccl)
@@ -546,6 +706,143 @@ func (a *AdminHandler) PostClient(response http.ResponseWriter, request *http.Re
 	render.JSON(response, request, ccl)
 }
 
+// PostRotateClientKey rotating the key of a client, the previous key is still valid for the grace period
+// @Summary rotating the key of a client, the previous key is still valid for the grace period
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Param payload body string false "json with the key type of the new key, e.g. {"keytype": "ECDSA-P256"}, default is the key type of the client"
+// @Success 201 {object} pmodel.Client "the client with the kid of the new key"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/key/rotate [post]
+func (a *AdminHandler) PostRotateClientKey(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	b, err := io.ReadAll(request.Body)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	du := struct {
+		KeyType string `json:"keytype"`
+	}{}
+	if len(b) > 0 {
+		err = json.Unmarshal(b, &du)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+			return
+		}
+	}
+	n := chi.URLParam(request, "name")
+	cl, err := a.adm.RotateClientKey(tk, n, du.KeyType)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ccl := pmodel.Client{
+		Name:      cl.Name,
+		AccessKey: cl.AccessKey,
+		Secret:    "*****",
+		Groups:    cl.Groups,
+		KID:       cl.KID,
+		KeyType:   cl.KeyType,
+	}
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, ccl)
+}
+
+// GetClientSecrets listing the active secrets of a client
+// @Summary listing the active secrets of a client, without the secrets itself
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Success 200 {array} pmodel.ClientSecret "the active secrets of the client"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/secrets [get]
+func (a *AdminHandler) GetClientSecrets(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	n := chi.URLParam(request, "name")
+	ss, err := a.adm.ClientSecrets(tk, n)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, ss)
+}
+
+// PostRotateClientSecret issuing a new secret for a client, the old secrets are still valid
+// @Summary issuing a new secret for a client, the old secrets are still valid until revoked
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Param payload body string false "json with the optional validity of the new secret, e.g. {"expires": "90d"}"
+// @Success 201 {object} pmodel.ClientSecret "the new secret, only given once"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/secrets/rotate [post]
+func (a *AdminHandler) PostRotateClientSecret(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	exp, err := secretExpires(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	n := chi.URLParam(request, "name")
+	s, err := a.adm.RotateClientSecret(tk, n, exp)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, s)
+}
+
+// DeleteClientSecret revoking a secret of a client
+// @Summary revoking a secret of a client, the last active secret can't be revoked
+// @Tags configs
+// @Param token as authentication header
+// @Param name path string true "name of the client"
+// @Param id path string true "id of the secret, primary for the secret given at the creation of the client"
+// @Success 200 {object} nothing
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/clients/{name}/secrets/{id} [delete]
+func (a *AdminHandler) DeleteClientSecret(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	n := chi.URLParam(request, "name")
+	id := chi.URLParam(request, "id")
+	err = a.adm.RevokeClientSecret(tk, n, id)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+}
+
 // GetKeys getting a list of groupkeys
 // @Summary getting a list of groupkeys
 // @Tags configs
@@ -577,13 +874,7 @@ func (a *AdminHandler) GetKeys(response http.ResponseWriter, request *http.Reque
 	}
 	cls := make([]pmodel.EncryptKeyInfo, 0)
 	for _, c := range cs {
-		cls = append(cls, pmodel.EncryptKeyInfo{
-			Alg:     c.Alg,
-			ID:      c.ID,
-			Group:   c.Group,
-			Key:     c.Key,
-			Created: c.Created,
-		})
+		cls = append(cls, keyInfo(c))
 	}
 	render.Status(request, http.StatusOK)
 	render.JSON(response, request, cls)
@@ -615,6 +906,7 @@ func (a *AdminHandler) PostKey(response http.ResponseWriter, request *http.Reque
 	}
 	pg := struct {
 		Group string `json:"group"`
+		Alg   string `json:"alg"`
 	}{}
 
 	err = json.Unmarshal(b, &pg)
@@ -622,20 +914,376 @@ func (a *AdminHandler) PostKey(response http.ResponseWriter, request *http.Reque
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
 	}
-	c, err := a.adm.CreateGroupKey(tk, pg.Group)
+	c, err := a.adm.CreateGroupKey(tk, pg.Group, pg.Alg)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	cl := keyInfo(*c)
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, cl)
+}
+
+// PostRotateKey rotating the group key, creating the next key version
+// @Summary rotating the group key, creating the next key version
+// @Tags configs
+// @Accept  n.n.
+// @Produce  json
+// @Param token as authentication header
+// @Param group path string true "name of the group"
+// @Success 201 {object} pmodel.EncryptKeyInfo "the new current group key"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/groupkeys/{group}/rotate [post]
+func (a *AdminHandler) PostRotateKey(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	g := chi.URLParam(request, "group")
+	c, err := a.adm.RotateGroupKey(tk, g)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	cl := keyInfo(*c)
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, cl)
+}
+
+// PostKeyState changing the lifecycle state of a key
+// @Summary changing the lifecycle state of a key, enabled, disabled or scheduled-for-destruction
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param id path string true "id of the key"
+// @Param payload body string true "json with the new state, e.g. {"state": "disabled"}"
+// @Success 200 {object} pmodel.EncryptKeyInfo "the changed key"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/groupkeys/{id}/state [post]
+func (a *AdminHandler) PostKeyState(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	id := chi.URLParam(request, "id")
+	b, err := io.ReadAll(request.Body)
 	if err != nil {
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
 	}
-	cl := pmodel.EncryptKeyInfo{
+	ps := struct {
+		State string `json:"state"`
+	}{}
+	err = json.Unmarshal(b, &ps)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	c, err := a.adm.SetKeyState(tk, id, ps.State)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, keyInfo(*c))
+}
+
+// DeleteKey schedules a key for destruction
+// @Summary schedules a key for destruction, the key will be destroyed after the configured grace period
+// @Tags configs
+// @Accept  n.n.
+// @Produce  json
+// @Param token as authentication header
+// @Param id path string true "id of the key"
+// @Success 200 {object} pmodel.EncryptKeyInfo "the scheduled key"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/groupkeys/{id} [delete]
+func (a *AdminHandler) DeleteKey(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	id := chi.URLParam(request, "id")
+	c, err := a.adm.SetKeyState(tk, id, model.KeyStateScheduled)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, keyInfo(*c))
+}
+
+// keyInfo converts a key into the public key info
+func keyInfo(c model.EncryptKey) pmodel.EncryptKeyInfo {
+	ki := pmodel.EncryptKeyInfo{
 		Alg:     c.Alg,
 		ID:      c.ID,
 		Group:   c.Group,
 		Key:     c.Key,
 		Created: c.Created,
+		Version: c.Version,
+		State:   c.KeyState(),
+	}
+	if !c.DestroyAt.IsZero() {
+		da := c.DestroyAt
+		ki.DestroyAt = &da
+	}
+	return ki
+}
+
+// PostRevokeCertificate revoking an issued certificate
+// @Summary revoking an issued certificate, the certificate will be part of the crl
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param serial path string true "serial number of the certificate as hex"
+// @Param payload body pmodel.Revocation true "json with the reason, e.g. {"reason": "keyCompromise"}"
+// @Success 200 {object} pmodel.CertificateInfo "the revoked certificate"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "certificate not found"
+// @Failure 409 {object} serror.Serr "certificate already revoked"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/certificates/{serial}/revoke [post]
+func (a *AdminHandler) PostRevokeCertificate(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	sn := chi.URLParam(request, "serial")
+	var rv pmodel.Revocation
+	if request.ContentLength != 0 {
+		err = json.NewDecoder(request.Body).Decode(&rv)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+			return
+		}
+	}
+	c, err := a.adm.RevokeCertificate(tk, sn, rv.Reason)
+	if err != nil {
+		if errors.Is(err, serror.ErrCertRevoked) {
+			httputils.Err(response, request, serror.Conflict(err))
+			return
+		}
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, certInfo(*c))
+}
+
+// GetCertificates getting the inventory of the issued certificates
+// @Summary getting the issued certificates sorted by the expiry, optional filtered
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Param client query string false "only certificates of this client"
+// @Param expiring query string false "only valid certificates expiring within this duration, e.g. 30d"
+// @Param revoked query bool false "true only revoked, false only not revoked certificates"
+// @Success 200 {array} pmodel.CertificateInfo "the certificates"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/certificates [get]
+func (a *AdminHandler) GetCertificates(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	q := request.URL.Query()
+	f := model.CertificateFilter{
+		Client: q.Get("client"),
+	}
+	if e := q.Get("expiring"); e != "" {
+		f.ExpiringWithin, err = str2duration.ParseDuration(e)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+			return
+		}
+	}
+	if r := q.Get("revoked"); r != "" {
+		rv, err := strconv.ParseBool(r)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+			return
+		}
+		f.Revoked = &rv
+	}
+	crs, err := a.adm.Certificates(tk, f)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	cis := make([]pmodel.CertificateInfo, 0)
+	for _, c := range crs {
+		cis = append(cis, certInfo(c))
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, cis)
+}
+
+// GetCertificate getting an issued certificate
+// @Summary getting an issued certificate with the pem
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Param serial path string true "serial number of the certificate as hex"
+// @Success 200 {object} pmodel.CertificateInfo "the certificate"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "certificate not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/certificates/{serial} [get]
+func (a *AdminHandler) GetCertificate(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	c, err := a.adm.Certificate(tk, chi.URLParam(request, "serial"))
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ci := certInfo(*c)
+	ci.PEM = c.Certificate
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, ci)
+}
+
+func certInfo(c model.Certificate) pmodel.CertificateInfo {
+	ci := pmodel.CertificateInfo{
+		Serial:    c.Serial,
+		Subject:   c.Subject,
+		Client:    c.Client,
+		Profile:   c.Profile,
+		NotBefore: c.NotBefore,
+		NotAfter:  c.NotAfter,
+	}
+	if c.IsRevoked() {
+		rv := c.Revoked
+		ci.Revoked = &rv
+		ci.Reason = model.ReasonName(c.Reason)
+	}
+	return ci
+}
+
+// GetProfiles getting all certificate profiles
+// @Summary getting all certificate profiles
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Success 200 {array} model.CertProfile "the certificate profiles"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/profiles [get]
+func (a *AdminHandler) GetProfiles(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ps, err := a.adm.Profiles(tk)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, ps)
+}
+
+// GetProfile getting the certificate profile
+// @Summary getting the certificate profile with the name
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Param name path string true "name of the profile"
+// @Success 200 {object} model.CertProfile "the certificate profile"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "profile not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/profiles/{name} [get]
+func (a *AdminHandler) GetProfile(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	p, err := a.adm.Profile(tk, chi.URLParam(request, "name"))
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, p)
+}
+
+// PostProfile creating or updating a certificate profile
+// @Summary creating or updating a certificate profile
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body model.CertProfile true "the certificate profile"
+// @Success 201 {object} model.CertProfile "the stored certificate profile"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/profiles [post]
+func (a *AdminHandler) PostProfile(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var p model.CertProfile
+	err = json.NewDecoder(request.Body).Decode(&p)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	err = a.adm.StoreProfile(tk, p)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
 	}
 	render.Status(request, http.StatusCreated)
-	render.JSON(response, request, cl)
+	render.JSON(response, request, p)
+}
+
+// DeleteProfile deleting a certificate profile
+// @Summary deleting the certificate profile with the name
+// @Tags configs
+// @Param token as authentication header
+// @Param name path string true "name of the profile"
+// @Success 200 {object} nothing
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "profile not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /admin/profiles/{name} [delete]
+func (a *AdminHandler) DeleteProfile(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	n := chi.URLParam(request, "name")
+	ok, err := a.adm.DeleteProfile(tk, n)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	if !ok {
+		httputils.Err(response, request, serror.NotFound("profile", n))
+		return
+	}
+	render.Status(request, http.StatusOK)
 }
 
 // PostDecodeCertificate decoding a certificate
@@ -36,6 +36,7 @@ const adminSubpath = "/admin"
 const loginSubpath = "/login"
 const jwksSubpath = "/.well-known"
 const caSubpath = "/ca"
+const acmeSubpath = "/acme"
 
 func token(r *http.Request) (string, error) {
 	tk := r.Header.Get("Authorization")
@@ -64,6 +65,8 @@ func APIRoutes(cfn config.Config, trc opentracing.Tracer) (*chi.Mux, error) {
 		r.Mount(NewAdminHandler().Routes())
 		r.Mount(NewJWKSHandler().Routes())
 		r.Mount(NewCACertHandler().Routes())
+		r.Mount(NewCAStatusHandler().Routes())
+		r.Mount(NewACMEHandler().Routes())
 		r.Mount(health.NewHealthHandler().Routes())
 		if cfn.Metrics.Enable {
 			r.Mount("/metrics", promhttp.Handler())
@@ -89,7 +92,7 @@ func setJWTHandler(router *chi.Mux, cfn config.Config) error {
 	if err != nil {
 		return err
 	}
-	jwtConfig.IgnorePages = append(jwtConfig.IgnorePages, "/api/v1/login", "/client", caSubpath, jwksSubpath)
+	jwtConfig.IgnorePages = append(jwtConfig.IgnorePages, "/api/v1/login", "/client", caSubpath, BaseURL+caSubpath, BaseURL+acmeSubpath, jwksSubpath)
 	logger.Infof("jwt config: %v", jwtConfig)
 	jwtAuth := auth.InitJWT(jwtConfig)
 	router.Use(
@@ -110,7 +113,7 @@ func setDefaultHandler(router *chi.Mux, cfn config.Config, tracer opentracing.Tr
 			// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
 			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-mcs-username", "X-mcs-password", "X-mcs-profile"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", "X-Key-Id"},
 			AllowCredentials: true,
 			MaxAge:           300, // Maximum value not ignored by any of major browsers
 		}),
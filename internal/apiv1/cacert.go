@@ -1,12 +1,19 @@
 package apiv1
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/samber/do"
 	"github.com/willie68/micro-vault/internal/api"
 	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/services/clients"
 	"github.com/willie68/micro-vault/internal/services/keyman"
 	"github.com/willie68/micro-vault/internal/utils/httputils"
 )
@@ -30,9 +37,9 @@ func (c *CACert) Routes() (string, *chi.Mux) {
 	return caSubpath, router
 }
 
-// GetCACert returning all possible public certificates of this service
+// GetCACert returning the trust bundle of the ca, the root certificate and after a rollover the previous roots
 func (c *CACert) GetCACert(response http.ResponseWriter, request *http.Request) {
-	crt, err := c.cas.X509CertPEM()
+	crt, err := c.cas.TrustBundlePEM()
 	if err != nil {
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusInternalServerError))
 		return
@@ -45,3 +52,183 @@ func (c *CACert) GetCACert(response http.ResponseWriter, request *http.Request)
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusInternalServerError))
 	}
 }
+
+// maxOCSPRequest the max size of an ocsp request
+const maxOCSPRequest = 10 * 1024
+
+// CAStatus handler for the public certificate status endpoints, crl and ocsp
+type CAStatus struct {
+	cl  clients.Clients
+	cas keyman.CAService
+}
+
+// NewCAStatusHandler returning a new REST API Handler for the crl and the ocsp responder
+func NewCAStatusHandler() api.Handler {
+	return &CAStatus{
+		cl:  do.MustInvoke[clients.Clients](nil),
+		cas: do.MustInvoke[keyman.CAService](nil),
+	}
+}
+
+// Routes getting all routes for the crl and ocsp endpoints
+func (c *CAStatus) Routes() (string, *chi.Mux) {
+	router := chi.NewRouter()
+	router.Get("/cert", c.GetChain)
+	router.Get("/intermediates", c.GetIntermediates)
+	router.Get("/crl", c.GetCRL)
+	router.Get("/crl/{id}", c.GetIssuerCRL)
+	router.Post("/ocsp", c.PostOCSP)
+	router.Get("/ocsp/*", c.GetOCSP)
+	return BaseURL + caSubpath, router
+}
+
+// GetChain returning the certificate chain of the issuing ca up to the root
+// @Summary returning the certificate chain of the issuing ca up to the root as pem
+// @Tags configs
+// @Produce  application/x-pem-file
+// @Success 200 {object} nothing
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /ca/cert [get]
+func (c *CAStatus) GetChain(response http.ResponseWriter, request *http.Request) {
+	crt, err := c.cas.ChainPEM()
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusInternalServerError))
+		return
+	}
+	writePEM(response, "chain.pem", crt)
+}
+
+// GetIntermediates returning all trusted intermediate certificates of the root
+// @Summary returning all trusted intermediate certificates as pem, the issuing one first, empty without an offline root
+// @Tags configs
+// @Produce  application/x-pem-file
+// @Success 200 {object} nothing
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /ca/intermediates [get]
+func (c *CAStatus) GetIntermediates(response http.ResponseWriter, request *http.Request) {
+	crt, err := c.cas.IntermediatesPEM()
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusInternalServerError))
+		return
+	}
+	writePEM(response, "intermediates.pem", crt)
+}
+
+func writePEM(response http.ResponseWriter, name, p string) {
+	response.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	response.Header().Set("Content-Type", "application/x-pem-file")
+	response.WriteHeader(http.StatusOK)
+	_, err := response.Write([]byte(p))
+	if err != nil {
+		logger.Errorf("error writing %s: %v", name, err)
+	}
+}
+
+// GetCRL returning the actual certificate revocation list of the ca
+// @Summary returning the actual certificate revocation list of the ca, default as der, with format=pem as pem
+// @Tags configs
+// @Produce  application/pkix-crl
+// @Param format query string false "der or pem"
+// @Success 200 {object} nothing
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /ca/crl [get]
+func (c *CAStatus) GetCRL(response http.ResponseWriter, request *http.Request) {
+	c.crl(response, request, c.cas.IssuerIDs()[0])
+}
+
+// GetIssuerCRL returning the actual certificate revocation list of the actual or a previous ca certificate
+// @Summary returning the crl of the ca certificate with the issuer id (hex subject key id), default as der, with format=pem as pem
+// @Tags configs
+// @Produce  application/pkix-crl
+// @Param id path string true "issuer id"
+// @Param format query string false "der or pem"
+// @Success 200 {object} nothing
+// @Failure 404 {object} serror.Serr "unknown issuer"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /ca/crl/{id} [get]
+func (c *CAStatus) GetIssuerCRL(response http.ResponseWriter, request *http.Request) {
+	c.crl(response, request, chi.URLParam(request, "id"))
+}
+
+func (c *CAStatus) crl(response http.ResponseWriter, request *http.Request, id string) {
+	var b []byte
+	if strings.EqualFold(request.URL.Query().Get("format"), "pem") {
+		crl, err := c.cl.IssuerCRLPEM(id)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusInternalServerError))
+			return
+		}
+		b = []byte(crl)
+		response.Header().Add("Content-Disposition", `attachment; filename="crl.pem"`)
+		response.Header().Set("Content-Type", "application/x-pem-file")
+	} else {
+		crl, err := c.cl.IssuerCRL(id)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusInternalServerError))
+			return
+		}
+		b = crl
+		response.Header().Add("Content-Disposition", `attachment; filename="crl.crl"`)
+		response.Header().Set("Content-Type", "application/pkix-crl")
+	}
+	response.WriteHeader(http.StatusOK)
+	_, err := response.Write(b)
+	if err != nil {
+		logger.Errorf("error writing crl: %v", err)
+	}
+}
+
+// PostOCSP answering an ocsp request (RFC 6960)
+// @Summary answering an ocsp request, posted as application/ocsp-request
+// @Tags configs
+// @Accept  application/ocsp-request
+// @Produce  application/ocsp-response
+// @Success 200 {object} nothing
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Router /ca/ocsp [post]
+func (c *CAStatus) PostOCSP(response http.ResponseWriter, request *http.Request) {
+	b, err := io.ReadAll(io.LimitReader(request.Body, maxOCSPRequest+1))
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	if len(b) > maxOCSPRequest {
+		httputils.Err(response, request, serror.Wrapc(errors.New("ocsp request too large"), http.StatusRequestEntityTooLarge))
+		return
+	}
+	c.ocsp(response, b)
+}
+
+// GetOCSP answering an ocsp request (RFC 6960), given base64 encoded in the path
+// @Summary answering an ocsp request, the url encoded base64 of the der request is part of the path
+// @Tags configs
+// @Produce  application/ocsp-response
+// @Success 200 {object} nothing
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Router /ca/ocsp/{request} [get]
+func (c *CAStatus) GetOCSP(response http.ResponseWriter, request *http.Request) {
+	p, err := url.PathUnescape(chi.URLParam(request, "*"))
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	c.ocsp(response, b)
+}
+
+func (c *CAStatus) ocsp(response http.ResponseWriter, der []byte) {
+	b, err := c.cl.OCSP(der)
+	if err != nil {
+		logger.Errorf("error answering ocsp request: %v", err)
+	}
+	response.Header().Set("Content-Type", "application/ocsp-response")
+	response.WriteHeader(http.StatusOK)
+	_, err = response.Write(b)
+	if err != nil {
+		logger.Errorf("error writing ocsp response: %v", err)
+	}
+}
@@ -2,23 +2,28 @@ package apiv1
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/samber/do"
 	"github.com/willie68/micro-vault/internal/api"
+	"github.com/willie68/micro-vault/internal/config"
 	"github.com/willie68/micro-vault/internal/services/keyman"
+	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
-// JWKSHandler handler for handling REST calls for jwks
+// JWKSHandler handler for handling REST calls for jwks and the authorization server metadata
 type JWKSHandler struct {
 	kmn keyman.Keyman
+	cfg config.Config
 }
 
 // NewJWKSHandler returning a new REST API Handler for jwks
 func NewJWKSHandler() api.Handler {
 	return &JWKSHandler{
 		kmn: do.MustInvoke[keyman.Keyman](nil),
+		cfg: do.MustInvoke[config.Config](nil),
 	}
 }
 
@@ -26,6 +31,7 @@ func NewJWKSHandler() api.Handler {
 func (j *JWKSHandler) Routes() (string, *chi.Mux) {
 	router := chi.NewRouter()
 	router.Get("/jwks.json", j.GetJWKS)
+	router.Get("/oauth-authorization-server", j.GetAuthServerMetadata)
 	return jwksSubpath, router
 }
 
@@ -34,3 +40,28 @@ func (j *JWKSHandler) GetJWKS(response http.ResponseWriter, request *http.Reques
 	render.Status(request, http.StatusOK)
 	render.JSON(response, request, j.kmn.JWKS())
 }
+
+// GetAuthServerMetadata returning the OAuth 2.0 authorization server metadata (RFC 8414) of this service
+// @Summary returning the OAuth 2.0 authorization server metadata of this service
+// @Tags configs
+// @Produce  json
+// @Success 200 {object} pmodel.AuthServerMetadata "the metadata"
+// @Router /.well-known/oauth-authorization-server [get]
+func (j *JWKSHandler) GetAuthServerMetadata(response http.ResponseWriter, request *http.Request) {
+	su := strings.TrimSuffix(j.cfg.Service.HTTP.ServiceURL, "/")
+	lu := su + BaseURL + loginSubpath
+	md := pmodel.AuthServerMetadata{
+		Issuer:                   su,
+		TokenEndpoint:            lu,
+		JWKSURI:                  su + jwksSubpath + "/jwks.json",
+		RevocationEndpoint:       lu + "/revoke",
+		IntrospectionEndpoint:    lu + "/introspect",
+		ResponseTypesSupported:   []string{},
+		GrantTypesSupported:      []string{grantClientCredentials, grantRefreshToken},
+		TokenEndpointAuthMethods: []string{"client_secret_basic", "client_secret_post"},
+		IntrospectionAuthMethods: []string{"bearer"},
+		RevocationAuthMethods:    []string{"none"},
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, md)
+}
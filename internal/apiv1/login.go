@@ -2,8 +2,11 @@ package apiv1
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
@@ -17,8 +20,16 @@ import (
 	"github.com/willie68/micro-vault/internal/services/admin"
 	"github.com/willie68/micro-vault/internal/services/clients"
 	"github.com/willie68/micro-vault/internal/utils/httputils"
+	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
+const (
+	grantClientCredentials = "client_credentials"
+	grantRefreshToken      = "refresh_token"
+)
+
+var errMissingToken = errors.New("the parameter token is missing")
+
 // LoginHandler handler for handling REST calls for admin endpoints
 type LoginHandler struct {
 	cl  clients.Clients
@@ -40,14 +51,17 @@ func (l *LoginHandler) Routes() (string, *chi.Mux) {
 	router := chi.NewRouter()
 	router.Post("/", l.PostLogin)
 	router.Get("/refresh", l.GetRefresh)
+	router.Post("/revoke", l.PostRevoke)
+	router.Post("/introspect", l.PostIntrospect)
 	router.Get("/privatekey", l.GetPrivateKey)
+	router.Get("/privatekeys", l.GetPrivateKeys)
 	return BaseURL + loginSubpath, router
 }
 
 // PostLogin login a client to the vault service
-// @Summary login a client to the vault service
+// @Summary login a client to the vault service, with a json body or with a standard OAuth2 token request (client_credentials or refresh_token grant)
 // @Tags configs
-// @Accept  json
+// @Accept  json,application/x-www-form-urlencoded
 // @Produce  json
 // @Param Accesskey, Secret as strings for login
 // @Param payload body string true "Add store"
@@ -56,6 +70,10 @@ func (l *LoginHandler) Routes() (string, *chi.Mux) {
 // @Failure 500 {object} serror.Serr "server error information as json"
 // @Router /vault/login [post]
 func (l *LoginHandler) PostLogin(response http.ResponseWriter, request *http.Request) {
+	if isFormRequest(request) {
+		l.postTokenRequest(response, request)
+		return
+	}
 	up := struct {
 		Username  string `json:"user"`
 		Password  []byte `json:"pwd"`
@@ -82,37 +100,52 @@ func (l *LoginHandler) PostLogin(response http.ResponseWriter, request *http.Req
 			return
 		}
 	}
-	jt, err := auth.DecodeJWT(t)
+	l.responseToken(response, request, t, rt, k)
+}
+
+// postTokenRequest the standard OAuth2 token request (RFC 6749), the client_credentials grant with the access key as
+// client_id and the secret as client_secret, given as HTTP Basic authentication or in the body, and the refresh_token grant
+func (l *LoginHandler) postTokenRequest(response http.ResponseWriter, request *http.Request) {
+	err := request.ParseForm()
 	if err != nil {
 		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
 		return
 	}
-	var name string
-	n := jt.Payload["name"]
-	if n != nil {
-		name = n.(string)
-	}
-	e := jt.Payload["exp"]
-	exp := e.(float64)
-	i := jt.Payload["iat"]
-	iat := i.(float64)
-	tk := struct {
-		Name         string `json:"name,omitempty"`
-		Token        string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
-		Type         string `json:"token_type"`
-		ExpiresIn    int    `json:"expires_in"`
-		Key          string `json:"key,omitempty"`
-	}{
-		Name:         name,
-		Token:        t,
-		RefreshToken: rt,
-		Type:         "Bearer",
-		ExpiresIn:    int(exp - iat),
-		Key:          k,
+	var t, rt string
+	switch gt := request.PostForm.Get("grant_type"); gt {
+	case grantClientCredentials:
+		id, secret, basic, err := clientCredentials(request)
+		if err != nil {
+			l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(err), ErrInvalidRequest))
+			return
+		}
+		t, rt, _, err = l.cl.Login(id, secret)
+		if err != nil {
+			if basic {
+				response.Header().Set("WWW-Authenticate", `Basic realm="micro-vault"`)
+			}
+			l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Unauthorized(err), ErrInvalidClient))
+			return
+		}
+	case grantRefreshToken:
+		rt = request.PostForm.Get("refresh_token")
+		if rt == "" {
+			l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(errors.New("the parameter refresh_token is missing")), ErrInvalidRequest))
+			return
+		}
+		t, rt, err = l.refresh(rt)
+		if err != nil {
+			l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(err), ErrInvalidGrant))
+			return
+		}
+	case "":
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(errors.New("the parameter grant_type is missing")), ErrInvalidRequest))
+		return
+	default:
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(fmt.Errorf("grant type %s is not supported", gt)), ErrUnsupportedGrantType))
+		return
 	}
-	render.Status(request, http.StatusOK)
-	render.JSON(response, request, tk)
+	l.responseToken(response, request, t, rt, "")
 }
 
 // GetRefresh refresh a client to the vault service
@@ -132,31 +165,27 @@ func (l *LoginHandler) GetRefresh(response http.ResponseWriter, request *http.Re
 		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
 		return
 	}
-	jtp, err := jwt.ParseInsecure([]byte(rt))
+	t, rt, err := l.refresh(rt)
 	if err != nil {
 		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
 		return
 	}
-	if len(jtp.Audience()) != 1 {
-		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
-		return
-	}
-	aud := jtp.Audience()[0]
-	var t string
-	if aud == clients.JKAudience {
-		t, rt, err = l.cl.Refresh(rt)
-		if err != nil {
-			l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
-			return
-		}
-	}
-	if aud == admin.JKAudience {
-		t, rt, err = l.adm.Refresh(rt)
-		if err != nil {
-			l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
-			return
-		}
+	l.responseToken(response, request, t, rt, "")
+}
+
+// refresh refreshing the tokens of a client or the admin with the refresh token
+func (l *LoginHandler) refresh(rt string) (string, string, error) {
+	switch audience(rt) {
+	case clients.JKAudience:
+		return l.cl.Refresh(rt)
+	case admin.JKAudience:
+		return l.adm.Refresh(rt)
 	}
+	return "", "", serror.ErrTokenNotValid
+}
+
+// responseToken writes the token response (RFC 6749), the key is only added for the json login of a client
+func (l *LoginHandler) responseToken(response http.ResponseWriter, request *http.Request, t, rt, k string) {
 	jt, err := auth.DecodeJWT(t)
 	if err != nil {
 		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
@@ -177,17 +206,138 @@ func (l *LoginHandler) GetRefresh(response http.ResponseWriter, request *http.Re
 		RefreshToken string `json:"refresh_token"`
 		Type         string `json:"token_type"`
 		ExpiresIn    int    `json:"expires_in"`
+		Key          string `json:"key,omitempty"`
 	}{
 		Name:         name,
 		Token:        t,
 		RefreshToken: rt,
 		Type:         "Bearer",
 		ExpiresIn:    int(exp - iat),
+		Key:          k,
 	}
+	response.Header().Set("Cache-Control", "no-store")
+	response.Header().Set("Pragma", "no-cache")
 	render.Status(request, http.StatusOK)
 	render.JSON(response, request, tk)
 }
 
+// clientCredentials the client credentials of a token request, the HTTP Basic authentication wins over the body
+func clientCredentials(request *http.Request) (id, secret string, basic bool, err error) {
+	if u, p, ok := request.BasicAuth(); ok {
+		// RFC 6749 2.3.1, the client id and secret are form encoded before the basic encoding
+		id, err = url.QueryUnescape(u)
+		if err != nil {
+			return "", "", true, err
+		}
+		secret, err = url.QueryUnescape(p)
+		if err != nil {
+			return "", "", true, err
+		}
+		return id, secret, true, nil
+	}
+	id = request.PostForm.Get("client_id")
+	secret = request.PostForm.Get("client_secret")
+	if id == "" || secret == "" {
+		return "", "", false, errors.New("the client credentials are missing")
+	}
+	return id, secret, false, nil
+}
+
+func isFormRequest(request *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return ct == "application/x-www-form-urlencoded"
+}
+
+// PostRevoke revoking an access or refresh token (RFC 7009)
+// @Summary revoking an access or refresh token of a client or the admin, invalid tokens are ignored
+// @Tags configs
+// @Accept  application/x-www-form-urlencoded
+// @Produce  json
+// @Param token formData string true "the token to revoke"
+// @Param token_type_hint formData string false "access_token or refresh_token, not needed"
+// @Success 200 "the token is revoked"
+// @Failure 400 {object} OAuthErr "client error information as json"
+// @Failure 500 {object} OAuthErr "server error information as json"
+// @Router /login/revoke [post]
+func (l *LoginHandler) PostRevoke(response http.ResponseWriter, request *http.Request) {
+	err := request.ParseForm()
+	if err != nil {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
+		return
+	}
+	tk := request.PostForm.Get("token")
+	if tk == "" {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(errMissingToken), ErrInvalidRequest))
+		return
+	}
+	switch audience(tk) {
+	case clients.JKAudience:
+		err = l.cl.RevokeToken(tk)
+	case admin.JKAudience:
+		err = l.adm.RevokeToken(tk)
+	}
+	if err != nil {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.InternalServerError(err), ErrInvalidRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.NoContent(response, request)
+}
+
+// PostIntrospect getting the state and the claims of an access or refresh token (RFC 7662)
+// @Summary getting the state and the claims of an access or refresh token, the caller must authenticate with a valid access token
+// @Tags configs
+// @Accept  application/x-www-form-urlencoded
+// @Produce  json
+// @Param token as authentication header
+// @Param token formData string true "the token to introspect"
+// @Success 200 {object} pmodel.Introspection "the state of the token"
+// @Failure 400 {object} OAuthErr "client error information as json"
+// @Failure 401 {object} OAuthErr "the caller is not authenticated"
+// @Router /login/introspect [post]
+func (l *LoginHandler) PostIntrospect(response http.ResponseWriter, request *http.Request) {
+	ct, err := token(request)
+	if err != nil {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Unauthorized(err), ErrInvalidClient))
+		return
+	}
+	if ci := l.introspect(ct); !ci.Active || ci.TokenType != "Bearer" {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Unauthorized(serror.ErrTokenNotValid), ErrInvalidClient))
+		return
+	}
+	err = request.ParseForm()
+	if err != nil {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.Wrapc(err, http.StatusBadRequest), ErrInvalidRequest))
+		return
+	}
+	tk := request.PostForm.Get("token")
+	if tk == "" {
+		l.responseOAuthError(response, request, l.wrapOAuthErr(*serror.BadRequest(errMissingToken), ErrInvalidRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, l.introspect(tk))
+}
+
+func (l *LoginHandler) introspect(tk string) pmodel.Introspection {
+	switch audience(tk) {
+	case clients.JKAudience:
+		return l.cl.Introspect(tk)
+	case admin.JKAudience:
+		return l.adm.Introspect(tk)
+	}
+	return pmodel.Introspection{Active: false}
+}
+
+// audience the audience of the token without verification, empty for an invalid token
+func audience(tk string) string {
+	jt, err := jwt.ParseInsecure([]byte(tk))
+	if err != nil || len(jt.Audience()) != 1 {
+		return ""
+	}
+	return jt.Audience()[0]
+}
+
 // GetPrivateKey getting the personal private key of a client certificate
 // @Summary getting the personal private key of a client certificate
 // @Tags configs
@@ -220,6 +370,41 @@ func (l *LoginHandler) GetPrivateKey(response http.ResponseWriter, request *http
 	}
 }
 
+// GetPrivateKeys getting the current and the previous, not retired private keys of a client
+// @Summary getting the current and the previous, not retired private keys of a client, the current key first
+// @Tags configs
+// @Accept  n.n.
+// @Produce  json
+// @Param token as authentication header
+// @Success 200 {array} pmodel.ClientKey "the private keys of the client"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/login/privatekeys [get]
+func (l *LoginHandler) GetPrivateKeys(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	ks, err := l.cl.GetPrivateKeys(tk)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	cks := make([]pmodel.ClientKey, 0)
+	for _, k := range ks {
+		cks = append(cks, pmodel.ClientKey{
+			KID:     k.KID,
+			Key:     k.Key,
+			KeyType: k.KeyType,
+			Retire:  k.Retire,
+		})
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, cks)
+}
+
 // OAuthErr error description for an OAuth error
 type OAuthErr struct {
 	serror.Serr
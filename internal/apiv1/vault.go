@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -17,6 +22,9 @@ import (
 	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
+// maxBatchRequest the max size of the body of a batch request
+const maxBatchRequest = 64 * 1024 * 1024
+
 // VaultHandler handler for handling REST calls for vaults endpoints
 type VaultHandler struct {
 	cl clients.Clients
@@ -33,25 +41,49 @@ func NewVaultHandler() api.Handler {
 func (v *VaultHandler) Routes() (string, *chi.Mux) {
 	router := chi.NewRouter()
 	router.Post("/clients/certificate", v.PostCert)
+	router.Post("/clients/certificate/bundle", v.PostCertBundle)
 	router.Get("/clients/certificate/{name}", v.GetCertByName)
+	router.Post("/clients/key/rotate", v.PostRotateClientKey)
+	router.Get("/clients/secrets", v.GetSecrets)
+	router.Post("/clients/secrets/rotate", v.PostRotateSecret)
+	router.Delete("/clients/secrets/{id}", v.DeleteSecret)
 	router.Post("/groups/keys", v.PostKeys)
 	router.Get("/groups/keys/{id}", v.GetKey)
 	router.Post("/groups/crypt", v.PostCrypt)
+	router.Post("/groups/crypt/batch", v.PostCryptBatch)
+	router.Post("/groups/stream/encrypt", v.PostEncryptStream)
+	router.Post("/groups/stream/decrypt", v.PostDecryptStream)
+	router.Post("/groups/datakey", v.PostDataKey)
+	router.Post("/groups/datakey/unwrap", v.PostUnwrapDataKey)
+	router.Post("/groups/rewrap", v.PostRewrap)
+	router.Post("/groups/rewrap/batch", v.PostRewrapBatch)
 	router.Post("/signature/sign", v.PostSign)
 	router.Post("/signature/check", v.PostCheck)
+	router.Post("/signature/sign/batch", v.PostSignBatch)
+	router.Post("/signature/check/batch", v.PostCheckBatch)
 	router.Post("/msg", v.PostMsg)
 	router.Get("/msg/{id}", v.GetMsg)
 	router.Delete("/msg/{id}", v.DeleteMsg)
+	router.Get("/kv/{group}", v.GetKV)
+	router.Get("/kv/{group}/*", v.GetKV)
+	router.Put("/kv/{group}/*", v.PutKV)
+	router.Post("/kv/{group}/*", v.PutKV)
+	router.Delete("/kv/{group}/*", v.DeleteKV)
+	router.Post("/kvundelete/{group}/*", v.PostKVUndelete)
+	router.Get("/kvmeta/{group}/*", v.GetKVMetadata)
+	router.Delete("/kvmeta/{group}/*", v.DeleteKVMetadata)
 	return BaseURL + vaultSubpath, router
 }
 
 // PostCert posting a certificate request to this mv service, returning a signed certificate
-// @Summary posting a certificate request to this mv service, returning a signed certificate
+// @Summary posting a certificate request to this mv service, returning a signed certificate followed by the ca chain
 // @Tags configs
 // @Accept  pem file
 // @Produce  n.n.
 // @Param token as authentication header
 // @Param payload body pem file
+// @Param profile query string false "certificate profile, default is the first profile of the client"
+// @Param mode query string false "client (default) the certificate is for the key of the client, csr the certificate is for the public key of the request"
 // @Success 200 {object} nothing
 // @Failure 400 {object} serror.Serr "client error information as json"
 // @Failure 500 {object} serror.Serr "server error information as json"
@@ -69,7 +101,16 @@ func (v *VaultHandler) PostCert(response http.ResponseWriter, request *http.Requ
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
 	}
-	ct, err := v.cl.CreateCertificate(tk, string(pb.Bytes()))
+	q := request.URL.Query()
+	var ct string
+	switch q.Get("mode") {
+	case "", "client":
+		ct, err = v.cl.CreateProfileCertificate(tk, pb.String(), q.Get("profile"))
+	case "csr":
+		ct, err = v.cl.CreateCSRCertificate(tk, pb.String(), q.Get("profile"))
+	default:
+		err = fmt.Errorf("unknown certificate mode: %s", q.Get("mode"))
+	}
 	if err != nil {
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
@@ -82,6 +123,51 @@ func (v *VaultHandler) PostCert(response http.ResponseWriter, request *http.Requ
 	}
 }
 
+// PostCertBundle posting a certificate request, returning a certificate with a new generated key
+// @Summary posting a certificate request, the mv service generates a new key, returning a password protected bundle
+// @Tags configs
+// @Accept  json
+// @Produce  pem or pkcs12
+// @Param token as authentication header
+// @Param payload body pmodel.CertBundleRequest true "the certificate request with the options of the bundle"
+// @Success 201 {object} nothing
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/clients/certificate/bundle [post]
+func (v *VaultHandler) PostCertBundle(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var br pmodel.CertBundleRequest
+	err = json.NewDecoder(request.Body).Decode(&br)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	b, err := v.cl.CreateCertificateBundle(tk, br.Template, clients.BundleOptions{
+		Profile:  br.Profile,
+		KeyType:  br.KeyType,
+		Format:   br.Format,
+		Password: br.Password,
+	})
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ct := "application/x-pem-file"
+	if strings.HasPrefix(br.Format, clients.BundlePKCS12) {
+		ct = "application/x-pkcs12"
+	}
+	response.Header().Add("Content-Type", ct)
+	response.WriteHeader(http.StatusCreated)
+	_, err = response.Write(b)
+	if err != nil {
+		logger.Errorf("error writing bundle: %v", err)
+	}
+}
+
 // GetCertByName getting the public key of a client certificate for the named client
 // @Summary getting the public key of a client certificate for the named client
 // @Tags configs
@@ -119,6 +205,132 @@ func (v *VaultHandler) GetCertByName(response http.ResponseWriter, request *http
 	}
 }
 
+// PostRotateClientKey rotating the key of the client, the previous key is still valid for the grace period
+// @Summary rotating the key of the client, the previous key is still valid for the grace period
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body string false "json with the key type of the new key, e.g. {"keytype": "ECDSA-P256"}, default is the key type of the client"
+// @Success 201 {object} pmodel.Client "the client with the new key and kid"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/clients/key/rotate [post]
+func (v *VaultHandler) PostRotateClientKey(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	b, err := io.ReadAll(request.Body)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	du := struct {
+		KeyType string `json:"keytype"`
+	}{}
+	if len(b) > 0 {
+		err = json.Unmarshal(b, &du)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+			return
+		}
+	}
+	cl, err := v.cl.RotateClientKey(tk, du.KeyType)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ccl := pmodel.Client{
+		Name:    cl.Name,
+		KID:     cl.KID,
+		Key:     cl.Key,
+		KeyType: cl.KeyType,
+	}
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, ccl)
+}
+
+// GetSecrets listing the active secrets of the client
+// @Summary listing the active secrets of the client, without the secrets itself
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Success 200 {array} pmodel.ClientSecret "the active secrets of the client"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/clients/secrets [get]
+func (v *VaultHandler) GetSecrets(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ss, err := v.cl.Secrets(tk)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, ss)
+}
+
+// PostRotateSecret issuing a new secret for the client, the old secrets are still valid
+// @Summary issuing a new secret for the client, the old secrets are still valid until revoked
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body string false "json with the optional validity of the new secret, e.g. {"expires": "90d"}"
+// @Success 201 {object} pmodel.ClientSecret "the new secret, only given once"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/clients/secrets/rotate [post]
+func (v *VaultHandler) PostRotateSecret(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	exp, err := secretExpires(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	s, err := v.cl.RotateSecret(tk, exp)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, s)
+}
+
+// DeleteSecret revoking a secret of the client
+// @Summary revoking a secret of the client, the last active secret can't be revoked
+// @Tags configs
+// @Param token as authentication header
+// @Param id path string true "id of the secret, primary for the secret given at the creation of the client"
+// @Success 200 {object} nothing
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/clients/secrets/{id} [delete]
+func (v *VaultHandler) DeleteSecret(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	err = v.cl.RevokeSecret(tk, chi.URLParam(request, "id"))
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+}
+
 // PostKeys posting data to generate a new key for group
 // @Summary posting data to generate a new key for group
 // @Tags configs
@@ -138,6 +350,7 @@ func (v *VaultHandler) PostKeys(response http.ResponseWriter, request *http.Requ
 	}
 	jd := struct {
 		Group string `json:"group"`
+		Alg   string `json:"alg"`
 	}{}
 	err = json.NewDecoder(request.Body).Decode(&jd)
 	if err != nil {
@@ -145,7 +358,7 @@ func (v *VaultHandler) PostKeys(response http.ResponseWriter, request *http.Requ
 		return
 	}
 
-	ek, err := v.cl.CreateEncryptKey(tk, jd.Group)
+	ek, err := v.cl.CreateEncryptKey(tk, jd.Group, jd.Alg)
 	if err != nil {
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
@@ -198,6 +411,139 @@ func (v *VaultHandler) GetKey(response http.ResponseWriter, request *http.Reques
 	render.JSON(response, request, jk)
 }
 
+// PostDataKey generating a new data key for envelope encryption
+// @Summary generating a new data key, returned in plaintext and wrapped with the current group key
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body string true "json with the group, e.g. {"group": "group1"}"
+// @Success 201 {object} pmodel.DataKey "the data key"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/datakey [post]
+func (v *VaultHandler) PostDataKey(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	jd := struct {
+		Group string `json:"group"`
+	}{}
+	err = json.NewDecoder(request.Body).Decode(&jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	dk, err := v.cl.GenerateDataKey(tk, jd.Group)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusCreated)
+	render.JSON(response, request, dk)
+}
+
+// PostUnwrapDataKey unwrapping a data key
+// @Summary unwrapping a data key, only for members of the group of the wrapping key
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body string true "json with the wrapped data key, e.g. {"ciphertext": "mv1:..."}"
+// @Success 200 {object} pmodel.DataKey "the data key"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/datakey/unwrap [post]
+func (v *VaultHandler) PostUnwrapDataKey(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var jd pmodel.DataKey
+	err = json.NewDecoder(request.Body).Decode(&jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	dk, err := v.cl.UnwrapDataKey(tk, jd.Ciphertext)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, dk)
+}
+
+// PostRewrap re-encrypting a ciphertext with the current group key or a given key
+// @Summary re-encrypting a ciphertext with the current group key or a given key, the plaintext is never returned. The old key may be scheduled for destruction, disabled and destroyed keys are rejected
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body pmodel.RewrapMessage true "the ciphertext with the key id"
+// @Success 200 {object} pmodel.RewrapMessage "the new ciphertext with the new key id"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/rewrap [post]
+func (v *VaultHandler) PostRewrap(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var jd pmodel.RewrapMessage
+	err = json.NewDecoder(request.Body).Decode(&jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	j, err := v.cl.Rewrap(tk, jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, j)
+}
+
+// PostRewrapBatch re-encrypting a list of ciphertexts
+// @Summary re-encrypting a list of ciphertexts, errors are reported per item
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body []pmodel.RewrapMessage true "the ciphertexts with the key ids"
+// @Success 200 {object} []pmodel.RewrapMessage "the new ciphertexts or the errors"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/rewrap/batch [post]
+func (v *VaultHandler) PostRewrapBatch(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	jd, err := decodeBatch[pmodel.RewrapMessage](response, request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	j, err := v.cl.RewrapBatch(tk, jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, j)
+}
+
 // PostCrypt posting a crypt message, getting back the result, server side en/decryption
 // @Summary  posting a crypt message, getting back the result, server side en/decryption
 // @Tags configs
@@ -233,6 +579,156 @@ func (v *VaultHandler) PostCrypt(response http.ResponseWriter, request *http.Req
 	render.JSON(response, request, j)
 }
 
+// PostCryptBatch server side en/decryption of a list of messages
+// @Summary server side en/decryption of a list of messages, errors are reported per item
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body []pmodel.Message true "the messages to en/decrypt"
+// @Success 200 {object} []pmodel.Message "the results or the errors"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/crypt/batch [post]
+func (v *VaultHandler) PostCryptBatch(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	jd, err := decodeBatch[pmodel.Message](response, request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	j, err := v.cl.CryptSSBatch(tk, jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, j)
+}
+
+// PostEncryptStream encrypting a binary stream with a group key, server side streaming encryption
+// @Summary encrypting a binary stream with a group key, server side streaming encryption
+// @Tags configs
+// @Accept  octet-stream
+// @Produce  octet-stream
+// @Param token as authentication header
+// @Param group query string false "group of the new key"
+// @Param id query string false "id of an existing key, instead of a new key"
+// @Param alg query string false "algorithm of the new key: AES-256-GCM (default) or XCHACHA20-POLY1305"
+// @Param payload body the plaintext
+// @Success 200 {object} the encrypted stream, the id of the key is in the header X-Key-Id
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/stream/encrypt [post]
+func (v *VaultHandler) PostEncryptStream(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	q := request.URL.Query()
+	ew, kid, err := v.cl.NewEncryptWriter(tk, response, q.Get("group"), q.Get("id"), q.Get("alg"))
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	prepareStream(response)
+	response.Header().Set("Content-Type", "application/octet-stream")
+	response.Header().Set("X-Key-Id", kid)
+	response.WriteHeader(http.StatusOK)
+	_, err = io.Copy(ew, request.Body)
+	if err == nil {
+		err = ew.Close()
+	}
+	if err != nil {
+		// the status is already send, so abort the response, the client will detect the truncated stream
+		logger.Errorf("encrypt stream failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// PostDecryptStream decrypting a binary stream, server side streaming decryption
+// @Summary decrypting a binary stream, server side streaming decryption, the key is taken from the stream header
+// @Tags configs
+// @Accept  octet-stream
+// @Produce  octet-stream
+// @Param token as authentication header
+// @Param payload body the encrypted stream
+// @Success 200 {object} the plaintext
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/groups/stream/decrypt [post]
+func (v *VaultHandler) PostDecryptStream(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	dr, err := v.cl.NewDecryptReader(tk, request.Body)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	prepareStream(response)
+	response.Header().Set("Content-Type", "application/octet-stream")
+	response.WriteHeader(http.StatusOK)
+	_, err = io.Copy(response, dr)
+	if err != nil {
+		// the status is already send, so abort the response, the client must not take the partial plaintext as valid
+		logger.Errorf("decrypt stream failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// decodeBatch decoding the json array of a batch request. The size of the body and the count of the items
+// are checked while decoding, so an oversized batch is rejected before it is read completely.
+func decodeBatch[T any](response http.ResponseWriter, request *http.Request) ([]T, error) {
+	dec := json.NewDecoder(http.MaxBytesReader(response, request.Body, maxBatchRequest))
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return nil, errors.New("batch must be a json array")
+	}
+	l := make([]T, 0)
+	for dec.More() {
+		if len(l) >= clients.MaxBatchSize {
+			return nil, fmt.Errorf("batch too large, max %d items", clients.MaxBatchSize)
+		}
+		var i T
+		err = dec.Decode(&i)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, i)
+	}
+	_, err = dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// prepareStream removes the server timeouts for long running streams and allows reading the request while writing the response
+func prepareStream(response http.ResponseWriter) {
+	rc := http.NewResponseController(response)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		logger.Debugf("can't reset read deadline: %v", err)
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debugf("can't reset write deadline: %v", err)
+	}
+	if err := rc.EnableFullDuplex(); err != nil {
+		logger.Debugf("can't enable full duplex: %v", err)
+	}
+}
+
 // PostSign posting a message to sign, getting back the result, server side signing
 // @Summary posting a message to sign, getting back the result, server side signing
 // @Tags configs
@@ -304,8 +800,72 @@ func (v *VaultHandler) PostCheck(response http.ResponseWriter, request *http.Req
 	render.JSON(response, request, j)
 }
 
+// PostSignBatch server side signing of a list of messages
+// @Summary server side signing of a list of messages, errors are reported per item
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body []pmodel.SignMessage true "the messages to sign"
+// @Success 200 {object} []pmodel.SignMessage "the signed messages or the errors"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/signature/sign/batch [post]
+func (v *VaultHandler) PostSignBatch(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	jd, err := decodeBatch[pmodel.SignMessage](response, request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	j, err := v.cl.SignSSBatch(tk, jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, j)
+}
+
+// PostCheckBatch server side checking of a list of signatures
+// @Summary server side checking of a list of signatures, errors are reported per item
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param payload body []pmodel.SignMessage true "the messages with the signatures"
+// @Success 200 {object} []pmodel.SignMessage "the check results or the errors"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/signature/check/batch [post]
+func (v *VaultHandler) PostCheckBatch(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	jd, err := decodeBatch[pmodel.SignMessage](response, request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+
+	j, err := v.cl.CheckSSBatch(tk, jd)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, j)
+}
+
 // PostMsg posting message to be stored securly for group/client
-// @Summary posting message to be stored securly for group/client
+// @Summary posting message to be stored securly for group/client, with optional ttl and read once flag
 // @Tags configs
 // @Accept  pem file
 // @Produce  n.n.
@@ -343,13 +903,14 @@ func (v *VaultHandler) PostMsg(response http.ResponseWriter, request *http.Reque
 }
 
 // GetMsg getting a single message, if allowed
-// @Summary getting a single message, if allowed
+// @Summary getting a single message, if allowed. Read once messages are deleted after this call.
 // @Tags configs
 // @Produce  n.n.
 // @Param token as authentication header
 // @Param id id of key
 // @Success 200 {object} nothing
 // @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 410 {object} serror.Serr "the message is expired"
 // @Failure 500 {object} serror.Serr "server error information as json"
 // @Router /vault/keys/{id} [post]
 func (v *VaultHandler) GetMsg(response http.ResponseWriter, request *http.Request) {
@@ -361,6 +922,10 @@ func (v *VaultHandler) GetMsg(response http.ResponseWriter, request *http.Reques
 	id := chi.URLParam(request, "id")
 	msg, err := v.cl.GetData(tk, id)
 	if err != nil {
+		if errors.Is(err, serror.ErrDataExpired) {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusGone))
+			return
+		}
 		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
 		return
 	}
@@ -396,3 +961,270 @@ func (v *VaultHandler) DeleteMsg(response http.ResponseWriter, request *http.Req
 	}
 	render.Status(request, http.StatusOK)
 }
+
+// GetKV getting a version of a secret, a path ending with / lists the keys of that folder
+// @Summary getting a version of a secret of the key/value store, a path ending with / lists the keys of that folder
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Param group path string true "group of the secret"
+// @Param path path string true "path of the secret"
+// @Param version query int false "version of the secret, default is the current version"
+// @Success 200 {object} pmodel.KVSecret "the secret, for a folder a list of keys"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found or deleted"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/kv/{group}/{path} [get]
+func (v *VaultHandler) GetKV(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	g := chi.URLParam(request, "group")
+	p, err := kvPath(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	if p == "" || strings.HasSuffix(p, "/") {
+		l, err := v.cl.ListKV(tk, g, p)
+		if err != nil {
+			kvErr(response, request, err, p)
+			return
+		}
+		render.Status(request, http.StatusOK)
+		render.JSON(response, request, l)
+		return
+	}
+	ver := 0
+	if vs := request.URL.Query().Get("version"); vs != "" {
+		ver, err = strconv.Atoi(vs)
+		if err != nil {
+			httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+			return
+		}
+	}
+	kv, err := v.cl.GetKV(tk, g, p, ver)
+	if err != nil {
+		kvErr(response, request, err, p)
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, kv)
+}
+
+// PutKV writing a new version of a secret
+// @Summary writing a new version of a secret of the key/value store, with optional check-and-set
+// @Tags configs
+// @Accept  json
+// @Produce  json
+// @Param token as authentication header
+// @Param group path string true "group of the secret"
+// @Param path path string true "path of the secret"
+// @Param payload body pmodel.KVWrite true "the value and the optional cas version"
+// @Success 200 {object} pmodel.KVMetadata "the metadata of the secret"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 409 {object} serror.Serr "check-and-set version mismatch"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/kv/{group}/{path} [put]
+func (v *VaultHandler) PutKV(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	p, err := kvPath(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var kw pmodel.KVWrite
+	err = json.NewDecoder(request.Body).Decode(&kw)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	md, err := v.cl.PutKV(tk, chi.URLParam(request, "group"), p, kw.Value, kw.CAS)
+	if err != nil {
+		kvErr(response, request, err, p)
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, md)
+}
+
+// DeleteKV soft deleting versions of a secret
+// @Summary soft deleting versions of a secret of the key/value store, default is the current version
+// @Tags configs
+// @Param token as authentication header
+// @Param group path string true "group of the secret"
+// @Param path path string true "path of the secret"
+// @Param versions query string false "comma separated list of versions"
+// @Success 200 "the versions are deleted"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/kv/{group}/{path} [delete]
+func (v *VaultHandler) DeleteKV(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	p, err := kvPath(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	vs := make([]int, 0)
+	if q := request.URL.Query().Get("versions"); q != "" {
+		for _, s := range strings.Split(q, ",") {
+			ver, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+				return
+			}
+			vs = append(vs, ver)
+		}
+	}
+	err = v.cl.DeleteKV(tk, chi.URLParam(request, "group"), p, vs)
+	if err != nil {
+		kvErr(response, request, err, p)
+		return
+	}
+	render.Status(request, http.StatusOK)
+}
+
+// PostKVUndelete restoring soft deleted versions of a secret
+// @Summary restoring soft deleted versions of a secret of the key/value store
+// @Tags configs
+// @Accept  json
+// @Param token as authentication header
+// @Param group path string true "group of the secret"
+// @Param path path string true "path of the secret"
+// @Param payload body pmodel.KVVersions true "the versions to restore"
+// @Success 200 "the versions are restored"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/kvundelete/{group}/{path} [post]
+func (v *VaultHandler) PostKVUndelete(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	p, err := kvPath(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	var kvs pmodel.KVVersions
+	err = json.NewDecoder(request.Body).Decode(&kvs)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	err = v.cl.UndeleteKV(tk, chi.URLParam(request, "group"), p, kvs.Versions)
+	if err != nil {
+		kvErr(response, request, err, p)
+		return
+	}
+	render.Status(request, http.StatusOK)
+}
+
+// GetKVMetadata getting the metadata of a secret
+// @Summary getting the metadata of a secret of the key/value store with all versions, without the values
+// @Tags configs
+// @Produce  json
+// @Param token as authentication header
+// @Param group path string true "group of the secret"
+// @Param path path string true "path of the secret"
+// @Success 200 {object} pmodel.KVMetadata "the metadata of the secret"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/kvmeta/{group}/{path} [get]
+func (v *VaultHandler) GetKVMetadata(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	p, err := kvPath(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	md, err := v.cl.GetKVMetadata(tk, chi.URLParam(request, "group"), p)
+	if err != nil {
+		kvErr(response, request, err, p)
+		return
+	}
+	render.Status(request, http.StatusOK)
+	render.JSON(response, request, md)
+}
+
+// DeleteKVMetadata removing a secret with all versions permanently
+// @Summary removing a secret of the key/value store with all versions and the metadata permanently
+// @Tags configs
+// @Param token as authentication header
+// @Param group path string true "group of the secret"
+// @Param path path string true "path of the secret"
+// @Success 200 "the secret is removed"
+// @Failure 400 {object} serror.Serr "client error information as json"
+// @Failure 404 {object} serror.Serr "secret not found"
+// @Failure 500 {object} serror.Serr "server error information as json"
+// @Router /vault/kvmeta/{group}/{path} [delete]
+func (v *VaultHandler) DeleteKVMetadata(response http.ResponseWriter, request *http.Request) {
+	tk, err := token(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	p, err := kvPath(request)
+	if err != nil {
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+		return
+	}
+	ok, err := v.cl.DestroyKV(tk, chi.URLParam(request, "group"), p)
+	if err != nil {
+		kvErr(response, request, err, p)
+		return
+	}
+	if !ok {
+		httputils.Err(response, request, serror.NotFound("secret", p))
+		return
+	}
+	render.Status(request, http.StatusOK)
+}
+
+// kvPath returns the unescaped path of the secret
+func kvPath(request *http.Request) (string, error) {
+	return url.PathUnescape(chi.URLParam(request, "*"))
+}
+
+// secretExpires reading the optional validity of a new client secret from the body
+func secretExpires(request *http.Request) (string, error) {
+	b, err := io.ReadAll(request.Body)
+	if err != nil || len(b) == 0 {
+		return "", err
+	}
+	du := struct {
+		Expires string `json:"expires"`
+	}{}
+	err = json.Unmarshal(b, &du)
+	return du.Expires, err
+}
+
+func kvErr(response http.ResponseWriter, request *http.Request, err error, p string) {
+	switch {
+	case errors.Is(err, serror.ErrNotExists), errors.Is(err, serror.ErrSecretDeleted):
+		httputils.Err(response, request, serror.NotFound("secret", p, err))
+	case errors.Is(err, serror.ErrCASMismatch):
+		httputils.Err(response, request, serror.Conflict(err))
+	default:
+		httputils.Err(response, request, serror.Wrapc(err, http.StatusBadRequest))
+	}
+}
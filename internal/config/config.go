@@ -45,7 +45,32 @@ type Service struct {
 	Rootpwd      string        `yaml:"rootpwd"`
 	PrivateKey   string        `yaml:"privatekey"`
 	CACert       CACert        `yaml:"cacert"`
+	ACME         ACME          `yaml:"acme"`
 	Storage      Storage       `yaml:"storage"`
+	// grace period for keys scheduled for destruction, e.g. 7d
+	KeyDestructionGrace string `yaml:"keydestructiongrace"`
+	// grace period for the previous keys of a client after a key rotation, e.g. 30d
+	ClientKeyGrace string `yaml:"clientkeygrace"`
+	// lifetimes of the issued tokens
+	Tokens Tokens `yaml:"tokens"`
+	// maximum number of stored versions of a key/value secret, the oldest versions are removed, default 100
+	KVMaxVersions int `yaml:"kvmaxversions"`
+}
+
+// Tokens lifetimes of the issued tokens, as durations like 5m, 1h or 7d
+type Tokens struct {
+	// lifetime of the access tokens of the clients, default 5m
+	ClientTTL string `yaml:"clientttl"`
+	// lifetime of the refresh tokens of the clients, default 60m
+	ClientRefreshTTL string `yaml:"clientrefreshttl"`
+	// maximum lifetime of the access tokens of the clients, even with an override on a client or group, default 24h
+	MaxClientTTL string `yaml:"maxclientttl"`
+	// maximum lifetime of the refresh tokens of the clients, even with an override on a client or group, default 7d
+	MaxClientRefreshTTL string `yaml:"maxclientrefreshttl"`
+	// lifetime of the access tokens of the admin, default 15m
+	AdminTTL string `yaml:"adminttl"`
+	// lifetime of the refresh tokens of the admin, default 60m
+	AdminRefreshTTL string `yaml:"adminrefreshttl"`
 }
 
 // HTTP configuration of the http service
@@ -67,6 +92,36 @@ type CACert struct {
 	PrivateKey  string            `yaml:"privatekey"`
 	Certificate string            `yaml:"certificate"`
 	Subject     map[string]string `yaml:"subject"`
+	// url of the crl distribution point, embedded into the issued certificates, default is the crl endpoint of the service url
+	CRLURL string `yaml:"crlurl"`
+	// url of the ocsp responder, embedded into the issued certificates, default is the ocsp endpoint of the service url
+	OCSPURL string `yaml:"ocspurl"`
+	// sign the ocsp responses with a delegated ocsp signing certificate instead of the ca key
+	OCSPDelegated bool `yaml:"ocspdelegated"`
+	// root ca certificate (pem) of an offline root. If set, the certificate and the private key above are
+	// an intermediate ca signed by this root, the root key itself is never needed by the service
+	Root string `yaml:"root"`
+	// further intermediate certificates (pem) of the root, which are still trusted and published, e.g. the intermediate before a change
+	Intermediates []string `yaml:"intermediates"`
+	// previous ca certificates after a change of the ca key, still trusted and published until the overlap is over
+	Previous []PreviousCA `yaml:"previous"`
+	// overlap of the previous ca certificates, starting with the actual ca certificate, default 365d
+	Overlap string `yaml:"overlap"`
+}
+
+// PreviousCA a previous certificate of the ca
+type PreviousCA struct {
+	Certificate string `yaml:"certificate"`
+	// optional private key, with it the crl and the ocsp responses for the certificates of the previous ca are still signed
+	PrivateKey string `yaml:"privatekey"`
+}
+
+// ACME configuration of the acme server
+type ACME struct {
+	// authorizations must be validated with a challenge, otherwise they are pre-authorized via the external account binding
+	ChallengeRequired bool `yaml:"challengerequired"`
+	// port used for the http-01 validation, default 80
+	HTTPPort int `yaml:"httpport"`
 }
 
 // Storage the type and properties of the storage
@@ -104,6 +159,17 @@ var DefaultConfig = Config{
 			Period:     30,
 			StartDelay: 3,
 		},
+		KeyDestructionGrace: "7d",
+		ClientKeyGrace:      "30d",
+		Tokens: Tokens{
+			ClientTTL:           "5m",
+			ClientRefreshTTL:    "60m",
+			MaxClientTTL:        "24h",
+			MaxClientRefreshTTL: "7d",
+			AdminTTL:            "15m",
+			AdminRefreshTTL:     "60m",
+		},
+		KVMaxVersions: 100,
 	},
 	SecretFile: "",
 	Logging: logging.LoggingConfig{
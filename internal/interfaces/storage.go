@@ -15,6 +15,8 @@ type Storage interface {
 
 	RevokeToken(id string, exp time.Time) error
 	IsRevoked(id string) bool
+	RevokeTokensBefore(n string, before, exp time.Time) error
+	TokensRevokedBefore(n string) (time.Time, bool)
 
 	HasGroup(n string) bool
 	AddGroup(g model.Group) (id string, err error)
@@ -36,9 +38,36 @@ type Storage interface {
 	HasEncryptKey(id string) bool
 	DeleteEncryptKey(id string) (bool, error)
 	ListEncryptKeys(s, l int64, c func(g model.EncryptKey) bool) error
+	AddGroupKey(e model.EncryptKey) error
+	ListGroupKeys(group string, c func(e model.EncryptKey) bool) error
+	ListScheduledKeys(until time.Time, c func(e model.EncryptKey) bool) error
 
 	StoreData(data model.Data) error
 	GetData(id string) (*model.Data, bool)
 	DeleteData(id string) (bool, error)
 	ListData(s, l int64, c func(g model.Data) bool) error
+
+	StoreKV(kv model.KVSecret, rev int) error
+	GetKV(group, path string) (*model.KVSecret, bool)
+	DeleteKV(group, path string) (bool, error)
+	ListKV(group string, c func(kv model.KVSecret) bool) error
+
+	StoreCertificate(c model.Certificate) error
+	GetCertificate(sn string) (*model.Certificate, bool)
+	ListCertificates(c func(c model.Certificate) bool) error
+	LastRevocation() (time.Time, bool)
+
+	StoreACMEAccount(a model.ACMEAccount) error
+	GetACMEAccount(id string) (*model.ACMEAccount, bool)
+	StoreACMEOrder(o model.ACMEOrder) error
+	GetACMEOrder(id string) (*model.ACMEOrder, bool)
+	StoreACMENonce(n string, exp time.Time) error
+	UseACMENonce(n string) bool
+	StoreACMEEAB(e model.ACMEEAB) error
+	GetACMEEAB(kid string) (*model.ACMEEAB, bool)
+
+	StoreProfile(p model.CertProfile) error
+	GetProfile(n string) (*model.CertProfile, bool)
+	DeleteProfile(n string) (bool, error)
+	ListProfiles(c func(p model.CertProfile) bool) error
 }
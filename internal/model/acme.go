@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+// ACME states of accounts, orders, authorizations and challenges (RFC 8555)
+const (
+	ACMEStatusPending     = "pending"
+	ACMEStatusReady       = "ready"
+	ACMEStatusProcessing  = "processing"
+	ACMEStatusValid       = "valid"
+	ACMEStatusInvalid     = "invalid"
+	ACMEStatusDeactivated = "deactivated"
+)
+
+// ACMEAccount an acme account, bound to a client via external account binding
+type ACMEAccount struct {
+	ID      string    `json:"id"`  // thumbprint of the account key
+	Key     string    `json:"key"` // the account key as jwk
+	Status  string    `json:"status"`
+	Contact []string  `json:"contact"`
+	Client  string    `json:"client"`
+	EAB     string    `json:"eab"` // kid of the external account binding
+	Created time.Time `json:"created"`
+	Orders  []string  `json:"orders"`
+}
+
+// ACMEOrder an acme order with all authorizations
+type ACMEOrder struct {
+	ID             string              `json:"id"`
+	Account        string              `json:"account"`
+	Client         string              `json:"client"`
+	Status         string              `json:"status"`
+	Created        time.Time           `json:"created"`
+	Expires        time.Time           `json:"expires"`
+	Identifiers    []ACMEIdentifier    `json:"identifiers"`
+	Profile        string              `json:"profile,omitempty"` // certificate profile of the order
+	Authorizations []ACMEAuthorization `json:"authorizations"`
+	Certificate    string              `json:"certificate"` // serial of the issued certificate
+}
+
+// ACMEIdentifier an identifier of an order, type dns or ip
+type ACMEIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ACMEAuthorization the authorization of a single identifier of an order
+type ACMEAuthorization struct {
+	Identifier ACMEIdentifier  `json:"identifier"`
+	Status     string          `json:"status"`
+	Challenges []ACMEChallenge `json:"challenges"`
+}
+
+// ACMEChallenge a challenge of an authorization
+type ACMEChallenge struct {
+	Type      string    `json:"type"`
+	Token     string    `json:"token"`
+	Status    string    `json:"status"`
+	Validated time.Time `json:"validated"`
+	ErrorType string    `json:"errortype"` // acme problem type of a failed validation
+	Error     string    `json:"error"`
+}
+
+// ACMEEAB the credentials of an external account binding of a client
+type ACMEEAB struct {
+	KID     string    `json:"kid"`
+	Key     string    `json:"key"` // base64url encoded hmac key
+	Client  string    `json:"client"`
+	Created time.Time `json:"created"`
+	Account string    `json:"account"` // the bound account, empty if not used
+}
+
+// Expired checking if the order is expired at the given time
+func (o ACMEOrder) Expired(now time.Time) bool {
+	return !o.Expires.IsZero() && now.After(o.Expires)
+}
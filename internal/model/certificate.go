@@ -0,0 +1,72 @@
+package model
+
+import (
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Certificate an issued certificate of the ca
+type Certificate struct {
+	Serial      string    `json:"serial"` // serial number as hex string
+	Subject     string    `json:"subject"`
+	Client      string    `json:"client"`
+	Profile     string    `json:"profile,omitempty"` // name of the certificate profile, empty without profile
+	NotBefore   time.Time `json:"notbefore"`
+	NotAfter    time.Time `json:"notafter"`
+	Certificate string    `json:"certificate"` // the certificate as pem
+	Revoked     time.Time `json:"revoked"`     // time of the revocation, zero if not revoked
+	Reason      int       `json:"reason"`      // crl reason code of the revocation
+}
+
+// CertificateFilter filter for the listing of the issued certificates, empty fields are not filtered
+type CertificateFilter struct {
+	Client         string        // only certificates of this client
+	ExpiringWithin time.Duration // only valid certificates, expiring within this duration
+	Revoked        *bool         // only revoked or only not revoked certificates
+}
+
+// Match checking if the certificate matches the filter
+func (f CertificateFilter) Match(c Certificate, now time.Time) bool {
+	if f.Client != "" && f.Client != c.Client {
+		return false
+	}
+	if f.ExpiringWithin > 0 && (now.After(c.NotAfter) || c.NotAfter.After(now.Add(f.ExpiringWithin))) {
+		return false
+	}
+	if f.Revoked != nil && *f.Revoked != c.IsRevoked() {
+		return false
+	}
+	return true
+}
+
+// CRLReasons the supported reason codes of a revocation (RFC 5280)
+var CRLReasons = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"privilegeWithdrawn":   9,
+}
+
+// ReasonName the name of the reason code of a revocation
+func ReasonName(r int) string {
+	for k, v := range CRLReasons {
+		if v == r {
+			return k
+		}
+	}
+	return ""
+}
+
+// IsRevoked checking if the certificate is revoked
+func (c Certificate) IsRevoked() bool {
+	return !c.Revoked.IsZero()
+}
+
+// Serial2ID converting the serial number of a certificate into the storage id
+func Serial2ID(sn *big.Int) string {
+	return strings.ToLower(sn.Text(16))
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // Client the model for a client
 type Client struct {
 	Name      string         `json:"name"`
@@ -10,5 +12,76 @@ type Client struct {
 	Groups    []string       `json:"groups"`
 	Key       string         `json:"key"`
 	KID       string         `json:"kid"`
+	KeyType   string         `json:"keytype,omitempty"` // RSA-2048, RSA-3072, RSA-4096 (default), ECDSA-P256, ECDSA-P384 or ED25519
 	Crt       map[string]any `json:"crt"`
+	// the previous keys of the client after a key rotation, valid until retired
+	PreviousKeys []ClientKey `json:"previouskeys,omitempty"`
+	// additional secrets of the client after a secret rotation
+	Secrets   []ClientSecret `json:"secrets,omitempty"`
+	Disabled  bool           `json:"disabled,omitempty"`
+	NotBefore time.Time      `json:"notbefore"` // the client can't login before, zero for no restriction
+	ExpiresAt time.Time      `json:"expiresat"` // the client can't login after, zero for no expiration
+	// token lifetimes of the client, overriding the lifetimes of the groups and the service, e.g. 1h
+	TokenTTL   string `json:"tokenttl,omitempty"`
+	RefreshTTL string `json:"refreshttl,omitempty"`
+}
+
+// PrimarySecretID the id of the secret given at the creation of the client (Salt and Hash of the client)
+const PrimarySecretID = "primary"
+
+// ClientSecret an additional secret of a client
+type ClientSecret struct {
+	ID      string    `json:"id"`
+	Salt    string    `json:"salt"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"` // zero for a secret without expiration
+}
+
+// Expired checking if the secret is expired
+func (s ClientSecret) Expired(now time.Time) bool {
+	return !s.Expires.IsZero() && !now.Before(s.Expires)
+}
+
+// ActiveSecrets the primary secret, if not revoked, followed by all not expired additional secrets of the client
+func (c Client) ActiveSecrets(now time.Time) []ClientSecret {
+	ss := make([]ClientSecret, 0)
+	if c.Hash != "" {
+		ss = append(ss, ClientSecret{ID: PrimarySecretID, Salt: c.Salt, Hash: c.Hash})
+	}
+	for _, s := range c.Secrets {
+		if !s.Expired(now) {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// ClientKey a previous key of a client
+type ClientKey struct {
+	KID     string    `json:"kid"`
+	Key     string    `json:"key"`
+	KeyType string    `json:"keytype,omitempty"`
+	Retire  time.Time `json:"retire"`
+}
+
+// ValidKeys the current key followed by all previous keys of the client, which are not retired
+func (c Client) ValidKeys(now time.Time) []ClientKey {
+	ks := []ClientKey{{KID: c.KID, Key: c.Key, KeyType: c.KeyType}}
+	for _, k := range c.PreviousKeys {
+		if now.Before(k.Retire) {
+			ks = append(ks, k)
+		}
+	}
+	return ks
+}
+
+// KeyByKID getting the current key or a previous, not retired key of the client with the kid
+func (c Client) KeyByKID(kid string, now time.Time) (string, bool) {
+	for _, k := range c.ValidKeys(now) {
+		if k.KID == kid {
+			return k.Key, true
+		}
+	}
+	return "", false
 }
@@ -4,9 +4,15 @@ import "time"
 
 // Data a model for the data store
 type Data struct {
-	ID      string    `json:"id"`
-	Expires time.Time `json:"expires"`
-	Created time.Time `json:"created"`
-	Group   string    `json:"group"`
-	Payload string    `json:"payload"`
+	ID       string    `json:"id"`
+	Expires  time.Time `json:"expires"` // zero for data without expiration
+	Created  time.Time `json:"created"`
+	Group    string    `json:"group"`
+	Payload  string    `json:"payload"`
+	ReadOnce bool      `json:"readonce"` // the data will be deleted after the first read
+}
+
+// Expired checking if the data is expired at the given time
+func (d Data) Expired(now time.Time) bool {
+	return !d.Expires.IsZero() && now.After(d.Expires)
 }
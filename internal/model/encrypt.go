@@ -2,11 +2,35 @@ package model
 
 import "time"
 
+// Lifecycle states of an encryption key, an empty state is treated as enabled
+const (
+	KeyStateEnabled   = "enabled"
+	KeyStateDisabled  = "disabled"
+	KeyStateScheduled = "scheduled-for-destruction"
+	KeyStateDestroyed = "destroyed"
+)
+
 // EncryptKey the key struct for transport a key
 type EncryptKey struct {
-	ID      string
-	Alg     string
-	Key     string
-	Created time.Time
-	Group   string
+	ID        string
+	Alg       string
+	Key       string
+	Created   time.Time
+	Group     string
+	Version   int       // version of a group key, 0 for single message keys, >0 for rotated group keys
+	State     string    // lifecycle state of the key, see KeyState constants
+	DestroyAt time.Time // only for keys scheduled for destruction, the key material will be destroyed after this time
+}
+
+// Enabled checking if the key can be used for en/decryption
+func (e EncryptKey) Enabled() bool {
+	return e.State == "" || e.State == KeyStateEnabled
+}
+
+// KeyState returning the lifecycle state of the key
+func (e EncryptKey) KeyState() string {
+	if e.State == "" {
+		return KeyStateEnabled
+	}
+	return e.State
 }
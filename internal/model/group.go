@@ -2,9 +2,12 @@ package model
 
 // Group model for a group
 type Group struct {
-	Name     string            `json:"name"`
-	Label    map[string]string `json:"label"`
-	IsClient bool              `json:"isclient"`
-	Key      string            `json:"key"`
-	KID      string            `json:"kid"`
+	Name        string            `json:"name"`
+	Label       map[string]string `json:"label"`
+	IsClient    bool              `json:"isclient"`
+	Key         string            `json:"key"`
+	KID         string            `json:"kid"`
+	KeyRotation string            `json:"keyrotation,omitempty"` // period for the automatic rotation of the group key, e.g. 90d
+	TokenTTL    string            `json:"tokenttl,omitempty"`    // lifetime of the access tokens of the clients of the group, e.g. 1h
+	RefreshTTL  string            `json:"refreshttl,omitempty"`  // lifetime of the refresh tokens of the clients of the group, e.g. 8h
 }
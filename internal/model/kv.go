@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// KVSecret a versioned secret of the key/value store, addressed by group and path
+type KVSecret struct {
+	Group          string      `json:"group"`
+	Path           string      `json:"path"`
+	CurrentVersion int         `json:"currentversion"`
+	Created        time.Time   `json:"created"`
+	CreatedBy      string      `json:"createdby"`
+	Updated        time.Time   `json:"updated"`
+	UpdatedBy      string      `json:"updatedby"`
+	Versions       []KVVersion `json:"versions"`
+	Revision       int         `json:"revision"` // storage revision, incremented with every write, for the conditional update
+}
+
+// KVVersion a single version of a secret
+type KVVersion struct {
+	Version   int       `json:"version"`
+	Value     string    `json:"value"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"createdby"`
+	Deleted   time.Time `json:"deleted"` // time of the soft delete, zero if not deleted
+}
+
+// ID the storage id of the secret
+func (k KVSecret) ID() string {
+	return KVID(k.Group, k.Path)
+}
+
+// Version returns the version v of the secret
+func (k *KVSecret) Version(v int) (*KVVersion, bool) {
+	for x := range k.Versions {
+		if k.Versions[x].Version == v {
+			return &k.Versions[x], true
+		}
+	}
+	return nil, false
+}
+
+// KVID the storage id of a secret, the group must not contain a /, so the id is unique
+func KVID(group, path string) string {
+	return group + "/" + path
+}
@@ -2,7 +2,8 @@ package model
 
 // Playbook model for the playbook file
 type Playbook struct {
-	Groups  []Group      `json:"groups"`
-	Clients []Client     `json:"clients"`
-	Keys    []EncryptKey `json:"keys"`
+	Groups   []Group       `json:"groups"`
+	Clients  []Client      `json:"clients"`
+	Keys     []EncryptKey  `json:"keys"`
+	Profiles []CertProfile `json:"profiles,omitempty"`
 }
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+)
+
+// KeyUsages the names of the key usages of a certificate profile
+var KeyUsages = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"certSign":          x509.KeyUsageCertSign,
+	"crlSign":           x509.KeyUsageCRLSign,
+}
+
+// ExtKeyUsages the names of the extended key usages of a certificate profile
+var ExtKeyUsages = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// subjectFields the fixable subject fields, same keys as in the certificate template of a client
+var subjectFields = []string{"uco", "upr", "ulo", "uor", "uou", "usa", "upc"}
+
+// CertProfile an admin defined certificate profile, controlling the usage, the validity and the names of the issued certificates
+type CertProfile struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	KeyUsage    []string          `json:"keyusage"`              // e.g. digitalSignature, keyEncipherment
+	ExtKeyUsage []string          `json:"extkeyusage,omitempty"` // e.g. serverAuth, clientAuth, codeSigning, emailProtection
+	MaxValidity string            `json:"maxvalidity,omitempty"` // maximal validity of the certificates, e.g. 90d
+	DNS         []string          `json:"dns,omitempty"`         // allowed dns names: example.com, *.example.com (one label), .example.com (all subdomains)
+	Wildcards   bool              `json:"wildcards,omitempty"`   // wildcard dns names like *.example.com may be issued
+	IPs         []string          `json:"ips,omitempty"`         // allowed ip addresses or cidr ranges
+	URIs        []string          `json:"uris,omitempty"`        // allowed uris, scheme, host and the leading path segments
+	Emails      []string          `json:"emails,omitempty"`      // allowed email addresses, @example.com for a whole domain
+	Subject     CertSubjectPolicy `json:"subject"`
+	CA          *CertCAPolicy     `json:"ca,omitempty"` // only for sub ca profiles
+}
+
+// CertSubjectPolicy the policy for the subject of the issued certificates
+type CertSubjectPolicy struct {
+	CommonNames []string          `json:"cn,omitempty"`        // allowed common names, same patterns as for dns names, empty for all
+	RequireCN   bool              `json:"requirecn,omitempty"` // a common name is required
+	Fixed       map[string]string `json:"fixed,omitempty"`     // fixed subject fields, replacing the request (uco, upr, ulo, uor, uou, usa, upc)
+}
+
+// CertCAPolicy the basic and the name constraints of a sub ca certificate
+type CertCAPolicy struct {
+	MaxPathLen   int      `json:"maxpathlen"` // 0, the sub ca can only issue leaf certificates
+	PermittedDNS []string `json:"permitteddns,omitempty"`
+	ExcludedDNS  []string `json:"excludeddns,omitempty"`
+	PermittedIPs []string `json:"permittedips,omitempty"` // cidr ranges
+	ExcludedIPs  []string `json:"excludedips,omitempty"`  // cidr ranges
+}
+
+// Validate checks the names and values of the profile
+func (p CertProfile) Validate() error {
+	if p.Name == "" {
+		return errors.New("profile without name")
+	}
+	if len(p.KeyUsage) == 0 {
+		return errors.New("profile without key usage")
+	}
+	for _, ku := range p.KeyUsage {
+		if _, ok := KeyUsages[ku]; !ok {
+			return fmt.Errorf("unknown key usage: %s", ku)
+		}
+	}
+	for _, eku := range p.ExtKeyUsage {
+		if _, ok := ExtKeyUsages[eku]; !ok {
+			return fmt.Errorf("unknown extended key usage: %s", eku)
+		}
+	}
+	if p.MaxValidity != "" {
+		if _, err := str2duration.ParseDuration(p.MaxValidity); err != nil {
+			return fmt.Errorf("configure maxvalidity with a valid duration: %v", err)
+		}
+	}
+	for _, ip := range p.IPs {
+		if _, err := ParseIPRange(ip); err != nil {
+			return err
+		}
+	}
+	for k := range p.Subject.Fixed {
+		if !slices.Contains(subjectFields, k) {
+			return fmt.Errorf("unknown subject field: %s", k)
+		}
+	}
+	if p.CA != nil {
+		if !slices.Contains(p.KeyUsage, "certSign") {
+			return errors.New("a sub ca profile needs the certSign key usage")
+		}
+		if p.CA.MaxPathLen < 0 {
+			return errors.New("max path length must not be negative")
+		}
+		for _, ips := range [][]string{p.CA.PermittedIPs, p.CA.ExcludedIPs} {
+			for _, ip := range ips {
+				if _, _, err := net.ParseCIDR(ip); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ParseIPRange parsing an ip address or a cidr range
+func ParseIPRange(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("not a valid ip address: %s", s)
+	}
+	bits := 8 * len(ip.To16())
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
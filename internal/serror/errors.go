@@ -12,4 +12,14 @@ var (
 	ErrTokenExpired      = errors.New("token expired")
 	ErrTokenNotValid     = errors.New("token not valid")
 	ErrMissingID         = errors.New("missing id")
+	ErrKeyNotEnabled     = errors.New("key is not enabled")
+	ErrKeyStateNotValid  = errors.New("key state transition not valid")
+	ErrDataExpired       = errors.New("data expired")
+	ErrCASMismatch       = errors.New("check-and-set version mismatch")
+	ErrSecretDeleted     = errors.New("secret version is deleted")
+	ErrCertRevoked       = errors.New("certificate already revoked")
+	ErrProfileViolation  = errors.New("certificate request violates the profile")
+	ErrClientDisabled    = errors.New("client is disabled")
+	ErrClientNotYetValid = errors.New("client is not yet valid")
+	ErrClientExpired     = errors.New("client is expired")
 )
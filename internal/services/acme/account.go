@@ -0,0 +1,188 @@
+package acme
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+)
+
+const eabKeyLen = 32
+
+// newAccountRequest the payload of a new account request
+type newAccountRequest struct {
+	Contact                []string        `json:"contact"`
+	TermsOfServiceAgreed   bool            `json:"termsOfServiceAgreed"`
+	OnlyReturnExisting     bool            `json:"onlyReturnExisting"`
+	ExternalAccountBinding json.RawMessage `json:"externalAccountBinding"`
+}
+
+// updateAccountRequest the payload of an account update request
+type updateAccountRequest struct {
+	Contact []string `json:"contact"`
+	Status  string   `json:"status"`
+}
+
+// NewEAB creating new external account binding credentials for the client of the token,
+// with these credentials an acme account can be created, which is bound to the client
+func (a *ACME) NewEAB(tk string) (*model.ACMEEAB, error) {
+	cl, err := a.cls.TokenClient(tk)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := randToken(16)
+	if err != nil {
+		return nil, err
+	}
+	key, err := randToken(eabKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	e := model.ACMEEAB{
+		KID:     kid,
+		Key:     key,
+		Client:  cl.Name,
+		Created: time.Now(),
+	}
+	err = a.stg.StoreACMEEAB(e)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("acme eab %s created for client %s", e.KID, e.Client)
+	return &e, nil
+}
+
+// NewAccount creating a new account for the key of the request or returning the existing one,
+// returning true if the account is newly created
+func (a *ACME) NewAccount(req Request) (*model.ACMEAccount, bool, error) {
+	if req.Account != nil {
+		return nil, false, malformed("new account request must be signed with a jwk")
+	}
+	var nar newAccountRequest
+	err := json.Unmarshal(req.Payload, &nar)
+	if err != nil {
+		return nil, false, malformed("can't parse new account request: %v", err)
+	}
+	id, err := thumbprint(req.Key)
+	if err != nil {
+		return nil, false, err
+	}
+	acc, ok := a.stg.GetACMEAccount(id)
+	if ok {
+		if acc.Status != model.ACMEStatusValid {
+			return nil, false, unauthorized("account is %s", acc.Status)
+		}
+		return acc, false, nil
+	}
+	if nar.OnlyReturnExisting {
+		return nil, false, problem(ErrAccountDoesNotExist, http.StatusBadRequest, "no account for this key")
+	}
+	if len(nar.ExternalAccountBinding) == 0 {
+		return nil, false, problem(ErrExternalAccountRequired, http.StatusBadRequest, "an external account binding is required, create one for your client")
+	}
+	e, err := a.verifyEAB(nar.ExternalAccountBinding, req.Key)
+	if err != nil {
+		return nil, false, err
+	}
+	if e.Account != "" {
+		return nil, false, unauthorized("external account binding already used")
+	}
+	_, err = a.cls.ActiveClientByName(e.Client)
+	if err != nil {
+		return nil, false, unauthorized("client of the external account binding %s: %v", e.Client, err)
+	}
+	key, err := json.Marshal(req.Key)
+	if err != nil {
+		return nil, false, err
+	}
+	acc = &model.ACMEAccount{
+		ID:      id,
+		Key:     string(key),
+		Status:  model.ACMEStatusValid,
+		Contact: nar.Contact,
+		Client:  e.Client,
+		EAB:     e.KID,
+		Created: time.Now(),
+		Orders:  make([]string, 0),
+	}
+	err = a.stg.StoreACMEAccount(*acc)
+	if err != nil {
+		return nil, false, err
+	}
+	e.Account = id
+	err = a.stg.StoreACMEEAB(*e)
+	if err != nil {
+		return nil, false, err
+	}
+	logger.Infof("acme account %s created for client %s", acc.ID, acc.Client)
+	return acc, true, nil
+}
+
+// UpdateAccount updating the contacts or deactivating the account, an empty payload only returns the account
+func (a *ACME) UpdateAccount(req Request, id string) (*model.ACMEAccount, error) {
+	acc, err := a.account(req, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Payload) == 0 {
+		return acc, nil
+	}
+	var uar updateAccountRequest
+	err = json.Unmarshal(req.Payload, &uar)
+	if err != nil {
+		return nil, malformed("can't parse account update request: %v", err)
+	}
+	switch uar.Status {
+	case "":
+	case model.ACMEStatusDeactivated:
+		acc.Status = model.ACMEStatusDeactivated
+		logger.Infof("acme account %s of client %s deactivated", acc.ID, acc.Client)
+	default:
+		return nil, malformed("account status can't be changed to %s", uar.Status)
+	}
+	if uar.Contact != nil {
+		acc.Contact = uar.Contact
+	}
+	err = a.stg.StoreACMEAccount(*acc)
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// AccountOrders the urls of the actual orders of the account
+func (a *ACME) AccountOrders(req Request, id string) (*OrderList, error) {
+	acc, err := a.account(req, id)
+	if err != nil {
+		return nil, err
+	}
+	ol := OrderList{
+		Orders: make([]string, 0),
+	}
+	for _, oid := range acc.Orders {
+		if _, ok := a.stg.GetACMEOrder(oid); ok {
+			ol.Orders = append(ol.Orders, a.OrderURL(oid))
+		}
+	}
+	return &ol, nil
+}
+
+// account the account of the request, which must be the account with the id
+func (a *ACME) account(req Request, id string) (*model.ACMEAccount, error) {
+	if req.Account == nil || req.Account.ID != id {
+		return nil, unauthorized("request not signed by account %s", id)
+	}
+	return req.Account, nil
+}
+
+// addOrder adding the order to the account, dropping all expired orders
+func (a *ACME) addOrder(acc model.ACMEAccount, id string) error {
+	acc.Orders = slices.DeleteFunc(acc.Orders, func(oid string) bool {
+		_, ok := a.stg.GetACMEOrder(oid)
+		return !ok
+	})
+	acc.Orders = append(acc.Orders, id)
+	return a.stg.StoreACMEAccount(acc)
+}
@@ -0,0 +1,127 @@
+package acme
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/willie68/micro-vault/internal/config"
+	"github.com/willie68/micro-vault/internal/interfaces"
+	"github.com/willie68/micro-vault/internal/logging"
+	"github.com/willie68/micro-vault/internal/services/clients"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+)
+
+const (
+	// Path the path of the acme server, relative to the service url
+	Path = "/api/v1/acme"
+
+	// ChallengeHTTP01 the http-01 challenge type
+	ChallengeHTTP01 = "http-01"
+	// ChallengeClientJWT01 the challenge type validated with the jwt of the bound client
+	ChallengeClientJWT01 = "client-jwt-01"
+
+	defaultHTTPPort = 80
+	nonceValid      = time.Hour
+	orderValid      = 24 * time.Hour
+	validateTimeout = 10 * time.Second
+)
+
+var logger = logging.New().WithName("svcACME")
+
+// ACME the acme server (RFC 8555) issuing certificates of the ca for the clients
+type ACME struct {
+	stg      interfaces.Storage
+	cls      clients.Clients
+	crt      keyman.CAService
+	cfg      config.ACME
+	base     string
+	httpPort int
+	hcl      *http.Client
+}
+
+// NewACME creates a new acme service
+func NewACME() (ACME, error) {
+	cfg := do.MustInvoke[config.Config](nil)
+	a := ACME{
+		stg:  do.MustInvoke[interfaces.Storage](nil),
+		cls:  do.MustInvoke[clients.Clients](nil),
+		crt:  do.MustInvoke[keyman.CAService](nil),
+		cfg:  cfg.Service.ACME,
+		base: strings.TrimSuffix(cfg.Service.HTTP.ServiceURL, "/") + Path,
+	}
+	err := a.Init()
+	if err != nil {
+		return ACME{}, err
+	}
+	do.ProvideValue[ACME](nil, a)
+	return a, nil
+}
+
+// Init initialize the acme service
+func (a *ACME) Init() error {
+	a.httpPort = a.cfg.HTTPPort
+	if a.httpPort == 0 {
+		a.httpPort = defaultHTTPPort
+	}
+	a.hcl = &http.Client{
+		Timeout: validateTimeout,
+		// the validation must not follow redirects to other hosts
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return nil
+}
+
+// URL the absolute url of the path of the acme server
+func (a *ACME) URL(p string) string {
+	return a.base + p
+}
+
+// Directory the directory object of the acme server
+func (a *ACME) Directory() Directory {
+	return Directory{
+		NewNonce:   a.URL("/new-nonce"),
+		NewAccount: a.URL("/new-account"),
+		NewOrder:   a.URL("/new-order"),
+		RevokeCert: a.URL("/revoke-cert"),
+		Meta: DirectoryMeta{
+			ExternalAccountRequired: true,
+		},
+	}
+}
+
+// NewNonce creating a new replay nonce, the nonces are stored, so every node can check them
+func (a *ACME) NewNonce() (string, error) {
+	n, err := randToken(16)
+	if err != nil {
+		return "", err
+	}
+	err = a.stg.StoreACMENonce(n, time.Now().Add(nonceValid))
+	if err != nil {
+		return "", err
+	}
+	return n, nil
+}
+
+// useNonce checking and consuming the nonce, every nonce can only be used once
+func (a *ACME) useNonce(n string) bool {
+	if n == "" {
+		return false
+	}
+	return a.stg.UseACMENonce(n)
+}
+
+// randToken a random base64url encoded token with l bytes of entropy
+func randToken(l int) (string, error) {
+	b := make([]byte, l)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,579 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/config"
+	"github.com/willie68/micro-vault/internal/interfaces"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/services/clients"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+	"github.com/willie68/micro-vault/internal/services/playbook"
+	"github.com/willie68/micro-vault/internal/services/storage"
+)
+
+const (
+	clAccess = "12345678"
+	clSecret = "e7d767cd1432145820669be6a60a912e"
+)
+
+var (
+	stg interfaces.Storage
+	cls clients.Clients
+	am  ACME
+)
+
+func init() {
+	var err error
+	stg, err = storage.NewMemory()
+	if err != nil {
+		panic(1)
+	}
+	pb := playbook.NewPlaybookFile("../../../testdata/playbook.json")
+	err = pb.Load()
+	if err != nil {
+		panic(1)
+	}
+	err = pb.Play()
+	if err != nil {
+		panic(1)
+	}
+	c := config.Config{
+		Service: config.Service{
+			HTTP: config.HTTP{
+				ServiceURL: "https://localhost:9543",
+			},
+			Rootuser:   "root",
+			Rootpwd:    "yxcvb",
+			PrivateKey: "../../../testdata/private.pem",
+			CACert: config.CACert{
+				Certificate: "../../../testdata/crt.pem",
+			},
+		},
+	}
+	c.Provide()
+	_, err = keyman.NewKeyman()
+	if err != nil {
+		panic(1)
+	}
+	_, err = keyman.NewCAService()
+	if err != nil {
+		panic(1)
+	}
+	cls, err = clients.NewClients()
+	if err != nil {
+		panic(1)
+	}
+	am, err = NewACME()
+	if err != nil {
+		panic(1)
+	}
+}
+
+// testAccount an acme client account for signing the requests
+type testAccount struct {
+	key *ecdsa.PrivateKey
+	pub jwk.Key
+	id  string
+}
+
+func newTestAccount(ast *assert.Assertions) *testAccount {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+	pub, err := jwk.FromRaw(key.Public())
+	ast.Nil(err)
+	return &testAccount{
+		key: key,
+		pub: pub,
+	}
+}
+
+// sign creating the jws of the request to the path, signed with the jwk or with the kid of the account
+func (ta *testAccount) sign(ast *assert.Assertions, a *ACME, path string, payload []byte) []byte {
+	n, err := a.NewNonce()
+	ast.Nil(err)
+	hdr := jws.NewHeaders()
+	ast.Nil(hdr.Set("nonce", n))
+	ast.Nil(hdr.Set("url", a.URL(path)))
+	if ta.id == "" {
+		ast.Nil(hdr.Set(jws.JWKKey, ta.pub))
+	} else {
+		ast.Nil(hdr.Set(jws.KeyIDKey, a.AccountURL(ta.id)))
+	}
+	b, err := jws.Sign(payload, jws.WithJSON(), jws.WithKey(jwa.ES256, ta.key, jws.WithProtectedHeaders(hdr)))
+	ast.Nil(err)
+	return b
+}
+
+func (ta *testAccount) request(ast *assert.Assertions, a *ACME, path string, payload any) *Request {
+	var p []byte
+	if payload != nil {
+		var err error
+		p, err = json.Marshal(payload)
+		ast.Nil(err)
+	}
+	req, err := a.Verify(ta.sign(ast, a, path, p), path)
+	ast.Nil(err)
+	return req
+}
+
+// eab the external account binding of the account key, signed with the eab credentials
+func (ta *testAccount) eab(ast *assert.Assertions, a *ACME, e model.ACMEEAB) json.RawMessage {
+	mac, err := base64.RawURLEncoding.DecodeString(e.Key)
+	ast.Nil(err)
+	pj, err := json.Marshal(ta.pub)
+	ast.Nil(err)
+	hdr := jws.NewHeaders()
+	ast.Nil(hdr.Set(jws.KeyIDKey, e.KID))
+	ast.Nil(hdr.Set("url", a.URL("/new-account")))
+	b, err := jws.Sign(pj, jws.WithJSON(), jws.WithKey(jwa.HS256, mac, jws.WithProtectedHeaders(hdr)))
+	ast.Nil(err)
+	return b
+}
+
+func (ta *testAccount) register(ast *assert.Assertions, a *ACME) *model.ACMEAccount {
+	tk, _, _, err := cls.Login(clAccess, clSecret)
+	ast.Nil(err)
+	e, err := a.NewEAB(tk)
+	ast.Nil(err)
+	req := ta.request(ast, a, "/new-account", map[string]any{
+		"termsOfServiceAgreed":   true,
+		"externalAccountBinding": ta.eab(ast, a, *e),
+	})
+	acc, created, err := a.NewAccount(*req)
+	ast.Nil(err)
+	ast.True(created)
+	ta.id = acc.ID
+	return acc
+}
+
+func createCSR(ast *assert.Assertions, dnss []string, ips []net.IP) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+	tmp := x509.CertificateRequest{
+		DNSNames:    dnss,
+		IPAddresses: ips,
+	}
+	if len(dnss) > 0 {
+		tmp.Subject = pkix.Name{CommonName: dnss[0]}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &tmp, key)
+	ast.Nil(err)
+	return base64.RawURLEncoding.EncodeToString(der)
+}
+
+func problemType(err error) string {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p.Type
+	}
+	return ""
+}
+
+func TestDirectory(t *testing.T) {
+	ast := assert.New(t)
+
+	d := am.Directory()
+	ast.Equal("https://localhost:9543/api/v1/acme/new-nonce", d.NewNonce)
+	ast.Equal("https://localhost:9543/api/v1/acme/new-account", d.NewAccount)
+	ast.True(d.Meta.ExternalAccountRequired)
+}
+
+func TestVerify(t *testing.T) {
+	ast := assert.New(t)
+	ta := newTestAccount(ast)
+
+	b := ta.sign(ast, &am, "/new-account", []byte("{}"))
+	req, err := am.Verify(b, "/new-account")
+	ast.Nil(err)
+	ast.Nil(req.Account)
+	ast.Equal("{}", string(req.Payload))
+
+	// the nonce is already used
+	_, err = am.Verify(b, "/new-account")
+	ast.Equal(ErrBadNonce, problemType(err))
+
+	b = ta.sign(ast, &am, "/new-account", []byte("{}"))
+	_, err = am.Verify(b, "/new-order")
+	ast.Equal(ErrUnauthorized, problemType(err))
+
+	ta.id = "unknown"
+	b = ta.sign(ast, &am, "/new-order", []byte("{}"))
+	_, err = am.Verify(b, "/new-order")
+	ast.Equal(ErrAccountDoesNotExist, problemType(err))
+
+	_, err = am.Verify([]byte("no jws"), "/new-order")
+	ast.Equal(ErrMalformed, problemType(err))
+}
+
+func TestNewAccount(t *testing.T) {
+	ast := assert.New(t)
+	ta := newTestAccount(ast)
+
+	req := ta.request(ast, &am, "/new-account", map[string]any{"termsOfServiceAgreed": true})
+	_, _, err := am.NewAccount(*req)
+	ast.Equal(ErrExternalAccountRequired, problemType(err))
+
+	req = ta.request(ast, &am, "/new-account", map[string]any{"onlyReturnExisting": true})
+	_, _, err = am.NewAccount(*req)
+	ast.Equal(ErrAccountDoesNotExist, problemType(err))
+
+	_, err = am.NewEAB("no token")
+	ast.NotNil(err)
+
+	tk, _, _, err := cls.Login(clAccess, clSecret)
+	ast.Nil(err)
+	e, err := am.NewEAB(tk)
+	ast.Nil(err)
+	ast.Equal("tester1", e.Client)
+
+	// eab signed with the wrong key
+	wrong := *e
+	wrong.Key = base64.RawURLEncoding.EncodeToString([]byte("wrong key"))
+	req = ta.request(ast, &am, "/new-account", map[string]any{"externalAccountBinding": ta.eab(ast, &am, wrong)})
+	_, _, err = am.NewAccount(*req)
+	ast.Equal(ErrUnauthorized, problemType(err))
+
+	eab := ta.eab(ast, &am, *e)
+	req = ta.request(ast, &am, "/new-account", map[string]any{
+		"contact":                []string{"mailto:info@wk-music.de"},
+		"externalAccountBinding": eab,
+	})
+	acc, created, err := am.NewAccount(*req)
+	ast.Nil(err)
+	ast.True(created)
+	ast.Equal("tester1", acc.Client)
+	ast.Equal(e.KID, acc.EAB)
+	ast.Equal(model.ACMEStatusValid, acc.Status)
+
+	// existing account of the key
+	req = ta.request(ast, &am, "/new-account", map[string]any{"onlyReturnExisting": true})
+	acc2, created, err := am.NewAccount(*req)
+	ast.Nil(err)
+	ast.False(created)
+	ast.Equal(acc.ID, acc2.ID)
+
+	// the eab can only be used once
+	ta2 := newTestAccount(ast)
+	req = ta2.request(ast, &am, "/new-account", map[string]any{"externalAccountBinding": ta2.eab(ast, &am, *e)})
+	_, _, err = am.NewAccount(*req)
+	ast.Equal(ErrUnauthorized, problemType(err))
+
+	// eab of another account key
+	req = ta2.request(ast, &am, "/new-account", map[string]any{"externalAccountBinding": eab})
+	_, _, err = am.NewAccount(*req)
+	ast.Equal(ErrUnauthorized, problemType(err))
+
+	ta.id = acc.ID
+	req = ta.request(ast, &am, "/account/"+acc.ID, nil)
+	acc2, err = am.UpdateAccount(*req, acc.ID)
+	ast.Nil(err)
+	ast.Equal([]string{"mailto:info@wk-music.de"}, acc2.Contact)
+
+	req = ta.request(ast, &am, "/account/"+acc.ID, map[string]any{"status": "deactivated"})
+	acc2, err = am.UpdateAccount(*req, acc.ID)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusDeactivated, acc2.Status)
+
+	b := ta.sign(ast, &am, "/new-order", []byte("{}"))
+	_, err = am.Verify(b, "/new-order")
+	ast.Equal(ErrUnauthorized, problemType(err))
+}
+
+func TestOrderPreAuthorized(t *testing.T) {
+	ast := assert.New(t)
+	ta := newTestAccount(ast)
+	acc := ta.register(ast, &am)
+
+	req := ta.request(ast, &am, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{{Type: "dns", Value: "www.example.com"}},
+	})
+	_, err := am.NewOrder(*req)
+	ast.Equal(ErrRejectedIdentifier, problemType(err))
+
+	req = ta.request(ast, &am, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{{Type: "email", Value: "info@wk-music.de"}},
+	})
+	_, err = am.NewOrder(*req)
+	ast.Equal(ErrUnsupportedIdentifier, problemType(err))
+
+	req = ta.request(ast, &am, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{
+			{Type: "dns", Value: "WKMusicSearch.local"},
+			{Type: "ip", Value: "192.168.178.10"},
+		},
+	})
+	o, err := am.NewOrder(*req)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusReady, o.Status)
+	ast.Equal("wkmusicsearch.local", o.Identifiers[0].Value)
+	ast.Equal(2, len(o.Authorizations))
+	ast.Equal(model.ACMEStatusValid, o.Authorizations[0].Status)
+	ast.Equal(ChallengeClientJWT01, o.Authorizations[0].Challenges[0].Type)
+
+	req = ta.request(ast, &am, "/account/"+acc.ID+"/orders", nil)
+	ol, err := am.AccountOrders(*req, acc.ID)
+	ast.Nil(err)
+	ast.Equal([]string{am.OrderURL(o.ID)}, ol.Orders)
+
+	oo := am.OrderObject(*o)
+	ast.Equal(am.AuthzURL(o.ID, 1), oo.Authorizations[1])
+	ast.Empty(oo.Certificate)
+
+	// the csr must contain all identifiers of the order
+	req = ta.request(ast, &am, "/order/"+o.ID+"/finalize", map[string]any{
+		"csr": createCSR(ast, []string{"wkmusicsearch.local"}, nil),
+	})
+	_, err = am.Finalize(*req, o.ID)
+	ast.Equal(ErrBadCSR, problemType(err))
+
+	req = ta.request(ast, &am, "/order/"+o.ID+"/finalize", map[string]any{
+		"csr": createCSR(ast, []string{"wkmusicsearch.local"}, []net.IP{net.ParseIP("192.168.178.10")}),
+	})
+	o, err = am.Finalize(*req, o.ID)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusValid, o.Status)
+	ast.NotEmpty(o.Certificate)
+	ast.Equal(am.URL("/cert/"+o.ID), am.OrderObject(*o).Certificate)
+
+	req = ta.request(ast, &am, "/cert/"+o.ID, nil)
+	chain, err := am.Certificate(*req, o.ID)
+	ast.Nil(err)
+	p, rest := pem.Decode([]byte(chain))
+	ast.NotNil(p)
+	crt, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+	ast.Equal([]string{"wkmusicsearch.local"}, crt.DNSNames)
+	ast.Equal("wkmusicsearch.local", crt.Subject.CommonName)
+	ast.Nil(crt.CheckSignatureFrom(am.crt.X509Cert()))
+	p, _ = pem.Decode(rest)
+	ast.NotNil(p)
+
+	sc, ok := stg.GetCertificate(o.Certificate)
+	ast.True(ok)
+	ast.Equal("tester1", sc.Client)
+
+	// the order is already finalized
+	req = ta.request(ast, &am, "/order/"+o.ID+"/finalize", map[string]any{
+		"csr": createCSR(ast, []string{"wkmusicsearch.local"}, []net.IP{net.ParseIP("192.168.178.10")}),
+	})
+	_, err = am.Finalize(*req, o.ID)
+	ast.Equal(ErrOrderNotReady, problemType(err))
+
+	// other accounts have no access to the order
+	ta2 := newTestAccount(ast)
+	ta2.register(ast, &am)
+	req = ta2.request(ast, &am, "/order/"+o.ID, nil)
+	_, err = am.Order(*req, o.ID)
+	ast.Equal(ErrUnauthorized, problemType(err))
+
+	reason := 9
+	req = ta.request(ast, &am, "/revoke-cert", map[string]any{
+		"certificate": base64.RawURLEncoding.EncodeToString(crt.Raw),
+		"reason":      &reason,
+	})
+	err = am.RevokeCert(*req)
+	ast.Nil(err)
+	sc, ok = stg.GetCertificate(o.Certificate)
+	ast.True(ok)
+	ast.True(sc.IsRevoked())
+	ast.Equal(9, sc.Reason)
+
+	req = ta.request(ast, &am, "/revoke-cert", map[string]any{
+		"certificate": base64.RawURLEncoding.EncodeToString(crt.Raw),
+	})
+	err = am.RevokeCert(*req)
+	ast.Equal(ErrAlreadyRevoked, problemType(err))
+}
+
+func TestOrderChallenges(t *testing.T) {
+	ast := assert.New(t)
+
+	// allowing the local host for the http-01 validation
+	a, ok := stg.AccessKey("tester1")
+	ast.True(ok)
+	cl, ok := stg.GetClient(a)
+	ast.True(ok)
+	ip := cl.Crt["ip"]
+	cl.Crt["ip"] = []any{"192.168.178.10", "127.0.0.1"}
+	ast.Nil(stg.UpdateClient(*cl))
+	defer func() {
+		cl.Crt["ip"] = ip
+		stg.UpdateClient(*cl)
+	}()
+
+	keyAuths := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tk := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		ka, ok := keyAuths[tk]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, ka)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	ast.Nil(err)
+
+	ac := am
+	ac.cfg.ChallengeRequired = true
+	ac.httpPort, err = strconv.Atoi(u.Port())
+	ast.Nil(err)
+
+	ta := newTestAccount(ast)
+	acc := ta.register(ast, &ac)
+
+	req := ta.request(ast, &ac, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{
+			{Type: "ip", Value: "127.0.0.1"},
+			{Type: "dns", Value: "wkmusicsearch.local"},
+		},
+	})
+	o, err := ac.NewOrder(*req)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusPending, o.Status)
+	az := ac.AuthzObject(*o, 0)
+	ast.Equal(model.ACMEStatusPending, az.Status)
+	ast.Equal(2, len(az.Challenges))
+	ast.Equal(ChallengeHTTP01, az.Challenges[0].Type)
+	ast.Equal(ac.ChallengeURL(o.ID, 0, ChallengeHTTP01), az.Challenges[0].URL)
+
+	req = ta.request(ast, &ac, "/order/"+o.ID+"/finalize", map[string]any{
+		"csr": createCSR(ast, []string{"wkmusicsearch.local"}, []net.IP{net.ParseIP("127.0.0.1")}),
+	})
+	_, err = ac.Finalize(*req, o.ID)
+	ast.Equal(ErrOrderNotReady, problemType(err))
+
+	// http-01 for the ip address
+	tk := az.Challenges[0].Token
+	keyAuths[tk] = tk + "." + acc.ID
+	p := fmt.Sprintf("/chall/%s/0/%s", o.ID, ChallengeHTTP01)
+	req = ta.request(ast, &ac, p, map[string]any{})
+	o, ch, err := ac.Challenge(*req, o.ID, 0, ChallengeHTTP01)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusValid, ch.Status)
+	ast.False(ch.Validated.IsZero())
+	ast.Equal(model.ACMEStatusValid, o.Authorizations[0].Status)
+	ast.Equal(model.ACMEStatusPending, o.Status)
+
+	// client-jwt-01 for the dns name
+	tk1, _, _, err := cls.Login(clAccess, clSecret)
+	ast.Nil(err)
+	p = fmt.Sprintf("/chall/%s/1/%s", o.ID, ChallengeClientJWT01)
+	req = ta.request(ast, &ac, p, map[string]any{"token": tk1})
+	o, ch, err = ac.Challenge(*req, o.ID, 1, ChallengeClientJWT01)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusValid, ch.Status)
+	ast.Equal(model.ACMEStatusReady, o.Status)
+
+	req = ta.request(ast, &ac, "/order/"+o.ID+"/finalize", map[string]any{
+		"csr": createCSR(ast, []string{"wkmusicsearch.local"}, []net.IP{net.ParseIP("127.0.0.1")}),
+	})
+	o, err = ac.Finalize(*req, o.ID)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusValid, o.Status)
+
+	// a wrong key authorization invalidates the order
+	req = ta.request(ast, &ac, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{{Type: "ip", Value: "127.0.0.1"}},
+	})
+	o, err = ac.NewOrder(*req)
+	ast.Nil(err)
+	tk = o.Authorizations[0].Challenges[0].Token
+	keyAuths[tk] = tk + ".wrong"
+	p = fmt.Sprintf("/chall/%s/0/%s", o.ID, ChallengeHTTP01)
+	req = ta.request(ast, &ac, p, map[string]any{})
+	o, ch, err = ac.Challenge(*req, o.ID, 0, ChallengeHTTP01)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusInvalid, ch.Status)
+	ast.Equal(ErrIncorrectResponse, ch.ErrorType)
+	ast.Equal(model.ACMEStatusInvalid, o.Status)
+	ast.NotNil(ac.ChallengeObject(*o, 0, *ch).Error)
+
+	// client-jwt-01 without a valid token
+	req = ta.request(ast, &ac, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{{Type: "dns", Value: "wkmusicsearch.local"}},
+	})
+	o, err = ac.NewOrder(*req)
+	ast.Nil(err)
+	p = fmt.Sprintf("/chall/%s/0/%s", o.ID, ChallengeClientJWT01)
+	req = ta.request(ast, &ac, p, map[string]any{"token": "no token"})
+	o, ch, err = ac.Challenge(*req, o.ID, 0, ChallengeClientJWT01)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusInvalid, ch.Status)
+	ast.Equal(ErrUnauthorized, ch.ErrorType)
+	ast.Equal(model.ACMEStatusInvalid, o.Status)
+}
+
+func TestDisabledClient(t *testing.T) {
+	ast := assert.New(t)
+	ta := newTestAccount(ast)
+	ta.register(ast, &am)
+
+	req := ta.request(ast, &am, "/new-order", map[string]any{
+		"identifiers": []model.ACMEIdentifier{{Type: "dns", Value: "wkmusicsearch.local"}},
+	})
+	o, err := am.NewOrder(*req)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusReady, o.Status)
+
+	// an eab created before the client was disabled
+	tk, _, _, err := cls.Login(clAccess, clSecret)
+	ast.Nil(err)
+	e, err := am.NewEAB(tk)
+	ast.Nil(err)
+
+	a, ok := stg.AccessKey("tester1")
+	ast.True(ok)
+	cl, ok := stg.GetClient(a)
+	ast.True(ok)
+	cl.Disabled = true
+	ast.Nil(stg.UpdateClient(*cl))
+	defer func() {
+		cl.Disabled = false
+		stg.UpdateClient(*cl)
+	}()
+
+	// the accounts of a disabled client can't be used
+	for _, p := range []string{"/new-order", "/order/" + o.ID + "/finalize"} {
+		_, err = am.Verify(ta.sign(ast, &am, p, []byte("{}")), p)
+		ast.Equal(ErrUnauthorized, problemType(err))
+	}
+
+	// and no new accounts can be registered
+	ta2 := newTestAccount(ast)
+	req = ta2.request(ast, &am, "/new-account", map[string]any{"externalAccountBinding": ta2.eab(ast, &am, *e)})
+	_, _, err = am.NewAccount(*req)
+	ast.Equal(ErrUnauthorized, problemType(err))
+
+	// enabled again, the order can be finalized
+	cl.Disabled = false
+	ast.Nil(stg.UpdateClient(*cl))
+	req = ta.request(ast, &am, "/order/"+o.ID+"/finalize", map[string]any{
+		"csr": createCSR(ast, []string{"wkmusicsearch.local"}, nil),
+	})
+	o, err = am.Finalize(*req, o.ID)
+	ast.Nil(err)
+	ast.Equal(model.ACMEStatusValid, o.Status)
+}
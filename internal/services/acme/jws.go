@@ -0,0 +1,153 @@
+package acme
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/willie68/micro-vault/internal/model"
+)
+
+// Request a verified acme request
+type Request struct {
+	Payload []byte
+	// Key the public key of the signer, given as jwk or as key of the account
+	Key jwk.Key
+	// Account the account of the signer, nil if the request is signed with a jwk
+	Account *model.ACMEAccount
+}
+
+// Verify verifying the jws of an acme request to the path of the acme server,
+// the nonce of the request is consumed
+func (a *ACME) Verify(body []byte, path string) (*Request, error) {
+	msg, err := jws.Parse(body)
+	if err != nil {
+		return nil, malformed("can't parse jws: %v", err)
+	}
+	sigs := msg.Signatures()
+	if len(sigs) != 1 {
+		return nil, malformed("jws must have exactly one signature")
+	}
+	hdr := sigs[0].ProtectedHeaders()
+	alg := hdr.Algorithm()
+	if alg == "" || alg == jwa.NoSignature || strings.HasPrefix(alg.String(), "HS") {
+		return nil, problem(ErrBadSignatureAlgorithm, http.StatusBadRequest, "signature algorithm not supported: %s", alg)
+	}
+	if n, _ := hdr.Get("nonce"); !a.useNonce(headerString(n)) {
+		return nil, problem(ErrBadNonce, http.StatusBadRequest, "nonce unknown or already used")
+	}
+	if u, _ := hdr.Get("url"); headerString(u) != a.URL(path) {
+		return nil, unauthorized("url of the request doesn't match: %v", u)
+	}
+
+	req := Request{}
+	switch {
+	case hdr.JWK() != nil && hdr.KeyID() != "":
+		return nil, malformed("jws must contain either jwk or kid")
+	case hdr.JWK() != nil:
+		req.Key = hdr.JWK()
+	case hdr.KeyID() != "":
+		id, ok := strings.CutPrefix(hdr.KeyID(), a.AccountURL(""))
+		if !ok {
+			return nil, problem(ErrAccountDoesNotExist, http.StatusBadRequest, "unknown kid: %s", hdr.KeyID())
+		}
+		acc, ok := a.stg.GetACMEAccount(id)
+		if !ok {
+			return nil, problem(ErrAccountDoesNotExist, http.StatusBadRequest, "unknown account: %s", id)
+		}
+		if acc.Status != model.ACMEStatusValid {
+			return nil, unauthorized("account is %s", acc.Status)
+		}
+		// a disabled or expired client can't use its accounts
+		_, err = a.cls.ActiveClientByName(acc.Client)
+		if err != nil {
+			return nil, unauthorized("client of the account %s: %v", acc.Client, err)
+		}
+		req.Key, err = jwk.ParseKey([]byte(acc.Key))
+		if err != nil {
+			return nil, err
+		}
+		req.Account = acc
+	default:
+		return nil, malformed("jws must contain either jwk or kid")
+	}
+
+	var raw any
+	err = req.Key.Raw(&raw)
+	if err != nil {
+		return nil, malformed("invalid jwk: %v", err)
+	}
+	req.Payload, err = jws.Verify(body, jws.WithKey(alg, raw))
+	if err != nil {
+		return nil, unauthorized("jws signature not valid: %v", err)
+	}
+	return &req, nil
+}
+
+// verifyEAB verifying the external account binding of a new account (RFC 8555 7.3.4),
+// the binding must be signed with the mac key of the eab credentials and contain the account key
+func (a *ACME) verifyEAB(eab json.RawMessage, key jwk.Key) (*model.ACMEEAB, error) {
+	msg, err := jws.Parse(eab)
+	if err != nil {
+		return nil, malformed("can't parse external account binding: %v", err)
+	}
+	sigs := msg.Signatures()
+	if len(sigs) != 1 {
+		return nil, malformed("external account binding must have exactly one signature")
+	}
+	hdr := sigs[0].ProtectedHeaders()
+	alg := hdr.Algorithm()
+	if !strings.HasPrefix(alg.String(), "HS") {
+		return nil, problem(ErrBadSignatureAlgorithm, http.StatusBadRequest, "external account binding must be signed with a mac algorithm: %s", alg)
+	}
+	if u, _ := hdr.Get("url"); headerString(u) != a.URL("/new-account") {
+		return nil, unauthorized("url of the external account binding doesn't match: %v", u)
+	}
+	e, ok := a.stg.GetACMEEAB(hdr.KeyID())
+	if !ok {
+		return nil, unauthorized("unknown external account binding: %s", hdr.KeyID())
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(e.Key)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := jws.Verify(eab, jws.WithKey(alg, mac))
+	if err != nil {
+		return nil, unauthorized("external account binding signature not valid: %v", err)
+	}
+	bk, err := jwk.ParseKey(payload)
+	if err != nil {
+		return nil, malformed("external account binding without valid jwk: %v", err)
+	}
+	bt, err := thumbprint(bk)
+	if err != nil {
+		return nil, err
+	}
+	kt, err := thumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+	if bt != kt {
+		return nil, unauthorized("external account binding doesn't match the account key")
+	}
+	return e, nil
+}
+
+// thumbprint the base64url encoded sha256 thumbprint of the key (RFC 7638)
+func thumbprint(k jwk.Key) (string, error) {
+	tp, err := k.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tp), nil
+}
+
+func headerString(v any) string {
+	s, _ := v.(string)
+	return s
+}
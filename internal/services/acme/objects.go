@@ -0,0 +1,151 @@
+package acme
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+)
+
+// Directory the directory object of the acme server
+type Directory struct {
+	NewNonce   string        `json:"newNonce"`
+	NewAccount string        `json:"newAccount"`
+	NewOrder   string        `json:"newOrder"`
+	RevokeCert string        `json:"revokeCert"`
+	Meta       DirectoryMeta `json:"meta"`
+}
+
+// DirectoryMeta the meta data of the directory
+type DirectoryMeta struct {
+	ExternalAccountRequired bool `json:"externalAccountRequired"`
+}
+
+// Account the account object
+type Account struct {
+	Status  string   `json:"status"`
+	Contact []string `json:"contact,omitempty"`
+	Orders  string   `json:"orders"`
+}
+
+// OrderList the list of the orders of an account
+type OrderList struct {
+	Orders []string `json:"orders"`
+}
+
+// Order the order object
+type Order struct {
+	Status         string                 `json:"status"`
+	Expires        string                 `json:"expires,omitempty"`
+	Identifiers    []model.ACMEIdentifier `json:"identifiers"`
+	Profile        string                 `json:"profile,omitempty"`
+	Authorizations []string               `json:"authorizations"`
+	Finalize       string                 `json:"finalize"`
+	Certificate    string                 `json:"certificate,omitempty"`
+}
+
+// Authorization the authorization object
+type Authorization struct {
+	Identifier model.ACMEIdentifier `json:"identifier"`
+	Status     string               `json:"status"`
+	Expires    string               `json:"expires,omitempty"`
+	Challenges []Challenge          `json:"challenges"`
+}
+
+// Challenge the challenge object
+type Challenge struct {
+	Type      string   `json:"type"`
+	URL       string   `json:"url"`
+	Status    string   `json:"status"`
+	Token     string   `json:"token"`
+	Validated string   `json:"validated,omitempty"`
+	Error     *Problem `json:"error,omitempty"`
+}
+
+// AccountURL the url of the account
+func (a *ACME) AccountURL(id string) string {
+	return a.URL("/account/" + id)
+}
+
+// OrderURL the url of the order
+func (a *ACME) OrderURL(id string) string {
+	return a.URL("/order/" + id)
+}
+
+// AuthzURL the url of the authorization of the order
+func (a *ACME) AuthzURL(id string, idx int) string {
+	return a.URL(fmt.Sprintf("/authz/%s/%d", id, idx))
+}
+
+// ChallengeURL the url of the challenge of an authorization of the order
+func (a *ACME) ChallengeURL(id string, idx int, typ string) string {
+	return a.URL(fmt.Sprintf("/chall/%s/%d/%s", id, idx, typ))
+}
+
+// AccountObject converting the account into the acme account object
+func (a *ACME) AccountObject(acc model.ACMEAccount) Account {
+	return Account{
+		Status:  acc.Status,
+		Contact: acc.Contact,
+		Orders:  a.AccountURL(acc.ID) + "/orders",
+	}
+}
+
+// OrderObject converting the order into the acme order object
+func (a *ACME) OrderObject(o model.ACMEOrder) Order {
+	ao := Order{
+		Status:         o.Status,
+		Expires:        rfc3339(o.Expires),
+		Identifiers:    o.Identifiers,
+		Profile:        o.Profile,
+		Authorizations: make([]string, len(o.Authorizations)),
+		Finalize:       a.OrderURL(o.ID) + "/finalize",
+	}
+	for x := range o.Authorizations {
+		ao.Authorizations[x] = a.AuthzURL(o.ID, x)
+	}
+	if o.Certificate != "" {
+		ao.Certificate = a.URL("/cert/" + o.ID)
+	}
+	return ao
+}
+
+// AuthzObject converting the authorization of the order into the acme authorization object
+func (a *ACME) AuthzObject(o model.ACMEOrder, idx int) Authorization {
+	az := o.Authorizations[idx]
+	ao := Authorization{
+		Identifier: az.Identifier,
+		Status:     az.Status,
+		Expires:    rfc3339(o.Expires),
+		Challenges: make([]Challenge, len(az.Challenges)),
+	}
+	for x, ch := range az.Challenges {
+		ao.Challenges[x] = a.ChallengeObject(o, idx, ch)
+	}
+	return ao
+}
+
+// ChallengeObject converting the challenge into the acme challenge object
+func (a *ACME) ChallengeObject(o model.ACMEOrder, idx int, ch model.ACMEChallenge) Challenge {
+	c := Challenge{
+		Type:      ch.Type,
+		URL:       a.ChallengeURL(o.ID, idx, ch.Type),
+		Status:    ch.Status,
+		Token:     ch.Token,
+		Validated: rfc3339(ch.Validated),
+	}
+	if ch.ErrorType != "" {
+		c.Error = &Problem{
+			Type:   ch.ErrorType,
+			Detail: ch.Error,
+		}
+	}
+	return c
+}
+
+func rfc3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,434 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/services/clients"
+)
+
+const (
+	identifierDNS = "dns"
+	identifierIP  = "ip"
+	// maxHTTP01Response the max size of the response of a http-01 validation
+	maxHTTP01Response = 1024
+)
+
+// newOrderRequest the payload of a new order request
+type newOrderRequest struct {
+	Identifiers []model.ACMEIdentifier `json:"identifiers"`
+	NotBefore   string                 `json:"notBefore"`
+	NotAfter    string                 `json:"notAfter"`
+	Profile     string                 `json:"profile,omitempty"` // certificate profile, empty for the default profile of the client
+}
+
+// clientJWTRequest the payload to validate a client-jwt-01 challenge
+type clientJWTRequest struct {
+	Token string `json:"token"`
+}
+
+// finalizeRequest the payload of a finalize request
+type finalizeRequest struct {
+	CSR string `json:"csr"`
+}
+
+// revokeRequest the payload of a certificate revocation request
+type revokeRequest struct {
+	Certificate string `json:"certificate"`
+	Reason      *int   `json:"reason"`
+}
+
+// NewOrder creating a new order for the account of the request, the identifiers must be part
+// of the certificate template of the bound client
+func (a *ACME) NewOrder(req Request) (*model.ACMEOrder, error) {
+	if req.Account == nil {
+		return nil, malformed("new order request must be signed by an account")
+	}
+	var nor newOrderRequest
+	err := json.Unmarshal(req.Payload, &nor)
+	if err != nil {
+		return nil, malformed("can't parse new order request: %v", err)
+	}
+	if len(nor.Identifiers) == 0 {
+		return nil, malformed("order without identifiers")
+	}
+	cl, err := a.cls.ActiveClientByName(req.Account.Client)
+	if err != nil {
+		return nil, unauthorized("client of the account %s: %v", req.Account.Client, err)
+	}
+	if nor.Profile != "" && !slices.Contains(clients.ClientProfiles(*cl), nor.Profile) {
+		return nil, malformed("profile not allowed for client: %s", nor.Profile)
+	}
+	dnss, ips, err := clients.CertIdentifiers(*cl)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]model.ACMEIdentifier, 0)
+	for _, id := range nor.Identifiers {
+		id, err := checkIdentifier(id, dnss, ips)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	now := time.Now()
+	o := model.ACMEOrder{
+		ID:             xid.New().String(),
+		Account:        req.Account.ID,
+		Client:         req.Account.Client,
+		Status:         model.ACMEStatusReady,
+		Created:        now,
+		Expires:        now.Add(orderValid),
+		Identifiers:    ids,
+		Profile:        nor.Profile,
+		Authorizations: make([]model.ACMEAuthorization, len(ids)),
+	}
+	for x, id := range ids {
+		az, err := a.newAuthorization(id, now)
+		if err != nil {
+			return nil, err
+		}
+		if az.Status != model.ACMEStatusValid {
+			o.Status = model.ACMEStatusPending
+		}
+		o.Authorizations[x] = *az
+	}
+	err = a.stg.StoreACMEOrder(o)
+	if err != nil {
+		return nil, err
+	}
+	err = a.addOrder(*req.Account, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("acme order %s created for client %s: %v", o.ID, o.Client, ids)
+	return &o, nil
+}
+
+// checkIdentifier checking the identifier against the allowed dns names and ip addresses,
+// returning the normalized identifier
+func checkIdentifier(id model.ACMEIdentifier, dnss []string, ips []net.IP) (model.ACMEIdentifier, error) {
+	switch id.Type {
+	case identifierDNS:
+		id.Value = strings.ToLower(strings.TrimSuffix(id.Value, "."))
+		if !slices.Contains(dnss, id.Value) {
+			return id, problem(ErrRejectedIdentifier, http.StatusBadRequest, "dns name not allowed for client: %s", id.Value)
+		}
+	case identifierIP:
+		ip := net.ParseIP(id.Value)
+		if ip == nil {
+			return id, malformed("invalid ip address: %s", id.Value)
+		}
+		id.Value = ip.String()
+		if !slices.ContainsFunc(ips, ip.Equal) {
+			return id, problem(ErrRejectedIdentifier, http.StatusBadRequest, "ip address not allowed for client: %s", id.Value)
+		}
+	default:
+		return id, problem(ErrUnsupportedIdentifier, http.StatusBadRequest, "identifier type not supported: %s", id.Type)
+	}
+	return id, nil
+}
+
+// newAuthorization creating the authorization of the identifier. Without required challenges
+// the authorization is already valid, because the account is bound to the client
+func (a *ACME) newAuthorization(id model.ACMEIdentifier, now time.Time) (*model.ACMEAuthorization, error) {
+	if !a.cfg.ChallengeRequired {
+		return &model.ACMEAuthorization{
+			Identifier: id,
+			Status:     model.ACMEStatusValid,
+			Challenges: []model.ACMEChallenge{
+				{Type: ChallengeClientJWT01, Status: model.ACMEStatusValid, Validated: now},
+			},
+		}, nil
+	}
+	az := model.ACMEAuthorization{
+		Identifier: id,
+		Status:     model.ACMEStatusPending,
+		Challenges: make([]model.ACMEChallenge, 0),
+	}
+	for _, typ := range []string{ChallengeHTTP01, ChallengeClientJWT01} {
+		tk, err := randToken(32)
+		if err != nil {
+			return nil, err
+		}
+		az.Challenges = append(az.Challenges, model.ACMEChallenge{
+			Type:   typ,
+			Token:  tk,
+			Status: model.ACMEStatusPending,
+		})
+	}
+	return &az, nil
+}
+
+// Order getting the order with the id of the account of the request
+func (a *ACME) Order(req Request, id string) (*model.ACMEOrder, error) {
+	if req.Account == nil {
+		return nil, malformed("request must be signed by an account")
+	}
+	o, ok := a.stg.GetACMEOrder(id)
+	if !ok {
+		return nil, notFound("order", id)
+	}
+	if o.Account != req.Account.ID {
+		return nil, unauthorized("order %s doesn't belong to the account", id)
+	}
+	return o, nil
+}
+
+// Authorization getting the order of the authorization with the index
+func (a *ACME) Authorization(req Request, id string, idx int) (*model.ACMEOrder, error) {
+	o, err := a.Order(req, id)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 || idx >= len(o.Authorizations) {
+		return nil, notFound("authorization", fmt.Sprintf("%s/%d", id, idx))
+	}
+	return o, nil
+}
+
+// Challenge answering a challenge of the authorization with the index. A non empty payload
+// triggers the validation of a pending challenge, returning the updated order and the challenge
+func (a *ACME) Challenge(req Request, id string, idx int, typ string) (*model.ACMEOrder, *model.ACMEChallenge, error) {
+	o, err := a.Authorization(req, id, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	az := &o.Authorizations[idx]
+	ci := slices.IndexFunc(az.Challenges, func(ch model.ACMEChallenge) bool {
+		return ch.Type == typ
+	})
+	if ci < 0 {
+		return nil, nil, notFound("challenge", typ)
+	}
+	ch := &az.Challenges[ci]
+	if len(req.Payload) == 0 || az.Status != model.ACMEStatusPending || ch.Status != model.ACMEStatusPending {
+		return o, ch, nil
+	}
+
+	var p *Problem
+	switch ch.Type {
+	case ChallengeHTTP01:
+		p = a.validateHTTP01(az.Identifier, ch.Token+"."+req.Account.ID)
+	case ChallengeClientJWT01:
+		p = a.validateClientJWT(req.Payload, o.Client)
+	}
+	if p != nil {
+		logger.Infof("acme challenge %s of order %s failed: %s", ch.Type, o.ID, p.Detail)
+		ch.Status = model.ACMEStatusInvalid
+		ch.ErrorType = p.Type
+		ch.Error = p.Detail
+		az.Status = model.ACMEStatusInvalid
+		o.Status = model.ACMEStatusInvalid
+	} else {
+		ch.Status = model.ACMEStatusValid
+		ch.Validated = time.Now()
+		az.Status = model.ACMEStatusValid
+		if !slices.ContainsFunc(o.Authorizations, func(az model.ACMEAuthorization) bool {
+			return az.Status != model.ACMEStatusValid
+		}) {
+			o.Status = model.ACMEStatusReady
+		}
+	}
+	err = a.stg.StoreACMEOrder(*o)
+	if err != nil {
+		return nil, nil, err
+	}
+	return o, ch, nil
+}
+
+// validateHTTP01 fetching the key authorization from the identifier (RFC 8555 8.3)
+func (a *ACME) validateHTTP01(id model.ACMEIdentifier, keyAuth string) *Problem {
+	tk, _, _ := strings.Cut(keyAuth, ".")
+	u := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", net.JoinHostPort(id.Value, strconv.Itoa(a.httpPort)), tk)
+	res, err := a.hcl.Get(u)
+	if err != nil {
+		return problem(ErrConnection, http.StatusBadRequest, "can't fetch %s: %v", u, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return problem(ErrIncorrectResponse, http.StatusBadRequest, "fetching %s: unexpected status %d", u, res.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxHTTP01Response))
+	if err != nil {
+		return problem(ErrConnection, http.StatusBadRequest, "can't read %s: %v", u, err)
+	}
+	if strings.TrimSpace(string(b)) != keyAuth {
+		return problem(ErrIncorrectResponse, http.StatusBadRequest, "key authorization of %s doesn't match", u)
+	}
+	return nil
+}
+
+// validateClientJWT the payload must contain a valid token of the client of the order
+func (a *ACME) validateClientJWT(payload []byte, client string) *Problem {
+	var cjr clientJWTRequest
+	err := json.Unmarshal(payload, &cjr)
+	if err != nil || cjr.Token == "" {
+		return problem(ErrIncorrectResponse, http.StatusBadRequest, "payload must contain the token of the client")
+	}
+	cl, err := a.cls.TokenClient(cjr.Token)
+	if err != nil {
+		return problem(ErrUnauthorized, http.StatusForbidden, "token not valid: %v", err)
+	}
+	if cl.Name != client {
+		return problem(ErrUnauthorized, http.StatusForbidden, "token of wrong client: %s", cl.Name)
+	}
+	return nil
+}
+
+// Finalize finalizing a ready order with the certificate request, the certificate is issued immediately
+func (a *ACME) Finalize(req Request, id string) (*model.ACMEOrder, error) {
+	o, err := a.Order(req, id)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != model.ACMEStatusReady {
+		return nil, problem(ErrOrderNotReady, http.StatusForbidden, "order is %s", o.Status)
+	}
+	var fr finalizeRequest
+	err = json.Unmarshal(req.Payload, &fr)
+	if err != nil {
+		return nil, malformed("can't parse finalize request: %v", err)
+	}
+	der, err := base64.RawURLEncoding.DecodeString(fr.CSR)
+	if err != nil {
+		return nil, problem(ErrBadCSR, http.StatusBadRequest, "can't decode csr: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, problem(ErrBadCSR, http.StatusBadRequest, "can't parse csr: %v", err)
+	}
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, problem(ErrBadCSR, http.StatusBadRequest, "csr signature not valid: %v", err)
+	}
+	err = checkCSR(*o, *csr)
+	if err != nil {
+		return nil, err
+	}
+
+	cd, _, err := a.cls.IssueCertificate(o.Client, o.Profile, *csr)
+	if err != nil {
+		return nil, problem(ErrServerInternal, http.StatusInternalServerError, "can't issue certificate: %v", err)
+	}
+	crt, err := x509.ParseCertificate(cd)
+	if err != nil {
+		return nil, err
+	}
+	o.Certificate = model.Serial2ID(crt.SerialNumber)
+	o.Status = model.ACMEStatusValid
+	err = a.stg.StoreACMEOrder(*o)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("acme order %s finalized, certificate %s issued for client %s", o.ID, o.Certificate, o.Client)
+	return o, nil
+}
+
+// checkCSR the certificate request must contain exactly the identifiers of the order
+func checkCSR(o model.ACMEOrder, csr x509.CertificateRequest) error {
+	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return problem(ErrBadCSR, http.StatusBadRequest, "csr must only contain dns names and ip addresses")
+	}
+	ids := make([]model.ACMEIdentifier, 0)
+	for _, d := range csr.DNSNames {
+		ids = append(ids, model.ACMEIdentifier{Type: identifierDNS, Value: strings.ToLower(d)})
+	}
+	for _, ip := range csr.IPAddresses {
+		ids = append(ids, model.ACMEIdentifier{Type: identifierIP, Value: ip.String()})
+	}
+	cmp := func(a, b model.ACMEIdentifier) int {
+		return strings.Compare(a.Type+":"+a.Value, b.Type+":"+b.Value)
+	}
+	slices.SortFunc(ids, cmp)
+	ids = slices.Compact(ids)
+	oids := slices.Clone(o.Identifiers)
+	slices.SortFunc(oids, cmp)
+	if !slices.Equal(ids, oids) {
+		return problem(ErrBadCSR, http.StatusBadRequest, "identifiers of the csr don't match the order")
+	}
+	cn := strings.ToLower(csr.Subject.CommonName)
+	if cn != "" && !slices.ContainsFunc(ids, func(id model.ACMEIdentifier) bool { return id.Value == cn }) {
+		return problem(ErrBadCSR, http.StatusBadRequest, "common name of the csr must be one of the identifiers")
+	}
+	return nil
+}
+
+// Certificate the pem encoded certificate chain of a valid order
+func (a *ACME) Certificate(req Request, id string) (string, error) {
+	o, err := a.Order(req, id)
+	if err != nil {
+		return "", err
+	}
+	if o.Certificate == "" {
+		return "", notFound("certificate", id)
+	}
+	crt, ok := a.stg.GetCertificate(o.Certificate)
+	if !ok {
+		return "", notFound("certificate", o.Certificate)
+	}
+	ca, err := a.crt.ChainPEM()
+	if err != nil {
+		return "", err
+	}
+	return crt.Certificate + ca, nil
+}
+
+// RevokeCert revoking a certificate, issued for the client of the account of the request
+func (a *ACME) RevokeCert(req Request) error {
+	if req.Account == nil {
+		return unauthorized("revocation must be signed by an account")
+	}
+	var rr revokeRequest
+	err := json.Unmarshal(req.Payload, &rr)
+	if err != nil {
+		return malformed("can't parse revocation request: %v", err)
+	}
+	der, err := base64.RawURLEncoding.DecodeString(rr.Certificate)
+	if err != nil {
+		return malformed("can't decode certificate: %v", err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return malformed("can't parse certificate: %v", err)
+	}
+	sn := model.Serial2ID(crt.SerialNumber)
+	sc, ok := a.stg.GetCertificate(sn)
+	if !ok {
+		return notFound("certificate", sn)
+	}
+	p, _ := pem.Decode([]byte(sc.Certificate))
+	if p == nil || !bytes.Equal(p.Bytes, der) || sc.Client != req.Account.Client {
+		return unauthorized("certificate %s not issued for the client of the account", sn)
+	}
+	reason := ""
+	if rr.Reason != nil {
+		reason = model.ReasonName(*rr.Reason)
+		if reason == "" {
+			return problem(ErrBadRevocationReason, http.StatusBadRequest, "revocation reason not supported: %d", *rr.Reason)
+		}
+	}
+	_, err = a.cls.RevokeCertificate(sn, reason)
+	if errors.Is(err, serror.ErrCertRevoked) {
+		return problem(ErrAlreadyRevoked, http.StatusBadRequest, "certificate %s already revoked", sn)
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package acme
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// acme error types (RFC 8555 6.7)
+const (
+	errPrefix                  = "urn:ietf:params:acme:error:"
+	ErrAccountDoesNotExist     = errPrefix + "accountDoesNotExist"
+	ErrAlreadyRevoked          = errPrefix + "alreadyRevoked"
+	ErrBadCSR                  = errPrefix + "badCSR"
+	ErrBadNonce                = errPrefix + "badNonce"
+	ErrBadRevocationReason     = errPrefix + "badRevocationReason"
+	ErrBadSignatureAlgorithm   = errPrefix + "badSignatureAlgorithm"
+	ErrConnection              = errPrefix + "connection"
+	ErrExternalAccountRequired = errPrefix + "externalAccountRequired"
+	ErrIncorrectResponse       = errPrefix + "incorrectResponse"
+	ErrMalformed               = errPrefix + "malformed"
+	ErrOrderNotReady           = errPrefix + "orderNotReady"
+	ErrRejectedIdentifier      = errPrefix + "rejectedIdentifier"
+	ErrServerInternal          = errPrefix + "serverInternal"
+	ErrUnauthorized            = errPrefix + "unauthorized"
+	ErrUnsupportedIdentifier   = errPrefix + "unsupportedIdentifier"
+)
+
+// Problem an acme problem document (RFC 7807), used as error of the acme service
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// Error returns the error
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+func problem(typ string, status int, format string, args ...any) *Problem {
+	return &Problem{
+		Type:   typ,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+func malformed(format string, args ...any) *Problem {
+	return problem(ErrMalformed, http.StatusBadRequest, format, args...)
+}
+
+func unauthorized(format string, args ...any) *Problem {
+	return problem(ErrUnauthorized, http.StatusForbidden, format, args...)
+}
+
+func notFound(typ, id string) *Problem {
+	return problem(ErrMalformed, http.StatusNotFound, "%s not found: %s", typ, id)
+}
@@ -2,10 +2,10 @@ package admin
 
 import (
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -24,6 +24,7 @@ import (
 	"github.com/willie68/micro-vault/internal/services/keyman"
 	"github.com/willie68/micro-vault/internal/services/playbook"
 	"github.com/willie68/micro-vault/internal/utils"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
 	cry "github.com/willie68/micro-vault/pkg/crypt"
 	"github.com/willie68/micro-vault/pkg/pmodel"
 )
@@ -34,6 +35,9 @@ const (
 	rtUsageKey     = "usage"
 	rtUsageRefresh = "mv-refresh"
 	JKAudience     = "microvault-admins"
+
+	defaultTokenTTL   = 15 * time.Minute
+	defaultRefreshTTL = 60 * time.Minute
 )
 
 var logger = logging.New().WithName("svcAdmin")
@@ -129,12 +133,58 @@ func (a *Admin) Refresh(rt string) (string, string, error) {
 	return tsig, rtsig, nil
 }
 
+// RevokeToken revoking an access or refresh token of the admin (RFC 7009),
+// invalid or expired tokens are ignored, as they can't be used anyway
+func (a *Admin) RevokeToken(tk string) error {
+	token, err := jwt.Parse([]byte(tk), jwt.WithKey(jwa.RS256, a.kmn.PublicKey()))
+	if err != nil {
+		logger.Infof("revoke token: token ignored: %v", err)
+		return nil
+	}
+	auds := token.Audience()
+	if len(auds) != 1 || auds[0] != JKAudience {
+		return nil
+	}
+	return a.stg.RevokeToken(token.JwtID(), token.Expiration())
+}
+
+// Introspect getting the state and the claims of an access or refresh token of the admin (RFC 7662)
+func (a *Admin) Introspect(tk string) pmodel.Introspection {
+	token, err := jwt.Parse([]byte(tk), jwt.WithKey(jwa.RS256, a.kmn.PublicKey()))
+	if err != nil {
+		return pmodel.Introspection{Active: false}
+	}
+	auds := token.Audience()
+	if len(auds) != 1 || auds[0] != JKAudience || a.stg.IsRevoked(token.JwtID()) {
+		return pmodel.Introspection{Active: false}
+	}
+	ti := pmodel.Introspection{
+		Active:    true,
+		TokenType: "Bearer",
+		Username:  a.rootusr,
+		Sub:       a.rootusr,
+		Aud:       JKAudience,
+		Iat:       token.IssuedAt().Unix(),
+		Exp:       token.Expiration().Unix(),
+		Jti:       token.JwtID(),
+	}
+	if token.PrivateClaims()[rtUsageKey] == rtUsageRefresh {
+		ti.TokenType = "refresh_token"
+		return ti
+	}
+	if !search(token.PrivateClaims()[tkRolesKey], tkRoleAdmin) {
+		return pmodel.Introspection{Active: false}
+	}
+	ti.Roles = []string{tkRoleAdmin}
+	return ti
+}
+
 func (a *Admin) generateToken(no time.Time) (string, error) {
 	id := utils.GenerateID()
 	t := jwt.New()
 	t.Set(jwt.AudienceKey, JKAudience)
 	t.Set(jwt.IssuedAtKey, no)
-	t.Set(jwt.ExpirationKey, no.Add(15*time.Minute))
+	t.Set(jwt.ExpirationKey, no.Add(a.tokenTTL(a.cfg.Service.Tokens.AdminTTL, defaultTokenTTL)))
 	t.Set(jwt.JwtIDKey, id)
 	t.Set(tkRolesKey, []string{tkRoleAdmin})
 
@@ -152,7 +202,7 @@ func (a *Admin) generateRefreshToken(no time.Time) (string, error) {
 	t := jwt.New()
 	t.Set(jwt.AudienceKey, JKAudience)
 	t.Set(jwt.IssuedAtKey, no)
-	t.Set(jwt.ExpirationKey, no.Add(60*time.Minute))
+	t.Set(jwt.ExpirationKey, no.Add(a.tokenTTL(a.cfg.Service.Tokens.AdminRefreshTTL, defaultRefreshTTL)))
 	t.Set(jwt.JwtIDKey, id)
 	t.Set(rtUsageKey, rtUsageRefresh)
 
@@ -165,6 +215,19 @@ func (a *Admin) generateRefreshToken(no time.Time) (string, error) {
 	return string(tsig), nil
 }
 
+// tokenTTL parses a token lifetime of the service config, using the default for empty or invalid values
+func (a *Admin) tokenTTL(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := str2duration.ParseDuration(s)
+	if err != nil || d <= 0 {
+		logger.Errorf("token ttl: %s is not a valid lifetime, using %s", s, def)
+		return def
+	}
+	return d
+}
+
 // Playbook plays the playbook
 func (a *Admin) Playbook(tk string, pm model.Playbook) error {
 	err := a.checkTk(tk)
@@ -216,6 +279,10 @@ func (a *Admin) AddGroup(tk string, g model.Group) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	err = a.cls.CheckTokenTTL(g.TokenTTL, g.RefreshTTL)
+	if err != nil {
+		return "", err
+	}
 	g.KID = kid
 	g.Key = pem
 	return a.grs.AddGroup(g)
@@ -227,6 +294,10 @@ func (a *Admin) UpdateGroup(tk string, g model.Group) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	err = a.cls.CheckTokenTTL(g.TokenTTL, g.RefreshTTL)
+	if err != nil {
+		return "", err
+	}
 	return a.grs.UpdateGroup(g)
 }
 
@@ -252,6 +323,7 @@ func (a *Admin) Clients(tk string) ([]model.Client, error) {
 			AccessKey: c.AccessKey,
 			Secret:    "",
 			Groups:    c.Groups,
+			KeyType:   c.KeyType,
 			Crt:       c.Crt,
 		}
 		cl = append(cl, nc)
@@ -290,7 +362,16 @@ func (a *Admin) NewClient(tk, n string, gs []string) (*pmodel.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	cl, err := a.createClient(n, gs)
+	return a.NewClientWithKeyType(tk, n, gs, "")
+}
+
+// NewClientWithKeyType creating a new client with a key of the key type, an empty key type is the default RSA-4096
+func (a *Admin) NewClientWithKeyType(tk, n string, gs []string, kt string) (*pmodel.Client, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := a.createClient(n, gs, kt)
 	if err != nil {
 		return nil, err
 	}
@@ -326,6 +407,7 @@ func (a *Admin) AddGroups2Client(tk, n string, gs []string) (*pmodel.Client, err
 		Groups:    c.Groups,
 		KID:       c.KID,
 		Key:       c.Key,
+		KeyType:   c.KeyType,
 		Crt:       c.Crt,
 	}
 	return &co, nil
@@ -360,11 +442,75 @@ func (a *Admin) ChangeCertificateTemplateClient(tk, n string, crt map[string]any
 		Groups:    c.Groups,
 		KID:       c.KID,
 		Key:       c.Key,
+		KeyType:   c.KeyType,
+		Crt:       c.Crt,
+	}
+	return &co, nil
+}
+
+// RotateClientKey rotating the key of the client, the previous key is still valid for the grace period
+func (a *Admin) RotateClientKey(tk, n, kt string) (*pmodel.Client, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if !a.stg.HasClient(n) {
+		return nil, serror.ErrNotExists
+	}
+	c, err := a.cls.RotateKeyOfClient(n, kt)
+	if err != nil {
+		return nil, err
+	}
+	co := pmodel.Client{
+		Name:      c.Name,
+		AccessKey: c.AccessKey,
+		Secret:    "*****",
+		Groups:    c.Groups,
+		KID:       c.KID,
+		Key:       c.Key,
+		KeyType:   c.KeyType,
 		Crt:       c.Crt,
 	}
 	return &co, nil
 }
 
+// RotateClientSecret issuing a new secret for the client, the old secrets are still valid.
+// exp is the optional validity of the new secret, e.g. 90d
+func (a *Admin) RotateClientSecret(tk, n, exp string) (*pmodel.ClientSecret, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if !a.stg.HasClient(n) {
+		return nil, serror.ErrNotExists
+	}
+	return a.cls.RotateSecretOfClient(n, exp)
+}
+
+// ClientSecrets listing the active secrets of the client, without the secrets itself
+func (a *Admin) ClientSecrets(tk, n string) ([]pmodel.ClientSecret, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if !a.stg.HasClient(n) {
+		return nil, serror.ErrNotExists
+	}
+	return a.cls.SecretsOfClient(n)
+}
+
+// RevokeClientSecret revoking the secret with the id of the client
+func (a *Admin) RevokeClientSecret(tk, n, id string) error {
+	err := a.checkTk(tk)
+	if err != nil {
+		return err
+	}
+	if !a.stg.HasClient(n) {
+		return serror.ErrNotExists
+	}
+	return a.cls.RevokeSecretOfClient(n, id)
+}
+
 // HasClient looking of the present of a single client based on the name
 func (a *Admin) HasClient(tk, n string) bool {
 	err := a.checkTk(tk)
@@ -392,15 +538,112 @@ func (a *Admin) Client(tk, n string) (*model.Client, error) {
 		return nil, serror.ErrNotExists
 	}
 	c := model.Client{
-		Name:      cl.Name,
-		AccessKey: cl.AccessKey,
-		Secret:    "",
-		Groups:    cl.Groups,
-		Crt:       cl.Crt,
+		Name:       cl.Name,
+		AccessKey:  cl.AccessKey,
+		Secret:     "",
+		Groups:     cl.Groups,
+		KeyType:    cl.KeyType,
+		Crt:        cl.Crt,
+		Disabled:   cl.Disabled,
+		NotBefore:  cl.NotBefore,
+		ExpiresAt:  cl.ExpiresAt,
+		TokenTTL:   cl.TokenTTL,
+		RefreshTTL: cl.RefreshTTL,
 	}
 	return &c, nil
 }
 
+// SetClientState enabling or disabling a client and setting its validity period
+func (a *Admin) SetClientState(tk, n string, st pmodel.ClientState) (*pmodel.Client, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	ak, ok := a.stg.AccessKey(n)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	c, ok := a.stg.GetClient(ak)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	c.Disabled = st.Disabled
+	c.NotBefore = time.Time{}
+	if st.NotBefore != nil {
+		c.NotBefore = *st.NotBefore
+	}
+	c.ExpiresAt = time.Time{}
+	if st.ExpiresAt != nil {
+		c.ExpiresAt = *st.ExpiresAt
+	}
+	if !c.NotBefore.IsZero() && !c.ExpiresAt.IsZero() && !c.NotBefore.Before(c.ExpiresAt) {
+		return nil, errors.New("notbefore must be before expiresat")
+	}
+	err = a.stg.UpdateClient(*c)
+	if err != nil {
+		return nil, err
+	}
+	co := pmodel.Client{
+		Name:      c.Name,
+		AccessKey: c.AccessKey,
+		Secret:    "*****",
+		Groups:    c.Groups,
+		KID:       c.KID,
+		KeyType:   c.KeyType,
+		Disabled:  c.Disabled,
+		NotBefore: st.NotBefore,
+		ExpiresAt: st.ExpiresAt,
+	}
+	return &co, nil
+}
+
+// RevokeClientTokens revoking all access and refresh tokens of the client issued until before, a zero before is now
+func (a *Admin) RevokeClientTokens(tk, n string, before time.Time) error {
+	err := a.checkTk(tk)
+	if err != nil {
+		return err
+	}
+	return a.cls.RevokeTokens(n, before)
+}
+
+// SetClientTokenTTL setting the token lifetimes of a client, empty values are using the lifetimes of the groups or the service
+func (a *Admin) SetClientTokenTTL(tk, n string, tt pmodel.TokenTTL) (*pmodel.Client, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	err = a.cls.CheckTokenTTL(tt.TokenTTL, tt.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	ak, ok := a.stg.AccessKey(n)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	c, ok := a.stg.GetClient(ak)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	c.TokenTTL = tt.TokenTTL
+	c.RefreshTTL = tt.RefreshTTL
+	err = a.stg.UpdateClient(*c)
+	if err != nil {
+		return nil, err
+	}
+	co := pmodel.Client{
+		Name:       c.Name,
+		AccessKey:  c.AccessKey,
+		Secret:     "*****",
+		Groups:     c.Groups,
+		KID:        c.KID,
+		KeyType:    c.KeyType,
+		Disabled:   c.Disabled,
+		TokenTTL:   c.TokenTTL,
+		RefreshTTL: c.RefreshTTL,
+	}
+	return &co, nil
+}
+
 // DeleteClient deleting a client
 func (a *Admin) DeleteClient(tk, n string) (bool, error) {
 	err := a.checkTk(tk)
@@ -457,19 +700,103 @@ func (a *Admin) Keys4Group(tk, g string, s, l int64) ([]model.EncryptKey, error)
 }
 
 // CreateGroupKey creates a new group key from the administrator endpoint
-func (a *Admin) CreateGroupKey(tk, g string) (*model.EncryptKey, error) {
+func (a *Admin) CreateGroupKey(tk, g, alg string) (*model.EncryptKey, error) {
 	err := a.checkTk(tk)
 	if err != nil {
 		return nil, err
 	}
 
-	ek, err := a.cls.CreateKey(g)
+	ek, err := a.cls.CreateKey(g, alg)
 	if err != nil {
 		return nil, err
 	}
 	return ek, nil
 }
 
+// RotateGroupKey creates the next version of the group key from the administrator endpoint
+func (a *Admin) RotateGroupKey(tk, g string) (*model.EncryptKey, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if !a.stg.HasGroup(g) {
+		return nil, serror.ErrNotExists
+	}
+	return a.cls.RotateKey(g)
+}
+
+// SetKeyState changing the lifecycle state of a key from the administrator endpoint
+func (a *Admin) SetKeyState(tk, id, state string) (*model.EncryptKey, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return a.cls.SetKeyState(id, state)
+}
+
+// RevokeCertificate revoking an issued certificate from the administrator endpoint
+func (a *Admin) RevokeCertificate(tk, sn, reason string) (*model.Certificate, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return a.cls.RevokeCertificate(sn, reason)
+}
+
+// Certificates getting the issued certificates matching the filter
+func (a *Admin) Certificates(tk string, f model.CertificateFilter) ([]model.Certificate, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return a.cls.Certificates(f)
+}
+
+// Certificate getting the issued certificate with the serial number
+func (a *Admin) Certificate(tk, sn string) (*model.Certificate, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return a.cls.Certificate(sn)
+}
+
+// Profiles getting all certificate profiles
+func (a *Admin) Profiles(tk string) ([]model.CertProfile, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return a.cls.Profiles()
+}
+
+// Profile getting the certificate profile with the name
+func (a *Admin) Profile(tk, n string) (*model.CertProfile, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return a.cls.Profile(n)
+}
+
+// StoreProfile creating or updating a certificate profile
+func (a *Admin) StoreProfile(tk string, p model.CertProfile) error {
+	err := a.checkTk(tk)
+	if err != nil {
+		return err
+	}
+	return a.cls.StoreProfile(p)
+}
+
+// DeleteProfile deleting the certificate profile with the name
+func (a *Admin) DeleteProfile(tk, n string) (bool, error) {
+	err := a.checkTk(tk)
+	if err != nil {
+		return false, err
+	}
+	return a.cls.DeleteProfile(n)
+}
+
 func (a *Admin) GetInfo(tk string) ([]string, error) {
 	err := a.checkTk(tk)
 	if err != nil {
@@ -503,6 +830,9 @@ func (a *Admin) checkTk(tk string) error {
 	if no.After(et) {
 		return serror.ErrTokenExpired
 	}
+	if a.stg.IsRevoked(token.JwtID()) {
+		return serror.ErrTokenNotValid
+	}
 	roles := token.PrivateClaims()[tkRolesKey]
 	if !search(roles, tkRoleAdmin) {
 		return serror.ErrTokenNotValid
@@ -538,11 +868,14 @@ func (a *Admin) checkRtk(tk string) (jwt.Token, error) {
 	return token, nil
 }
 
-// createClient creates a new client with defined groups
-func (a *Admin) createClient(n string, g []string) (*pmodel.Client, error) {
+// createClient creates a new client with defined groups and a key of the key type
+func (a *Admin) createClient(n string, g []string, kt string) (*pmodel.Client, error) {
 	if a.stg.HasClient(n) || a.stg.HasGroup(n) {
 		return nil, serror.ErrAlreadyExists
 	}
+	if kt == "" {
+		kt = cry.DefaultKeyType
+	}
 	secret, err := generateToken()
 	if err != nil {
 		return nil, err
@@ -551,7 +884,7 @@ func (a *Admin) createClient(n string, g []string) (*pmodel.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	kid, pem, err := generateRSAKey()
+	kid, pem, err := generateKey(kt)
 	if err != nil {
 		return nil, err
 	}
@@ -564,6 +897,7 @@ func (a *Admin) createClient(n string, g []string) (*pmodel.Client, error) {
 		Groups:    g,
 		Key:       pem,
 		KID:       kid,
+		KeyType:   strings.ToUpper(kt),
 	}
 	_, err = a.stg.AddClient(c)
 	if err != nil {
@@ -592,6 +926,7 @@ func (a *Admin) createClient(n string, g []string) (*pmodel.Client, error) {
 		Groups:    c.Groups,
 		KID:       c.KID,
 		Key:       c.Key,
+		KeyType:   c.KeyType,
 	}
 	return &co, nil
 }
@@ -630,7 +965,11 @@ func hash(p []byte) string {
 }
 
 func generateRSAKey() (string, string, error) {
-	rsk, err := rsa.GenerateKey(rand.Reader, 4096)
+	return generateKey(cry.KeyTypeRSA4096)
+}
+
+func generateKey(kt string) (string, string, error) {
+	rsk, err := cry.GenerateKey(kt)
 	if err != nil {
 		return "", "", err
 	}
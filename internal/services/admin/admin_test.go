@@ -5,16 +5,21 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
+	"github.com/samber/do"
 	"github.com/stretchr/testify/assert"
 	"github.com/willie68/micro-vault/internal/config"
 	"github.com/willie68/micro-vault/internal/interfaces"
 	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
 	"github.com/willie68/micro-vault/internal/services/clients"
 	"github.com/willie68/micro-vault/internal/services/groups"
 	"github.com/willie68/micro-vault/internal/services/keyman"
 	"github.com/willie68/micro-vault/internal/services/playbook"
 	"github.com/willie68/micro-vault/internal/services/storage"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
 const (
@@ -314,6 +319,185 @@ func TestGroup(t *testing.T) {
 	ast.Equal(len(gs), len(gs2))
 }
 
+func TestClientKeyType(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+
+	cl, err := adm.NewClientWithKeyType(tk, "clientec", []string{"group1"}, "ecdsa-p256")
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeECDSAP256, cl.KeyType)
+	kt, err := cry.KeyTypeOfPEM(cl.Key)
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeECDSAP256, kt)
+	kid, err := cry.GetKIDOfPEM(cl.Key)
+	ast.Nil(err)
+	ast.Equal(kid, cl.KID)
+
+	c, err := adm.Client(tk, "clientec")
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeECDSAP256, c.KeyType)
+
+	_, err = adm.NewClientWithKeyType(tk, "clientdsa", []string{"group1"}, "DSA")
+	ast.ErrorIs(err, cry.ErrUnknownKeyType)
+	ast.False(adm.HasClient(tk, "clientdsa"))
+
+	ok, err := adm.DeleteClient(tk, "clientec")
+	ast.Nil(err)
+	ast.True(ok)
+}
+
+func TestRotateClientKey(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+
+	_, err = adm.RotateClientKey(tk, "unknownclient", "")
+	ast.NotNil(err)
+
+	cl, err := adm.NewClientWithKeyType(tk, "clientrotate", []string{"group1"}, cry.KeyTypeECDSAP256)
+	ast.Nil(err)
+	rcl, err := adm.RotateClientKey(tk, "clientrotate", cry.KeyTypeEd25519)
+	ast.Nil(err)
+	ast.Equal(cl.AccessKey, rcl.AccessKey)
+	ast.NotEqual(cl.KID, rcl.KID)
+	ast.Equal(cry.KeyTypeEd25519, rcl.KeyType)
+	kid, err := cry.GetKIDOfPEM(rcl.Key)
+	ast.Nil(err)
+	ast.Equal(kid, rcl.KID)
+
+	ok, err := adm.DeleteClient(tk, "clientrotate")
+	ast.Nil(err)
+	ast.True(ok)
+}
+
+func TestRotateClientSecret(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+	cls := do.MustInvoke[clients.Clients](nil)
+
+	_, err = adm.RotateClientSecret(tk, "unknownclient", "")
+	ast.NotNil(err)
+
+	cl, err := adm.NewClient(tk, "clientsecret", []string{"group1"})
+	ast.Nil(err)
+	s, err := adm.RotateClientSecret(tk, "clientsecret", "30d")
+	ast.Nil(err)
+	ast.NotEmpty(s.Secret)
+	ast.NotNil(s.Expires)
+
+	_, _, _, err = cls.Login(cl.AccessKey, cl.Secret)
+	ast.Nil(err)
+	_, _, _, err = cls.Login(cl.AccessKey, s.Secret)
+	ast.Nil(err)
+
+	ss, err := adm.ClientSecrets(tk, "clientsecret")
+	ast.Nil(err)
+	ast.Len(ss, 2)
+	ast.Empty(ss[1].Secret)
+
+	ast.Nil(adm.RevokeClientSecret(tk, "clientsecret", model.PrimarySecretID))
+	_, _, _, err = cls.Login(cl.AccessKey, cl.Secret)
+	ast.NotNil(err)
+	_, _, _, err = cls.Login(cl.AccessKey, s.Secret)
+	ast.Nil(err)
+	ast.NotNil(adm.RevokeClientSecret(tk, "clientsecret", s.ID))
+
+	ok, err := adm.DeleteClient(tk, "clientsecret")
+	ast.Nil(err)
+	ast.True(ok)
+}
+
+func TestSetClientState(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+	cls := do.MustInvoke[clients.Clients](nil)
+
+	_, err = adm.SetClientState(tk, "unknownclient", pmodel.ClientState{Disabled: true})
+	ast.NotNil(err)
+
+	cl, err := adm.NewClient(tk, "clientstate", []string{"group1"})
+	ast.Nil(err)
+	cs, err := adm.SetClientState(tk, "clientstate", pmodel.ClientState{Disabled: true})
+	ast.Nil(err)
+	ast.True(cs.Disabled)
+	c, err := adm.Client(tk, "clientstate")
+	ast.Nil(err)
+	ast.True(c.Disabled)
+	_, _, _, err = cls.Login(cl.AccessKey, cl.Secret)
+	ast.ErrorIs(err, serror.ErrClientDisabled)
+
+	exp := time.Now().Add(-time.Minute)
+	nb := exp.Add(-time.Hour)
+	_, err = adm.SetClientState(tk, "clientstate", pmodel.ClientState{NotBefore: &exp, ExpiresAt: &nb})
+	ast.NotNil(err)
+	_, err = adm.SetClientState(tk, "clientstate", pmodel.ClientState{NotBefore: &nb, ExpiresAt: &exp})
+	ast.Nil(err)
+	_, _, _, err = cls.Login(cl.AccessKey, cl.Secret)
+	ast.ErrorIs(err, serror.ErrClientExpired)
+
+	cs, err = adm.SetClientState(tk, "clientstate", pmodel.ClientState{})
+	ast.Nil(err)
+	ast.False(cs.Disabled)
+	ast.Nil(cs.ExpiresAt)
+	_, _, _, err = cls.Login(cl.AccessKey, cl.Secret)
+	ast.Nil(err)
+
+	ok, err := adm.DeleteClient(tk, "clientstate")
+	ast.Nil(err)
+	ast.True(ok)
+}
+
+func TestSetClientTokenTTL(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+
+	_, err = adm.SetClientTokenTTL(tk, "unknownclient", pmodel.TokenTTL{TokenTTL: "1h"})
+	ast.NotNil(err)
+
+	_, err = adm.NewClient(tk, "clientttl", []string{"group1"})
+	ast.Nil(err)
+	_, err = adm.SetClientTokenTTL(tk, "clientttl", pmodel.TokenTTL{TokenTTL: "100d"})
+	ast.NotNil(err)
+	cs, err := adm.SetClientTokenTTL(tk, "clientttl", pmodel.TokenTTL{TokenTTL: "1h", RefreshTTL: "8h"})
+	ast.Nil(err)
+	ast.Equal("1h", cs.TokenTTL)
+	c, err := adm.Client(tk, "clientttl")
+	ast.Nil(err)
+	ast.Equal("1h", c.TokenTTL)
+	ast.Equal("8h", c.RefreshTTL)
+
+	_, err = adm.AddGroup(tk, model.Group{Name: "groupttl", TokenTTL: "100d"})
+	ast.NotNil(err)
+
+	ok, err := adm.DeleteClient(tk, "clientttl")
+	ast.Nil(err)
+	ast.True(ok)
+}
+
+func TestAdminRevokeToken(t *testing.T) {
+	ast := assert.New(t)
+	tk, rt, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+
+	ti := adm.Introspect(tk)
+	ast.True(ti.Active)
+	ast.Equal([]string{tkRoleAdmin}, ti.Roles)
+	ast.Equal("refresh_token", adm.Introspect(rt).TokenType)
+
+	ast.Nil(adm.RevokeToken(tk))
+	ast.False(adm.Introspect(tk).Active)
+	_, err = adm.Groups(tk)
+	ast.ErrorIs(err, serror.ErrTokenNotValid)
+
+	ast.Nil(adm.RevokeToken(rt))
+	_, _, err = adm.Refresh(rt)
+	ast.NotNil(err)
+}
+
 func TestClientCRUD(t *testing.T) {
 	ast := assert.New(t)
 	tk, _, err := adm.LoginUP(rootuser, rootpwd)
@@ -457,7 +641,7 @@ func TestKeys4Group(t *testing.T) {
 		if idx == 1 {
 			cnt++
 		}
-		_, err := adm.CreateGroupKey(tk, fmt.Sprintf("group%d", idx))
+		_, err := adm.CreateGroupKey(tk, fmt.Sprintf("group%d", idx), "")
 		ast.Nil(err)
 	}
 
@@ -472,6 +656,31 @@ func TestKeys4Group(t *testing.T) {
 	ast.Equal(cnt, len(cs))
 }
 
+func TestRotateGroupKey(t *testing.T) {
+	ast := assert.New(t)
+	installPlaybook()
+	tk, _, err := adm.LoginUP(rootuser, rootpwd)
+	ast.Nil(err)
+	ast.NotEmpty(tk)
+
+	ek, err := adm.RotateGroupKey(tk, "unknowngroup")
+	ast.NotNil(err)
+	ast.Nil(ek)
+
+	ek, err = adm.RotateGroupKey(tk, "group3")
+	ast.Nil(err)
+	ast.NotNil(ek)
+	ast.Equal(1, ek.Version)
+
+	ek, err = adm.RotateGroupKey(tk, "group3")
+	ast.Nil(err)
+	ast.Equal(2, ek.Version)
+
+	cs, err := adm.Keys4Group(tk, "group3", 0, 100)
+	ast.Nil(err)
+	ast.Equal(2, len(cs))
+}
+
 func TestGetInfo(t *testing.T) {
 	ast := assert.New(t)
 	err := stg.Init()
@@ -0,0 +1,102 @@
+package clients
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+// TokenClient getting the client of the token
+func (c *Clients) TokenClient(tk string) (*model.Client, error) {
+	return c.client(tk)
+}
+
+// ClientByName getting the client with the name
+func (c *Clients) ClientByName(name string) (*model.Client, error) {
+	a, ok := c.stg.AccessKey(name)
+	if !ok {
+		return nil, serror.NotFound("client", name)
+	}
+	cl, ok := c.stg.GetClient(a)
+	if !ok {
+		return nil, serror.NotFound("client", name)
+	}
+	return cl, nil
+}
+
+// ActiveClientByName getting the client with the name, the client must be enabled and in its validity period
+func (c *Clients) ActiveClientByName(name string) (*model.Client, error) {
+	cl, err := c.ClientByName(name)
+	if err != nil {
+		return nil, err
+	}
+	err = checkClientState(*cl, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// CertIdentifiers the dns names and ip addresses a certificate of the client may contain,
+// defined by the certificate template of the client
+func CertIdentifiers(cl model.Client) ([]string, []net.IP, error) {
+	dnss, err := mergeDNSs(cl.Crt, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ips, err := mergeIPs(cl.Crt, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dnss, ips, nil
+}
+
+// IssueCertificate issue a certificate for the client with the name using the public key of the
+// certificate request. Only dns names and ip addresses of the certificate template are allowed,
+// returning the der and pem encoded certificate. An empty profile is the default profile of the client.
+func (c *Clients) IssueCertificate(name, profile string, csr x509.CertificateRequest) ([]byte, string, error) {
+	cl, err := c.ActiveClientByName(name)
+	if err != nil {
+		return nil, "", err
+	}
+	dnss, ips, err := CertIdentifiers(*cl)
+	if err != nil {
+		return nil, "", err
+	}
+	// dns names are case insensitive, like the identifiers of the acme orders
+	dns := make([]string, 0, len(csr.DNSNames))
+	for _, d := range csr.DNSNames {
+		d = strings.ToLower(strings.TrimSuffix(d, "."))
+		if !slices.ContainsFunc(dnss, func(n string) bool { return strings.EqualFold(n, d) }) {
+			return nil, "", fmt.Errorf("dns name not allowed for client: %s", d)
+		}
+		dns = append(dns, d)
+	}
+	for _, ip := range csr.IPAddresses {
+		if !slices.ContainsFunc(ips, ip.Equal) {
+			return nil, "", fmt.Errorf("ip address not allowed for client: %s", ip.String())
+		}
+	}
+	if len(csr.DNSNames)+len(csr.IPAddresses) == 0 {
+		return nil, "", errors.New("certificate request without identifiers")
+	}
+	tmp := x509.CertificateRequest{
+		Subject:     csr.Subject,
+		DNSNames:    dns,
+		IPAddresses: csr.IPAddresses,
+	}
+	if tmp.Subject.CommonName == "" {
+		if ucn, ok := cl.Crt["ucn"].(string); ok {
+			tmp.Subject.CommonName = ucn
+		}
+	}
+	mergeSubject(&tmp, cl.Crt)
+	return c.signCertificate(*cl, profile, tmp, csr.PublicKey)
+}
@@ -0,0 +1,89 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+func TestCertIdentifiers(t *testing.T) {
+	ast := assert.New(t)
+
+	cl, err := cls.ClientByName("tester1")
+	ast.Nil(err)
+	dnss, ips, err := CertIdentifiers(*cl)
+	ast.Nil(err)
+	ast.Contains(dnss, "wkmusicsearch.local")
+	ast.Equal(1, len(ips))
+	ast.True(ips[0].Equal(net.ParseIP("192.168.178.10")))
+
+	_, err = cls.ClientByName("unknown")
+	ast.NotNil(err)
+}
+
+func TestIssueCertificate(t *testing.T) {
+	ast := assert.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames:    []string{"wkmusicsearch.local"},
+		IPAddresses: []net.IP{net.ParseIP("192.168.178.10")},
+	}, key)
+	ast.Nil(err)
+	csr, err := x509.ParseCertificateRequest(der)
+	ast.Nil(err)
+
+	cd, pm, err := cls.IssueCertificate("tester1", "", *csr)
+	ast.Nil(err)
+	ast.NotEmpty(pm)
+	crt, err := x509.ParseCertificate(cd)
+	ast.Nil(err)
+	ast.Equal("wkmusicsearch", crt.Subject.CommonName)
+	ast.Equal([]string{"wkmusicsearch.local"}, crt.DNSNames)
+	ast.True(key.PublicKey.Equal(crt.PublicKey))
+
+	cr, ok := stg.GetCertificate(model.Serial2ID(crt.SerialNumber))
+	ast.True(ok)
+	ast.Equal("tester1", cr.Client)
+
+	// dns names are compared case insensitive
+	csr.DNSNames = []string{"WKMusicSearch.local"}
+	cd, _, err = cls.IssueCertificate("tester1", "", *csr)
+	ast.Nil(err)
+	crt, err = x509.ParseCertificate(cd)
+	ast.Nil(err)
+	ast.Equal([]string{"wkmusicsearch.local"}, crt.DNSNames)
+
+	csr.DNSNames = []string{"www.example.com"}
+	_, _, err = cls.IssueCertificate("tester1", "", *csr)
+	ast.NotNil(err)
+
+	csr.DNSNames = nil
+	csr.IPAddresses = nil
+	_, _, err = cls.IssueCertificate("tester1", "", *csr)
+	ast.NotNil(err)
+
+	_, _, err = cls.IssueCertificate("unknown", "", *csr)
+	ast.NotNil(err)
+
+	// a disabled client gets no certificates
+	csr.DNSNames = []string{"wkmusicsearch.local"}
+	cl, err := cls.ClientByName("tester1")
+	ast.Nil(err)
+	cl.Disabled = true
+	ast.Nil(stg.UpdateClient(*cl))
+	_, _, err = cls.IssueCertificate("tester1", "", *csr)
+	ast.ErrorIs(err, serror.ErrClientDisabled)
+	cl.Disabled = false
+	ast.Nil(stg.UpdateClient(*cl))
+	_, _, err = cls.IssueCertificate("tester1", "", *csr)
+	ast.Nil(err)
+}
@@ -0,0 +1,102 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// CryptSSBatch server side en/decryption of a list of messages, errors are reported per item.
+// Keys are only looked up once per batch and new group keys are shared for all messages to the same group.
+func (c *Clients) CryptSSBatch(tk string, msgs []pmodel.Message) ([]pmodel.Message, error) {
+	_, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) > MaxBatchSize {
+		return nil, fmt.Errorf("batch too large, max %d items", MaxBatchSize)
+	}
+	kc := c.newKeyCache(tk)
+	res := make([]pmodel.Message, len(msgs))
+	for x, msg := range msgs {
+		m, err := kc.crypt(msg)
+		if err != nil {
+			msg.Message = ""
+			msg.Error = err.Error()
+			res[x] = msg
+			continue
+		}
+		res[x] = *m
+	}
+	return res, nil
+}
+
+// SignSSBatch server side signature of a list of messages, errors are reported per item
+func (c *Clients) SignSSBatch(tk string, msgs []pmodel.SignMessage) ([]pmodel.SignMessage, error) {
+	_, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) > MaxBatchSize {
+		return nil, fmt.Errorf("batch too large, max %d items", MaxBatchSize)
+	}
+	pk, kid, err := c.signer(tk)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]pmodel.SignMessage, len(msgs))
+	for x, msg := range msgs {
+		m, err := sign(pk, kid, &msg)
+		if err != nil {
+			msg.Error = err.Error()
+			res[x] = msg
+			continue
+		}
+		res[x] = *m
+	}
+	return res, nil
+}
+
+// CheckSSBatch server side check of a list of signatures, errors are reported per item
+func (c *Clients) CheckSSBatch(tk string, msgs []pmodel.SignMessage) ([]pmodel.SignMessage, error) {
+	_, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) > MaxBatchSize {
+		return nil, fmt.Errorf("batch too large, max %d items", MaxBatchSize)
+	}
+	kc := c.newKeyCache(tk)
+	res := make([]pmodel.SignMessage, len(msgs))
+	for x, msg := range msgs {
+		m, err := kc.checkSign(msg)
+		if err != nil {
+			msg.Valid = false
+			msg.Error = err.Error()
+			res[x] = msg
+			continue
+		}
+		res[x] = *m
+	}
+	return res, nil
+}
+
+// crypt server side en/decryption of a group or private message
+func (k *keyCache) crypt(msg pmodel.Message) (*pmodel.Message, error) {
+	if strings.EqualFold(msg.Type, "group") {
+		return k.ssGroup(msg)
+	}
+	if strings.EqualFold(msg.Type, "private") {
+		return k.ssClient(msg)
+	}
+	return &msg, nil
+}
+
+func (k *keyCache) checkSign(msg pmodel.SignMessage) (*pmodel.SignMessage, error) {
+	pub, err := k.publicKey4KID(msg.KeyInfo.KID)
+	if err != nil {
+		return nil, err
+	}
+	return checkSign(pub, &msg)
+}
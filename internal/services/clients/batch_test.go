@@ -0,0 +1,85 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+func TestCryptSSBatch(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	msgs := make([]pmodel.Message, 0)
+	for x := 0; x < 5; x++ {
+		msg, err := buildGroupMessage("group1")
+		ast.Nil(err)
+		msgs = append(msgs, msg)
+	}
+	msgs = append(msgs, pmodel.Message{Type: "private", Recipient: "tester1", Message: "private message"})
+	msgs = append(msgs, pmodel.Message{Type: "group", ID: "unknown", Message: "unknown", Decrypt: true})
+
+	res, err := cls.CryptSSBatch(tk, msgs)
+	ast.Nil(err)
+	ast.Len(res, 7)
+
+	// all new group messages share one new key
+	for x := 0; x < 5; x++ {
+		ast.Empty(res[x].Error)
+		ast.True(res[x].Decrypt)
+		ast.Equal(res[0].ID, res[x].ID)
+	}
+	ast.Empty(res[5].Error)
+	ast.NotEqual("private message", res[5].Message)
+	ast.NotEmpty(res[6].Error)
+	ast.Empty(res[6].Message)
+
+	// decrypt the group messages
+	res2, err := cls.CryptSSBatch(tk, res[:5])
+	ast.Nil(err)
+	for x := 0; x < 5; x++ {
+		ast.Empty(res2[x].Error)
+		ast.Equal(msgs[x].Message, res2[x].Message)
+	}
+
+	_, err = cls.CryptSSBatch(tk, make([]pmodel.Message, MaxBatchSize+1))
+	ast.NotNil(err)
+}
+
+func TestSSSignBatch(t *testing.T) {
+	ast := assert.New(t)
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	tk2, _, _, err := cls.Login("87654321", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	msgs := []pmodel.SignMessage{
+		{Message: "Dies ist eine Message"},
+		{Message: "Dies ist eine andere Message"},
+	}
+	sms, err := cls.SignSSBatch(tk1, msgs)
+	ast.Nil(err)
+	ast.Len(sms, 2)
+	for _, sm := range sms {
+		ast.Empty(sm.Error)
+		ast.NotEmpty(sm.Signature)
+		ast.Equal("RS256", sm.KeyInfo.Alg)
+	}
+
+	// tampered message and unknown kid
+	sms[1].Message = "manipuliert"
+	sms = append(sms, pmodel.SignMessage{KeyInfo: pmodel.KeyInfo{KID: "unknown"}, Message: "m", Signature: "s"})
+	cms, err := cls.CheckSSBatch(tk2, sms)
+	ast.Nil(err)
+	ast.Len(cms, 3)
+	ast.True(cms[0].Valid)
+	ast.Empty(cms[0].Error)
+	ast.False(cms[1].Valid)
+	ast.False(cms[2].Valid)
+	ast.NotEmpty(cms[2].Error)
+}
@@ -0,0 +1,128 @@
+package clients
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// formats of a certificate bundle
+const (
+	BundlePEM          = "pem"           // certificate, chain and the encrypted pkcs#8 private key
+	BundlePKCS12       = "pkcs12"        // pkcs#12 with aes-256 and sha-256
+	BundlePKCS12Legacy = "pkcs12-legacy" // pkcs#12 with 3des and sha-1, for older windows and java versions
+)
+
+// BundleOptions the options for a certificate with a server side generated key
+type BundleOptions struct {
+	Profile  string // certificate profile, empty for the default profile of the client
+	KeyType  string // type of the generated key, see crypt.GenerateKey, default ECDSA-P256
+	Format   string // format of the bundle, default pem
+	Password string // password for the private key in the bundle
+}
+
+// CreateCertificateBundle generate a new key and a certificate for it. The key is not stored in micro-vault,
+// it's only part of the returned password protected bundle.
+func (c *Clients) CreateCertificateBundle(tk string, certTemplate string, opts BundleOptions) ([]byte, error) {
+	if opts.Password == "" {
+		return nil, errors.New("a password for the bundle is required")
+	}
+	if opts.Format == "" {
+		opts.Format = BundlePEM
+	}
+	if opts.Format != BundlePEM && opts.Format != BundlePKCS12 && opts.Format != BundlePKCS12Legacy {
+		return nil, fmt.Errorf("unknown bundle format: %s", opts.Format)
+	}
+	cl, tmp, err := c.certTemplate(tk, certTemplate)
+	if err != nil {
+		return nil, err
+	}
+	key, err := generateKey(opts.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	der, _, err := c.signCertificate(*cl, opts.Profile, *tmp, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	xc, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := c.chain()
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Format {
+	case BundlePKCS12:
+		return pkcs12.Modern.Encode(key, xc, chain, opts.Password)
+	case BundlePKCS12Legacy:
+		return pkcs12.Legacy.Encode(key, xc, chain, opts.Password)
+	}
+	return pemBundle(key, xc, chain, opts.Password)
+}
+
+// generateKey generates a new private key of the key type, ECDSA-P256 for an empty key type
+func generateKey(kt string) (crypto.Signer, error) {
+	if kt == "" {
+		kt = cry.KeyTypeECDSAP256
+	}
+	return cry.GenerateKey(kt)
+}
+
+// chain the certificates of the ca chain
+func (c *Clients) chain() ([]*x509.Certificate, error) {
+	pm, err := c.crt.ChainPEM()
+	if err != nil {
+		return nil, err
+	}
+	xcs := make([]*x509.Certificate, 0)
+	b := []byte(pm)
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			break
+		}
+		xc, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		xcs = append(xcs, xc)
+	}
+	return xcs, nil
+}
+
+// pemBundle the certificate followed by the chain and the password encrypted pkcs#8 private key
+func pemBundle(key crypto.Signer, xc *x509.Certificate, chain []*x509.Certificate, pwd string) ([]byte, error) {
+	kb, err := pkcs8.MarshalPrivateKey(key, []byte(pwd), &pkcs8.Opts{
+		Cipher: pkcs8.AES256CBC,
+		KDFOpts: pkcs8.PBKDF2Opts{
+			SaltSize:       16,
+			IterationCount: 100000,
+			HMACHash:       crypto.SHA256,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	for _, c := range append([]*x509.Certificate{xc}, chain...) {
+		err = pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = pem.Encode(buf, &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: kb})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,132 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestCSRCertificate(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	csr, err := createCsrPem(key)
+	ast.Nil(err)
+
+	pcrt, err := cls.CreateCSRCertificate(tk, csr, "")
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(pcrt))
+	ast.NotNil(p)
+	xc, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+	ast.True(key.PublicKey.Equal(xc.PublicKey))
+
+	// the legacy mode uses the key of the client
+	pcrt, err = cls.CreateCertificate(tk, csr)
+	ast.Nil(err)
+	p, _ = pem.Decode([]byte(pcrt))
+	xc, err = x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+	ast.False(key.PublicKey.Equal(xc.PublicKey))
+
+	// a request with a wrong signature is rejected
+	b, _ := pem.Decode([]byte(csr))
+	b.Bytes[len(b.Bytes)-1] ^= 0xff
+	_, err = cls.CreateCSRCertificate(tk, string(pem.EncodeToMemory(b)), "")
+	ast.NotNil(err)
+}
+
+func TestCertificateBundle(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	tkey, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	csr, err := createCsrPem(tkey)
+	ast.Nil(err)
+
+	// pem bundle with the encrypted private key
+	b, err := cls.CreateCertificateBundle(tk, csr, BundleOptions{Password: "geheim"})
+	ast.Nil(err)
+	var xcs []*x509.Certificate
+	var key any
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			break
+		}
+		switch p.Type {
+		case "CERTIFICATE":
+			xc, err := x509.ParseCertificate(p.Bytes)
+			ast.Nil(err)
+			xcs = append(xcs, xc)
+		case "ENCRYPTED PRIVATE KEY":
+			_, err = pkcs8.ParsePKCS8PrivateKey(p.Bytes, []byte("wrong"))
+			ast.NotNil(err)
+			key, err = pkcs8.ParsePKCS8PrivateKey(p.Bytes, []byte("geheim"))
+			ast.Nil(err)
+		}
+	}
+	ast.Equal(2, len(xcs))
+	ek, ok := key.(*ecdsa.PrivateKey)
+	ast.True(ok)
+	ast.True(ek.PublicKey.Equal(xcs[0].PublicKey))
+	ast.False(tkey.PublicKey.Equal(xcs[0].PublicKey))
+	ast.Nil(xcs[0].CheckSignatureFrom(xcs[1]))
+
+	// pkcs#12 bundles
+	for _, f := range []string{BundlePKCS12, BundlePKCS12Legacy} {
+		b, err = cls.CreateCertificateBundle(tk, csr, BundleOptions{KeyType: cry.KeyTypeRSA2048, Format: f, Password: "geheim"})
+		ast.Nil(err)
+		key, xc, ca, err := pkcs12.DecodeChain(b, "geheim")
+		ast.Nil(err)
+		rk, ok := key.(*rsa.PrivateKey)
+		ast.True(ok)
+		ast.True(rk.PublicKey.Equal(xc.PublicKey))
+		ast.Equal(1, len(ca))
+		ast.True(bytes.Equal(xcs[1].Raw, ca[0].Raw))
+	}
+
+	_, err = cls.CreateCertificateBundle(tk, csr, BundleOptions{})
+	ast.NotNil(err)
+	_, err = cls.CreateCertificateBundle(tk, csr, BundleOptions{Format: "jks", Password: "geheim"})
+	ast.NotNil(err)
+	_, err = cls.CreateCertificateBundle(tk, csr, BundleOptions{KeyType: "DSA", Password: "geheim"})
+	ast.NotNil(err)
+}
+
+func TestGenerateKey(t *testing.T) {
+	ast := assert.New(t)
+
+	for kt, bits := range map[string]int{cry.KeyTypeECDSAP256: 256, "ecdsa-p384": 384, cry.KeyTypeRSA3072: 3072} {
+		k, err := generateKey(kt)
+		ast.Nil(err)
+		switch pk := k.Public().(type) {
+		case *ecdsa.PublicKey:
+			ast.Equal(bits, pk.Curve.Params().BitSize)
+		case *rsa.PublicKey:
+			ast.Equal(bits, pk.N.BitLen())
+		default:
+			ast.Fail("unknown key type")
+		}
+	}
+	k, err := generateKey("")
+	ast.Nil(err)
+	_, ok := k.(*ecdsa.PrivateKey)
+	ast.True(ok)
+	_, err = generateKey("DSA")
+	ast.NotNil(err)
+}
@@ -23,13 +23,7 @@ func mergeTemplate(tmp *x509.CertificateRequest, crt map[string]any) (*x509.Cert
 	if tmp.Subject.CommonName == "" {
 		tmp.Subject.CommonName = crt["ucn"].(string)
 	}
-	tmp.Subject.Country = mergeListWCRTValue(tmp.Subject.Country, crt["uco"], false)
-	tmp.Subject.Province = mergeListWCRTValue(tmp.Subject.Province, crt["upr"], false)
-	tmp.Subject.Locality = mergeListWCRTValue(tmp.Subject.Locality, crt["ulo"], false)
-	tmp.Subject.Organization = mergeListWCRTValue(tmp.Subject.Organization, crt["uor"], false)
-	tmp.Subject.OrganizationalUnit = mergeListWCRTValue(tmp.Subject.OrganizationalUnit, crt["uou"], false)
-	tmp.Subject.StreetAddress = mergeListWCRTValue(tmp.Subject.StreetAddress, crt["usa"], false)
-	tmp.Subject.PostalCode = mergeListWCRTValue(tmp.Subject.PostalCode, crt["upc"], false)
+	mergeSubject(tmp, crt)
 
 	tmp.EmailAddresses = mergeListWCRTValue(tmp.EmailAddresses, crt["uem"], true)
 
@@ -51,6 +45,17 @@ func mergeTemplate(tmp *x509.CertificateRequest, crt map[string]any) (*x509.Cert
 	return tmp, nil
 }
 
+// mergeSubject merging the subject fields of the certificate template into the request
+func mergeSubject(tmp *x509.CertificateRequest, crt map[string]any) {
+	tmp.Subject.Country = mergeListWCRTValue(tmp.Subject.Country, crt["uco"], false)
+	tmp.Subject.Province = mergeListWCRTValue(tmp.Subject.Province, crt["upr"], false)
+	tmp.Subject.Locality = mergeListWCRTValue(tmp.Subject.Locality, crt["ulo"], false)
+	tmp.Subject.Organization = mergeListWCRTValue(tmp.Subject.Organization, crt["uor"], false)
+	tmp.Subject.OrganizationalUnit = mergeListWCRTValue(tmp.Subject.OrganizationalUnit, crt["uou"], false)
+	tmp.Subject.StreetAddress = mergeListWCRTValue(tmp.Subject.StreetAddress, crt["usa"], false)
+	tmp.Subject.PostalCode = mergeListWCRTValue(tmp.Subject.PostalCode, crt["upc"], false)
+}
+
 func mergeDNSs(crt map[string]any, tmpDNSs []string) ([]string, error) {
 	dnss := make([]string, 0)
 	if tmpDNSs != nil {
@@ -0,0 +1,113 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+const defaultClientKeyGrace = time.Hour * 24 * 30
+
+// RotateClientKey rotating the key of the client of the token, the previous key is still valid for the grace period
+func (c *Clients) RotateClientKey(tk, kt string) (*model.Client, error) {
+	cl, err := c.client(tk)
+	if err != nil {
+		return nil, err
+	}
+	return c.rotateClientKey(*cl, kt, time.Now())
+}
+
+// RotateKeyOfClient rotating the key of the named client, the previous key is still valid for the grace period
+func (c *Clients) RotateKeyOfClient(name, kt string) (*model.Client, error) {
+	cl, err := c.ClientByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.rotateClientKey(*cl, kt, time.Now())
+}
+
+// GetPrivateKeys getting the current and all not retired previous private keys of the client of the token
+func (c *Clients) GetPrivateKeys(tk string) ([]model.ClientKey, error) {
+	cl, err := c.client(tk)
+	if err != nil {
+		return nil, err
+	}
+	return cl.ValidKeys(time.Now()), nil
+}
+
+// rotateClientKey generating a new key for the client, an empty key type keeps the key type of the client.
+// The current key is moved to the previous keys, retired previous keys are removed.
+func (c *Clients) rotateClientKey(cl model.Client, kt string, now time.Time) (*model.Client, error) {
+	if kt == "" {
+		kt = cl.KeyType
+	}
+	if kt == "" {
+		kt, _ = cry.KeyTypeOfPEM(cl.Key)
+	}
+	if !cry.ValidKeyType(kt) {
+		return nil, fmt.Errorf("%w: %s", cry.ErrUnknownKeyType, kt)
+	}
+	g, err := c.clientKeyGrace()
+	if err != nil {
+		return nil, err
+	}
+	pk, err := cry.GenerateKey(kt)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := cry.Prv2Pem(pk)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := cry.GetKID(pk)
+	if err != nil {
+		return nil, err
+	}
+
+	pks := []model.ClientKey{{KID: cl.KID, Key: cl.Key, KeyType: cl.KeyType, Retire: now.Add(g)}}
+	for _, k := range cl.PreviousKeys {
+		if now.Before(k.Retire) {
+			pks = append(pks, k)
+		}
+	}
+	cl.PreviousKeys = pks
+	cl.Key = string(pem)
+	cl.KID = kid
+	cl.KeyType = strings.ToUpper(kt)
+	if cl.KeyType == "" {
+		cl.KeyType = cry.DefaultKeyType
+	}
+	err = c.stg.UpdateClient(cl)
+	if err != nil {
+		return nil, err
+	}
+	c.kids.Store(kid, cl.AccessKey)
+	return &cl, nil
+}
+
+// clientByPreviousKID searching the client with a previous key with the kid
+func (c *Clients) clientByPreviousKID(kid string) (*model.Client, bool) {
+	var cl *model.Client
+	c.stg.ListClients(func(g model.Client) bool {
+		for _, k := range g.PreviousKeys {
+			if k.KID == kid {
+				cl = &g
+				return false
+			}
+		}
+		return true
+	})
+	return cl, cl != nil
+}
+
+func (c *Clients) clientKeyGrace() (time.Duration, error) {
+	g := c.cfg.Service.ClientKeyGrace
+	if g == "" {
+		return defaultClientKeyGrace, nil
+	}
+	return str2duration.ParseDuration(g)
+}
@@ -0,0 +1,126 @@
+package clients
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+func TestRotateClientKeyConcurrent(t *testing.T) {
+	ast := assert.New(t)
+
+	n := "rotate-client-conc"
+	addKeyTypeClient(ast, n, cry.KeyTypeECDSAP256)
+	cl, ok := cls.stg.GetClient(n)
+	ast.True(ok)
+
+	// rotating the key while other requests are resolving the kids
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := cls.rotateClientKey(*cl, cry.KeyTypeEd25519, time.Now())
+			ast.Nil(err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := cls.publicKey4KID(cl.KID)
+			ast.Nil(err)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRotateClientKey(t *testing.T) {
+	ast := assert.New(t)
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	n := "rotate-client"
+	pp := addKeyTypeClient(ast, n, cry.KeyTypeECDSAP256)
+	opk, err := cry.Pem2Key(pp)
+	ast.Nil(err)
+	tk, _, _, err := cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	// signature and message with the old key
+	omsg, err := cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+	ast.Nil(err)
+	okid := omsg.KeyInfo.KID
+	cm, err := buildClientMessage(n)
+	ast.Nil(err)
+	om, err := cls.CryptSS(tk1, cm)
+	ast.Nil(err)
+
+	cl, err := cls.RotateClientKey(tk, "")
+	ast.Nil(err)
+	ast.NotEqual(okid, cl.KID)
+	ast.Equal(cry.KeyTypeECDSAP256, cl.KeyType)
+	ast.Len(cl.PreviousKeys, 1)
+	ast.Equal(okid, cl.PreviousKeys[0].KID)
+
+	// the old signature is still valid
+	msg, err := cls.CheckSS(tk1, omsg)
+	ast.Nil(err)
+	ast.True(msg.Valid)
+
+	// new signatures and the public key are of the new key
+	msg, err = cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+	ast.Nil(err)
+	ast.Equal(cl.KID, msg.KeyInfo.KID)
+	msg, err = cls.CheckSS(tk1, msg)
+	ast.Nil(err)
+	ast.True(msg.Valid)
+	pub, err := cls.GetPublicKey(tk1, n)
+	ast.Nil(err)
+	ok, err := cry.SignCheckPEM(pub, msg.Signature, msg.Message)
+	ast.Nil(err)
+	ast.True(ok)
+
+	// the old message can be decrypted with the previous key
+	ks, err := cls.GetPrivateKeys(tk)
+	ast.Nil(err)
+	ast.Len(ks, 2)
+	ast.Equal(cl.KID, ks[0].KID)
+	ast.Equal(okid, ks[1].KID)
+	ast.Equal(pp, ks[1].Key)
+	pt, err := cry.DecryptPrv(opk, om.Message)
+	ast.Nil(err)
+	ast.Equal(cm.Message, pt)
+
+	// after the grace period the old key is retired
+	_, ok = cl.KeyByKID(okid, time.Now().Add(defaultClientKeyGrace+time.Hour))
+	ast.False(ok)
+	cl, err = cls.rotateClientKey(*cl, cry.KeyTypeEd25519, time.Now().Add(defaultClientKeyGrace+time.Hour))
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeEd25519, cl.KeyType)
+	ast.Len(cl.PreviousKeys, 1)
+	ast.NotEqual(okid, cl.PreviousKeys[0].KID)
+	_, err = cls.CheckSS(tk1, omsg)
+	ast.ErrorIs(err, serror.ErrNotExists)
+}
+
+func TestRotateKeyOfClient(t *testing.T) {
+	ast := assert.New(t)
+
+	n := "rotate-admin"
+	addKeyTypeClient(ast, n, cry.KeyTypeRSA2048)
+	cl, err := cls.RotateKeyOfClient(n, cry.KeyTypeECDSAP384)
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeECDSAP384, cl.KeyType)
+	kt, err := cry.KeyTypeOfPEM(cl.Key)
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeECDSAP384, kt)
+	ast.Equal(cry.KeyTypeRSA2048, cl.PreviousKeys[0].KeyType)
+
+	_, err = cls.RotateKeyOfClient(n, "DSA")
+	ast.NotNil(err)
+	_, err = cls.RotateKeyOfClient("unknown-client", "")
+	ast.NotNil(err)
+}
@@ -2,19 +2,17 @@ package clients
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/aes"
 	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/lestrrat-go/jwx/v2/jwa"
@@ -34,12 +32,13 @@ import (
 )
 
 const (
-	JKAudience          = "microvault-client"
-	rtUsageKey          = "usage"
-	rtUsageRefresh      = "mv-refresh"
-	defaultCertValid    = time.Hour * 24 * 365
-	errTkNotValidGroups = "token not valid, no groups"
-	errAccKeyPermit     = "access to key permitted"
+	JKAudience                 = "microvault-client"
+	rtUsageKey                 = "usage"
+	rtUsageRefresh             = "mv-refresh"
+	defaultCertValid           = time.Hour * 24 * 365
+	defaultKeyDestructionGrace = time.Hour * 24 * 7
+	errTkNotValidGroups        = "token not valid, no groups"
+	errAccKeyPermit            = "access to key permitted"
 )
 
 var logger = logging.New().WithName("svcClients")
@@ -50,7 +49,8 @@ type Clients struct {
 	cfg  config.Config
 	kmn  keyman.Keyman
 	crt  keyman.CAService
-	kids map[string]string // map key is the kid, value is the access key of the client
+	kids *sync.Map // map key is the kid, value is the access key of the client
+	crl  *crlCache
 }
 
 // NewClients creates a new clients service
@@ -71,7 +71,9 @@ func NewClients() (Clients, error) {
 
 // Init initialize the clients service
 func (c *Clients) Init() error {
-	c.kids = make(map[string]string)
+	c.kids = &sync.Map{}
+	c.crl = &crlCache{}
+	c.crt.OnIssue(c.storeServiceCertificate)
 	c.stg.ListClients(func(g model.Client) bool {
 		if g.KID == "" {
 			kid, err := cry.GetKIDOfPEM(g.Key)
@@ -80,44 +82,49 @@ func (c *Clients) Init() error {
 			}
 			g.KID = kid
 		}
-		c.kids[g.KID] = g.AccessKey
+		c.kids.Store(g.KID, g.AccessKey)
+		for _, k := range g.PreviousKeys {
+			c.kids.Store(k.KID, g.AccessKey)
+		}
 		return true
 	})
 	return nil
 }
 
-// Login logging in a client, returning a token if ok,
+// Login logging in a client with one of its active secrets, returning a token if ok,
 // return token, refreshtoken, key, error
 func (c *Clients) Login(a, s string) (string, string, string, error) {
 	if !c.stg.HasClient(a) {
 		return "", "", "", serror.ErrLoginFailed
 	}
 	cl, ok := c.stg.GetClient(a)
-	salt, err := hex.DecodeString(cl.Salt)
-	if err != nil {
-		log.Printf("failed to generate salt: %s", err)
-		return "", "", "", err
+	if !ok {
+		return "", "", "", serror.ErrLoginFailed
 	}
 	secret, err := hex.DecodeString(s)
 	if err != nil {
 		log.Printf("failed to decode secret: %s", err)
 		return "", "", "", err
 	}
-	hash := cry.HashSecret(secret, salt)
-	if !ok || (ok && cl.Hash != hash) {
+	if !checkSecret(*cl, secret, time.Now()) {
 		return "", "", "", serror.ErrLoginFailed
 	}
+	err = checkClientState(*cl, time.Now())
+	if err != nil {
+		return "", "", "", err
+	}
 
 	no := time.Now()
+	ttl, rttl := c.tokenTTL(*cl)
 
 	// Signing a token (using raw rsa.PrivateKey)
-	rtsig, err := c.generateRefreshToken(no, cl.Name)
+	rtsig, err := c.generateRefreshToken(no, rttl, cl.Name)
 	if err != nil {
 		log.Printf("failed to generate token: %s", err)
 		return "", "", "", err
 	}
 
-	tsig, err := c.generateToken(no, cl.Name, cl.Groups)
+	tsig, err := c.generateToken(no, ttl, cl.Name, cl.Groups)
 	if err != nil {
 		log.Printf("failed to generate token: %s", err)
 		return "", "", "", err
@@ -149,16 +156,21 @@ func (c *Clients) Refresh(rt string) (string, string, error) {
 		logger.Error("failed to refresh, token not valid, no client defined")
 		return "", "", serror.ErrTokenNotValid
 	}
+	err = checkClientState(*cl, time.Now())
+	if err != nil {
+		return "", "", err
+	}
 
 	no := time.Now()
+	ttl, rttl := c.tokenTTL(*cl)
 	// Signing a token (using raw rsa.PrivateKey)
-	rtsig, err := c.generateRefreshToken(no, cl.Name)
+	rtsig, err := c.generateRefreshToken(no, rttl, cl.Name)
 	if err != nil {
 		logger.Errorf("sign token: failed to generate refesh token: %s", err)
 		return "", "", err
 	}
 
-	tsig, err := c.generateToken(no, cl.Name, cl.Groups)
+	tsig, err := c.generateToken(no, ttl, cl.Name, cl.Groups)
 	if err != nil {
 		logger.Errorf("sign token: failed to generate token: %s", err)
 		return "", "", err
@@ -174,12 +186,12 @@ func (c *Clients) Refresh(rt string) (string, string, error) {
 	return tsig, rtsig, nil
 }
 
-func (c *Clients) generateToken(no time.Time, n string, gr []string) (string, error) {
+func (c *Clients) generateToken(no time.Time, ttl time.Duration, n string, gr []string) (string, error) {
 	id := utils.GenerateID()
 	t := jwt.New()
 	t.Set(jwt.AudienceKey, JKAudience)
 	t.Set(jwt.IssuedAtKey, no)
-	t.Set(jwt.ExpirationKey, no.Add(5*time.Minute))
+	t.Set(jwt.ExpirationKey, no.Add(ttl))
 	t.Set(jwt.JwtIDKey, id)
 	t.Set("name", n)
 	t.Set("groups", gr)
@@ -193,12 +205,12 @@ func (c *Clients) generateToken(no time.Time, n string, gr []string) (string, er
 	return string(tsig), nil
 }
 
-func (c *Clients) generateRefreshToken(no time.Time, n string) (string, error) {
+func (c *Clients) generateRefreshToken(no time.Time, ttl time.Duration, n string) (string, error) {
 	id := utils.GenerateID()
 	t := jwt.New()
 	t.Set(jwt.AudienceKey, JKAudience)
 	t.Set(jwt.IssuedAtKey, no)
-	t.Set(jwt.ExpirationKey, no.Add(60*time.Minute))
+	t.Set(jwt.ExpirationKey, no.Add(ttl))
 	t.Set(jwt.JwtIDKey, id)
 	t.Set("name", n)
 	t.Set(rtUsageKey, rtUsageRefresh)
@@ -211,56 +223,126 @@ func (c *Clients) generateRefreshToken(no time.Time, n string) (string, error) {
 	return string(tsig), nil
 }
 
-// CreateCertificate generate a new certificate for the client, signed with the CA cert
+// CreateCertificate generate a new certificate for the client, signed with the CA cert,
+// followed by the certificate chain of the CA
 func (c *Clients) CreateCertificate(tk string, certTemplate string) (string, error) {
-	_, err := c.checkTk(tk)
+	return c.CreateProfileCertificate(tk, certTemplate, "")
+}
+
+// CreateProfileCertificate generate a new certificate for the client with the certificate profile,
+// an empty profile is the default profile of the client
+func (c *Clients) CreateProfileCertificate(tk string, certTemplate string, profile string) (string, error) {
+	cl, tmp, err := c.certTemplate(tk, certTemplate)
 	if err != nil {
 		return "", err
 	}
-	cl, err := c.client(tk)
+	pk, err := cry.Pem2Key(cl.Key)
 	if err != nil {
 		return "", err
 	}
-	pk, err := cry.Pem2Prv(cl.Key)
+	return c.signChain(*cl, profile, *tmp, pk.Public())
+}
+
+// CreateCSRCertificate generate a new certificate for the public key of the certificate request,
+// so the private key never leaves the client. An empty profile is the default profile of the client.
+func (c *Clients) CreateCSRCertificate(tk string, certTemplate string, profile string) (string, error) {
+	cl, tmp, err := c.certTemplate(tk, certTemplate)
 	if err != nil {
 		return "", err
 	}
+	err = tmp.CheckSignature()
+	if err != nil {
+		return "", fmt.Errorf("certificate request not signed with its key: %w", err)
+	}
+	return c.signChain(*cl, profile, *tmp, tmp.PublicKey)
+}
 
-	validTo := defaultCertValid // one year is the default valid certificate duration
-	if vad, ok := cl.Crt["vad"].(string); ok {
-		validTo, err = str2duration.ParseDuration(vad)
-		if err != nil {
-			return "", fmt.Errorf("configure vad with a valid duration: %v", err)
-		}
+// certTemplate getting the client of the token and the pem encoded certificate request merged with the certificate template of the client
+func (c *Clients) certTemplate(tk string, certTemplate string) (*model.Client, *x509.CertificateRequest, error) {
+	_, err := c.checkTk(tk)
+	if err != nil {
+		return nil, nil, err
+	}
+	cl, err := c.client(tk)
+	if err != nil {
+		return nil, nil, err
 	}
 	p, _ := pem.Decode([]byte(certTemplate))
 	if p == nil {
-		return "", errors.New("no pem block found")
+		return nil, nil, errors.New("no pem block found")
 	}
 	if p.Type != "CERTIFICATE REQUEST" {
-		return "", errors.New("wrong pem block found, must be \"CERTIFICATE REQUEST\"")
+		return nil, nil, errors.New("wrong pem block found, must be \"CERTIFICATE REQUEST\"")
 	}
 	tmp, err := x509.ParseCertificateRequest(p.Bytes)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	tmp, err = mergeTemplate(tmp, cl.Crt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cl, tmp, nil
+}
+
+// signChain signs the certificate for the public key, returning the pem certificate followed by the chain of the CA
+func (c *Clients) signChain(cl model.Client, profile string, tmp x509.CertificateRequest, pub any) (string, error) {
+	_, pm, err := c.signCertificate(cl, profile, tmp, pub)
 	if err != nil {
 		return "", err
 	}
-	b, err := c.crt.CertSignRequest(*tmp, &pk.PublicKey, validTo)
+	ca, err := c.crt.ChainPEM()
 	if err != nil {
 		return "", err
 	}
+	return pm + ca, nil
+}
+
+// signCertificate signs the certificate template for the client with the CA cert and
+// stores the issued certificate, returning the der and pem encoded certificate.
+// With a certificate profile the template is checked against the profile, which defines the usage of the certificate.
+func (c *Clients) signCertificate(cl model.Client, profile string, tmp x509.CertificateRequest, pub any) ([]byte, string, error) {
+	validTo := defaultCertValid // one year is the default valid certificate duration
+	if vad, ok := cl.Crt["vad"].(string); ok {
+		var err error
+		validTo, err = str2duration.ParseDuration(vad)
+		if err != nil {
+			return nil, "", fmt.Errorf("configure vad with a valid duration: %v", err)
+		}
+	}
+	p, err := c.certProfile(cl, profile)
+	if err != nil {
+		return nil, "", err
+	}
+	usage := keyman.DefaultUsage()
+	if p != nil {
+		var maxValid time.Duration
+		usage, maxValid, err = applyProfile(*p, cl, &tmp)
+		if err != nil {
+			return nil, "", err
+		}
+		if maxValid > 0 && validTo > maxValid {
+			validTo = maxValid
+		}
+		profile = p.Name
+	}
+	b, err := c.crt.CertSignUsage(tmp, pub, validTo, usage)
+	if err != nil {
+		return nil, "", err
+	}
 	caPEM := new(bytes.Buffer)
 	err = pem.Encode(caPEM, &pem.Block{
 		Type:  "CERTIFICATE",
 		Bytes: b,
 	})
 	if err != nil {
-		return "", err
+		return nil, "", err
+	}
+	err = c.storeCertificate(cl.Name, profile, b, caPEM.String())
+	if err != nil {
+		return nil, "", err
 	}
-	return caPEM.String(), nil
+	return b, caPEM.String(), nil
 }
 
 // GetPrivateKey get the private certificate for the client
@@ -277,7 +359,7 @@ func (c *Clients) GetPrivateKey(tk string) (string, error) {
 }
 
 // CreateEncryptKey creates a new encryption key, stores it into the storage with id
-func (c *Clients) CreateEncryptKey(tk string, group string) (*model.EncryptKey, error) {
+func (c *Clients) CreateEncryptKey(tk, group, alg string) (*model.EncryptKey, error) {
 	jt, err := c.checkTk(tk)
 	if err != nil {
 		return nil, err
@@ -291,12 +373,166 @@ func (c *Clients) CreateEncryptKey(tk string, group string) (*model.EncryptKey,
 	if !f && (!ok || (group != n)) {
 		return nil, errors.New("group not valid, can't create a key for this group")
 	}
-	return c.CreateKey(group)
+	return c.CreateKey(group, alg)
 }
 
 // CreateKey creates a new encryption key for a specifig group
-func (c *Clients) CreateKey(group string) (*model.EncryptKey, error) {
-	id := xid.New().String()
+func (c *Clients) CreateKey(group, alg string) (*model.EncryptKey, error) {
+	e, err := newKey(group, alg)
+	if err != nil {
+		return nil, err
+	}
+	err = c.stg.StoreEncryptKey(*e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// RotateKey creates the next version of the group key, older versions are still usable for decryption.
+// If the version was created concurrently (e.g. by another node), serror.ErrAlreadyExists is returned.
+func (c *Clients) RotateKey(group string) (*model.EncryptKey, error) {
+	v := 0
+	alg := ""
+	err := c.stg.ListGroupKeys(group, func(e model.EncryptKey) bool {
+		if e.Version > v {
+			v = e.Version
+			alg = e.Alg
+		}
+		return true
+	})
+	// a legacy key is rotated to the default algorithm
+	if !cry.IsAEAD(alg) {
+		alg = ""
+	}
+	if err != nil {
+		return nil, err
+	}
+	e, err := newKey(group, alg)
+	if err != nil {
+		return nil, err
+	}
+	e.Version = v + 1
+	err = c.stg.AddGroupKey(*e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// CurrentKey returns the current enabled version of the group key, nil if the group has no such key
+func (c *Clients) CurrentKey(group string) (*model.EncryptKey, error) {
+	var cur *model.EncryptKey
+	err := c.stg.ListGroupKeys(group, func(e model.EncryptKey) bool {
+		if e.Version > 0 && e.Enabled() && (cur == nil || e.Version > cur.Version) {
+			k := e
+			cur = &k
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cur, nil
+}
+
+// CurrentOrNewKey returns the current version of the group key, creating the first version, if the group has no current key
+func (c *Clients) CurrentOrNewKey(group string) (*model.EncryptKey, error) {
+	key, err := c.CurrentKey(group)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		return key, nil
+	}
+	key, err = c.RotateKey(group)
+	// the first version was created concurrently
+	if errors.Is(err, serror.ErrAlreadyExists) {
+		return c.CurrentKey(group)
+	}
+	return key, err
+}
+
+// SetKeyState changing the lifecycle state of a key.
+// Enabled and disabled keys can be switched or scheduled for destruction,
+// a scheduled destruction can be canceled by enabling or disabling the key.
+// Destroyed keys can't be changed anymore.
+func (c *Clients) SetKeyState(id, state string) (*model.EncryptKey, error) {
+	e, ok := c.stg.GetEncryptKey(id)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	if e.KeyState() == model.KeyStateDestroyed {
+		return nil, serror.ErrKeyStateNotValid
+	}
+	switch state {
+	case model.KeyStateEnabled, model.KeyStateDisabled:
+		e.DestroyAt = time.Time{}
+	case model.KeyStateScheduled:
+		if e.KeyState() != model.KeyStateScheduled {
+			d, err := c.destructionGrace()
+			if err != nil {
+				return nil, err
+			}
+			e.DestroyAt = time.Now().Add(d)
+		}
+	default:
+		return nil, serror.ErrKeyStateNotValid
+	}
+	e.State = state
+	err := c.stg.StoreEncryptKey(*e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// DestroyKeys destroys the key material of all keys, whose destruction grace period has elapsed.
+// Only the metadata of the keys will be kept. Returns the ids of the destroyed keys.
+func (c *Clients) DestroyKeys(now time.Time) ([]string, error) {
+	ks := make([]model.EncryptKey, 0)
+	err := c.stg.ListScheduledKeys(now, func(e model.EncryptKey) bool {
+		if e.State == model.KeyStateScheduled && !now.Before(e.DestroyAt) {
+			ks = append(ks, e)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	for _, e := range ks {
+		e.Key = ""
+		e.State = model.KeyStateDestroyed
+		err = c.stg.StoreEncryptKey(e)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, e.ID)
+	}
+	return ids, nil
+}
+
+func (c *Clients) destructionGrace() (time.Duration, error) {
+	g := c.cfg.Service.KeyDestructionGrace
+	if g == "" {
+		return defaultKeyDestructionGrace, nil
+	}
+	return str2duration.ParseDuration(g)
+}
+
+// newKey creates a new random 256 bit key for the group, using the algorithm alg, default is AES-256-GCM.
+// The legacy AES-256 is only used for decrypting old ciphertexts, so no new keys are created with it.
+func newKey(group, alg string) (*model.EncryptKey, error) {
+	if alg == "" {
+		alg = cry.AlgAES256GCM
+	}
+	if alg == cry.AlgAES256 {
+		return nil, cry.ErrLegacyAlg
+	}
+	if !cry.ValidAlg(alg) {
+		return nil, cry.ErrUnknownAlg
+	}
 	buf := make([]byte, 32)
 	_, err := rand.Read(buf)
 	if err != nil {
@@ -307,19 +543,29 @@ func (c *Clients) CreateKey(group string) (*model.EncryptKey, error) {
 		return nil, err
 	}
 
-	e := model.EncryptKey{
-		ID:      id,
-		Alg:     "AES-256",
+	return &model.EncryptKey{
+		ID:      xid.New().String(),
+		Alg:     alg,
 		Key:     hex.EncodeToString(buf),
 		Created: time.Now(),
 		Group:   group,
-	}
-	err = c.stg.StoreEncryptKey(e)
-	return &e, nil
+	}, nil
 }
 
 // GetEncryptKey get an encryption key with id
 func (c *Clients) GetEncryptKey(tk string, id string) (*model.EncryptKey, error) {
+	e, err := c.encryptKey(tk, id)
+	if err != nil {
+		return nil, err
+	}
+	if !e.Enabled() {
+		return nil, serror.ErrKeyNotEnabled
+	}
+	return e, nil
+}
+
+// encryptKey get an encryption key with id in any state, checking only the access of the client
+func (c *Clients) encryptKey(tk string, id string) (*model.EncryptKey, error) {
 	jt, err := c.checkTk(tk)
 	if err != nil {
 		return nil, err
@@ -339,7 +585,6 @@ func (c *Clients) GetEncryptKey(tk string, id string) (*model.EncryptKey, error)
 	if !f && (!ok || (e.Group != n)) {
 		return nil, errors.New(errAccKeyPermit)
 	}
-
 	return e, nil
 }
 
@@ -359,11 +604,11 @@ func (c *Clients) GetPublicKey(tk string, cl string) (string, error) {
 	if dc == nil {
 		return "", serror.ErrUnknowError
 	}
-	k, err := cry.Pem2Prv(dc.Key)
+	k, err := cry.Pem2Key(dc.Key)
 	if err != nil {
 		return "", err
 	}
-	ks, err := cry.Pub2Pem(&k.PublicKey)
+	ks, err := cry.Pub2Pem(k.Public())
 	if err != nil {
 		return "", err
 	}
@@ -376,58 +621,91 @@ func (c *Clients) SignSS(tk string, msg *pmodel.SignMessage) (*pmodel.SignMessag
 	if err != nil {
 		return nil, err
 	}
-	cl, err := c.client(tk)
+	pk, kid, err := c.signer(tk)
 	if err != nil {
 		return nil, err
 	}
-	pk, err := cry.Pem2Prv(cl.Key)
+	return sign(pk, kid, msg)
+}
+
+// CheckSS server side check signature
+func (c *Clients) CheckSS(tk string, msg *pmodel.SignMessage) (*pmodel.SignMessage, error) {
+	_, err := c.checkTk(tk)
 	if err != nil {
 		return nil, err
 	}
-
-	kid, err := cry.GetKID(pk)
+	pub, err := c.publicKey4KID(msg.KeyInfo.KID)
 	if err != nil {
-		logger.Infof("failed to generate kid: %s", err)
 		return nil, err
 	}
+	return checkSign(pub, msg)
+}
 
-	sig, err := cry.Sign(*pk, msg.Message)
+// signer returns the private key and the kid of the client of the token
+func (c *Clients) signer(tk string) (crypto.Signer, string, error) {
+	cl, err := c.client(tk)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	msg.Signature = sig
-	ki := pmodel.KeyInfo{
-		Alg: "RS256",
-		KID: kid,
+	pk, err := cry.Pem2Key(cl.Key)
+	if err != nil {
+		return nil, "", err
 	}
-	msg.KeyInfo = ki
-	return msg, nil
-}
 
-// CheckSS server side check signature
-func (c *Clients) CheckSS(tk string, msg *pmodel.SignMessage) (*pmodel.SignMessage, error) {
-	_, err := c.checkTk(tk)
+	kid, err := cry.GetKID(pk)
 	if err != nil {
-		return nil, err
+		logger.Infof("failed to generate kid: %s", err)
+		return nil, "", err
 	}
+	return pk, kid, nil
+}
+
+// publicKey4KID returns the public key of the client with the kid, the kid can be of a previous, not retired key
+func (c *Clients) publicKey4KID(kid string) (crypto.PublicKey, error) {
 	var cl *model.Client
-	a, ok := c.kids[msg.KeyInfo.KID]
+	a, ok := c.kids.Load(kid)
 	if !ok {
-		cl, ok = c.stg.ClientByKID(msg.KeyInfo.KID)
+		cl, ok = c.stg.ClientByKID(kid)
+		if !ok {
+			cl, ok = c.clientByPreviousKID(kid)
+		}
 		if !ok {
 			return nil, serror.ErrNotExists
 		}
-		c.kids[cl.KID] = cl.AccessKey
-		a = cl.AccessKey
+		c.kids.Store(kid, cl.AccessKey)
 	} else {
-		cl, ok = c.stg.GetClient(a)
+		cl, ok = c.stg.GetClient(a.(string))
+		if !ok {
+			return nil, serror.ErrNotExists
+		}
+	}
+	key, ok := cl.KeyByKID(kid, time.Now())
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	pk, err := cry.Pem2Key(key)
+	if err != nil {
+		return nil, err
 	}
-	rsk, err := cry.Pem2Prv(cl.Key)
+	return pk.Public(), nil
+}
+
+func sign(pk crypto.Signer, kid string, msg *pmodel.SignMessage) (*pmodel.SignMessage, error) {
+	sig, err := cry.SignKey(pk, msg.Message)
 	if err != nil {
 		return nil, err
 	}
+	msg.Signature = sig
+	ki := pmodel.KeyInfo{
+		Alg: cry.SigAlg(pk.Public()),
+		KID: kid,
+	}
+	msg.KeyInfo = ki
+	return msg, nil
+}
 
-	ok, err = cry.SignCheck(&rsk.PublicKey, msg.Signature, msg.Message)
+func checkSign(pub crypto.PublicKey, msg *pmodel.SignMessage) (*pmodel.SignMessage, error) {
+	ok, err := cry.SignCheck(pub, msg.Signature, msg.Message)
 	if err != nil {
 		return nil, err
 	}
@@ -443,16 +721,11 @@ func (c *Clients) CryptSS(tk string, msg pmodel.Message) (*pmodel.Message, error
 		return nil, err
 	}
 
-	if strings.EqualFold(msg.Type, "group") {
-		return c.ssGroup(tk, msg)
-	}
-	if strings.EqualFold(msg.Type, "private") {
-		return c.ssClient(tk, msg)
-	}
-	return &msg, nil
+	return c.newKeyCache(tk).crypt(msg)
 }
 
-// StoreData stores data secruly for a client/group, returning the id
+// StoreData stores data secruly for a client/group, returning the id.
+// With a ttl the data expires, with read once the data will be deleted after the first read.
 func (c *Clients) StoreData(tk string, msg pmodel.Message) (string, error) {
 	jt, err := c.checkTk(tk)
 	if err != nil {
@@ -473,10 +746,21 @@ func (c *Clients) StoreData(tk string, msg pmodel.Message) (string, error) {
 	}
 
 	dt := model.Data{
-		ID:      msg.ID,
-		Created: time.Now(),
-		Group:   msg.Recipient,
-		Payload: string(js),
+		ID:       msg.ID,
+		Created:  time.Now(),
+		Group:    msg.Recipient,
+		Payload:  string(js),
+		ReadOnce: msg.ReadOnce,
+	}
+	if msg.TTL != "" {
+		d, err := str2duration.ParseDuration(msg.TTL)
+		if err != nil {
+			return "", err
+		}
+		if d <= 0 {
+			return "", errors.New("ttl must be positive")
+		}
+		dt.Expires = dt.Created.Add(d)
 	}
 	err = c.stg.StoreData(dt)
 	if err != nil {
@@ -485,7 +769,7 @@ func (c *Clients) StoreData(tk string, msg pmodel.Message) (string, error) {
 	return dt.ID, nil
 }
 
-// GetData retrieving securly stored data, if allowed
+// GetData retrieving securly stored data, if allowed. Expired data returns an error, read once data is deleted.
 func (c *Clients) GetData(tk, id string) (*pmodel.Message, error) {
 	jt, err := c.checkTk(tk)
 	if err != nil {
@@ -507,6 +791,22 @@ func (c *Clients) GetData(tk, id string) (*pmodel.Message, error) {
 	if !f && (!ok || (dt.Group != n)) {
 		return nil, errors.New(errAccKeyPermit)
 	}
+	if dt.Expired(time.Now()) {
+		if _, err := c.stg.DeleteData(id); err != nil {
+			logger.Errorf("error deleting expired data %s: %v", id, err)
+		}
+		return nil, serror.ErrDataExpired
+	}
+	if dt.ReadOnce {
+		// only the reader, who deletes the data, gets it
+		ok, err := c.stg.DeleteData(id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, serror.ErrNotExists
+		}
+	}
 	var msg pmodel.Message
 	err = json.Unmarshal([]byte(dt.Payload), &msg)
 	if err != nil {
@@ -541,81 +841,6 @@ func (c *Clients) DeleteData(tk, id string) (bool, error) {
 	return c.stg.DeleteData(id)
 }
 
-func (c *Clients) ssGroup(tk string, msg pmodel.Message) (*pmodel.Message, error) {
-	if msg.Decrypt {
-		if msg.ID == "" {
-			return nil, errors.New("missing key id")
-		}
-		key, err := c.GetEncryptKey(tk, msg.ID)
-		if err != nil {
-			return nil, err
-		}
-		k, err := hex.DecodeString(key.Key)
-		if err != nil {
-			return nil, err
-		}
-		m, err := cry.Decrypt(k, msg.Message)
-		if err != nil {
-			return nil, err
-		}
-		msg.Message = m
-		msg.Decrypt = false
-		return &msg, nil
-	}
-	// Encrypt
-	var key *model.EncryptKey
-	var err error
-	if msg.ID == "" {
-		key, err = c.CreateEncryptKey(tk, msg.Recipient)
-	} else {
-		key, err = c.GetEncryptKey(tk, msg.ID)
-	}
-	if err != nil {
-		return nil, err
-	}
-	k, err := hex.DecodeString(key.Key)
-	if err != nil {
-		return nil, err
-	}
-	m, err := cry.Encrypt(k, msg.Message)
-	if err != nil {
-		return nil, err
-	}
-	msg.ID = key.ID
-	msg.Recipient = key.Group
-	msg.Message = m
-	msg.Decrypt = true
-	return &msg, nil
-}
-
-func (c *Clients) ssClient(tk string, msg pmodel.Message) (*pmodel.Message, error) {
-	if !msg.Decrypt {
-		if msg.Recipient == "" {
-			return nil, errors.New("missing recipient")
-		}
-		key, err := c.GetPublicKey(tk, msg.Recipient)
-		if err != nil {
-			return nil, err
-		}
-
-		pub, err := cry.Pem2Pub(key)
-		if err != nil {
-			return nil, err
-		}
-
-		ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, []byte(msg.Message), nil)
-
-		if err != nil {
-			return nil, err
-		}
-		// convert to base64
-		msg.Message = base64.StdEncoding.EncodeToString(ciphertext)
-		msg.Decrypt = true
-		return &msg, nil
-	}
-	return nil, errors.New("server side private decryption is not supported")
-}
-
 func (c *Clients) checkTk(tk string) (jwt.Token, error) {
 	jt, err := jwt.Parse([]byte(tk), jwt.WithKey(jwa.RS256, c.kmn.PrivateKey()))
 	if err != nil {
@@ -632,9 +857,47 @@ func (c *Clients) checkTk(tk string) (jwt.Token, error) {
 	if time.Now().After(et) {
 		return nil, serror.ErrTokenExpired
 	}
+	if c.isRevoked(jt) {
+		return nil, serror.ErrTokenNotValid
+	}
+	err = c.checkTkClient(jt)
+	if err != nil {
+		return nil, err
+	}
 	return jt, nil
 }
 
+// checkTkClient checking the state of the client of the token, a disabled or expired client can't use its tokens
+func (c *Clients) checkTkClient(jt jwt.Token) error {
+	n, ok := jt.PrivateClaims()["name"].(string)
+	if !ok {
+		return nil
+	}
+	a, ok := c.stg.AccessKey(n)
+	if !ok {
+		return nil
+	}
+	cl, ok := c.stg.GetClient(a)
+	if !ok {
+		return nil
+	}
+	return checkClientState(*cl, time.Now())
+}
+
+// checkClientState checking if the client is enabled and in its validity period
+func checkClientState(cl model.Client, now time.Time) error {
+	if cl.Disabled {
+		return serror.ErrClientDisabled
+	}
+	if !cl.NotBefore.IsZero() && now.Before(cl.NotBefore) {
+		return serror.ErrClientNotYetValid
+	}
+	if !cl.ExpiresAt.IsZero() && !now.Before(cl.ExpiresAt) {
+		return serror.ErrClientExpired
+	}
+	return nil
+}
+
 func (c *Clients) checkRtk(tk string) (jwt.Token, error) {
 	jt, err := jwt.Parse([]byte(tk), jwt.WithKey(jwa.RS256, c.kmn.PublicKey()))
 	if err != nil {
@@ -651,8 +914,7 @@ func (c *Clients) checkRtk(tk string) (jwt.Token, error) {
 	if time.Now().After(et) {
 		return nil, serror.ErrTokenExpired
 	}
-	id := jt.JwtID()
-	if c.stg.IsRevoked(id) {
+	if c.isRevoked(jt) {
 		return nil, serror.ErrTokenNotValid
 	}
 	usage := jt.PrivateClaims()[rtUsageKey]
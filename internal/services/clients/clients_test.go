@@ -7,8 +7,10 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,6 +19,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/willie68/micro-vault/internal/config"
 	"github.com/willie68/micro-vault/internal/interfaces"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
 	"github.com/willie68/micro-vault/internal/services/keyman"
 	"github.com/willie68/micro-vault/internal/services/playbook"
 	"github.com/willie68/micro-vault/internal/services/storage"
@@ -200,7 +204,7 @@ func TestGeneratePrivateAES(t *testing.T) {
 	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
 	ast.Nil(err)
 
-	e, err := cls.CreateEncryptKey(tk, "tester1")
+	e, err := cls.CreateEncryptKey(tk, "tester1", "")
 	ast.Nil(err)
 	ast.NotNil(e)
 
@@ -219,7 +223,7 @@ func TestGenerateAES(t *testing.T) {
 	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
 	ast.Nil(err)
 
-	e, err := cls.CreateEncryptKey(tk, "group1")
+	e, err := cls.CreateEncryptKey(tk, "group1", "")
 	ast.Nil(err)
 	ast.NotNil(e)
 
@@ -238,11 +242,11 @@ func TestGenAESWrGroup(t *testing.T) {
 	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
 	ast.Nil(err)
 
-	e, err := cls.CreateEncryptKey(tk, "group3")
+	e, err := cls.CreateEncryptKey(tk, "group3", "")
 	ast.NotNil(err)
 	ast.Nil(e)
 
-	e, err = cls.CreateEncryptKey(tk, "group1")
+	e, err = cls.CreateEncryptKey(tk, "group1", "")
 	ast.Nil(err)
 	ast.NotNil(e)
 
@@ -326,6 +330,91 @@ func TestSSGroupWG(t *testing.T) {
 	ast.Nil(m2)
 }
 
+func TestSSGroupAEAD(t *testing.T) {
+	ast := assert.New(t)
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	tk2, _, _, err := cls.Login("87654321", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	for _, alg := range []string{cry.AlgAES256GCM, cry.AlgXChaCha20Poly1305} {
+		msg, err := buildGroupMessage("group2")
+		ast.Nil(err)
+		msg.Alg = alg
+		msg.AD = "associated"
+
+		m, err := cls.CryptSS(tk1, msg)
+		ast.Nil(err)
+		ast.True(m.Decrypt)
+		ast.Equal(alg, m.Alg)
+		ast.True(cry.HasHeader(m.Message))
+
+		// the key id is taken from the ciphertext header
+		dm := *m
+		dm.ID = ""
+		m2, err := cls.CryptSS(tk2, dm)
+		ast.Nil(err)
+		ast.Equal(m.ID, m2.ID)
+		ast.Equal(msg.Message, m2.Message)
+
+		// wrong associated data
+		dm.AD = "other"
+		_, err = cls.CryptSS(tk2, dm)
+		ast.NotNil(err)
+	}
+
+	msg, err := buildGroupMessage("group2")
+	ast.Nil(err)
+	msg.Alg = "DES"
+	_, err = cls.CryptSS(tk1, msg)
+	ast.NotNil(err)
+}
+
+func TestSSGroupLegacy(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	// no new legacy keys
+	_, err = cls.CreateEncryptKey(tk, "group2", cry.AlgAES256)
+	ast.ErrorIs(err, cry.ErrLegacyAlg)
+
+	// old ciphertexts of a legacy key can be decrypted
+	e := storeLegacyKey(ast, "group2")
+	kb, err := hex.DecodeString(e.Key)
+	ast.Nil(err)
+	ct, err := cry.Encrypt(kb, "Dies ist eine Message")
+	ast.Nil(err)
+	msg := pmodel.Message{
+		Type:      "group",
+		Recipient: "group2",
+		ID:        e.ID,
+		Decrypt:   true,
+		Message:   ct,
+	}
+	m, err := cls.CryptSS(tk, msg)
+	ast.Nil(err)
+	ast.Equal("Dies ist eine Message", m.Message)
+
+	// but nothing new is encrypted with it
+	msg.Decrypt = false
+	msg.Message = "Dies ist eine Message"
+	_, err = cls.CryptSS(tk, msg)
+	ast.ErrorIs(err, cry.ErrLegacyAlg)
+}
+
+// storeLegacyKey storing a key with the legacy AES-256 algorithm, like keys created before the aead algorithms
+func storeLegacyKey(ast *assert.Assertions, group string) model.EncryptKey {
+	e, err := newKey(group, "")
+	ast.Nil(err)
+	e.Alg = cry.AlgAES256
+	ast.Nil(stg.StoreEncryptKey(*e))
+	return *e
+}
+
 func buildGroupMessage(g string) (pmodel.Message, error) {
 	adr := struct {
 		Lastname  string `json:"lastname"`
@@ -471,6 +560,51 @@ func TestMsgStore(t *testing.T) {
 	ast.False(ok)
 }
 
+func TestStoreDataTTL(t *testing.T) {
+	ast := assert.New(t)
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	tk2, _, _, err := cls.Login("87654321", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	msg := pmodel.Message{
+		Type:      "group",
+		Recipient: "group2",
+		Message:   "Dies ist eine Message",
+		TTL:       "10ms",
+	}
+
+	id, err := cls.StoreData(tk1, msg)
+	ast.Nil(err)
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = cls.GetData(tk2, id)
+	ast.Equal(serror.ErrDataExpired, err)
+
+	// expired data is deleted
+	_, err = cls.GetData(tk2, id)
+	ast.Equal(serror.ErrNotExists, err)
+
+	msg.TTL = "no duration"
+	_, err = cls.StoreData(tk1, msg)
+	ast.NotNil(err)
+
+	// read once
+	msg.TTL = "1d"
+	msg.ReadOnce = true
+	id, err = cls.StoreData(tk1, msg)
+	ast.Nil(err)
+
+	msg1, err := cls.GetData(tk2, id)
+	ast.Nil(err)
+	ast.Equal(msg.Message, msg1.Message)
+
+	_, err = cls.GetData(tk1, id)
+	ast.Equal(serror.ErrNotExists, err)
+}
+
 func TestSSSign(t *testing.T) {
 	ast := assert.New(t)
 
@@ -530,3 +664,151 @@ func checkRToken(rt string, ast *assert.Assertions) {
 	ast.True(ok)
 	ast.Equal("tester1", n)
 }
+
+func TestRotateKey(t *testing.T) {
+	ast := assert.New(t)
+
+	cur, err := cls.CurrentKey("group4")
+	ast.Nil(err)
+	ast.Nil(cur)
+
+	e1, err := cls.RotateKey("group4")
+	ast.Nil(err)
+	ast.NotNil(e1)
+	ast.Equal(1, e1.Version)
+	ast.Equal("group4", e1.Group)
+	ast.Equal(cry.AlgAES256GCM, e1.Alg)
+
+	e2, err := cls.RotateKey("group4")
+	ast.Nil(err)
+	ast.Equal(2, e2.Version)
+	ast.NotEqual(e1.ID, e2.ID)
+	ast.NotEqual(e1.Key, e2.Key)
+
+	cur, err = cls.CurrentKey("group4")
+	ast.Nil(err)
+	ast.Equal(e2.ID, cur.ID)
+
+	// older versions are still available for decryption
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	o, err := cls.GetEncryptKey(tk, e1.ID)
+	ast.Nil(err)
+	ast.Equal(e1.Key, o.Key)
+
+	e3, err := cls.RotateKey("group3")
+	ast.Nil(err)
+	_, err = cls.GetEncryptKey(tk, e3.ID)
+	ast.NotNil(err) // client is not member of group3
+
+	e4, err := cls.RotateKey("group3")
+	ast.Nil(err)
+	ast.Equal(e3.Version+1, e4.Version)
+
+	// a legacy key is rotated to the default algorithm
+	l, err := newKey("group5", "")
+	ast.Nil(err)
+	l.Alg = cry.AlgAES256
+	l.Version = 1
+	ast.Nil(stg.AddGroupKey(*l))
+	e5, err := cls.RotateKey("group5")
+	ast.Nil(err)
+	ast.Equal(2, e5.Version)
+	ast.Equal(cry.AlgAES256GCM, e5.Alg)
+}
+
+func TestRotateKeyConcurrent(t *testing.T) {
+	ast := assert.New(t)
+
+	// concurrent rotations (e.g. the schedulers of several nodes) create every version only once
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	vs := make(map[int]string)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := cls.RotateKey("rotgroup")
+			if err != nil {
+				ast.ErrorIs(err, serror.ErrAlreadyExists)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			_, ok := vs[e.Version]
+			ast.False(ok)
+			vs[e.Version] = e.ID
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	err := cls.stg.ListGroupKeys("rotgroup", func(e model.EncryptKey) bool {
+		ast.Equal(vs[e.Version], e.ID)
+		n++
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(len(vs), n)
+}
+
+func TestKeyLifecycle(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	msg, err := buildGroupMessage("group2")
+	ast.Nil(err)
+
+	m, err := cls.CryptSS(tk, msg)
+	ast.Nil(err)
+
+	// disabled keys are refused
+	e, err := cls.SetKeyState(m.ID, model.KeyStateDisabled)
+	ast.Nil(err)
+	ast.Equal(model.KeyStateDisabled, e.State)
+
+	_, err = cls.GetEncryptKey(tk, m.ID)
+	ast.Equal(serror.ErrKeyNotEnabled, err)
+
+	_, err = cls.CryptSS(tk, *m)
+	ast.NotNil(err)
+
+	// enabled again
+	_, err = cls.SetKeyState(m.ID, model.KeyStateEnabled)
+	ast.Nil(err)
+	m2, err := cls.CryptSS(tk, *m)
+	ast.Nil(err)
+	ast.Equal(msg.Message, m2.Message)
+
+	_, err = cls.SetKeyState(m.ID, "unknown")
+	ast.Equal(serror.ErrKeyStateNotValid, err)
+
+	// scheduled for destruction
+	e, err = cls.SetKeyState(m.ID, model.KeyStateScheduled)
+	ast.Nil(err)
+	ast.Equal(model.KeyStateScheduled, e.State)
+	ast.True(e.DestroyAt.After(time.Now().Add(6 * 24 * time.Hour)))
+
+	_, err = cls.GetEncryptKey(tk, m.ID)
+	ast.Equal(serror.ErrKeyNotEnabled, err)
+
+	ids, err := cls.DestroyKeys(time.Now())
+	ast.Nil(err)
+	ast.NotContains(ids, m.ID)
+
+	ids, err = cls.DestroyKeys(e.DestroyAt)
+	ast.Nil(err)
+	ast.Contains(ids, m.ID)
+
+	// only the metadata is left
+	e, ok := stg.GetEncryptKey(m.ID)
+	ast.True(ok)
+	ast.Equal(model.KeyStateDestroyed, e.State)
+	ast.Empty(e.Key)
+	ast.Equal("group2", e.Group)
+
+	_, err = cls.SetKeyState(m.ID, model.KeyStateEnabled)
+	ast.Equal(serror.ErrKeyStateNotValid, err)
+}
@@ -0,0 +1,178 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+const (
+	// crlRefresh after this the crl will be regenerated, even without a change
+	crlRefresh = 15 * time.Minute
+	// crlValid the next update of the crl
+	crlValid = 24 * time.Hour
+)
+
+// crlCache the actual crls of the ca, one for every issuer id, shared by all copies of the clients service
+type crlCache struct {
+	sync.Mutex
+	crls    map[string][]byte
+	created time.Time
+	revoked time.Time // latest revocation in the storage, when the crls were created
+}
+
+// storeCertificate saving the issued certificate of the client
+func (c *Clients) storeCertificate(cl, profile string, der []byte, pm string) error {
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	return c.stg.StoreCertificate(model.Certificate{
+		Serial:      model.Serial2ID(crt.SerialNumber),
+		Subject:     crt.Subject.String(),
+		Client:      cl,
+		Profile:     profile,
+		NotBefore:   crt.NotBefore,
+		NotAfter:    crt.NotAfter,
+		Certificate: pm,
+	})
+}
+
+// RevokeCertificate revoking the certificate with the serial number, the crl is regenerated
+func (c *Clients) RevokeCertificate(sn, reason string) (*model.Certificate, error) {
+	if reason == "" {
+		reason = "unspecified"
+	}
+	rc, ok := model.CRLReasons[reason]
+	if !ok {
+		return nil, fmt.Errorf("unknown revocation reason: %s", reason)
+	}
+	cr, ok := c.stg.GetCertificate(serialID(sn))
+	if !ok {
+		return nil, serror.NotFound("certificate", sn)
+	}
+	if cr.IsRevoked() {
+		return nil, serror.ErrCertRevoked
+	}
+	cr.Revoked = time.Now()
+	cr.Reason = rc
+	err := c.stg.StoreCertificate(*cr)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("certificate %s of client %s revoked: %s", cr.Serial, cr.Client, reason)
+	// the revocation is stored, so a failing crl must not fail the revocation
+	_, err = c.updateCRL(time.Now(), true)
+	if err != nil {
+		logger.Errorf("error updating crl after revocation of %s: %v", cr.Serial, err)
+	}
+	return cr, nil
+}
+
+// CRL getting the actual crl of the ca as der
+func (c *Clients) CRL() ([]byte, error) {
+	return c.IssuerCRL(c.crt.IssuerIDs()[0])
+}
+
+// CRLPEM getting the actual crl of the ca as pem
+func (c *Clients) CRLPEM() (string, error) {
+	return c.IssuerCRLPEM(c.crt.IssuerIDs()[0])
+}
+
+// IssuerCRL getting the crl of the actual or a previous ca certificate with the issuer id as der
+func (c *Clients) IssuerCRL(id string) ([]byte, error) {
+	crls, err := c.updateCRL(time.Now(), false)
+	if err != nil {
+		return nil, err
+	}
+	der, ok := crls[id]
+	if !ok {
+		return nil, serror.NotFound("issuer", id)
+	}
+	return der, nil
+}
+
+// IssuerCRLPEM getting the crl of the actual or a previous ca certificate with the issuer id as pem
+func (c *Clients) IssuerCRLPEM(id string) (string, error) {
+	der, err := c.IssuerCRL(id)
+	if err != nil {
+		return "", err
+	}
+	crlPEM := new(bytes.Buffer)
+	err = pem.Encode(crlPEM, &pem.Block{
+		Type:  "X509 CRL",
+		Bytes: der,
+	})
+	if err != nil {
+		return "", err
+	}
+	return crlPEM.String(), nil
+}
+
+// UpdateCRL regenerating the crl, if the refresh period is elapsed
+func (c *Clients) UpdateCRL(now time.Time) error {
+	_, err := c.updateCRL(now, false)
+	return err
+}
+
+func (c *Clients) updateCRL(now time.Time, force bool) (map[string][]byte, error) {
+	c.crl.Lock()
+	defer c.crl.Unlock()
+	// a revocation on another node changes the latest revocation in the storage
+	lr, _ := c.stg.LastRevocation()
+	if !force && c.crl.crls != nil && now.Before(c.crl.created.Add(crlRefresh)) && lr.Equal(c.crl.revoked) {
+		return c.crl.crls, nil
+	}
+	entries := make([]x509.RevocationListEntry, 0)
+	err := c.stg.ListCertificates(func(cr model.Certificate) bool {
+		// expired certificates are no longer part of the crl
+		if !cr.IsRevoked() || now.After(cr.NotAfter) {
+			return true
+		}
+		sn, ok := new(big.Int).SetString(cr.Serial, 16)
+		if !ok {
+			logger.Errorf("crl: certificate with wrong serial number: %s", cr.Serial)
+			return true
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   sn,
+			RevocationTime: cr.Revoked,
+			ReasonCode:     cr.Reason,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the serial numbers are unique over all issuers, so every issuer signs the same list
+	crls := make(map[string][]byte)
+	for _, id := range c.crt.IssuerIDs() {
+		der, err := c.crt.CreateIssuerCRL(id, entries, now, now.Add(crlValid))
+		if err != nil {
+			return nil, err
+		}
+		crls[id] = der
+	}
+	c.crl.crls = crls
+	c.crl.created = now
+	c.crl.revoked = lr
+	return crls, nil
+}
+
+// serialID normalize the serial number, given as hex string with optional colons
+func serialID(sn string) string {
+	sn = strings.ToLower(strings.ReplaceAll(sn, ":", ""))
+	sn = strings.TrimLeft(sn, "0")
+	if sn == "" {
+		return "0"
+	}
+	return sn
+}
@@ -0,0 +1,125 @@
+package clients
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+func TestRevokeCertificate(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, k, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	pk, err := cry.Pem2Prv(k)
+	ast.Nil(err)
+	csr, err := createCsrPem(pk)
+	ast.Nil(err)
+	pcrt, err := cls.CreateCertificate(tk, csr)
+	ast.Nil(err)
+
+	p, _ := pem.Decode([]byte(pcrt))
+	ast.NotNil(p)
+	xc, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+	sn := model.Serial2ID(xc.SerialNumber)
+
+	cr, ok := stg.GetCertificate(sn)
+	ast.True(ok)
+	ast.Equal("tester1", cr.Client)
+	ast.Equal(xc.Subject.String(), cr.Subject)
+	ast.False(cr.IsRevoked())
+
+	der, err := cls.CRL()
+	ast.Nil(err)
+	crl, err := x509.ParseRevocationList(der)
+	ast.Nil(err)
+	ast.Nil(crl.CheckSignatureFrom(cls.crt.X509Cert()))
+	ast.False(crlContains(crl, xc))
+
+	_, err = cls.RevokeCertificate(sn, "unknownReason")
+	ast.NotNil(err)
+
+	_, err = cls.RevokeCertificate("4711", "keyCompromise")
+	ast.NotNil(err)
+
+	cr, err = cls.RevokeCertificate(sn, "keyCompromise")
+	ast.Nil(err)
+	ast.True(cr.IsRevoked())
+	ast.Equal(1, cr.Reason)
+
+	_, err = cls.RevokeCertificate(sn, "keyCompromise")
+	ast.ErrorIs(err, serror.ErrCertRevoked)
+
+	der, err = cls.CRL()
+	ast.Nil(err)
+	crl, err = x509.ParseRevocationList(der)
+	ast.Nil(err)
+	ast.True(crlContains(crl, xc))
+	ast.True(crl.NextUpdate.After(time.Now()))
+
+	pm, err := cls.CRLPEM()
+	ast.Nil(err)
+	p, _ = pem.Decode([]byte(pm))
+	ast.NotNil(p)
+	ast.Equal("X509 CRL", p.Type)
+}
+
+func TestCRLRevokedOnOtherNode(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, k, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	pk, err := cry.Pem2Prv(k)
+	ast.Nil(err)
+	csr, err := createCsrPem(pk)
+	ast.Nil(err)
+	pcrt, err := cls.CreateCertificate(tk, csr)
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(pcrt))
+	ast.NotNil(p)
+	xc, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+
+	der, err := cls.CRL()
+	ast.Nil(err)
+	crl, err := x509.ParseRevocationList(der)
+	ast.Nil(err)
+	ast.False(crlContains(crl, xc))
+
+	// the revocation of another node is only written to the storage
+	cr, ok := stg.GetCertificate(model.Serial2ID(xc.SerialNumber))
+	ast.True(ok)
+	cr.Revoked = time.Now()
+	cr.Reason = 1
+	ast.Nil(stg.StoreCertificate(*cr))
+
+	der, err = cls.CRL()
+	ast.Nil(err)
+	crl, err = x509.ParseRevocationList(der)
+	ast.Nil(err)
+	ast.True(crlContains(crl, xc))
+}
+
+func TestSerialID(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Equal("ab12", serialID("00:AB:12"))
+	ast.Equal("ab12", serialID("ab12"))
+	ast.Equal("0", serialID("00"))
+}
+
+func crlContains(crl *x509.RevocationList, xc *x509.Certificate) bool {
+	for _, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(xc.SerialNumber) == 0 {
+			return true
+		}
+	}
+	return false
+}
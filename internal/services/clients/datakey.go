@@ -0,0 +1,106 @@
+package clients
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+const dataKeySize = 32
+
+// GenerateDataKey generates a new data key for envelope encryption. The data key is returned
+// in plaintext and wrapped with the current key of the group. If the group has no current key, a first version will be created.
+func (c *Clients) GenerateDataKey(tk, group string) (*pmodel.DataKey, error) {
+	jt, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	gr, ok := jt.PrivateClaims()["groups"]
+	if !ok {
+		return nil, errors.New(errTkNotValidGroups)
+	}
+	n, ok := jt.PrivateClaims()["name"].(string)
+	f := search(gr, group)
+	if !f && (!ok || (group != n)) {
+		return nil, errors.New("group not valid, can't create a data key for this group")
+	}
+
+	key, err := c.CurrentOrNewKey(group)
+	if err != nil {
+		return nil, err
+	}
+
+	dk := make([]byte, dataKeySize)
+	_, err = rand.Read(dk)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := wrapKey(key, dk)
+	if err != nil {
+		return nil, err
+	}
+	return &pmodel.DataKey{
+		KID:        key.ID,
+		Group:      key.Group,
+		Alg:        cry.AlgAES256GCM,
+		Plaintext:  hex.EncodeToString(dk),
+		Ciphertext: ct,
+	}, nil
+}
+
+// UnwrapDataKey unwraps a data key, the wrapping key is taken from the header of the ciphertext
+func (c *Clients) UnwrapDataKey(tk, ct string) (*pmodel.DataKey, error) {
+	_, kid, err := cry.ParseHeader(ct)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.GetEncryptKey(tk, kid)
+	if err != nil {
+		return nil, err
+	}
+	dk, err := unwrapKey(key, ct)
+	if err != nil {
+		return nil, err
+	}
+	return &pmodel.DataKey{
+		KID:        key.ID,
+		Group:      key.Group,
+		Alg:        cry.AlgAES256GCM,
+		Plaintext:  hex.EncodeToString(dk),
+		Ciphertext: ct,
+	}, nil
+}
+
+// wrapKey wraps the data key with the group key, always using an authenticated encryption bound to the group
+func wrapKey(key *model.EncryptKey, dk []byte) (string, error) {
+	k, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return "", err
+	}
+	return cry.EncryptAEAD(wrapAlg(key.Alg), k, key.ID, string(dk), []byte(key.Group))
+}
+
+// unwrapKey unwraps the data key with the group key
+func unwrapKey(key *model.EncryptKey, ct string) ([]byte, error) {
+	k, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	dk, err := cry.DecryptAEAD(wrapAlg(key.Alg), k, ct, []byte(key.Group))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(dk), nil
+}
+
+// wrapAlg legacy keys are using AES-256-GCM for wrapping
+func wrapAlg(alg string) string {
+	if cry.IsAEAD(alg) {
+		return alg
+	}
+	return cry.AlgAES256GCM
+}
@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+func TestDataKey(t *testing.T) {
+	ast := assert.New(t)
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	tk2, _, _, err := cls.Login("87654321", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	dk, err := cls.GenerateDataKey(tk1, "group2")
+	ast.Nil(err)
+	ast.NotNil(dk)
+	ast.Equal("group2", dk.Group)
+	ast.Len(dk.Plaintext, 64)
+	ast.True(cry.HasHeader(dk.Ciphertext))
+
+	cur, err := cls.CurrentKey("group2")
+	ast.Nil(err)
+	ast.Equal(cur.ID, dk.KID)
+
+	udk, err := cls.UnwrapDataKey(tk2, dk.Ciphertext)
+	ast.Nil(err)
+	ast.Equal(dk.Plaintext, udk.Plaintext)
+	ast.Equal(dk.KID, udk.KID)
+
+	// a second data key is wrapped with the same group key
+	dk2, err := cls.GenerateDataKey(tk1, "group2")
+	ast.Nil(err)
+	ast.Equal(dk.KID, dk2.KID)
+	ast.NotEqual(dk.Plaintext, dk2.Plaintext)
+
+	// not a member of the group
+	_, err = cls.GenerateDataKey(tk1, "group3")
+	ast.NotNil(err)
+
+	// disabled group key
+	_, err = cls.SetKeyState(dk.KID, model.KeyStateDisabled)
+	ast.Nil(err)
+	_, err = cls.UnwrapDataKey(tk2, dk.Ciphertext)
+	ast.NotNil(err)
+	_, err = cls.SetKeyState(dk.KID, model.KeyStateEnabled)
+	ast.Nil(err)
+
+	_, err = cls.UnwrapDataKey(tk2, "no valid ciphertext")
+	ast.NotNil(err)
+}
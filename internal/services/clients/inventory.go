@@ -0,0 +1,87 @@
+package clients
+
+import (
+	"encoding/pem"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+// ServiceClient the client name of the certificates, the ca service creates for itself
+const ServiceClient = "micro-vault"
+
+var (
+	certExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mv_certificate_expiry_timestamp_seconds",
+		Help: "soonest expiry of the valid, not revoked certificates of a client as unix timestamp",
+	}, []string{"client"})
+	caExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mv_ca_certificate_expiry_timestamp_seconds",
+		Help: "expiry of the ca certificates as unix timestamp, ca is the signing certificate, root the root certificate",
+	}, []string{"ca"})
+)
+
+// Certificates getting the issued certificates matching the filter, sorted by the expiry
+func (c *Clients) Certificates(f model.CertificateFilter) ([]model.Certificate, error) {
+	now := time.Now()
+	crs := make([]model.Certificate, 0)
+	err := c.stg.ListCertificates(func(cr model.Certificate) bool {
+		if f.Match(cr, now) {
+			crs = append(crs, cr)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(crs, func(a, b model.Certificate) int {
+		return a.NotAfter.Compare(b.NotAfter)
+	})
+	return crs, nil
+}
+
+// Certificate getting the issued certificate with the serial number (hex)
+func (c *Clients) Certificate(sn string) (*model.Certificate, error) {
+	cr, ok := c.stg.GetCertificate(serialID(sn))
+	if !ok {
+		return nil, serror.NotFound("certificate", sn)
+	}
+	return cr, nil
+}
+
+// storeServiceCertificate saving a certificate, the ca service created for itself
+func (c *Clients) storeServiceCertificate(der []byte) {
+	pm := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	err := c.storeCertificate(ServiceClient, "", der, string(pm))
+	if err != nil {
+		logger.Errorf("error storing service certificate: %v", err)
+	}
+}
+
+// UpdateCertMetrics updating the expiry gauges of the issued and the ca certificates
+func (c *Clients) UpdateCertMetrics(now time.Time) error {
+	exp := make(map[string]time.Time)
+	err := c.stg.ListCertificates(func(cr model.Certificate) bool {
+		if cr.IsRevoked() || now.After(cr.NotAfter) {
+			return true
+		}
+		if e, ok := exp[cr.Client]; !ok || cr.NotAfter.Before(e) {
+			exp[cr.Client] = cr.NotAfter
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	certExpiry.Reset()
+	for cl, e := range exp {
+		certExpiry.WithLabelValues(cl).Set(float64(e.Unix()))
+	}
+	caExpiry.WithLabelValues("ca").Set(float64(c.crt.X509Cert().NotAfter.Unix()))
+	caExpiry.WithLabelValues("root").Set(float64(c.crt.RootCert().NotAfter.Unix()))
+	return nil
+}
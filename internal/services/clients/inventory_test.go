@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+func TestCertificateInventory(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, k, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	pk, err := cry.Pem2Prv(k)
+	ast.Nil(err)
+	csr, err := createCsrPem(pk)
+	ast.Nil(err)
+	pcrt, err := cls.CreateCertificate(tk, csr)
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(pcrt))
+	ast.NotNil(p)
+	xc, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+	sn := model.Serial2ID(xc.SerialNumber)
+
+	// certificates of the ca service itself are part of the inventory
+	sc, err := cls.crt.CreateCertificate()
+	ast.Nil(err)
+	ast.NotNil(sc)
+	crs, err := cls.Certificates(model.CertificateFilter{Client: ServiceClient})
+	ast.Nil(err)
+	ast.Less(0, len(crs))
+
+	crs, err = cls.Certificates(model.CertificateFilter{Client: "tester1"})
+	ast.Nil(err)
+	ast.True(containsSerial(crs, sn))
+	for x := 1; x < len(crs); x++ {
+		ast.False(crs[x].NotAfter.Before(crs[x-1].NotAfter))
+	}
+
+	// tester1 certificates are valid for 30 days
+	crs, err = cls.Certificates(model.CertificateFilter{Client: "tester1", ExpiringWithin: 7 * 24 * time.Hour})
+	ast.Nil(err)
+	ast.False(containsSerial(crs, sn))
+	crs, err = cls.Certificates(model.CertificateFilter{Client: "tester1", ExpiringWithin: 31 * 24 * time.Hour})
+	ast.Nil(err)
+	ast.True(containsSerial(crs, sn))
+
+	cr, err := cls.Certificate(sn)
+	ast.Nil(err)
+	ast.Equal(pcrt[:len(cr.Certificate)], cr.Certificate)
+	_, err = cls.Certificate("4711")
+	ast.NotNil(err)
+
+	err = cls.UpdateCertMetrics(time.Now())
+	ast.Nil(err)
+	ast.Less(float64(time.Now().Unix()), testutil.ToFloat64(certExpiry.WithLabelValues("tester1")))
+	ast.Equal(float64(cls.crt.X509Cert().NotAfter.Unix()), testutil.ToFloat64(caExpiry.WithLabelValues("ca")))
+
+	_, err = cls.RevokeCertificate(sn, "superseded")
+	ast.Nil(err)
+	rv := true
+	crs, err = cls.Certificates(model.CertificateFilter{Client: "tester1", Revoked: &rv})
+	ast.Nil(err)
+	ast.True(containsSerial(crs, sn))
+	rv = false
+	crs, err = cls.Certificates(model.CertificateFilter{Client: "tester1", Revoked: &rv})
+	ast.Nil(err)
+	ast.False(containsSerial(crs, sn))
+}
+
+func containsSerial(crs []model.Certificate, sn string) bool {
+	for _, cr := range crs {
+		if cr.Serial == sn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package clients
+
+import (
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// MaxBatchSize maximal count of items in one batch request
+const MaxBatchSize = 10000
+
+// keyCache caching the keys of one request, so a batch for one group only needs one key lookup
+type keyCache struct {
+	c       *Clients
+	tk      string
+	keys    map[string]*model.EncryptKey
+	current map[string]*model.EncryptKey
+	created map[string]*model.EncryptKey
+	pubs    map[string]crypto.PublicKey
+}
+
+func (c *Clients) newKeyCache(tk string) *keyCache {
+	return &keyCache{
+		c:       c,
+		tk:      tk,
+		keys:    make(map[string]*model.EncryptKey),
+		current: make(map[string]*model.EncryptKey),
+		created: make(map[string]*model.EncryptKey),
+		pubs:    make(map[string]crypto.PublicKey),
+	}
+}
+
+// get returns the key with the id, checking the access of the client
+func (k *keyCache) get(id string) (*model.EncryptKey, error) {
+	if e, ok := k.keys[id]; ok {
+		return e, nil
+	}
+	e, err := k.c.GetEncryptKey(k.tk, id)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[id] = e
+	return e, nil
+}
+
+// sourceKey returns the key with the id for the decryption of a rewrap. Keys scheduled for destruction
+// can still be used until their destruction, so the ciphertexts can be migrated in the grace period.
+func (k *keyCache) sourceKey(id string) (*model.EncryptKey, error) {
+	if e, ok := k.keys[id]; ok {
+		return e, nil
+	}
+	e, err := k.c.encryptKey(k.tk, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Enabled() {
+		k.keys[id] = e
+		return e, nil
+	}
+	if e.KeyState() != model.KeyStateScheduled || !time.Now().Before(e.DestroyAt) {
+		return nil, serror.ErrKeyNotEnabled
+	}
+	return e, nil
+}
+
+// currentKey returns the current key of the group
+func (k *keyCache) currentKey(group string) (*model.EncryptKey, error) {
+	if e, ok := k.current[group]; ok {
+		return e, nil
+	}
+	e, err := k.c.CurrentOrNewKey(group)
+	if err != nil {
+		return nil, err
+	}
+	k.current[group] = e
+	k.keys[e.ID] = e
+	return e, nil
+}
+
+// newKey returns a new key for the group, the key is created only once per cache
+func (k *keyCache) newKey(group, alg string) (*model.EncryptKey, error) {
+	ck := group + "|" + alg
+	if e, ok := k.created[ck]; ok {
+		return e, nil
+	}
+	e, err := k.c.CreateEncryptKey(k.tk, group, alg)
+	if err != nil {
+		return nil, err
+	}
+	k.created[ck] = e
+	k.keys[e.ID] = e
+	return e, nil
+}
+
+// publicKey returns the public key of the client with the name
+func (k *keyCache) publicKey(name string) (crypto.PublicKey, error) {
+	if pub, ok := k.pubs[name]; ok {
+		return pub, nil
+	}
+	key, err := k.c.GetPublicKey(k.tk, name)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := cry.Pem2PubKey(key)
+	if err != nil {
+		return nil, err
+	}
+	k.pubs[name] = pub
+	return pub, nil
+}
+
+// publicKey4KID returns the public key of the client with the kid
+func (k *keyCache) publicKey4KID(kid string) (crypto.PublicKey, error) {
+	if pub, ok := k.pubs["kid:"+kid]; ok {
+		return pub, nil
+	}
+	pub, err := k.c.publicKey4KID(kid)
+	if err != nil {
+		return nil, err
+	}
+	k.pubs["kid:"+kid] = pub
+	return pub, nil
+}
+
+// ssClient server side encryption of a private message
+func (k *keyCache) ssClient(msg pmodel.Message) (*pmodel.Message, error) {
+	if msg.Decrypt {
+		return nil, errors.New("server side private decryption is not supported")
+	}
+	if msg.Recipient == "" {
+		return nil, errors.New("missing recipient")
+	}
+	pub, err := k.publicKey(msg.Recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := cry.EncryptPub(pub, msg.Message)
+	if err != nil {
+		return nil, err
+	}
+	msg.Message = ct
+	msg.Decrypt = true
+	return &msg, nil
+}
+
+// ssGroup server side en/decryption of a group message, new keys are shared for all messages to the same group
+func (k *keyCache) ssGroup(msg pmodel.Message) (*pmodel.Message, error) {
+	if msg.Decrypt {
+		if msg.ID == "" && cry.HasHeader(msg.Message) {
+			_, kid, err := cry.ParseHeader(msg.Message)
+			if err != nil {
+				return nil, err
+			}
+			msg.ID = kid
+		}
+		if msg.ID == "" {
+			return nil, errors.New("missing key id")
+		}
+		key, err := k.get(msg.ID)
+		if err != nil {
+			return nil, err
+		}
+		kb, err := hex.DecodeString(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		m, err := cry.DecryptAlg(key.Alg, kb, msg.Message, []byte(msg.AD))
+		if err != nil {
+			return nil, err
+		}
+		msg.Message = m
+		msg.Alg = key.Alg
+		msg.Decrypt = false
+		return &msg, nil
+	}
+	// Encrypt
+	var key *model.EncryptKey
+	var err error
+	if msg.ID == "" {
+		key, err = k.newKey(msg.Recipient, msg.Alg)
+	} else {
+		key, err = k.get(msg.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kb, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	m, err := cry.EncryptAlg(key.Alg, kb, key.ID, msg.Message, []byte(msg.AD))
+	if err != nil {
+		return nil, err
+	}
+	msg.ID = key.ID
+	msg.Alg = key.Alg
+	msg.Recipient = key.Group
+	msg.Message = m
+	msg.Decrypt = true
+	return &msg, nil
+}
@@ -0,0 +1,95 @@
+package clients
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+func addKeyTypeClient(ast *assert.Assertions, n, kt string) string {
+	pk, err := cry.GenerateKey(kt)
+	ast.Nil(err)
+	pp, err := cry.Prv2Pem(pk)
+	ast.Nil(err)
+	kid, err := cry.GetKID(pk)
+	ast.Nil(err)
+	salt, err := cry.GenerateSalt()
+	ast.Nil(err)
+	secret, err := hex.DecodeString("e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	_, err = stg.AddClient(model.Client{
+		Name:      n,
+		Salt:      hex.EncodeToString(salt),
+		AccessKey: n,
+		Hash:      cry.HashSecret(secret, salt),
+		Groups:    []string{"group2"},
+		Key:       string(pp),
+		KID:       kid,
+		KeyType:   kt,
+	})
+	ast.Nil(err)
+	_, err = stg.AddGroup(model.Group{Name: n, IsClient: true})
+	ast.Nil(err)
+	return string(pp)
+}
+
+func TestKeyTypeClients(t *testing.T) {
+	ast := assert.New(t)
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	for kt, alg := range map[string]string{cry.KeyTypeECDSAP256: "ES256", cry.KeyTypeECDSAP384: "ES384", cry.KeyTypeEd25519: "EdDSA"} {
+		n := "keytype-" + kt
+		pp := addKeyTypeClient(ast, n, kt)
+		pk, err := cry.Pem2Key(pp)
+		ast.Nil(err)
+		tk, _, k, err := cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+		ast.Nil(err)
+		ast.Equal(pp, k)
+
+		// server side signature, checked by another client
+		msg, err := cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+		ast.Nil(err)
+		ast.Equal(alg, msg.KeyInfo.Alg)
+		msg, err = cls.CheckSS(tk1, msg)
+		ast.Nil(err)
+		ast.True(msg.Valid)
+
+		// server side encryption for the client
+		cm, err := buildClientMessage(n)
+		ast.Nil(err)
+		m, err := cls.CryptSS(tk1, cm)
+		ast.Nil(err)
+		pt, err := cry.DecryptPrv(pk, m.Message)
+		ast.Nil(err)
+		ast.Equal(cm.Message, pt)
+
+		// public key and certificate of the client
+		pub, err := cls.GetPublicKey(tk1, n)
+		ast.Nil(err)
+		ok, err := cry.SignCheckPEM(pub, msg.Signature, msg.Message)
+		ast.Nil(err)
+		ast.True(ok)
+
+		// the key of the request is ignored, the certificate is for the key of the client
+		tpk, err := cry.GenerateKey(cry.KeyTypeRSA2048)
+		ast.Nil(err)
+		csr, err := createCsrPem(tpk)
+		ast.Nil(err)
+		pc, err := cls.CreateCertificate(tk, csr)
+		ast.Nil(err)
+		b, _ := pem.Decode([]byte(pc))
+		ast.NotNil(b)
+		xc, err := x509.ParseCertificate(b.Bytes)
+		ast.Nil(err)
+		pm, err := cry.Pub2Pem(xc.PublicKey)
+		ast.Nil(err)
+		ast.Equal(pub, string(pm))
+	}
+}
@@ -0,0 +1,296 @@
+package clients
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+const (
+	errKVGroup     = "group not valid, no access to the secrets of this group"
+	errKVGroupName = "group not valid, must not contain /"
+
+	defaultKVMaxVersions = 100
+	// kvRetries retries of a write after a concurrent write of the same secret
+	kvRetries = 5
+)
+
+// PutKV writes a new version of the secret. With cas the write only succeeds,
+// if cas is the current version of the secret (0 for a new secret).
+func (c *Clients) PutKV(tk, group, path, value string, cas *int) (*pmodel.KVMetadata, error) {
+	n, path, err := c.checkKV(tk, group, path)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < kvRetries; i++ {
+		kv, err := c.putKV(n, group, path, value, cas)
+		// without cas a concurrent write is retried with the new version
+		if errors.Is(err, serror.ErrCASMismatch) && cas == nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return kvMetadata(*kv), nil
+	}
+	return nil, serror.ErrCASMismatch
+}
+
+// putKV writes the new version, the storage only accepts the write, if the secret wasn't changed since reading
+func (c *Clients) putKV(n, group, path, value string, cas *int) (*model.KVSecret, error) {
+	now := time.Now()
+	kv, ok := c.stg.GetKV(group, path)
+	if !ok {
+		kv = &model.KVSecret{
+			Group:     group,
+			Path:      path,
+			Created:   now,
+			CreatedBy: n,
+			Versions:  make([]model.KVVersion, 0),
+		}
+	}
+	if cas != nil && *cas != kv.CurrentVersion {
+		return nil, serror.ErrCASMismatch
+	}
+	kv.CurrentVersion++
+	kv.Updated = now
+	kv.UpdatedBy = n
+	kv.Versions = append(kv.Versions, model.KVVersion{
+		Version:   kv.CurrentVersion,
+		Value:     value,
+		Created:   now,
+		CreatedBy: n,
+	})
+	if mx := c.kvMaxVersions(); len(kv.Versions) > mx {
+		kv.Versions = kv.Versions[len(kv.Versions)-mx:]
+	}
+	err := c.stg.StoreKV(*kv, kv.Revision)
+	if err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+// GetKV reading a version of the secret, version 0 is the current version
+func (c *Clients) GetKV(tk, group, path string, version int) (*pmodel.KVSecret, error) {
+	_, path, err := c.checkKV(tk, group, path)
+	if err != nil {
+		return nil, err
+	}
+	kv, ok := c.stg.GetKV(group, path)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	if version == 0 {
+		version = kv.CurrentVersion
+	}
+	v, ok := kv.Version(version)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	if !v.Deleted.IsZero() {
+		return nil, serror.ErrSecretDeleted
+	}
+	return &pmodel.KVSecret{
+		Group:     kv.Group,
+		Path:      kv.Path,
+		Version:   v.Version,
+		Value:     v.Value,
+		Created:   v.Created,
+		CreatedBy: v.CreatedBy,
+	}, nil
+}
+
+// GetKVMetadata reading the metadata of the secret with all versions
+func (c *Clients) GetKVMetadata(tk, group, path string) (*pmodel.KVMetadata, error) {
+	_, path, err := c.checkKV(tk, group, path)
+	if err != nil {
+		return nil, err
+	}
+	kv, ok := c.stg.GetKV(group, path)
+	if !ok {
+		return nil, serror.ErrNotExists
+	}
+	return kvMetadata(*kv), nil
+}
+
+// ListKV lists the keys of the group below the prefix, sub folders ending with a /
+func (c *Clients) ListKV(tk, group, prefix string) ([]string, error) {
+	jt, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(group, "/") {
+		return nil, errors.New(errKVGroupName)
+	}
+	if !memberOf(jt, group) {
+		return nil, errors.New(errKVGroup)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	ks := make(map[string]bool)
+	err = c.stg.ListKV(group, func(kv model.KVSecret) bool {
+		if !strings.HasPrefix(kv.Path, prefix) {
+			return true
+		}
+		k := strings.TrimPrefix(kv.Path, prefix)
+		if i := strings.Index(k, "/"); i >= 0 {
+			k = k[:i+1]
+		}
+		ks[k] = true
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	l := make([]string, 0, len(ks))
+	for k := range ks {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l, nil
+}
+
+// DeleteKV soft deletes the versions of the secret, without versions the current version is deleted
+func (c *Clients) DeleteKV(tk, group, path string, versions []int) error {
+	return c.markKV(tk, group, path, versions, time.Now())
+}
+
+// UndeleteKV restores soft deleted versions of the secret
+func (c *Clients) UndeleteKV(tk, group, path string, versions []int) error {
+	if len(versions) == 0 {
+		return errors.New("no versions to undelete")
+	}
+	return c.markKV(tk, group, path, versions, time.Time{})
+}
+
+// DestroyKV removes the secret with all versions and the metadata permanently
+func (c *Clients) DestroyKV(tk, group, path string) (bool, error) {
+	_, path, err := c.checkKV(tk, group, path)
+	if err != nil {
+		return false, err
+	}
+	return c.stg.DeleteKV(group, path)
+}
+
+func (c *Clients) markKV(tk, group, path string, versions []int, deleted time.Time) error {
+	_, path, err := c.checkKV(tk, group, path)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < kvRetries; i++ {
+		kv, ok := c.stg.GetKV(group, path)
+		if !ok {
+			return serror.ErrNotExists
+		}
+		vs := versions
+		if len(vs) == 0 {
+			vs = []int{kv.CurrentVersion}
+		}
+		for _, vn := range vs {
+			v, ok := kv.Version(vn)
+			if !ok {
+				return serror.ErrNotExists
+			}
+			v.Deleted = deleted
+		}
+		err = c.stg.StoreKV(*kv, kv.Revision)
+		// a concurrent write, retry with the new revision
+		if errors.Is(err, serror.ErrCASMismatch) {
+			continue
+		}
+		return err
+	}
+	return serror.ErrCASMismatch
+}
+
+func (c *Clients) kvMaxVersions() int {
+	if c.cfg.Service.KVMaxVersions > 0 {
+		return c.cfg.Service.KVMaxVersions
+	}
+	return defaultKVMaxVersions
+}
+
+// checkKV checks the token, the membership of the group and the path, returning the client name and the cleaned path
+func (c *Clients) checkKV(tk, group, path string) (string, string, error) {
+	jt, err := c.checkTk(tk)
+	if err != nil {
+		return "", "", err
+	}
+	n, ok := jt.PrivateClaims()["name"].(string)
+	if !ok {
+		return "", "", serror.ErrTokenNotValid
+	}
+	// the group and the path are separated by a /, see model.KVID
+	if strings.Contains(group, "/") {
+		return "", "", errors.New(errKVGroupName)
+	}
+	if !memberOf(jt, group) {
+		return "", "", errors.New(errKVGroup)
+	}
+	path, err = kvPath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if path == "" {
+		return "", "", serror.ErrMissingID
+	}
+	return n, path, nil
+}
+
+func memberOf(jt jwt.Token, group string) bool {
+	gr, ok := jt.PrivateClaims()["groups"]
+	if !ok {
+		return false
+	}
+	n, ok := jt.PrivateClaims()["name"].(string)
+	return search(gr, group) || (ok && group == n)
+}
+
+// kvPath cleans the path, segments must not be empty or relative
+func kvPath(p string) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	for _, s := range strings.Split(p, "/") {
+		if s == "" || s == "." || s == ".." {
+			return "", errors.New("path not valid")
+		}
+	}
+	return p, nil
+}
+
+func kvMetadata(kv model.KVSecret) *pmodel.KVMetadata {
+	md := pmodel.KVMetadata{
+		Group:          kv.Group,
+		Path:           kv.Path,
+		CurrentVersion: kv.CurrentVersion,
+		Created:        kv.Created,
+		CreatedBy:      kv.CreatedBy,
+		Updated:        kv.Updated,
+		UpdatedBy:      kv.UpdatedBy,
+		Versions:       make([]pmodel.KVVersionInfo, 0, len(kv.Versions)),
+	}
+	for _, v := range kv.Versions {
+		vi := pmodel.KVVersionInfo{
+			Version:   v.Version,
+			Created:   v.Created,
+			CreatedBy: v.CreatedBy,
+		}
+		if !v.Deleted.IsZero() {
+			d := v.Deleted
+			vi.Deleted = &d
+		}
+		md.Versions = append(md.Versions, vi)
+	}
+	return &md
+}
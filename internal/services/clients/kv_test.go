@@ -0,0 +1,192 @@
+package clients
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+func TestKV(t *testing.T) {
+	ast := assert.New(t)
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	tk2, _, _, err := cls.Login("87654321", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	tk3, _, _, err := cls.Login("345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	md, err := cls.PutKV(tk1, "group2", "/db/password/", "secret1", nil)
+	ast.Nil(err)
+	ast.Equal("db/password", md.Path)
+	ast.Equal(1, md.CurrentVersion)
+	ast.Equal("tester1", md.CreatedBy)
+
+	// check-and-set
+	cas := 0
+	_, err = cls.PutKV(tk2, "group2", "db/password", "secret2", &cas)
+	ast.Equal(serror.ErrCASMismatch, err)
+	cas = 1
+	md, err = cls.PutKV(tk2, "group2", "db/password", "secret2", &cas)
+	ast.Nil(err)
+	ast.Equal(2, md.CurrentVersion)
+	ast.Equal("tester1", md.CreatedBy)
+	ast.NotEqual("tester1", md.UpdatedBy)
+	ast.Len(md.Versions, 2)
+
+	kv, err := cls.GetKV(tk1, "group2", "db/password", 0)
+	ast.Nil(err)
+	ast.Equal("secret2", kv.Value)
+	ast.Equal(2, kv.Version)
+
+	kv, err = cls.GetKV(tk1, "group2", "db/password", 1)
+	ast.Nil(err)
+	ast.Equal("secret1", kv.Value)
+
+	_, err = cls.GetKV(tk1, "group2", "db/password", 3)
+	ast.Equal(serror.ErrNotExists, err)
+
+	// the group and the path can't be mixed up
+	_, err = cls.GetKV(tk1, "group2/db", "password", 0)
+	ast.NotNil(err)
+	_, err = cls.ListKV(tk1, "group2/db", "")
+	ast.NotNil(err)
+
+	// no member of the group
+	_, err = cls.GetKV(tk3, "group2", "db/password", 0)
+	ast.NotNil(err)
+	_, err = cls.PutKV(tk3, "group2", "db/password", "hacked", nil)
+	ast.NotNil(err)
+
+	// soft delete and undelete
+	err = cls.DeleteKV(tk1, "group2", "db/password", nil)
+	ast.Nil(err)
+	_, err = cls.GetKV(tk1, "group2", "db/password", 0)
+	ast.Equal(serror.ErrSecretDeleted, err)
+	kv, err = cls.GetKV(tk1, "group2", "db/password", 1)
+	ast.Nil(err)
+	ast.Equal("secret1", kv.Value)
+
+	md, err = cls.GetKVMetadata(tk1, "group2", "db/password")
+	ast.Nil(err)
+	ast.NotNil(md.Versions[1].Deleted)
+	ast.Nil(md.Versions[0].Deleted)
+
+	err = cls.UndeleteKV(tk1, "group2", "db/password", []int{2})
+	ast.Nil(err)
+	kv, err = cls.GetKV(tk1, "group2", "db/password", 0)
+	ast.Nil(err)
+	ast.Equal("secret2", kv.Value)
+
+	// listing
+	_, err = cls.PutKV(tk1, "group2", "db/user", "dbuser", nil)
+	ast.Nil(err)
+	_, err = cls.PutKV(tk1, "group2", "api/token", "token", nil)
+	ast.Nil(err)
+	ks, err := cls.ListKV(tk1, "group2", "")
+	ast.Nil(err)
+	ast.Equal([]string{"api/", "db/"}, ks)
+	ks, err = cls.ListKV(tk1, "group2", "db/")
+	ast.Nil(err)
+	ast.Equal([]string{"password", "user"}, ks)
+	_, err = cls.ListKV(tk3, "group2", "")
+	ast.NotNil(err)
+
+	// invalid paths
+	_, err = cls.PutKV(tk1, "group2", "db/../password", "x", nil)
+	ast.NotNil(err)
+	_, err = cls.PutKV(tk1, "group2", "", "x", nil)
+	ast.NotNil(err)
+
+	// destroy
+	ok, err := cls.DestroyKV(tk1, "group2", "db/password")
+	ast.Nil(err)
+	ast.True(ok)
+	_, err = cls.GetKVMetadata(tk1, "group2", "db/password")
+	ast.Equal(serror.ErrNotExists, err)
+}
+
+func TestKVConcurrent(t *testing.T) {
+	ast := assert.New(t)
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	// with check-and-set only one of the concurrent writes succeeds
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cas := 0
+			_, err := cls.PutKV(tk1, "group2", "app/cas", fmt.Sprintf("v%d", i), &cas)
+			if err == nil {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	ast.Equal(1, ok)
+
+	// without check-and-set no write is lost
+	ok = 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := cls.PutKV(tk1, "group2", "app/cas", fmt.Sprintf("w%d", i), nil)
+			if err == nil {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	md, err := cls.GetKVMetadata(tk1, "group2", "app/cas")
+	ast.Nil(err)
+	ast.Equal(1+ok, md.CurrentVersion)
+
+	_, err = cls.DestroyKV(tk1, "group2", "app/cas")
+	ast.Nil(err)
+}
+
+func TestKVMaxVersions(t *testing.T) {
+	ast := assert.New(t)
+	cls.cfg.Service.KVMaxVersions = 3
+	defer func() {
+		cls.cfg.Service.KVMaxVersions = 0
+	}()
+
+	tk1, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	for i := 1; i <= 5; i++ {
+		_, err = cls.PutKV(tk1, "group2", "app/max", fmt.Sprintf("v%d", i), nil)
+		ast.Nil(err)
+	}
+	md, err := cls.GetKVMetadata(tk1, "group2", "app/max")
+	ast.Nil(err)
+	ast.Equal(5, md.CurrentVersion)
+	ast.Len(md.Versions, 3)
+	ast.Equal(3, md.Versions[0].Version)
+
+	// the oldest versions are removed
+	_, err = cls.GetKV(tk1, "group2", "app/max", 2)
+	ast.Equal(serror.ErrNotExists, err)
+	kv, err := cls.GetKV(tk1, "group2", "app/max", 3)
+	ast.Nil(err)
+	ast.Equal("v3", kv.Value)
+
+	_, err = cls.DestroyKV(tk1, "group2", "app/max")
+	ast.Nil(err)
+}
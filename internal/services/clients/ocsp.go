@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspValid the next update of an ocsp response
+const ocspValid = time.Hour
+
+// OCSP answering a der encoded ocsp request from the issued certificate records.
+// Error states are returned as ocsp error responses, an error is only returned, if no response could be created.
+func (c *Clients) OCSP(der []byte) ([]byte, error) {
+	req, err := ocsp.ParseRequest(der)
+	if err != nil {
+		logger.Errorf("ocsp: malformed request: %v", err)
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+	nonce, err := keyman.OCSPNonce(der)
+	if err != nil {
+		logger.Errorf("ocsp: malformed nonce: %v", err)
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+	if !c.crt.IsOCSPIssuer(req) {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+	now := time.Now()
+	tmp := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(ocspValid),
+	}
+	cr, ok := c.stg.GetCertificate(model.Serial2ID(req.SerialNumber))
+	if ok {
+		tmp.Status = ocsp.Good
+		if cr.IsRevoked() {
+			tmp.Status = ocsp.Revoked
+			tmp.RevokedAt = cr.Revoked
+			tmp.RevocationReason = cr.Reason
+		}
+	}
+	b, err := c.crt.CreateOCSPResponse(req, tmp, nonce)
+	if err != nil {
+		logger.Errorf("ocsp: error creating response: %v", err)
+		return ocsp.InternalErrorErrorResponse, err
+	}
+	return b, nil
+}
@@ -0,0 +1,65 @@
+package clients
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestOCSP(t *testing.T) {
+	ast := assert.New(t)
+	tk, _, k, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	pk, err := cry.Pem2Prv(k)
+	ast.Nil(err)
+	csr, err := createCsrPem(pk)
+	ast.Nil(err)
+	pcrt, err := cls.CreateCertificate(tk, csr)
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(pcrt))
+	ast.NotNil(p)
+	xc, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+
+	ca := cls.crt.X509Cert()
+	der, err := ocsp.CreateRequest(xc, ca, nil)
+	ast.Nil(err)
+
+	b, err := cls.OCSP(der)
+	ast.Nil(err)
+	res, err := ocsp.ParseResponseForCert(b, xc, ca)
+	ast.Nil(err)
+	ast.Equal(ocsp.Good, res.Status)
+
+	_, err = cls.RevokeCertificate(model.Serial2ID(xc.SerialNumber), "superseded")
+	ast.Nil(err)
+
+	b, err = cls.OCSP(der)
+	ast.Nil(err)
+	res, err = ocsp.ParseResponseForCert(b, xc, ca)
+	ast.Nil(err)
+	ast.Equal(ocsp.Revoked, res.Status)
+	ast.Equal(ocsp.Superseded, res.RevocationReason)
+
+	// unknown certificate
+	xc2 := *xc
+	xc2.SerialNumber = big.NewInt(4711)
+	der, err = ocsp.CreateRequest(&xc2, ca, nil)
+	ast.Nil(err)
+	b, err = cls.OCSP(der)
+	ast.Nil(err)
+	res, err = ocsp.ParseResponse(b, ca)
+	ast.Nil(err)
+	ast.Equal(ocsp.Unknown, res.Status)
+
+	b, err = cls.OCSP([]byte("no ocsp request"))
+	ast.Nil(err)
+	ast.Equal(ocsp.MalformedRequestErrorResponse, b)
+}
@@ -0,0 +1,302 @@
+package clients
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+)
+
+// Profiles getting all certificate profiles
+func (c *Clients) Profiles() ([]model.CertProfile, error) {
+	ps := make([]model.CertProfile, 0)
+	err := c.stg.ListProfiles(func(p model.CertProfile) bool {
+		ps = append(ps, p)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(ps, func(a, b model.CertProfile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return ps, nil
+}
+
+// Profile getting the certificate profile with the name
+func (c *Clients) Profile(n string) (*model.CertProfile, error) {
+	p, ok := c.stg.GetProfile(n)
+	if !ok {
+		return nil, serror.NotFound("profile", n)
+	}
+	return p, nil
+}
+
+// StoreProfile validating and storing the certificate profile
+func (c *Clients) StoreProfile(p model.CertProfile) error {
+	err := p.Validate()
+	if err != nil {
+		return serror.Wrapc(err, 400)
+	}
+	return c.stg.StoreProfile(p)
+}
+
+// DeleteProfile deleting the certificate profile with the name
+func (c *Clients) DeleteProfile(n string) (bool, error) {
+	return c.stg.DeleteProfile(n)
+}
+
+// ClientProfiles the names of the certificate profiles assigned in the certificate template of the client,
+// the first one is the default profile
+func ClientProfiles(cl model.Client) []string {
+	switch v := cl.Crt["profiles"].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		ps := make([]string, 0)
+		for _, mv := range v {
+			if p, ok := mv.(string); ok {
+				ps = append(ps, p)
+			}
+		}
+		return ps
+	}
+	return nil
+}
+
+// certProfile getting the profile of a new certificate of the client, nil for a client without profiles
+func (c *Clients) certProfile(cl model.Client, name string) (*model.CertProfile, error) {
+	pns := ClientProfiles(cl)
+	if len(pns) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("%w: profile %s is not assigned to the client", serror.ErrProfileViolation, name)
+		}
+		return nil, nil
+	}
+	if name == "" {
+		name = pns[0]
+	}
+	if !slices.Contains(pns, name) {
+		return nil, fmt.Errorf("%w: profile %s is not assigned to the client", serror.ErrProfileViolation, name)
+	}
+	return c.Profile(name)
+}
+
+// applyProfile checks the certificate request against the profile and the certificate template of the client,
+// sets the fixed subject fields and returns the usage and the maximal validity of the certificate, 0 for no limit
+func applyProfile(p model.CertProfile, cl model.Client, tmp *x509.CertificateRequest) (keyman.CertUsage, time.Duration, error) {
+	var usage keyman.CertUsage
+	var maxValid time.Duration
+	if p.MaxValidity != "" {
+		var err error
+		maxValid, err = str2duration.ParseDuration(p.MaxValidity)
+		if err != nil {
+			return usage, 0, err
+		}
+	}
+	err := checkSubject(p, cl, tmp)
+	if err != nil {
+		return usage, 0, err
+	}
+	err = checkNames(p, cl, tmp)
+	if err != nil {
+		return usage, 0, err
+	}
+
+	for _, ku := range p.KeyUsage {
+		usage.KeyUsage |= model.KeyUsages[ku]
+	}
+	for _, eku := range p.ExtKeyUsage {
+		usage.ExtKeyUsage = append(usage.ExtKeyUsage, model.ExtKeyUsages[eku])
+	}
+	if p.CA != nil {
+		usage.IsCA = true
+		usage.MaxPathLen = p.CA.MaxPathLen
+		usage.PermittedDNSDomains = p.CA.PermittedDNS
+		usage.ExcludedDNSDomains = p.CA.ExcludedDNS
+		usage.PermittedIPRanges, err = parseCIDRs(p.CA.PermittedIPs)
+		if err != nil {
+			return usage, 0, err
+		}
+		usage.ExcludedIPRanges, err = parseCIDRs(p.CA.ExcludedIPs)
+		if err != nil {
+			return usage, 0, err
+		}
+	}
+	return usage, maxValid, nil
+}
+
+func checkSubject(p model.CertProfile, cl model.Client, tmp *x509.CertificateRequest) error {
+	sp := p.Subject
+	for k, v := range sp.Fixed {
+		switch k {
+		case "uco":
+			tmp.Subject.Country = []string{v}
+		case "upr":
+			tmp.Subject.Province = []string{v}
+		case "ulo":
+			tmp.Subject.Locality = []string{v}
+		case "uor":
+			tmp.Subject.Organization = []string{v}
+		case "uou":
+			tmp.Subject.OrganizationalUnit = []string{v}
+		case "usa":
+			tmp.Subject.StreetAddress = []string{v}
+		case "upc":
+			tmp.Subject.PostalCode = []string{v}
+		}
+	}
+	cn := tmp.Subject.CommonName
+	if cn == "" {
+		if sp.RequireCN {
+			return fmt.Errorf("%w: common name required", serror.ErrProfileViolation)
+		}
+		return nil
+	}
+	if len(sp.CommonNames) == 0 {
+		return nil
+	}
+	if ucn, ok := cl.Crt["ucn"].(string); ok && ucn == cn {
+		return nil
+	}
+	if !slices.ContainsFunc(sp.CommonNames, func(pt string) bool { return matchDNS(pt, cn) }) {
+		return fmt.Errorf("%w: common name %s not allowed", serror.ErrProfileViolation, cn)
+	}
+	return nil
+}
+
+// checkNames checks the subject alternative names of the request. Names of the certificate template
+// of the client are always allowed, all others must match the patterns of the profile.
+func checkNames(p model.CertProfile, cl model.Client, tmp *x509.CertificateRequest) error {
+	dnss, ips, err := CertIdentifiers(cl)
+	if err != nil {
+		return err
+	}
+	for _, d := range tmp.DNSNames {
+		if strings.HasPrefix(d, "*.") && !p.Wildcards {
+			return fmt.Errorf("%w: wildcard dns name %s not allowed", serror.ErrProfileViolation, d)
+		}
+		if slices.Contains(dnss, d) {
+			continue
+		}
+		if !slices.ContainsFunc(p.DNS, func(pt string) bool { return matchDNS(pt, d) }) {
+			return fmt.Errorf("%w: dns name %s not allowed", serror.ErrProfileViolation, d)
+		}
+	}
+	for _, ip := range tmp.IPAddresses {
+		if slices.ContainsFunc(ips, ip.Equal) {
+			continue
+		}
+		if !slices.ContainsFunc(p.IPs, func(pt string) bool {
+			n, err := model.ParseIPRange(pt)
+			return err == nil && n.Contains(ip)
+		}) {
+			return fmt.Errorf("%w: ip address %s not allowed", serror.ErrProfileViolation, ip.String())
+		}
+	}
+	uris, err := mergeURIs(cl.Crt, nil)
+	if err != nil {
+		return err
+	}
+	for _, u := range tmp.URIs {
+		us := u.String()
+		if slices.ContainsFunc(uris, func(e *url.URL) bool { return e.String() == us }) {
+			continue
+		}
+		if !slices.ContainsFunc(p.URIs, func(pt string) bool { return matchURI(pt, u) }) {
+			return fmt.Errorf("%w: uri %s not allowed", serror.ErrProfileViolation, us)
+		}
+	}
+	uem, _ := cl.Crt["uem"].(string)
+	for _, e := range tmp.EmailAddresses {
+		if e == uem {
+			continue
+		}
+		if !slices.ContainsFunc(p.Emails, func(pt string) bool { return matchEmail(pt, e) }) {
+			return fmt.Errorf("%w: email address %s not allowed", serror.ErrProfileViolation, e)
+		}
+	}
+	return nil
+}
+
+// matchDNS checks the dns name against the pattern: example.com exact, *.example.com one label, .example.com all subdomains
+func matchDNS(pattern, name string) bool {
+	pattern = strings.ToLower(pattern)
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		label, ok := strings.CutSuffix(name, pattern[1:])
+		return ok && label != "" && !strings.Contains(label, ".")
+	case strings.HasPrefix(pattern, "."):
+		return strings.HasSuffix(name, pattern) && len(name) > len(pattern)
+	}
+	return pattern == name
+}
+
+// matchURI checks the uri against the pattern: the scheme must be equal, the host matches like matchDNS
+// and the path of the pattern must be a prefix of the path segments of the uri
+func matchURI(pattern string, u *url.URL) bool {
+	p, err := url.Parse(pattern)
+	if err != nil || p.Scheme == "" || !strings.EqualFold(p.Scheme, u.Scheme) {
+		return false
+	}
+	if p.Opaque != "" || u.Opaque != "" {
+		// e.g. urn:example:app, the segments are separated by :
+		return matchSegments(p.Opaque, u.Opaque, ":")
+	}
+	if p.User.String() != u.User.String() || !matchDNS(p.Hostname(), u.Hostname()) || p.Port() != u.Port() {
+		return false
+	}
+	if p.RawQuery != u.RawQuery || p.Fragment != u.Fragment {
+		return false
+	}
+	// encoded slashes would change the segments
+	if u.RawPath != "" {
+		return false
+	}
+	return matchSegments(p.Path, u.Path, "/")
+}
+
+// matchSegments checks, if the segments of the pattern are the first segments of s. Relative segments are not allowed.
+func matchSegments(pattern, s, sep string) bool {
+	ss := strings.Split(strings.Trim(s, sep), sep)
+	if slices.Contains(ss, ".") || slices.Contains(ss, "..") {
+		return false
+	}
+	pattern = strings.Trim(pattern, sep)
+	if pattern == "" {
+		return true
+	}
+	ps := strings.Split(pattern, sep)
+	return len(ss) >= len(ps) && slices.Equal(ps, ss[:len(ps)])
+}
+
+// matchEmail checks the email address against the pattern: exact address or @example.com for the domain
+func matchEmail(pattern, email string) bool {
+	if strings.HasPrefix(pattern, "@") {
+		return strings.HasSuffix(strings.ToLower(email), strings.ToLower(pattern))
+	}
+	return strings.EqualFold(pattern, email)
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	ns := make([]*net.IPNet, 0)
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
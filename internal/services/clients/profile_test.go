@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+func TestMatchDNS(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.True(matchDNS("example.com", "Example.com"))
+	ast.False(matchDNS("example.com", "www.example.com"))
+	ast.True(matchDNS("*.example.com", "www.example.com"))
+	ast.False(matchDNS("*.example.com", "a.b.example.com"))
+	ast.False(matchDNS("*.example.com", "example.com"))
+	ast.True(matchDNS(".example.com", "a.b.example.com"))
+	ast.False(matchDNS(".example.com", "example.com"))
+	ast.False(matchDNS(".example.com", "badexample.com"))
+
+	ast.True(matchURI("spiffe://example.com/", mustURL("spiffe://example.com/ns/app")))
+	ast.True(matchURI("spiffe://example.com/ns", mustURL("spiffe://Example.com/ns/app")))
+	ast.True(matchURI("spiffe://.example.com/", mustURL("spiffe://a.example.com/ns")))
+	ast.True(matchURI("urn:example:", mustURL("urn:example:app")))
+	ast.False(matchURI("spiffe://example.com", mustURL("spiffe://example.com.evil.org/ns")))
+	ast.False(matchURI("spiffe://example.com", mustURL("spiffe://example.com@evil.org/ns")))
+	ast.False(matchURI("spiffe://example.com", mustURL("spiffe://example.com:8443/ns")))
+	ast.False(matchURI("spiffe://example.com", mustURL("https://example.com/ns")))
+	ast.False(matchURI("spiffe://example.com/ns", mustURL("spiffe://example.com/nsadmin")))
+	ast.False(matchURI("spiffe://example.com/ns", mustURL("spiffe://example.com/ns/../admin")))
+	ast.False(matchURI("spiffe://example.com/ns", mustURL("spiffe://example.com/ns%2Fadmin")))
+	ast.False(matchURI("urn:example:", mustURL("urn:examplefoo:app")))
+
+	ast.True(matchEmail("@example.com", "info@example.com"))
+	ast.False(matchEmail("@example.com", "info@example.org"))
+	ast.True(matchEmail("info@example.com", "Info@example.com"))
+}
+
+func mustURL(s string) *url.URL {
+	u, _ := url.Parse(s)
+	return u
+}
+
+func TestClientProfiles(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Nil(ClientProfiles(model.Client{}))
+	ast.Equal([]string{"server"}, ClientProfiles(model.Client{Crt: map[string]any{"profiles": "server"}}))
+	ast.Equal([]string{"server", "client"}, ClientProfiles(model.Client{Crt: map[string]any{"profiles": []any{"server", "client"}}}))
+}
+
+func TestProfileCertificate(t *testing.T) {
+	ast := assert.New(t)
+
+	ps := []model.CertProfile{
+		{
+			Name:        "tls-client",
+			KeyUsage:    []string{"digitalSignature"},
+			ExtKeyUsage: []string{"clientAuth"},
+			MaxValidity: "7d",
+			DNS:         []string{".svc.local"},
+			Subject:     model.CertSubjectPolicy{RequireCN: true, Fixed: map[string]string{"uor": "MCS"}},
+		},
+		{
+			Name:        "subca",
+			KeyUsage:    []string{"certSign", "crlSign"},
+			MaxValidity: "30d",
+			CA: &model.CertCAPolicy{
+				MaxPathLen:   0,
+				PermittedDNS: []string{".svc.local"},
+				PermittedIPs: []string{"10.0.0.0/8"},
+			},
+		},
+	}
+	for _, p := range ps {
+		ast.Nil(cls.StoreProfile(p))
+	}
+	ast.NotNil(cls.StoreProfile(model.CertProfile{Name: "bad", KeyUsage: []string{"unknown"}}))
+	defer func() {
+		for _, p := range ps {
+			_, err := cls.DeleteProfile(p.Name)
+			ast.Nil(err)
+		}
+	}()
+
+	cl := model.Client{
+		Name: "profiler",
+		Crt: map[string]any{
+			"ucn":      "profiler",
+			"dns":      "profiler.local",
+			"vad":      "30d",
+			"profiles": []any{"tls-client", "subca"},
+		},
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+
+	// default profile is the first one, validity is capped to the profile
+	der, _, err := cls.signCertificate(cl, "", x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "profiler"},
+		DNSNames: []string{"profiler.local", "api.svc.local"},
+	}, &key.PublicKey)
+	ast.Nil(err)
+	xc, err := x509.ParseCertificate(der)
+	ast.Nil(err)
+	ast.Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, xc.ExtKeyUsage)
+	ast.Equal(x509.KeyUsageDigitalSignature, xc.KeyUsage)
+	ast.Equal([]string{"MCS"}, xc.Subject.Organization)
+	ast.False(xc.IsCA)
+	ast.True(xc.NotAfter.Before(time.Now().Add(8 * 24 * time.Hour)))
+	cr, ok := stg.GetCertificate(model.Serial2ID(xc.SerialNumber))
+	ast.True(ok)
+	ast.Equal("tls-client", cr.Profile)
+
+	// names outside of the profile and the template are rejected
+	_, _, err = cls.signCertificate(cl, "tls-client", x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "profiler"},
+		DNSNames: []string{"www.example.com"},
+	}, &key.PublicKey)
+	ast.ErrorIs(err, serror.ErrProfileViolation)
+	_, _, err = cls.signCertificate(cl, "tls-client", x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "profiler"},
+		DNSNames: []string{"*.svc.local"},
+	}, &key.PublicKey)
+	ast.ErrorIs(err, serror.ErrProfileViolation)
+	_, _, err = cls.signCertificate(cl, "tls-client", x509.CertificateRequest{
+		IPAddresses: []net.IP{net.ParseIP("10.1.1.1")},
+	}, &key.PublicKey)
+	ast.ErrorIs(err, serror.ErrProfileViolation)
+
+	// only assigned profiles are allowed
+	_, _, err = cls.signCertificate(cl, "server", x509.CertificateRequest{}, &key.PublicKey)
+	ast.ErrorIs(err, serror.ErrProfileViolation)
+
+	// sub ca with basic and name constraints
+	der, _, err = cls.signCertificate(cl, "subca", x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "profiler"},
+	}, &key.PublicKey)
+	ast.Nil(err)
+	xc, err = x509.ParseCertificate(der)
+	ast.Nil(err)
+	ast.True(xc.IsCA)
+	ast.True(xc.MaxPathLenZero)
+	ast.Equal(x509.KeyUsageCertSign|x509.KeyUsageCRLSign, xc.KeyUsage)
+	ast.Equal([]string{".svc.local"}, xc.PermittedDNSDomains)
+	ast.Equal(1, len(xc.PermittedIPRanges))
+	ast.True(xc.PermittedDNSDomainsCritical)
+
+	// clients without profiles are working as before
+	_, _, err = cls.signCertificate(model.Client{Name: "legacy"}, "tls-client", x509.CertificateRequest{}, &key.PublicKey)
+	ast.ErrorIs(err, serror.ErrProfileViolation)
+	der, _, err = cls.signCertificate(model.Client{Name: "legacy"}, "", x509.CertificateRequest{}, &key.PublicKey)
+	ast.Nil(err)
+	xc, err = x509.ParseCertificate(der)
+	ast.Nil(err)
+	ast.Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}, xc.ExtKeyUsage)
+
+	_, err = cls.Profile("unknown")
+	ast.NotNil(err)
+	ps2, err := cls.Profiles()
+	ast.Nil(err)
+	ast.Equal(2, len(ps2))
+}
@@ -0,0 +1,97 @@
+package clients
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// Rewrap re-encrypts a ciphertext with the current key of the group of the old key or with the given target key.
+// The plaintext never leaves the service. The old key may be scheduled for destruction, but not disabled or destroyed.
+func (c *Clients) Rewrap(tk string, msg pmodel.RewrapMessage) (*pmodel.RewrapMessage, error) {
+	_, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	return c.newKeyCache(tk).rewrap(msg)
+}
+
+// RewrapBatch re-encrypts a list of ciphertexts, errors are reported per item
+func (c *Clients) RewrapBatch(tk string, msgs []pmodel.RewrapMessage) ([]pmodel.RewrapMessage, error) {
+	_, err := c.checkTk(tk)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) > MaxBatchSize {
+		return nil, fmt.Errorf("batch too large, max %d items", MaxBatchSize)
+	}
+	kc := c.newKeyCache(tk)
+	res := make([]pmodel.RewrapMessage, len(msgs))
+	for x, msg := range msgs {
+		m, err := kc.rewrap(msg)
+		if err != nil {
+			msg.Message = ""
+			msg.Error = err.Error()
+			res[x] = msg
+			continue
+		}
+		res[x] = *m
+	}
+	return res, nil
+}
+
+func (k *keyCache) rewrap(msg pmodel.RewrapMessage) (*pmodel.RewrapMessage, error) {
+	if msg.ID == "" && cry.HasHeader(msg.Message) {
+		_, kid, err := cry.ParseHeader(msg.Message)
+		if err != nil {
+			return nil, err
+		}
+		msg.ID = kid
+	}
+	if msg.ID == "" {
+		return nil, serror.ErrMissingID
+	}
+	old, err := k.sourceKey(msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	var key *model.EncryptKey
+	if msg.TargetID != "" {
+		key, err = k.get(msg.TargetID)
+	} else {
+		key, err = k.currentKey(old.Group)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.ID == old.ID {
+		return nil, errors.New("ciphertext is already encrypted with the target key")
+	}
+
+	ob, err := hex.DecodeString(old.Key)
+	if err != nil {
+		return nil, err
+	}
+	pt, err := cry.DecryptAlg(old.Alg, ob, msg.Message, []byte(msg.AD))
+	if err != nil {
+		return nil, err
+	}
+	nk, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := cry.EncryptAlg(key.Alg, nk, key.ID, pt, []byte(msg.AD))
+	if err != nil {
+		return nil, err
+	}
+	return &pmodel.RewrapMessage{
+		ID:      key.ID,
+		Message: ct,
+		AD:      msg.AD,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+func TestRewrap(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	msg, err := buildGroupMessage("group1")
+	ast.Nil(err)
+	m, err := cls.CryptSS(tk, msg)
+	ast.Nil(err)
+
+	cur, err := cls.RotateKey("group1")
+	ast.Nil(err)
+
+	rw, err := cls.Rewrap(tk, pmodel.RewrapMessage{ID: m.ID, Message: m.Message})
+	ast.Nil(err)
+	ast.Equal(cur.ID, rw.ID)
+	ast.NotEqual(m.Message, rw.Message)
+
+	// decrypt with the new key
+	dm := pmodel.Message{Type: "group", ID: rw.ID, Message: rw.Message, Decrypt: true}
+	dm2, err := cls.CryptSS(tk, dm)
+	ast.Nil(err)
+	ast.Equal(msg.Message, dm2.Message)
+
+	// already on the target key
+	_, err = cls.Rewrap(tk, *rw)
+	ast.NotNil(err)
+
+	// rewrap to an explicit aead target key
+	tgt, err := cls.CreateKey("group1", cry.AlgXChaCha20Poly1305)
+	ast.Nil(err)
+	rw2, err := cls.Rewrap(tk, pmodel.RewrapMessage{ID: rw.ID, Message: rw.Message, TargetID: tgt.ID})
+	ast.Nil(err)
+	ast.Equal(tgt.ID, rw2.ID)
+	ast.True(cry.HasHeader(rw2.Message))
+
+	// the key id is taken from the header
+	rw3, err := cls.Rewrap(tk, pmodel.RewrapMessage{Message: rw2.Message})
+	ast.Nil(err)
+	ast.Equal(cur.ID, rw3.ID)
+}
+
+func TestRewrapScheduledKey(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	old, err := cls.CreateKey("group1", "")
+	ast.Nil(err)
+	msg, err := buildGroupMessage("group1")
+	ast.Nil(err)
+	msg.ID = old.ID
+	m, err := cls.CryptSS(tk, msg)
+	ast.Nil(err)
+
+	// in the grace period the ciphertext can only be migrated, not decrypted
+	_, err = cls.SetKeyState(old.ID, model.KeyStateScheduled)
+	ast.Nil(err)
+	dm := pmodel.Message{Type: "group", ID: m.ID, Message: m.Message, Decrypt: true}
+	_, err = cls.CryptSS(tk, dm)
+	ast.ErrorIs(err, serror.ErrKeyNotEnabled)
+
+	rw, err := cls.Rewrap(tk, pmodel.RewrapMessage{ID: m.ID, Message: m.Message})
+	ast.Nil(err)
+	ast.NotEqual(old.ID, rw.ID)
+	dm = pmodel.Message{Type: "group", ID: rw.ID, Message: rw.Message, Decrypt: true}
+	dm2, err := cls.CryptSS(tk, dm)
+	ast.Nil(err)
+	ast.Equal(msg.Message, dm2.Message)
+
+	// the scheduled key can't be the target key
+	_, err = cls.Rewrap(tk, pmodel.RewrapMessage{ID: rw.ID, Message: rw.Message, TargetID: old.ID})
+	ast.ErrorIs(err, serror.ErrKeyNotEnabled)
+
+	// disabled keys are rejected
+	_, err = cls.SetKeyState(old.ID, model.KeyStateDisabled)
+	ast.Nil(err)
+	_, err = cls.Rewrap(tk, pmodel.RewrapMessage{ID: m.ID, Message: m.Message})
+	ast.ErrorIs(err, serror.ErrKeyNotEnabled)
+}
+
+func TestRewrapBatch(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	msgs := make([]pmodel.RewrapMessage, 0)
+	for x := 0; x < 10; x++ {
+		msg, err := buildGroupMessage("group4")
+		ast.Nil(err)
+		m, err := cls.CryptSS(tk, msg)
+		ast.Nil(err)
+		msgs = append(msgs, pmodel.RewrapMessage{ID: m.ID, Message: m.Message})
+	}
+	msgs = append(msgs, pmodel.RewrapMessage{ID: "unknown", Message: "unknown"})
+
+	res, err := cls.RewrapBatch(tk, msgs)
+	ast.Nil(err)
+	ast.Len(res, 11)
+
+	cur, err := cls.CurrentKey("group4")
+	ast.Nil(err)
+	for x := 0; x < 10; x++ {
+		ast.Empty(res[x].Error)
+		ast.Equal(cur.ID, res[x].ID)
+	}
+	ast.NotEmpty(res[10].Error)
+	ast.Empty(res[10].Message)
+
+	_, err = cls.RewrapBatch(tk, make([]pmodel.RewrapMessage, MaxBatchSize+1))
+	ast.NotNil(err)
+}
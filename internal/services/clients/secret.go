@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// ErrLastSecret the last active secret of a client can't be revoked
+var ErrLastSecret = errors.New("the last active secret of the client can't be revoked")
+
+// RotateSecret issuing a new secret for the client of the token, the old secrets are still valid.
+// exp is the optional validity of the new secret, e.g. 90d
+func (c *Clients) RotateSecret(tk, exp string) (*pmodel.ClientSecret, error) {
+	cl, err := c.client(tk)
+	if err != nil {
+		return nil, err
+	}
+	return c.rotateSecret(*cl, exp, time.Now())
+}
+
+// RotateSecretOfClient issuing a new secret for the named client, the old secrets are still valid
+func (c *Clients) RotateSecretOfClient(name, exp string) (*pmodel.ClientSecret, error) {
+	cl, err := c.ClientByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.rotateSecret(*cl, exp, time.Now())
+}
+
+// Secrets listing the active secrets of the client of the token, without the secrets itself
+func (c *Clients) Secrets(tk string) ([]pmodel.ClientSecret, error) {
+	cl, err := c.client(tk)
+	if err != nil {
+		return nil, err
+	}
+	return secretInfos(*cl, time.Now()), nil
+}
+
+// SecretsOfClient listing the active secrets of the named client, without the secrets itself
+func (c *Clients) SecretsOfClient(name string) ([]pmodel.ClientSecret, error) {
+	cl, err := c.ClientByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return secretInfos(*cl, time.Now()), nil
+}
+
+// RevokeSecret revoking the secret with the id of the client of the token
+func (c *Clients) RevokeSecret(tk, id string) error {
+	cl, err := c.client(tk)
+	if err != nil {
+		return err
+	}
+	return c.revokeSecret(*cl, id, time.Now())
+}
+
+// RevokeSecretOfClient revoking the secret with the id of the named client
+func (c *Clients) RevokeSecretOfClient(name, id string) error {
+	cl, err := c.ClientByName(name)
+	if err != nil {
+		return err
+	}
+	return c.revokeSecret(*cl, id, time.Now())
+}
+
+func (c *Clients) rotateSecret(cl model.Client, exp string, now time.Time) (*pmodel.ClientSecret, error) {
+	s := model.ClientSecret{
+		ID:      uuid.NewString(),
+		Created: now,
+	}
+	if exp != "" {
+		d, err := str2duration.ParseDuration(exp)
+		if err != nil {
+			return nil, err
+		}
+		// a secret, which is already expired, would lock out the client
+		if d <= 0 {
+			return nil, fmt.Errorf("expiry must be a positive duration: %s", exp)
+		}
+		s.Expires = now.Add(d)
+	}
+	secret := make([]byte, 16)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := cry.GenerateSalt()
+	if err != nil {
+		return nil, err
+	}
+	s.Salt = hex.EncodeToString(salt)
+	s.Hash = cry.HashSecret(secret, salt)
+
+	// expired secrets are removed
+	ss := slices.DeleteFunc(slices.Clone(cl.Secrets), func(e model.ClientSecret) bool {
+		return e.Expired(now)
+	})
+	cl.Secrets = append(ss, s)
+	err = c.stg.UpdateClient(cl)
+	if err != nil {
+		return nil, err
+	}
+	si := secretInfo(s)
+	si.Secret = hex.EncodeToString(secret)
+	return &si, nil
+}
+
+func (c *Clients) revokeSecret(cl model.Client, id string, now time.Time) error {
+	as := cl.ActiveSecrets(now)
+	if !slices.ContainsFunc(as, func(s model.ClientSecret) bool { return s.ID == id }) {
+		return serror.NotFound("secret", id)
+	}
+	if len(as) == 1 {
+		return ErrLastSecret
+	}
+	if id == model.PrimarySecretID {
+		cl.Salt = ""
+		cl.Hash = ""
+	}
+	cl.Secrets = slices.DeleteFunc(slices.Clone(cl.Secrets), func(s model.ClientSecret) bool {
+		return s.ID == id || s.Expired(now)
+	})
+	return c.stg.UpdateClient(cl)
+}
+
+// checkSecret checking the secret against all active secrets of the client
+func checkSecret(cl model.Client, secret []byte, now time.Time) bool {
+	for _, s := range cl.ActiveSecrets(now) {
+		salt, err := hex.DecodeString(s.Salt)
+		if err != nil {
+			logger.Errorf("failed to decode salt of secret %s: %v", s.ID, err)
+			continue
+		}
+		if cry.HashSecret(secret, salt) == s.Hash {
+			return true
+		}
+	}
+	return false
+}
+
+func secretInfos(cl model.Client, now time.Time) []pmodel.ClientSecret {
+	sis := make([]pmodel.ClientSecret, 0)
+	for _, s := range cl.ActiveSecrets(now) {
+		sis = append(sis, secretInfo(s))
+	}
+	return sis
+}
+
+func secretInfo(s model.ClientSecret) pmodel.ClientSecret {
+	si := pmodel.ClientSecret{
+		ID: s.ID,
+	}
+	if !s.Created.IsZero() {
+		cr := s.Created
+		si.Created = &cr
+	}
+	if !s.Expires.IsZero() {
+		ex := s.Expires
+		si.Expires = &ex
+	}
+	return si
+}
@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+func TestClientSecrets(t *testing.T) {
+	ast := assert.New(t)
+
+	n := "secret-client"
+	addKeyTypeClient(ast, n, cry.KeyTypeECDSAP256)
+	tk, _, _, err := cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	s, err := cls.RotateSecret(tk, "90d")
+	ast.Nil(err)
+	ast.NotEmpty(s.ID)
+	ast.NotEmpty(s.Secret)
+	ast.NotNil(s.Expires)
+
+	// both secrets are valid
+	_, _, _, err = cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	_, _, _, err = cls.Login(n, s.Secret)
+	ast.Nil(err)
+
+	ss, err := cls.Secrets(tk)
+	ast.Nil(err)
+	ast.Len(ss, 2)
+	ast.Equal(model.PrimarySecretID, ss[0].ID)
+	ast.Nil(ss[0].Created)
+	ast.Equal(s.ID, ss[1].ID)
+	ast.Empty(ss[1].Secret)
+
+	// revoking the old secret
+	ast.Nil(cls.RevokeSecret(tk, model.PrimarySecretID))
+	_, _, _, err = cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.ErrorIs(err, serror.ErrLoginFailed)
+	_, _, _, err = cls.Login(n, s.Secret)
+	ast.Nil(err)
+
+	// the last secret and unknown secrets can't be revoked
+	err = cls.RevokeSecret(tk, s.ID)
+	ast.ErrorIs(err, ErrLastSecret)
+	err = cls.RevokeSecret(tk, model.PrimarySecretID)
+	ast.NotNil(err)
+
+	// admin rotation, the expired secret is removed
+	s2, err := cls.RotateSecretOfClient(n, "")
+	ast.Nil(err)
+	ast.Nil(s2.Expires)
+	cl, err := cls.ClientByName(n)
+	ast.Nil(err)
+	b, err := hex.DecodeString(s2.Secret)
+	ast.Nil(err)
+	ast.True(checkSecret(*cl, b, time.Now()))
+	b, err = hex.DecodeString(s.Secret)
+	ast.Nil(err)
+	ast.True(checkSecret(*cl, b, time.Now()))
+	ast.False(checkSecret(*cl, b, time.Now().Add(91*24*time.Hour)))
+	_, err = cls.rotateSecret(*cl, "", time.Now().Add(91*24*time.Hour))
+	ast.Nil(err)
+	ss, err = cls.SecretsOfClient(n)
+	ast.Nil(err)
+	ast.Len(ss, 2)
+	ast.NotEqual(s.ID, ss[0].ID)
+	ast.NotEqual(s.ID, ss[1].ID)
+
+	_, err = cls.RotateSecretOfClient(n, "abc")
+	ast.NotNil(err)
+	_, err = cls.RotateSecretOfClient(n, "0s")
+	ast.NotNil(err)
+	_, err = cls.RotateSecretOfClient(n, "-1h")
+	ast.NotNil(err)
+	_, err = cls.RotateSecretOfClient("unknown-client", "")
+	ast.NotNil(err)
+}
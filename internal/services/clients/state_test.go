@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+func TestClientState(t *testing.T) {
+	ast := assert.New(t)
+
+	n := "state-client"
+	addKeyTypeClient(ast, n, cry.KeyTypeECDSAP256)
+	tk, rt, _, err := cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	setState := func(f func(cl *model.Client)) {
+		cl, err := cls.ClientByName(n)
+		ast.Nil(err)
+		f(cl)
+		ast.Nil(stg.UpdateClient(*cl))
+	}
+
+	// a disabled client can't login, refresh or use its tokens
+	setState(func(cl *model.Client) { cl.Disabled = true })
+	_, _, _, err = cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.ErrorIs(err, serror.ErrClientDisabled)
+	_, _, err = cls.Refresh(rt)
+	ast.ErrorIs(err, serror.ErrClientDisabled)
+	_, err = cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+	ast.ErrorIs(err, serror.ErrClientDisabled)
+
+	// enabled again, the token is valid again
+	setState(func(cl *model.Client) { cl.Disabled = false })
+	_, err = cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+	ast.Nil(err)
+
+	setState(func(cl *model.Client) { cl.NotBefore = time.Now().Add(time.Hour) })
+	_, _, _, err = cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.ErrorIs(err, serror.ErrClientNotYetValid)
+
+	setState(func(cl *model.Client) {
+		cl.NotBefore = time.Now().Add(-2 * time.Hour)
+		cl.ExpiresAt = time.Now().Add(-time.Hour)
+	})
+	_, _, _, err = cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.ErrorIs(err, serror.ErrClientExpired)
+	_, err = cls.GetPrivateKey(tk)
+	ast.ErrorIs(err, serror.ErrClientExpired)
+
+	setState(func(cl *model.Client) { cl.ExpiresAt = time.Now().Add(time.Hour) })
+	_, _, _, err = cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+}
@@ -0,0 +1,48 @@
+package clients
+
+import (
+	"encoding/hex"
+	"io"
+
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+// NewEncryptWriter creates a writer, encrypting the stream with a group key into w.
+// With an id the existing key is used, otherwise a new key of the group is created, default algorithm is AES-256-GCM.
+// returning the writer and the id of the key
+func (c *Clients) NewEncryptWriter(tk string, w io.Writer, group, id, alg string) (io.WriteCloser, string, error) {
+	var key *model.EncryptKey
+	var err error
+	if id != "" {
+		key, err = c.GetEncryptKey(tk, id)
+	} else {
+		if alg == "" {
+			alg = cry.AlgAES256GCM
+		}
+		key, err = c.CreateEncryptKey(tk, group, alg)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	kb, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return nil, "", err
+	}
+	ew, err := cry.NewEncryptWriter(w, key.Alg, kb, key.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return ew, key.ID, nil
+}
+
+// NewDecryptReader creates a reader, decrypting the stream, the key is taken from the stream header
+func (c *Clients) NewDecryptReader(tk string, r io.Reader) (io.Reader, error) {
+	return cry.NewDecryptReaderFunc(r, func(kid string) ([]byte, error) {
+		key, err := c.GetEncryptKey(tk, kid)
+		if err != nil {
+			return nil, err
+		}
+		return hex.DecodeString(key.Key)
+	})
+}
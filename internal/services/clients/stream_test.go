@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+func TestStream(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	pt := make([]byte, 200000)
+	_, err = rand.Read(pt)
+	ast.Nil(err)
+
+	var ct bytes.Buffer
+	ew, id, err := cls.NewEncryptWriter(tk, &ct, "group1", "", cry.AlgXChaCha20Poly1305)
+	ast.Nil(err)
+	ast.NotEmpty(id)
+	_, err = io.Copy(ew, bytes.NewReader(pt))
+	ast.Nil(err)
+	ast.Nil(ew.Close())
+
+	dr, err := cls.NewDecryptReader(tk, bytes.NewReader(ct.Bytes()))
+	ast.Nil(err)
+	res, err := io.ReadAll(dr)
+	ast.Nil(err)
+	ast.True(bytes.Equal(pt, res))
+
+	// encrypt with the existing key
+	var ct2 bytes.Buffer
+	ew, id2, err := cls.NewEncryptWriter(tk, &ct2, "", id, "")
+	ast.Nil(err)
+	ast.Equal(id, id2)
+	ast.Nil(ew.Close())
+
+	// legacy keys can't be used for streams
+	e := storeLegacyKey(ast, "group1")
+	_, _, err = cls.NewEncryptWriter(tk, &ct2, "", e.ID, "")
+	ast.NotNil(err)
+
+	// not a member of the group
+	_, _, err = cls.NewEncryptWriter(tk, &ct2, "group3", "", "")
+	ast.NotNil(err)
+}
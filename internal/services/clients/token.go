@@ -0,0 +1,103 @@
+package clients
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+const (
+	tkTypeBearer  = "Bearer"
+	tkTypeRefresh = "refresh_token"
+)
+
+// RevokeToken revoking an access or refresh token of a client (RFC 7009),
+// invalid or expired tokens are ignored, as they can't be used anyway
+func (c *Clients) RevokeToken(tk string) error {
+	jt, err := jwt.Parse([]byte(tk), jwt.WithKey(jwa.RS256, c.kmn.PublicKey()))
+	if err != nil {
+		logger.Infof("revoke token: token ignored: %v", err)
+		return nil
+	}
+	auds := jt.Audience()
+	if len(auds) != 1 || auds[0] != JKAudience {
+		return nil
+	}
+	return c.stg.RevokeToken(jt.JwtID(), jt.Expiration())
+}
+
+// RevokeTokens revoking all tokens of the client issued before the second of before, a zero before revokes all tokens issued until now.
+// The revocation is kept until the last of these tokens can be expired.
+func (c *Clients) RevokeTokens(n string, before time.Time) error {
+	now := time.Now()
+	if before.IsZero() {
+		before = now
+	}
+	if before.After(now) {
+		return errors.New("tokens can only be revoked until now")
+	}
+	// the issued at of the tokens has only seconds, so the tokens issued in the second of before stay valid
+	before = before.Truncate(time.Second)
+	if _, ok := c.stg.AccessKey(n); !ok {
+		return serror.ErrNotExists
+	}
+	if b, ok := c.stg.TokensRevokedBefore(n); ok && b.After(before) {
+		return nil
+	}
+	exp := before.Add(max(c.maxTokenTTL(), c.maxRefreshTTL()))
+	return c.stg.RevokeTokensBefore(n, before, exp)
+}
+
+// isRevoked checking if the token itself or all tokens of its client issued until then are revoked
+func (c *Clients) isRevoked(jt jwt.Token) bool {
+	if c.stg.IsRevoked(jt.JwtID()) {
+		return true
+	}
+	n, ok := jt.PrivateClaims()["name"].(string)
+	if !ok {
+		return false
+	}
+	before, ok := c.stg.TokensRevokedBefore(n)
+	return ok && jt.IssuedAt().Before(before)
+}
+
+// Introspect getting the state and the claims of an access or refresh token of a client (RFC 7662)
+func (c *Clients) Introspect(tk string) pmodel.Introspection {
+	jt, err := c.checkTk(tk)
+	if err != nil {
+		return pmodel.Introspection{Active: false}
+	}
+	ti := pmodel.Introspection{
+		Active:    true,
+		TokenType: tkTypeBearer,
+		Sub:       jt.Subject(),
+		Aud:       JKAudience,
+		Iat:       jt.IssuedAt().Unix(),
+		Exp:       jt.Expiration().Unix(),
+		Jti:       jt.JwtID(),
+	}
+	if jt.PrivateClaims()[rtUsageKey] == rtUsageRefresh {
+		ti.TokenType = tkTypeRefresh
+	}
+	if n, ok := jt.PrivateClaims()["name"].(string); ok {
+		ti.Username = n
+		if ti.Sub == "" {
+			ti.Sub = n
+		}
+		if a, ok := c.stg.AccessKey(n); ok {
+			ti.ClientID = a
+		}
+	}
+	if gs, ok := jt.PrivateClaims()["groups"].([]any); ok {
+		for _, g := range gs {
+			if s, ok := g.(string); ok {
+				ti.Groups = append(ti.Groups, s)
+			}
+		}
+	}
+	return ti
+}
@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+func TestRevokeToken(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, rt, _, err := cls.Login("12345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+
+	ti := cls.Introspect(tk)
+	ast.True(ti.Active)
+	ast.Equal("Bearer", ti.TokenType)
+	ast.Equal("12345678", ti.ClientID)
+	ast.NotEmpty(ti.Username)
+	ast.NotEmpty(ti.Groups)
+	ast.True(ti.Exp > ti.Iat)
+
+	ti = cls.Introspect(rt)
+	ast.True(ti.Active)
+	ast.Equal("refresh_token", ti.TokenType)
+
+	ast.Nil(cls.RevokeToken(tk))
+	ast.False(cls.Introspect(tk).Active)
+	_, err = cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+	ast.ErrorIs(err, serror.ErrTokenNotValid)
+
+	ast.Nil(cls.RevokeToken(rt))
+	_, _, err = cls.Refresh(rt)
+	ast.NotNil(err)
+
+	// invalid tokens are ignored
+	ast.Nil(cls.RevokeToken("no.valid.token"))
+	ast.False(cls.Introspect("no.valid.token").Active)
+}
+
+func TestRevokeTokens(t *testing.T) {
+	ast := assert.New(t)
+
+	tk, _, _, err := cls.Login("345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	ast.True(cls.Introspect(tk).Active)
+
+	ast.NotNil(cls.RevokeTokens("tester", time.Now().Add(1*time.Hour)))
+	ast.ErrorIs(cls.RevokeTokens("unknown", time.Now()), serror.ErrNotExists)
+
+	// tokens issued before the second of the revocation
+	n := cls.Introspect(tk).Username
+	cl, err := cls.ClientByName(n)
+	ast.Nil(err)
+	iat := time.Now().Add(-time.Minute)
+	tk, err = cls.generateToken(iat, time.Hour, n, cl.Groups)
+	ast.Nil(err)
+	rt, err := cls.generateRefreshToken(iat, time.Hour, n)
+	ast.Nil(err)
+	ast.True(cls.Introspect(tk).Active)
+
+	ast.Nil(cls.RevokeTokens(n, time.Now()))
+
+	ast.False(cls.Introspect(tk).Active)
+	_, err = cls.SignSS(tk, &pmodel.SignMessage{Message: "Dies ist eine Message"})
+	ast.ErrorIs(err, serror.ErrTokenNotValid)
+	_, _, err = cls.Refresh(rt)
+	ast.NotNil(err)
+
+	// tokens issued after the revocation are valid, also in the same second
+	tk, _, _, err = cls.Login("345678", "e7d767cd1432145820669be6a60a912e")
+	ast.Nil(err)
+	ast.True(cls.Introspect(tk).Active)
+}
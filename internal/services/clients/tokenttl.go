@@ -0,0 +1,107 @@
+package clients
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+)
+
+const (
+	defaultTokenTTL      = 5 * time.Minute
+	defaultRefreshTTL    = 60 * time.Minute
+	defaultMaxTokenTTL   = 24 * time.Hour
+	defaultMaxRefreshTTL = 7 * 24 * time.Hour
+)
+
+// CheckTokenTTL checks the token lifetimes of an override on a client or a group,
+// empty values are allowed, all others must be positive and can't exceed the configured maximum
+func (c *Clients) CheckTokenTTL(ttl, rttl string) error {
+	err := checkTTL("token ttl", ttl, c.maxTokenTTL())
+	if err != nil {
+		return err
+	}
+	return checkTTL("refresh ttl", rttl, c.maxRefreshTTL())
+}
+
+// tokenTTL the effective lifetimes of the token and refresh token of the client.
+// The override of the client wins over the overrides of its groups, where the shortest one is used,
+// at last the lifetimes of the service config are used. The lifetimes are capped to the configured maximum.
+func (c *Clients) tokenTTL(cl model.Client) (time.Duration, time.Duration) {
+	ts := c.cfg.Service.Tokens
+	ttl := configTTL(ts.ClientTTL, defaultTokenTTL)
+	rttl := configTTL(ts.ClientRefreshTTL, defaultRefreshTTL)
+
+	gttl, grttl := c.groupTokenTTL(cl.Groups)
+	if gttl > 0 {
+		ttl = gttl
+	}
+	if grttl > 0 {
+		rttl = grttl
+	}
+
+	if d, err := str2duration.ParseDuration(cl.TokenTTL); cl.TokenTTL != "" && err == nil && d > 0 {
+		ttl = d
+	}
+	if d, err := str2duration.ParseDuration(cl.RefreshTTL); cl.RefreshTTL != "" && err == nil && d > 0 {
+		rttl = d
+	}
+	return min(ttl, c.maxTokenTTL()), min(rttl, c.maxRefreshTTL())
+}
+
+// groupTokenTTL the shortest token lifetimes defined on the groups, 0 if no group defines one
+func (c *Clients) groupTokenTTL(gs []string) (time.Duration, time.Duration) {
+	var ttl, rttl time.Duration
+	for _, n := range gs {
+		g, ok := c.stg.GetGroup(n)
+		if !ok {
+			continue
+		}
+		if d, err := str2duration.ParseDuration(g.TokenTTL); g.TokenTTL != "" && err == nil && d > 0 && (ttl == 0 || d < ttl) {
+			ttl = d
+		}
+		if d, err := str2duration.ParseDuration(g.RefreshTTL); g.RefreshTTL != "" && err == nil && d > 0 && (rttl == 0 || d < rttl) {
+			rttl = d
+		}
+	}
+	return ttl, rttl
+}
+
+func (c *Clients) maxTokenTTL() time.Duration {
+	return configTTL(c.cfg.Service.Tokens.MaxClientTTL, defaultMaxTokenTTL)
+}
+
+func (c *Clients) maxRefreshTTL() time.Duration {
+	return configTTL(c.cfg.Service.Tokens.MaxClientRefreshTTL, defaultMaxRefreshTTL)
+}
+
+// configTTL parses a lifetime of the service config, using the default for empty or invalid values
+func configTTL(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := str2duration.ParseDuration(s)
+	if err != nil || d <= 0 {
+		logger.Errorf("token ttl: %s is not a valid lifetime, using %s", s, def)
+		return def
+	}
+	return d
+}
+
+func checkTTL(name, s string, mx time.Duration) error {
+	if s == "" {
+		return nil
+	}
+	d, err := str2duration.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid duration: %v", name, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s must be a positive duration: %s", name, s)
+	}
+	if d > mx {
+		return fmt.Errorf("%s %s exceeds the maximum of %s", name, s, mx)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/model"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
+)
+
+func TestClientTokenTTL(t *testing.T) {
+	ast := assert.New(t)
+
+	n := "ttl-client"
+	addKeyTypeClient(ast, n, cry.KeyTypeECDSAP256)
+	_, err := stg.AddGroup(model.Group{Name: "ttl-group", TokenTTL: "10m", RefreshTTL: "2h"})
+	ast.Nil(err)
+
+	lifetimes := func() (time.Duration, time.Duration) {
+		tk, rt, _, err := cls.Login(n, "e7d767cd1432145820669be6a60a912e")
+		ast.Nil(err)
+		jt, err := jwt.ParseString(tk, jwt.WithVerify(false))
+		ast.Nil(err)
+		jrt, err := jwt.ParseString(rt, jwt.WithVerify(false))
+		ast.Nil(err)
+		return jt.Expiration().Sub(jt.IssuedAt()), jrt.Expiration().Sub(jrt.IssuedAt())
+	}
+	update := func(f func(cl *model.Client)) {
+		cl, err := cls.ClientByName(n)
+		ast.Nil(err)
+		f(cl)
+		ast.Nil(stg.UpdateClient(*cl))
+	}
+
+	// defaults of the service
+	ttl, rttl := lifetimes()
+	ast.Equal(5*time.Minute, ttl)
+	ast.Equal(60*time.Minute, rttl)
+
+	// lifetimes of the group
+	update(func(cl *model.Client) { cl.Groups = append(cl.Groups, "ttl-group") })
+	ttl, rttl = lifetimes()
+	ast.Equal(10*time.Minute, ttl)
+	ast.Equal(2*time.Hour, rttl)
+
+	// the client wins over the group
+	update(func(cl *model.Client) { cl.TokenTTL = "1h" })
+	ttl, rttl = lifetimes()
+	ast.Equal(time.Hour, ttl)
+	ast.Equal(2*time.Hour, rttl)
+
+	// never more than the maximum
+	update(func(cl *model.Client) { cl.TokenTTL = "48h" })
+	ttl, _ = lifetimes()
+	ast.Equal(24*time.Hour, ttl)
+}
+
+func TestCheckTokenTTL(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Nil(cls.CheckTokenTTL("", ""))
+	ast.Nil(cls.CheckTokenTTL("1h", "8h"))
+	ast.NotNil(cls.CheckTokenTTL("48h", ""))
+	ast.NotNil(cls.CheckTokenTTL("", "30d"))
+	ast.NotNil(cls.CheckTokenTTL("-1h", ""))
+	ast.NotNil(cls.CheckTokenTTL("abc", ""))
+}
@@ -1,10 +1,14 @@
 package groups
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/samber/do"
 	"github.com/willie68/micro-vault/internal/interfaces"
 	"github.com/willie68/micro-vault/internal/model"
 	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
 )
 
 // Groups group management
@@ -32,6 +36,14 @@ func (g *Groups) AddGroup(group model.Group) (id string, err error) {
 	if g.stg.HasGroup(group.Name) {
 		return "", serror.ErrAlreadyExists
 	}
+	err = checkName(group.Name)
+	if err != nil {
+		return "", err
+	}
+	err = checkKeyRotation(group.KeyRotation)
+	if err != nil {
+		return "", err
+	}
 	id, err = g.stg.AddGroup(group)
 	return
 }
@@ -41,12 +53,19 @@ func (g *Groups) UpdateGroup(group model.Group) (id string, err error) {
 	if !g.stg.HasGroup(group.Name) {
 		return "", serror.ErrNotExists
 	}
+	err = checkKeyRotation(group.KeyRotation)
+	if err != nil {
+		return "", err
+	}
 	gr, ok := g.stg.GetGroup(group.Name)
 	if !ok {
 		return "", serror.ErrNotExists
 	}
-	// only the labels can be updated
+	// only the labels and the key rotation policy can be updated
 	gr.Label = group.Label
+	gr.KeyRotation = group.KeyRotation
+	gr.TokenTTL = group.TokenTTL
+	gr.RefreshTTL = group.RefreshTTL
 	id, err = g.stg.AddGroup(*gr)
 	return
 }
@@ -63,3 +82,29 @@ func (g *Groups) DeleteGroup(name string) bool {
 	// TODO check clients with that group?
 	return ok
 }
+
+// checkName checks the name of a new group, the / is the separator of the group and the path of a secret
+func checkName(n string) error {
+	if n == "" {
+		return serror.ErrMissingID
+	}
+	if strings.Contains(n, "/") {
+		return fmt.Errorf("group name must not contain /: %s", n)
+	}
+	return nil
+}
+
+// checkKeyRotation checks if the key rotation period of a group is a valid duration
+func checkKeyRotation(kr string) error {
+	if kr == "" {
+		return nil
+	}
+	d, err := str2duration.ParseDuration(kr)
+	if err != nil {
+		return fmt.Errorf("key rotation is not a valid duration: %v", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("key rotation must be a positive duration: %s", kr)
+	}
+	return nil
+}
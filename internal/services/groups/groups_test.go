@@ -99,3 +99,55 @@ func TestGroupUpdate(t *testing.T) {
 	ok = stg.HasGroup(id)
 	ast.False(ok)
 }
+
+func TestGroupName(t *testing.T) {
+	ast := assert.New(t)
+
+	g := Groups{
+		stg: stg,
+	}
+
+	_, err := g.AddGroup(model.Group{Name: "group/1"})
+	ast.NotNil(err)
+	ast.False(stg.HasGroup("group/1"))
+
+	_, err = g.AddGroup(model.Group{Name: ""})
+	ast.NotNil(err)
+}
+
+func TestGroupKeyRotation(t *testing.T) {
+	ast := assert.New(t)
+
+	g := Groups{
+		stg: stg,
+	}
+
+	gr := model.Group{
+		Name:        ids,
+		KeyRotation: "xyz",
+	}
+
+	_, err := g.AddGroup(gr)
+	ast.NotNil(err)
+	ast.False(stg.HasGroup(ids))
+
+	gr.KeyRotation = "90d"
+	id, err := g.AddGroup(gr)
+	ast.Nil(err)
+	ast.Equal(ids, id)
+
+	gr.KeyRotation = "-1h"
+	_, err = g.UpdateGroup(gr)
+	ast.NotNil(err)
+
+	gr.KeyRotation = "30d"
+	_, err = g.UpdateGroup(gr)
+	ast.Nil(err)
+
+	gs, ok := stg.GetGroup(id)
+	ast.True(ok)
+	ast.Equal("30d", gs.KeyRotation)
+
+	ok = g.DeleteGroup(id)
+	ast.True(ok)
+}
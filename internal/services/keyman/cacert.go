@@ -9,16 +9,28 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"math/big"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/samber/do"
 	"github.com/willie68/micro-vault/internal/config"
 )
 
+// public pathes of the crl and the ocsp endpoint
+const (
+	crlPath  = "/api/v1/ca/crl"
+	ocspPath = "/api/v1/ca/ocsp"
+)
+
+// ErrNoCRLSign the ca certificate has no crl sign key usage, so no crl can be issued with it
+var ErrNoCRLSign = errors.New("the ca certificate has no crl sign key usage")
+
 // Cert the certificate
 type Cert struct {
 	caX509       []byte
@@ -28,9 +40,26 @@ type Cert struct {
 // CAService the CA cert service
 type CAService struct {
 	cfg          config.CACert
+	crlURL       string
+	ocspURL      string
+	ocsp         *ocspSigner
 	caPrivateKey *rsa.PrivateKey
 	caX509       x509.Certificate
 	certBytes    []byte
+	// the root certificate, in a single level ca the same as caX509
+	root      x509.Certificate
+	rootBytes []byte
+	// further trusted intermediates of the root
+	intermediates [][]byte
+	// previous ca certificates of a rollover
+	previous []issuer
+	hooks    *issueHooks
+}
+
+// issueHooks the listeners for certificates created by the ca service itself, shared by all copies of the service
+type issueHooks struct {
+	sync.Mutex
+	fns []func(der []byte)
 }
 
 // NewCAService creating a new CA service
@@ -38,7 +67,18 @@ func NewCAService() (*CAService, error) {
 	cfg := do.MustInvoke[config.Config](nil)
 	cnf := cfg.Service.CACert
 	c := CAService{
-		cfg: cnf,
+		cfg:     cnf,
+		crlURL:  cnf.CRLURL,
+		ocspURL: cnf.OCSPURL,
+		ocsp:    &ocspSigner{},
+		hooks:   &issueHooks{},
+	}
+	su := strings.TrimSuffix(cfg.Service.HTTP.ServiceURL, "/")
+	if c.crlURL == "" && su != "" {
+		c.crlURL = su + crlPath
+	}
+	if c.ocspURL == "" && su != "" {
+		c.ocspURL = su + ocspPath
 	}
 
 	err := c.init()
@@ -61,6 +101,10 @@ func (c *CAService) init() error {
 		}
 	}
 
+	if c.hierarchy() {
+		return c.initHierarchy()
+	}
+
 	if !fileExists(c.cfg.Certificate) {
 		logger.Alert("create a new public ca root certificate")
 		err := c.createCert()
@@ -79,10 +123,123 @@ func (c *CAService) init() error {
 			logger.Errorf("error loading certificate: %v", err)
 			return err
 		}
+		if c.caX509.KeyUsage&x509.KeyUsageCRLSign == 0 {
+			logger.Alert("the ca root certificate has no crl sign key usage, no crl can be issued, please renew the certificate")
+		}
+	}
+	c.root = c.caX509
+	c.rootBytes = c.certBytes
+	return c.loadPrevious()
+}
+
+// initHierarchy initialize a ca with an offline root. Only the public root certificate is loaded, the
+// certificate of the ca is the issuing intermediate. If the intermediate is missing, a certificate
+// request is written, which must be signed with the root key.
+func (c *CAService) initHierarchy() error {
+	rb, err := readCertificate(c.cfg.Root)
+	if err != nil {
+		logger.Errorf("error loading root certificate: %v", err)
+		return err
+	}
+	root, err := x509.ParseCertificate(rb)
+	if err != nil {
+		return err
+	}
+	if !root.IsCA {
+		return errors.New("the root certificate is not a ca certificate")
+	}
+	c.root = *root
+	c.rootBytes = rb
+
+	if !fileExists(c.cfg.Certificate) {
+		return c.writeCSR()
+	}
+	err = c.loadCertificate()
+	if err != nil {
+		logger.Errorf("error loading certificate: %v", err)
+		return err
+	}
+	err = checkIntermediate(&c.caX509, root, time.Now())
+	if err != nil {
+		return fmt.Errorf("intermediate %s: %w", c.cfg.Certificate, err)
+	}
+	pub, ok := c.caX509.PublicKey.(*rsa.PublicKey)
+	if !ok || !pub.Equal(&c.caPrivateKey.PublicKey) {
+		return fmt.Errorf("intermediate %s doesn't match the ca private key", c.cfg.Certificate)
+	}
+	err = c.loadPrevious()
+	if err != nil {
+		return err
+	}
+
+	c.intermediates = make([][]byte, 0)
+	for _, f := range c.cfg.Intermediates {
+		b, err := readCertificate(f)
+		if err != nil {
+			return err
+		}
+		xc, err := x509.ParseCertificate(b)
+		if err != nil {
+			return err
+		}
+		err = c.checkTrusted(xc, time.Now())
+		if err != nil {
+			return fmt.Errorf("intermediate %s: %w", f, err)
+		}
+		c.intermediates = append(c.intermediates, b)
 	}
 	return nil
 }
 
+// writeCSR writes a certificate request for the ca key next to the configured certificate
+func (c *CAService) writeCSR() error {
+	der, err := CreateCSR(subjectName(c.cfg.Subject), c.caPrivateKey)
+	if err != nil {
+		return err
+	}
+	f := c.cfg.Certificate + ".csr"
+	err = os.MkdirAll(filepath.Dir(f), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(f, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	logger.Alertf("intermediate certificate missing, sign the request %s with the root ca and save it as %s", f, c.cfg.Certificate)
+	return fmt.Errorf("%w: sign %s with the root ca", ErrIntermediateMissing, f)
+}
+
+// checkIntermediate checks, if the certificate is a valid ca certificate issued by the root
+func checkIntermediate(xc, root *x509.Certificate, now time.Time) error {
+	if !xc.IsCA {
+		return errors.New("not a ca certificate")
+	}
+	if xc.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("missing cert sign key usage")
+	}
+	if now.After(xc.NotAfter) {
+		return errors.New("certificate expired")
+	}
+	return xc.CheckSignatureFrom(root)
+}
+
+// checkTrusted checks, if the certificate is an intermediate of the root or of a previous root
+func (c *CAService) checkTrusted(xc *x509.Certificate, now time.Time) error {
+	var err error
+	for _, r := range c.roots(now) {
+		err = checkIntermediate(xc, r, now)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *CAService) hierarchy() bool {
+	return c.cfg.Root != ""
+}
+
 // getCaPrivateKey returning a private key. First try to load it from the file name given.
 // if not possible, it creates a new one and try to save it to the given location. In this
 // case it automatically invalids the public certificate of the CA service
@@ -100,7 +257,7 @@ func (c *CAService) getCaPrivateKey() (*rsa.PrivateKey, error) {
 		}
 		if c.cfg.Certificate != "" {
 			err = os.Remove(c.cfg.Certificate)
-			if err != nil {
+			if err != nil && !os.IsNotExist(err) {
 				return nil, err
 			}
 		}
@@ -119,32 +276,63 @@ func (c *CAService) X509Cert() *x509.Certificate {
 
 // X509CertPEM getting the X509 certificate as pem
 func (c *CAService) X509CertPEM() (string, error) {
+	return certsPEM(c.certBytes)
+}
+
+// RootCert getting the root certificate, the trust anchor of all issued certificates
+func (c *CAService) RootCert() *x509.Certificate {
+	return &c.root
+}
+
+// RootPEM getting the root certificate as pem
+func (c *CAService) RootPEM() (string, error) {
+	return certsPEM(c.rootBytes)
+}
+
+// ChainPEM getting the certificate chain from the issuing ca up to the root as pem
+func (c *CAService) ChainPEM() (string, error) {
+	if !c.hierarchy() {
+		return certsPEM(c.certBytes)
+	}
+	return certsPEM(c.certBytes, c.rootBytes)
+}
+
+// IntermediatesPEM getting all trusted intermediate certificates as pem, the issuing one first.
+// Previous intermediates of a rollover are part of it, until they are retired.
+func (c *CAService) IntermediatesPEM() (string, error) {
+	if !c.hierarchy() {
+		return "", nil
+	}
+	ders := append([][]byte{c.certBytes}, c.intermediates...)
+	for _, p := range c.trusted(time.Now()) {
+		if !p.root() {
+			ders = append(ders, p.cert.Raw)
+		}
+	}
+	return certsPEM(ders...)
+}
+
+func certsPEM(ders ...[]byte) (string, error) {
 	caPEM := new(bytes.Buffer)
-	err := pem.Encode(caPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: c.certBytes,
-	})
-	if err != nil {
-		return "", err
+	for _, der := range ders {
+		err := pem.Encode(caPEM, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: der,
+		})
+		if err != nil {
+			return "", err
+		}
 	}
 	return caPEM.String(), nil
 }
 
 func (c *CAService) loadCertificate() error {
-	f := c.cfg.Certificate
-	b, err := os.ReadFile(f)
+	b, err := readCertificate(c.cfg.Certificate)
 	if err != nil {
 		return err
 	}
-	p, _ := pem.Decode(b)
-	if p == nil {
-		return errors.New("no pem block found")
-	}
-	if p.Type != "CERTIFICATE" {
-		return errors.New("wrong pem block found")
-	}
-	c.certBytes = p.Bytes
-	xc, err := x509.ParseCertificate(p.Bytes)
+	c.certBytes = b
+	xc, err := x509.ParseCertificate(b)
 	if err != nil {
 		return err
 	}
@@ -152,6 +340,22 @@ func (c *CAService) loadCertificate() error {
 	return nil
 }
 
+// readCertificate reads the first pem certificate of the file as der
+func readCertificate(f string) ([]byte, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := pem.Decode(b)
+	if p == nil {
+		return nil, errors.New("no pem block found")
+	}
+	if p.Type != "CERTIFICATE" {
+		return nil, errors.New("wrong pem block found")
+	}
+	return p.Bytes, nil
+}
+
 func (c *CAService) saveCertificate() error {
 	f := c.cfg.Certificate
 	p := filepath.Dir(f)
@@ -192,22 +396,13 @@ func (c *CAService) createCert() error {
 
 	// create the root certificate
 	ca := &x509.Certificate{
-		SerialNumber: &ser,
-		Subject: pkix.Name{
-			Organization:       []string{c.cfg.Subject["Organization"]},
-			Country:            []string{c.cfg.Subject["Country"]},
-			Province:           []string{c.cfg.Subject["Province"]},
-			Locality:           []string{c.cfg.Subject["Locality"]},
-			StreetAddress:      []string{c.cfg.Subject["StreetAddress"]},
-			PostalCode:         []string{c.cfg.Subject["PostalCode"]},
-			CommonName:         c.cfg.Subject["CommonName"],
-			OrganizationalUnit: []string{c.cfg.Subject["OrganizationalUnit"]},
-		},
+		SerialNumber:          &ser,
+		Subject:               subjectName(c.cfg.Subject),
 		NotBefore:             time.Now(),
 		NotAfter:              time.Now().AddDate(10, 0, 0),
 		IsCA:                  true,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
 		SubjectKeyId:          hashKeyID(c.caPrivateKey.N),
 		AuthorityKeyId:        hashKeyID(c.caPrivateKey.N),
@@ -219,22 +414,32 @@ func (c *CAService) createCert() error {
 		return err
 	}
 
+	xc, err := x509.ParseCertificate(caBytes)
+	if err != nil {
+		return err
+	}
 	c.certBytes = caBytes
-	c.caX509 = *ca
+	c.caX509 = *xc
 	return nil
 }
 
-// CertSignRequest signing the certificate
+// CertSignRequest signing the certificate for server and client authentication
 func (c *CAService) CertSignRequest(template x509.CertificateRequest, pub any, validTo time.Duration) ([]byte, error) {
+	return c.CertSignUsage(template, pub, validTo, DefaultUsage())
+}
+
+// CertSignUsage signing the certificate with the usage, normally defined by a certificate profile
+func (c *CAService) CertSignUsage(template x509.CertificateRequest, pub any, validTo time.Duration, usage CertUsage) ([]byte, error) {
+	if usage.IsCA && c.caX509.MaxPathLen == 0 && c.caX509.MaxPathLenZero {
+		return nil, errors.New("the ca is not allowed to issue sub ca certificates")
+	}
 	ser, err := randBigint()
 	if err != nil {
 		return []byte{}, err
 	}
 	// create client certificate template
+	// the signature algorithm is given by the key of the ca, not by the key of the request
 	clientCRTTemplate := x509.Certificate{
-		Signature:          template.Signature,
-		SignatureAlgorithm: template.SignatureAlgorithm,
-
 		PublicKeyAlgorithm: template.PublicKeyAlgorithm,
 		PublicKey:          template.PublicKey,
 		EmailAddresses:     template.EmailAddresses,
@@ -247,21 +452,85 @@ func (c *CAService) CertSignRequest(template x509.CertificateRequest, pub any, v
 		NotBefore:          time.Now(),
 		NotAfter:           time.Now().Add(validTo),
 		AuthorityKeyId:     hashKeyID(c.caPrivateKey.N),
-		KeyUsage:           x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:           usage.KeyUsage,
+		ExtKeyUsage:        usage.ExtKeyUsage,
+	}
+	if usage.IsCA {
+		clientCRTTemplate.IsCA = true
+		clientCRTTemplate.BasicConstraintsValid = true
+		clientCRTTemplate.MaxPathLen = usage.MaxPathLen
+		clientCRTTemplate.MaxPathLenZero = usage.MaxPathLen == 0
+		clientCRTTemplate.PermittedDNSDomains = usage.PermittedDNSDomains
+		clientCRTTemplate.ExcludedDNSDomains = usage.ExcludedDNSDomains
+		clientCRTTemplate.PermittedIPRanges = usage.PermittedIPRanges
+		clientCRTTemplate.ExcludedIPRanges = usage.ExcludedIPRanges
+		// name constraints must be critical (RFC 5280)
+		clientCRTTemplate.PermittedDNSDomainsCritical = true
+	}
+	if c.crlURL != "" {
+		clientCRTTemplate.CRLDistributionPoints = []string{c.crlURL}
+	}
+	if c.ocspURL != "" {
+		clientCRTTemplate.OCSPServer = []string{c.ocspURL}
 	}
 	return x509.CreateCertificate(rand.Reader, &clientCRTTemplate, &c.caX509, pub, c.caPrivateKey)
 }
 
+// CreateCRL creating a new certificate revocation list with the revoked certificates, signed by the ca
+func (c *CAService) CreateCRL(entries []x509.RevocationListEntry, now, next time.Time) ([]byte, error) {
+	return c.CreateIssuerCRL(IssuerID(&c.caX509), entries, now, next)
+}
+
+// CreateIssuerCRL creating a new certificate revocation list with the revoked certificates,
+// signed by the actual or a previous ca certificate with the id
+func (c *CAService) CreateIssuerCRL(id string, entries []x509.RevocationListEntry, now, next time.Time) ([]byte, error) {
+	xc, key, err := c.signer(id, now)
+	if err != nil {
+		return nil, err
+	}
+	// only ca certificates allowed to sign crls are issuing them
+	if xc.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, ErrNoCRLSign
+	}
+	tmp := x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		// the crl number must be increasing, even on different nodes
+		Number:     big.NewInt(now.UnixMilli()),
+		ThisUpdate: now,
+		NextUpdate: next,
+	}
+	return x509.CreateRevocationList(rand.Reader, &tmp, xc, key)
+}
+
+// OnIssue registering a listener, which is called with every certificate created by CreateCertificate
+func (c *CAService) OnIssue(fn func(der []byte)) {
+	c.hooks.Lock()
+	defer c.hooks.Unlock()
+	c.hooks.fns = append(c.hooks.fns, fn)
+}
+
+func (c *CAService) issued(der []byte) {
+	c.hooks.Lock()
+	fns := c.hooks.fns
+	c.hooks.Unlock()
+	for _, fn := range fns {
+		fn(der)
+	}
+}
+
 // CreateCertificate create a usual simple certificate
 func (c *CAService) CreateCertificate() (*Cert, error) {
 	certPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
 		return nil, err
 	}
+	ser, err := randBigint()
+	if err != nil {
+		return nil, err
+	}
 
 	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(1658),
+		SerialNumber: &ser,
 		Subject: pkix.Name{
 			Organization:       []string{c.cfg.Subject["Organisation"]},
 			Country:            []string{c.cfg.Subject["Country"]},
@@ -285,6 +554,7 @@ func (c *CAService) CreateCertificate() (*Cert, error) {
 	if err != nil {
 		return nil, err
 	}
+	c.issued(certBytes)
 	certPEM := new(bytes.Buffer)
 	err = pem.Encode(certPEM, &pem.Block{
 		Type:  "CERTIFICATE",
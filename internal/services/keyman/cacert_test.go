@@ -171,6 +171,64 @@ func TestCSR(t *testing.T) {
 	shutDown(ast)
 }
 
+func TestCRL(t *testing.T) {
+	ast := assert.New(t)
+
+	_ = os.Remove(certfile)
+
+	cfg := config.Config{
+		Service: config.Service{
+			PrivateKey: keyfile,
+			HTTP: config.HTTP{
+				ServiceURL: "https://127.0.0.1:8443/",
+			},
+			CACert: config.CACert{
+				Certificate: certfile,
+				Subject:     subjectMap,
+			},
+		},
+	}
+	cfg.Provide()
+
+	_, err := NewKeyman()
+	ast.Nil(err)
+
+	ca, err := NewCAService()
+	ast.Nil(err)
+	ast.NotNil(ca)
+
+	certPrivKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	b, err := ca.CertSignRequest(x509.CertificateRequest{Subject: pkix.Name{CommonName: "test"}}, &certPrivKey.PublicKey, time.Hour)
+	ast.Nil(err)
+	xc, err := x509.ParseCertificate(b)
+	ast.Nil(err)
+	ast.Equal([]string{"https://127.0.0.1:8443/api/v1/ca/crl"}, xc.CRLDistributionPoints)
+
+	now := time.Now()
+	der, err := ca.CreateCRL([]x509.RevocationListEntry{{SerialNumber: xc.SerialNumber, RevocationTime: now, ReasonCode: 1}}, now, now.Add(time.Hour))
+	ast.Nil(err)
+	crl, err := x509.ParseRevocationList(der)
+	ast.Nil(err)
+
+	// a new ca certificate has the crl sign key usage
+	pm, err := ca.X509CertPEM()
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(pm))
+	cac, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+	ast.Nil(crl.CheckSignatureFrom(cac))
+	ast.Equal(1, len(crl.RevokedCertificateEntries))
+	ast.Equal(0, xc.SerialNumber.Cmp(crl.RevokedCertificateEntries[0].SerialNumber))
+
+	// without the crl sign key usage no crl is issued
+	ca.caX509.KeyUsage &^= x509.KeyUsageCRLSign
+	_, err = ca.CreateCRL(nil, now, now.Add(time.Hour))
+	ast.ErrorIs(err, ErrNoCRLSign)
+
+	shutDown(ast)
+}
+
 func TestNewPrivateKey(t *testing.T) {
 	ast := assert.New(t)
 
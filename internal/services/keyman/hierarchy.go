@@ -0,0 +1,90 @@
+package keyman
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"time"
+)
+
+// ErrIntermediateMissing the certificate of the intermediate ca is missing and must be signed with the offline root
+var ErrIntermediateMissing = errors.New("intermediate ca certificate missing")
+
+// CreateRootCA creating a self signed root ca certificate (der). This is an offline operation,
+// the root key should never be used by the service itself.
+func CreateRootCA(subject pkix.Name, key *rsa.PrivateKey, validity time.Duration) ([]byte, error) {
+	ser, err := randBigint()
+	if err != nil {
+		return nil, err
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          &ser,
+		Subject:               subject,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(validity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		SubjectKeyId:          hashKeyID(key.N),
+		AuthorityKeyId:        hashKeyID(key.N),
+	}
+	return x509.CreateCertificate(rand.Reader, ca, ca, &key.PublicKey, key)
+}
+
+// CreateCSR creating a certificate request (der) for the key of an intermediate ca
+func CreateCSR(subject pkix.Name, key *rsa.PrivateKey) ([]byte, error) {
+	return x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject}, key)
+}
+
+// SignIntermediate signing the certificate request of an intermediate ca with the root (der).
+// The intermediate can only issue leaf certificates and never outlives the root.
+func SignIntermediate(csr *x509.CertificateRequest, root *x509.Certificate, rootKey *rsa.PrivateKey, validity time.Duration) ([]byte, error) {
+	err := csr.CheckSignature()
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := csr.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("the intermediate key must be a rsa key")
+	}
+	rpub, ok := root.PublicKey.(*rsa.PublicKey)
+	if !ok || !rpub.Equal(&rootKey.PublicKey) {
+		return nil, errors.New("the root key doesn't match the root certificate")
+	}
+	ser, err := randBigint()
+	if err != nil {
+		return nil, err
+	}
+	notAfter := time.Now().Add(validity)
+	if notAfter.After(root.NotAfter) {
+		notAfter = root.NotAfter
+	}
+	tmp := &x509.Certificate{
+		SerialNumber:          &ser,
+		Subject:               csr.Subject,
+		NotBefore:             time.Now(),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		SubjectKeyId:          hashKeyID(pub.N),
+	}
+	return x509.CreateCertificate(rand.Reader, tmp, root, pub, rootKey)
+}
+
+// subjectName converting the configured subject into a distinguished name
+func subjectName(s map[string]string) pkix.Name {
+	return pkix.Name{
+		Organization:       []string{s["Organization"]},
+		Country:            []string{s["Country"]},
+		Province:           []string{s["Province"]},
+		Locality:           []string{s["Locality"]},
+		StreetAddress:      []string{s["StreetAddress"]},
+		PostalCode:         []string{s["PostalCode"]},
+		CommonName:         s["CommonName"],
+		OrganizationalUnit: []string{s["OrganizationalUnit"]},
+	}
+}
@@ -0,0 +1,229 @@
+package keyman
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/config"
+)
+
+func writeRoot(ast *assert.Assertions, f string) (*x509.Certificate, *rsa.PrivateKey) {
+	rsk, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	der, err := CreateRootCA(pkix.Name{CommonName: "root"}, rsk, time.Hour*24*365)
+	ast.Nil(err)
+	ast.Nil(os.WriteFile(f, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), os.ModePerm))
+	root, err := x509.ParseCertificate(der)
+	ast.Nil(err)
+	return root, rsk
+}
+
+func signCSR(ast *assert.Assertions, csrfile, crtfile string, root *x509.Certificate, rsk *rsa.PrivateKey) {
+	b, err := os.ReadFile(csrfile)
+	ast.Nil(err)
+	p, _ := pem.Decode(b)
+	ast.NotNil(p)
+	ast.Equal("CERTIFICATE REQUEST", p.Type)
+	csr, err := x509.ParseCertificateRequest(p.Bytes)
+	ast.Nil(err)
+	der, err := SignIntermediate(csr, root, rsk, time.Hour*24*30)
+	ast.Nil(err)
+	ast.Nil(os.WriteFile(crtfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), os.ModePerm))
+}
+
+func pemCerts(ast *assert.Assertions, s string) []*x509.Certificate {
+	xcs := make([]*x509.Certificate, 0)
+	b := []byte(s)
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			return xcs
+		}
+		xc, err := x509.ParseCertificate(p.Bytes)
+		ast.Nil(err)
+		xcs = append(xcs, xc)
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	ast := assert.New(t)
+	dir := t.TempDir()
+	rootfile := filepath.Join(dir, "root.pem")
+	cakey := filepath.Join(dir, "ca.key")
+	cacrt := filepath.Join(dir, "ca.pem")
+
+	root, rsk := writeRoot(ast, rootfile)
+
+	cfg := config.Config{
+		Service: config.Service{
+			PrivateKey: keyfile,
+			CACert: config.CACert{
+				PrivateKey:  cakey,
+				Certificate: cacrt,
+				Root:        rootfile,
+				Subject:     subjectMap,
+			},
+		},
+	}
+	cfg.Provide()
+	_, err := NewKeyman()
+	ast.Nil(err)
+
+	// without an intermediate only the request is written
+	_, err = NewCAService()
+	ast.NotNil(err)
+	ast.True(errors.Is(err, ErrIntermediateMissing))
+	ast.True(fileExists(cacrt + ".csr"))
+	ast.False(fileExists(cacrt))
+
+	signCSR(ast, cacrt+".csr", cacrt, root, rsk)
+
+	ca, err := NewCAService()
+	ast.Nil(err)
+	ast.True(ca.X509Cert().IsCA)
+	ast.Equal(root.Raw, ca.RootCert().Raw)
+
+	rp, err := ca.RootPEM()
+	ast.Nil(err)
+	ast.Equal(1, len(pemCerts(ast, rp)))
+
+	cp, err := ca.ChainPEM()
+	ast.Nil(err)
+	chain := pemCerts(ast, cp)
+	ast.Equal(2, len(chain))
+	ast.Equal(ca.X509Cert().Raw, chain[0].Raw)
+	ast.Equal(root.Raw, chain[1].Raw)
+
+	// a leaf is issued by the intermediate and verifies up to the root
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	b, err := ca.CertSignRequest(x509.CertificateRequest{Subject: pkix.Name{CommonName: "leaf"}}, &pk.PublicKey, time.Hour)
+	ast.Nil(err)
+	leaf, err := x509.ParseCertificate(b)
+	ast.Nil(err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	inters := x509.NewCertPool()
+	inters.AddCert(chain[0])
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: inters})
+	ast.Nil(err)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots})
+	ast.NotNil(err)
+
+	ip, err := ca.IntermediatesPEM()
+	ast.Nil(err)
+	ast.Equal(1, len(pemCerts(ast, ip)))
+
+	shutDown(ast)
+}
+
+func TestHierarchyNewIntermediate(t *testing.T) {
+	ast := assert.New(t)
+	dir := t.TempDir()
+	rootfile := filepath.Join(dir, "root.pem")
+
+	root, rsk := writeRoot(ast, rootfile)
+
+	// the old intermediate stays trusted and published
+	oldkey, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	der, err := CreateCSR(pkix.Name{CommonName: "old"}, oldkey)
+	ast.Nil(err)
+	ast.Nil(os.WriteFile(filepath.Join(dir, "old.csr"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), os.ModePerm))
+	signCSR(ast, filepath.Join(dir, "old.csr"), filepath.Join(dir, "old.pem"), root, rsk)
+
+	cakey := filepath.Join(dir, "new.key")
+	cacrt := filepath.Join(dir, "new.pem")
+	cfg := config.Config{
+		Service: config.Service{
+			PrivateKey: keyfile,
+			CACert: config.CACert{
+				PrivateKey:    cakey,
+				Certificate:   cacrt,
+				Root:          rootfile,
+				Intermediates: []string{filepath.Join(dir, "old.pem")},
+				Subject:       subjectMap,
+			},
+		},
+	}
+	cfg.Provide()
+	_, err = NewKeyman()
+	ast.Nil(err)
+
+	_, err = NewCAService()
+	ast.True(errors.Is(err, ErrIntermediateMissing))
+	signCSR(ast, cacrt+".csr", cacrt, root, rsk)
+
+	ca, err := NewCAService()
+	ast.Nil(err)
+	ip, err := ca.IntermediatesPEM()
+	ast.Nil(err)
+	inters := pemCerts(ast, ip)
+	ast.Equal(2, len(inters))
+	ast.Equal(ca.X509Cert().Raw, inters[0].Raw)
+	ast.Equal("old", inters[1].Subject.CommonName)
+
+	shutDown(ast)
+}
+
+func TestHierarchyWrongRoot(t *testing.T) {
+	ast := assert.New(t)
+	dir := t.TempDir()
+	rootfile := filepath.Join(dir, "root.pem")
+	cacrt := filepath.Join(dir, "ca.pem")
+
+	_, _ = writeRoot(ast, rootfile)
+	other, osk := writeRoot(ast, filepath.Join(dir, "other.pem"))
+
+	cfg := config.Config{
+		Service: config.Service{
+			PrivateKey: keyfile,
+			CACert: config.CACert{
+				PrivateKey:  filepath.Join(dir, "ca.key"),
+				Certificate: cacrt,
+				Root:        rootfile,
+				Subject:     subjectMap,
+			},
+		},
+	}
+	cfg.Provide()
+	_, err := NewKeyman()
+	ast.Nil(err)
+
+	_, err = NewCAService()
+	ast.True(errors.Is(err, ErrIntermediateMissing))
+	signCSR(ast, cacrt+".csr", cacrt, other, osk)
+
+	// signed by a foreign root
+	_, err = NewCAService()
+	ast.NotNil(err)
+
+	// the root key must match the root certificate
+	b, err := os.ReadFile(cacrt + ".csr")
+	ast.Nil(err)
+	p, _ := pem.Decode(b)
+	csr, err := x509.ParseCertificateRequest(p.Bytes)
+	ast.Nil(err)
+	_, err = SignIntermediate(csr, other, rsaKey(ast), time.Hour)
+	ast.NotNil(err)
+
+	ast.Nil(do.Shutdown[config.Config](nil))
+	ast.Nil(do.Shutdown[Keyman](nil))
+}
+
+func rsaKey(ast *assert.Assertions) *rsa.PrivateKey {
+	rsk, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	return rsk
+}
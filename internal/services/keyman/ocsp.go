@@ -0,0 +1,310 @@
+package keyman
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// the delegated ocsp signing certificate will be renewed, if it's valid less than this
+const (
+	ocspSignerValid = 7 * 24 * time.Hour
+	ocspSignerRenew = 24 * time.Hour
+)
+
+var (
+	oidOCSPBasic   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+	oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+	oidSHA256RSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	hashOIDs       = map[crypto.Hash]asn1.ObjectIdentifier{
+		crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+		crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+		crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+		crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+	}
+)
+
+// asn.1 structures of a ocsp response (RFC 6960), the x/crypto implementation has no support for response extensions
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// asn.1 structure of a ocsp request, only for reading the request extensions
+type ocspRequestASN1 struct {
+	TBSRequest ocspTBSRequest
+	Signature  asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspTBSRequest struct {
+	Version       int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList   []asn1.RawValue
+	Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+// ocspSigner the delegated ocsp signing certificate, shared by all copies of the ca service
+type ocspSigner struct {
+	sync.Mutex
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+// OCSPNonce getting the nonce extension of a der encoded ocsp request, nil if the request has no nonce
+func OCSPNonce(der []byte) ([]byte, error) {
+	var req ocspRequestASN1
+	_, err := asn1.Unmarshal(der, &req)
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range req.TBSRequest.Extensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			if len(ext.Value) == 0 || len(ext.Value) > 34 {
+				return nil, errors.New("ocsp nonce not valid")
+			}
+			return ext.Value, nil
+		}
+	}
+	return nil, nil
+}
+
+// IsOCSPIssuer checking if the ocsp request is for a certificate of this ca, the actual or a previous one
+func (c *CAService) IsOCSPIssuer(req *ocsp.Request) bool {
+	_, _, ok := c.ocspIssuer(req, time.Now())
+	return ok
+}
+
+// ocspIssuer getting the ca certificate and key, which issued the certificate of the request
+func (c *CAService) ocspIssuer(req *ocsp.Request, now time.Time) (*x509.Certificate, *rsa.PrivateKey, bool) {
+	if _, ok := hashOIDs[req.HashAlgorithm]; !ok || !req.HashAlgorithm.Available() {
+		return nil, nil, false
+	}
+	for _, id := range c.issuerIDs(now) {
+		xc, key, err := c.signer(id, now)
+		if err != nil {
+			continue
+		}
+		nh, kh, err := issuerHashes(xc, req.HashAlgorithm)
+		if err != nil {
+			continue
+		}
+		if string(nh) == string(req.IssuerNameHash) && string(kh) == string(req.IssuerKeyHash) {
+			return xc, key, true
+		}
+	}
+	return nil, nil, false
+}
+
+// CreateOCSPResponse creating a signed ocsp response for the request with the status of the template.
+// The nonce is the raw value of the nonce extension of the request and is returned in the response extensions.
+// The response is signed with the ca key or, if configured, with a delegated ocsp signing certificate.
+// Requests for certificates of a previous ca are always signed with the key of the previous ca.
+func (c *CAService) CreateOCSPResponse(req *ocsp.Request, tmp ocsp.Response, nonce []byte) ([]byte, error) {
+	hoid, ok := hashOIDs[req.HashAlgorithm]
+	if !ok {
+		return nil, errors.New("unsupported issuer hash algorithm")
+	}
+	sr := ocspSingleResponse{
+		CertID: ocspCertID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  hoid,
+				Parameters: asn1.RawValue{Tag: asn1.TagNull},
+			},
+			NameHash:      req.IssuerNameHash,
+			IssuerKeyHash: req.IssuerKeyHash,
+			SerialNumber:  req.SerialNumber,
+		},
+		ThisUpdate: tmp.ThisUpdate.UTC(),
+		NextUpdate: tmp.NextUpdate.UTC(),
+	}
+	switch tmp.Status {
+	case ocsp.Good:
+		sr.Good = true
+	case ocsp.Revoked:
+		sr.Revoked = ocspRevokedInfo{
+			RevocationTime: tmp.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(tmp.RevocationReason),
+		}
+	default:
+		sr.Unknown = true
+	}
+
+	now := time.Now()
+	_, key, ok := c.ocspIssuer(req, now)
+	if !ok {
+		return nil, errors.New("unknown issuer of the ocsp request")
+	}
+	var crt *x509.Certificate
+	if key == c.caPrivateKey {
+		var err error
+		key, crt, err = c.ocspSigner(now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	kh, err := asn1.Marshal(keyHash(&key.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	rd := ocspResponseData{
+		// responder id by key hash
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: kh},
+		ProducedAt:     now.Truncate(time.Second).UTC(),
+		Responses:      []ocspSingleResponse{sr},
+	}
+	if len(nonce) > 0 {
+		rd.ResponseExtensions = []pkix.Extension{{Id: oidOCSPNonce, Value: nonce}}
+	}
+	tbs, err := asn1.Marshal(rd)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(tbs)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		return nil, err
+	}
+	br := ocspBasicResponse{
+		TBSResponseData: rd,
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidSHA256RSA,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		},
+		Signature: asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	}
+	if crt != nil {
+		br.Certificates = []asn1.RawValue{{FullBytes: crt.Raw}}
+	}
+	brd, err := asn1.Marshal(br)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspResponseASN1{
+		Status: asn1.Enumerated(ocsp.Success),
+		Response: ocspResponseBytes{
+			ResponseType: oidOCSPBasic,
+			Response:     brd,
+		},
+	})
+}
+
+// ocspSigner returning the key and the certificate for signing ocsp responses, the certificate is nil for the ca key
+func (c *CAService) ocspSigner(now time.Time) (*rsa.PrivateKey, *x509.Certificate, error) {
+	if !c.cfg.OCSPDelegated {
+		return c.caPrivateKey, nil, nil
+	}
+	c.ocsp.Lock()
+	defer c.ocsp.Unlock()
+	if c.ocsp.cert != nil && now.Add(ocspSignerRenew).Before(c.ocsp.cert.NotAfter) {
+		return c.ocsp.key, c.ocsp.cert, nil
+	}
+	logger.Info("create a new delegated ocsp signing certificate")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	ser, err := randBigint()
+	if err != nil {
+		return nil, nil, err
+	}
+	tmp := x509.Certificate{
+		SerialNumber: &ser,
+		Subject: pkix.Name{
+			Organization: c.caX509.Subject.Organization,
+			CommonName:   c.caX509.Subject.CommonName + " OCSP Responder",
+		},
+		NotBefore:      now.Add(-time.Minute),
+		NotAfter:       now.Add(ocspSignerValid),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		SubjectKeyId:   hashKeyID(key.N),
+		AuthorityKeyId: hashKeyID(c.caPrivateKey.N),
+		// the responder certificate itself must not be checked via ocsp
+		ExtraExtensions: []pkix.Extension{{Id: oidOCSPNoCheck, Value: asn1.NullBytes}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmp, &c.caX509, &key.PublicKey, c.caPrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.ocsp.key = key
+	c.ocsp.cert = crt
+	return key, crt, nil
+}
+
+func issuerHashes(xc *x509.Certificate, hf crypto.Hash) ([]byte, []byte, error) {
+	var pki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(xc.RawSubjectPublicKeyInfo, &pki); err != nil {
+		return nil, nil, err
+	}
+	h := hf.New()
+	h.Write(xc.RawSubject)
+	nh := h.Sum(nil)
+	h.Reset()
+	h.Write(pki.PublicKey.RightAlign())
+	return nh, h.Sum(nil), nil
+}
+
+func keyHash(pub *rsa.PublicKey) []byte {
+	h := sha1.Sum(x509.MarshalPKCS1PublicKey(pub))
+	return h[:]
+}
@@ -0,0 +1,117 @@
+package keyman
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/config"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestOCSPResponse(t *testing.T) {
+	for _, delegated := range []bool{false, true} {
+		ocspResponse(t, delegated)
+	}
+}
+
+func ocspResponse(t *testing.T, delegated bool) {
+	ast := assert.New(t)
+
+	_ = os.Remove(certfile)
+
+	cfg := config.Config{
+		Service: config.Service{
+			PrivateKey: keyfile,
+			HTTP: config.HTTP{
+				ServiceURL: "https://127.0.0.1:8443",
+			},
+			CACert: config.CACert{
+				Certificate:   certfile,
+				Subject:       subjectMap,
+				OCSPDelegated: delegated,
+			},
+		},
+	}
+	cfg.Provide()
+
+	_, err := NewKeyman()
+	ast.Nil(err)
+	ca, err := NewCAService()
+	ast.Nil(err)
+	defer shutDown(ast)
+
+	certPrivKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	b, err := ca.CertSignRequest(x509.CertificateRequest{Subject: pkix.Name{CommonName: "test"}}, &certPrivKey.PublicKey, time.Hour)
+	ast.Nil(err)
+	xc, err := x509.ParseCertificate(b)
+	ast.Nil(err)
+	ast.Equal([]string{"https://127.0.0.1:8443/api/v1/ca/ocsp"}, xc.OCSPServer)
+
+	der, err := ocsp.CreateRequest(xc, ca.X509Cert(), &ocsp.RequestOptions{Hash: crypto.SHA256})
+	ast.Nil(err)
+	der = withNonce(ast, der, []byte{0x04, 0x04, 1, 2, 3, 4})
+
+	nonce, err := OCSPNonce(der)
+	ast.Nil(err)
+	ast.Equal([]byte{0x04, 0x04, 1, 2, 3, 4}, nonce)
+
+	req, err := ocsp.ParseRequest(der)
+	ast.Nil(err)
+	ast.True(ca.IsOCSPIssuer(req))
+
+	now := time.Now()
+	rb, err := ca.CreateOCSPResponse(req, ocsp.Response{
+		Status:           ocsp.Revoked,
+		RevokedAt:        now.Add(-time.Minute),
+		RevocationReason: ocsp.KeyCompromise,
+		ThisUpdate:       now,
+		NextUpdate:       now.Add(time.Hour),
+	}, nonce)
+	ast.Nil(err)
+
+	res, err := ocsp.ParseResponseForCert(rb, xc, ca.X509Cert())
+	ast.Nil(err)
+	ast.Equal(ocsp.Revoked, res.Status)
+	ast.Equal(ocsp.KeyCompromise, res.RevocationReason)
+	ast.Equal(0, xc.SerialNumber.Cmp(res.SerialNumber))
+	if delegated {
+		ast.NotNil(res.Certificate)
+		ast.Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, res.Certificate.ExtKeyUsage)
+	} else {
+		ast.Nil(res.Certificate)
+	}
+
+	// the nonce must be part of the response extensions
+	var ra ocspResponseASN1
+	_, err = asn1.Unmarshal(rb, &ra)
+	ast.Nil(err)
+	var br ocspBasicResponse
+	_, err = asn1.Unmarshal(ra.Response.Response, &br)
+	ast.Nil(err)
+	ast.Equal(1, len(br.TBSResponseData.ResponseExtensions))
+	ast.True(br.TBSResponseData.ResponseExtensions[0].Id.Equal(oidOCSPNonce))
+	ast.Equal(nonce, br.TBSResponseData.ResponseExtensions[0].Value)
+
+	// request of a foreign issuer
+	req.IssuerKeyHash = []byte{1, 2, 3}
+	ast.False(ca.IsOCSPIssuer(req))
+}
+
+func withNonce(ast *assert.Assertions, der, nonce []byte) []byte {
+	var req ocspRequestASN1
+	_, err := asn1.Unmarshal(der, &req)
+	ast.Nil(err)
+	req.TBSRequest.Extensions = append(req.TBSRequest.Extensions, pkix.Extension{Id: oidOCSPNonce, Value: nonce})
+	b, err := asn1.Marshal(req)
+	ast.Nil(err)
+	return b
+}
@@ -0,0 +1,137 @@
+package keyman
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+)
+
+// defaultOverlap previous ca certificates are trusted for this time after the start of the actual ca,
+// the default validity of the issued certificates
+const defaultOverlap = 365 * 24 * time.Hour
+
+// issuer a previous ca certificate, which is trusted until it's retired
+type issuer struct {
+	cert   x509.Certificate
+	key    *rsa.PrivateKey
+	retire time.Time
+}
+
+func (i *issuer) root() bool {
+	return bytes.Equal(i.cert.RawIssuer, i.cert.RawSubject) && i.cert.CheckSignatureFrom(&i.cert) == nil
+}
+
+// loadPrevious loading the previous ca certificates of a rollover, the overlap starts with the actual ca certificate
+func (c *CAService) loadPrevious() error {
+	overlap := defaultOverlap
+	if c.cfg.Overlap != "" {
+		var err error
+		overlap, err = str2duration.ParseDuration(c.cfg.Overlap)
+		if err != nil {
+			return fmt.Errorf("configure overlap with a valid duration: %v", err)
+		}
+	}
+	retire := c.caX509.NotBefore.Add(overlap)
+	c.previous = make([]issuer, 0)
+	for _, p := range c.cfg.Previous {
+		b, err := readCertificate(p.Certificate)
+		if err != nil {
+			return fmt.Errorf("previous ca %s: %w", p.Certificate, err)
+		}
+		xc, err := x509.ParseCertificate(b)
+		if err != nil {
+			return err
+		}
+		is := issuer{cert: *xc, retire: retire}
+		if p.PrivateKey != "" {
+			rsk, err := loadFromFile(p.PrivateKey)
+			if err != nil {
+				return err
+			}
+			pub, ok := xc.PublicKey.(*rsa.PublicKey)
+			if rsk == nil || !ok || !pub.Equal(&rsk.PublicKey) {
+				return fmt.Errorf("previous ca %s doesn't match the private key %s", p.Certificate, p.PrivateKey)
+			}
+			is.key = rsk
+		}
+		if time.Now().After(retire) {
+			logger.Alertf("previous ca %s is retired since %s, remove it from the configuration", p.Certificate, retire.Format(time.RFC3339))
+		} else {
+			logger.Infof("previous ca %s is trusted until %s", p.Certificate, retire.Format(time.RFC3339))
+		}
+		c.previous = append(c.previous, is)
+	}
+	return nil
+}
+
+// trusted getting the previous ca certificates, which are not retired
+func (c *CAService) trusted(now time.Time) []issuer {
+	is := make([]issuer, 0)
+	for _, p := range c.previous {
+		if now.Before(p.retire) {
+			is = append(is, p)
+		}
+	}
+	return is
+}
+
+// roots getting all trusted root certificates, the actual root first
+func (c *CAService) roots(now time.Time) []*x509.Certificate {
+	rs := []*x509.Certificate{&c.root}
+	ts := c.trusted(now)
+	for i := range ts {
+		if ts[i].root() {
+			rs = append(rs, &ts[i].cert)
+		}
+	}
+	return rs
+}
+
+// TrustBundlePEM getting all trusted root certificates as pem. After a rollover these are the actual and the previous roots.
+func (c *CAService) TrustBundlePEM() (string, error) {
+	ders := make([][]byte, 0)
+	for _, r := range c.roots(time.Now()) {
+		ders = append(ders, r.Raw)
+	}
+	return certsPEM(ders...)
+}
+
+// IssuerID the id of a ca certificate, the hex encoded subject key id
+func IssuerID(xc *x509.Certificate) string {
+	return hex.EncodeToString(xc.SubjectKeyId)
+}
+
+// IssuerIDs getting the ids of all ca certificates, which are signing crls, the actual ca first
+func (c *CAService) IssuerIDs() []string {
+	return c.issuerIDs(time.Now())
+}
+
+func (c *CAService) issuerIDs(now time.Time) []string {
+	ids := []string{IssuerID(&c.caX509)}
+	for _, p := range c.trusted(now) {
+		if p.key != nil {
+			ids = append(ids, IssuerID(&p.cert))
+		}
+	}
+	return ids
+}
+
+// signer getting the certificate and the key of the ca certificate with the id
+func (c *CAService) signer(id string, now time.Time) (*x509.Certificate, *rsa.PrivateKey, error) {
+	if id == IssuerID(&c.caX509) {
+		return &c.caX509, c.caPrivateKey, nil
+	}
+	ts := c.trusted(now)
+	for i := range ts {
+		if ts[i].key != nil && id == IssuerID(&ts[i].cert) {
+			return &ts[i].cert, ts[i].key, nil
+		}
+	}
+	return nil, nil, serror.NotFound("issuer", id)
+}
@@ -0,0 +1,115 @@
+package keyman
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/config"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRollover(t *testing.T) {
+	ast := assert.New(t)
+	dir := t.TempDir()
+	oldkey := filepath.Join(dir, "old.key")
+	oldcrt := filepath.Join(dir, "old.pem")
+
+	cfg := config.Config{
+		Service: config.Service{
+			PrivateKey: keyfile,
+			CACert: config.CACert{
+				PrivateKey:  oldkey,
+				Certificate: oldcrt,
+				Subject:     subjectMap,
+			},
+		},
+	}
+	cfg.Provide()
+	_, err := NewKeyman()
+	ast.Nil(err)
+	ca, err := NewCAService()
+	ast.Nil(err)
+	oldca := *ca.X509Cert()
+
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	ast.Nil(err)
+	b, err := ca.CertSignRequest(x509.CertificateRequest{Subject: pkix.Name{CommonName: "old leaf"}}, &pk.PublicKey, time.Hour)
+	ast.Nil(err)
+	leaf, err := x509.ParseCertificate(b)
+	ast.Nil(err)
+	shutDown(ast)
+
+	// rollover to a new key, the old ca stays trusted for the overlap
+	cfg.Service.CACert = config.CACert{
+		PrivateKey:  filepath.Join(dir, "new.key"),
+		Certificate: filepath.Join(dir, "new.pem"),
+		Subject:     subjectMap,
+		Previous:    []config.PreviousCA{{Certificate: oldcrt, PrivateKey: oldkey}},
+		Overlap:     "30d",
+	}
+	cfg.Provide()
+	_, err = NewKeyman()
+	ast.Nil(err)
+	ca, err = NewCAService()
+	ast.Nil(err)
+	ast.NotEqual(oldca.Raw, ca.X509Cert().Raw)
+
+	bp, err := ca.TrustBundlePEM()
+	ast.Nil(err)
+	bundle := pemCerts(ast, bp)
+	ast.Equal(2, len(bundle))
+	ast.Equal(ca.X509Cert().Raw, bundle[0].Raw)
+	ast.Equal(oldca.Raw, bundle[1].Raw)
+
+	// the old leaf is still valid with the bundle, new leaves are signed with the new key
+	roots := x509.NewCertPool()
+	for _, r := range bundle {
+		roots.AddCert(r)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots})
+	ast.Nil(err)
+	b, err = ca.CertSignRequest(x509.CertificateRequest{Subject: pkix.Name{CommonName: "new leaf"}}, &pk.PublicKey, time.Hour)
+	ast.Nil(err)
+	nleaf, err := x509.ParseCertificate(b)
+	ast.Nil(err)
+	ast.Nil(nleaf.CheckSignatureFrom(ca.X509Cert()))
+
+	// crl and ocsp for the certificates of the old ca are signed with the old key
+	ids := ca.IssuerIDs()
+	ast.Equal([]string{IssuerID(ca.X509Cert()), IssuerID(&oldca)}, ids)
+	now := time.Now()
+	der, err := ca.CreateIssuerCRL(ids[1], []x509.RevocationListEntry{{SerialNumber: leaf.SerialNumber, RevocationTime: now}}, now, now.Add(time.Hour))
+	ast.Nil(err)
+	crl, err := x509.ParseRevocationList(der)
+	ast.Nil(err)
+	ast.Nil(crl.CheckSignatureFrom(&oldca))
+
+	rd, err := ocsp.CreateRequest(leaf, &oldca, nil)
+	ast.Nil(err)
+	req, err := ocsp.ParseRequest(rd)
+	ast.Nil(err)
+	ast.True(ca.IsOCSPIssuer(req))
+	rb, err := ca.CreateOCSPResponse(req, ocsp.Response{Status: ocsp.Good, ThisUpdate: now, NextUpdate: now.Add(time.Hour)}, nil)
+	ast.Nil(err)
+	res, err := ocsp.ParseResponseForCert(rb, leaf, &oldca)
+	ast.Nil(err)
+	ast.Equal(ocsp.Good, res.Status)
+
+	// after the overlap the old ca is retired
+	later := now.Add(31 * 24 * time.Hour)
+	ast.Equal(0, len(ca.trusted(later)))
+	ast.Equal(1, len(ca.roots(later)))
+	ast.Equal([]string{ids[0]}, ca.issuerIDs(later))
+	_, _, err = ca.signer(ids[1], later)
+	ast.NotNil(err)
+	_, _, ok := ca.ocspIssuer(req, later)
+	ast.False(ok)
+
+	shutDown(ast)
+}
@@ -0,0 +1,26 @@
+package keyman
+
+import (
+	"crypto/x509"
+	"net"
+)
+
+// CertUsage the usage of an issued certificate, key usages and for sub ca certificates the constraints
+type CertUsage struct {
+	KeyUsage            x509.KeyUsage
+	ExtKeyUsage         []x509.ExtKeyUsage
+	IsCA                bool
+	MaxPathLen          int
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+	PermittedIPRanges   []*net.IPNet
+	ExcludedIPRanges    []*net.IPNet
+}
+
+// DefaultUsage the usage of certificates without a profile, for server and client authentication
+func DefaultUsage() CertUsage {
+	return CertUsage{
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+}
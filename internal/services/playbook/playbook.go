@@ -1,8 +1,6 @@
 package playbook
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -74,6 +72,11 @@ func (p *Playbook) Play() error {
 	if err != nil {
 		return err
 	}
+
+	err = p.addProfiles()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -94,12 +97,17 @@ func (p *Playbook) addClients() error {
 func (p *Playbook) ensureAddClient(c model.Client) (err error) {
 	if c.Key == "" {
 		logger.Infof("creating new Pem for %s", c.Name)
-		c.Key, err = p.generateNewKeyPem()
+		c.Key, err = p.generateNewKeyPem(c.KeyType)
 		if err != nil {
 			return err
 		}
 	}
 
+	c.KeyType, err = cry.KeyTypeOfPEM(c.Key)
+	if err != nil {
+		return err
+	}
+
 	if c.KID == "" {
 		c.KID, err = cry.GetKIDOfPEM(c.Key)
 		if err != nil {
@@ -124,6 +132,7 @@ func (p *Playbook) ensureAddClient(c model.Client) (err error) {
 		Groups:    c.Groups,
 		Key:       c.Key,
 		KID:       c.KID,
+		KeyType:   c.KeyType,
 		Crt:       c.Crt,
 	}
 	_, err = p.stg.AddClient(cl)
@@ -144,8 +153,8 @@ func (p *Playbook) ensureAddClient(c model.Client) (err error) {
 	return nil
 }
 
-func (p *Playbook) generateNewKeyPem() (string, error) {
-	rsk, err := rsa.GenerateKey(rand.Reader, 4096)
+func (p *Playbook) generateNewKeyPem(kt string) (string, error) {
+	rsk, err := cry.GenerateKey(kt)
 	if err != nil {
 		return "", err
 	}
@@ -184,12 +193,30 @@ func (p *Playbook) addKeys() error {
 	return nil
 }
 
+func (p *Playbook) addProfiles() error {
+	for _, cp := range p.pm.Profiles {
+		err := cp.Validate()
+		if err != nil {
+			logger.Errorf("can't import profile \"%s\": %v", cp.Name, err)
+			return err
+		}
+		err = p.stg.StoreProfile(cp)
+		if err != nil {
+			logger.Errorf("error adding profile %s: %v", cp.Name, err)
+			return err
+		}
+		logger.Infof("adding profile %s", cp.Name)
+	}
+	return nil
+}
+
 // Export exporting the actual groups and clients to a playbook file
 func (p *Playbook) Export(pf string) error {
 	pb := model.Playbook{
-		Groups:  make([]model.Group, 0),
-		Clients: make([]model.Client, 0),
-		Keys:    make([]model.EncryptKey, 0),
+		Groups:   make([]model.Group, 0),
+		Clients:  make([]model.Client, 0),
+		Keys:     make([]model.EncryptKey, 0),
+		Profiles: make([]model.CertProfile, 0),
 	}
 	err := p.stg.ListClients(func(c model.Client) bool {
 		pb.Clients = append(pb.Clients, c)
@@ -217,6 +244,14 @@ func (p *Playbook) Export(pf string) error {
 		return err
 	}
 
+	err = p.stg.ListProfiles(func(cp model.CertProfile) bool {
+		pb.Profiles = append(pb.Profiles, cp)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
 	file, err := os.Create(pf)
 	if err != nil {
 		return err
@@ -91,6 +91,7 @@ func TestPlaybookModel(t *testing.T) {
 			}, {
 				Name:      "tester2",
 				AccessKey: "456",
+				KeyType:   cry.KeyTypeEd25519,
 			},
 		},
 	}
@@ -112,6 +113,15 @@ func TestPlaybookModel(t *testing.T) {
 	ast.True(stg.HasGroup("tester2"))
 
 	ast.False(stg.HasClient("tester3"))
+
+	for n, kt := range map[string]string{"123": cry.KeyTypeRSA4096, "456": cry.KeyTypeEd25519} {
+		c, ok := stg.GetClient(n)
+		ast.True(ok)
+		ast.Equal(kt, c.KeyType)
+		pkt, err := cry.KeyTypeOfPEM(c.Key)
+		ast.Nil(err)
+		ast.Equal(kt, pkt)
+	}
 }
 
 func TestPlaybookExport(t *testing.T) {
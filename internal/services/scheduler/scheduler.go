@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/willie68/micro-vault/internal/interfaces"
+	"github.com/willie68/micro-vault/internal/logging"
+	"github.com/willie68/micro-vault/internal/serror"
+	"github.com/willie68/micro-vault/internal/services/clients"
+	"github.com/willie68/micro-vault/internal/utils/str2duration"
+)
+
+var logger = logging.New().WithName("scheduler")
+
+// Scheduler runs the periodic maintenance jobs of the service, like the group key rotation or key destruction
+type Scheduler struct {
+	stg     interfaces.Storage
+	cls     clients.Clients
+	ticker  *time.Ticker
+	tckDone chan bool
+}
+
+// NewScheduler creates and starts a new scheduler
+func NewScheduler() (*Scheduler, error) {
+	s := Scheduler{
+		stg: do.MustInvoke[interfaces.Storage](nil),
+		cls: do.MustInvoke[clients.Clients](nil),
+	}
+	err := s.Init()
+	if err != nil {
+		return nil, err
+	}
+	do.ProvideValue[*Scheduler](nil, &s)
+	return &s, nil
+}
+
+// Init initialize the scheduler and starts the background job
+func (s *Scheduler) Init() error {
+	s.tckDone = make(chan bool)
+	s.ticker = time.NewTicker(1 * time.Minute)
+
+	go func() {
+		for {
+			select {
+			case <-s.tckDone:
+				return
+			case <-s.ticker.C:
+				s.Run()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the scheduler, waiting for a running job to finish
+func (s *Scheduler) Stop() {
+	s.ticker.Stop()
+	s.tckDone <- true
+}
+
+// Run executes all scheduled jobs once
+func (s *Scheduler) Run() {
+	now := time.Now()
+	s.rotateGroupKeys(now)
+	s.destroyKeys(now)
+	s.updateCRL(now)
+	s.updateCertMetrics(now)
+}
+
+// rotateGroupKeys rotates the keys of all groups, where the key rotation period is elapsed
+func (s *Scheduler) rotateGroupKeys(now time.Time) {
+	gs, err := s.stg.GetGroups()
+	if err != nil {
+		logger.Errorf("key rotation: error getting groups: %v", err)
+		return
+	}
+	for _, g := range gs {
+		if g.KeyRotation == "" {
+			continue
+		}
+		d, err := str2duration.ParseDuration(g.KeyRotation)
+		if err != nil || d <= 0 {
+			logger.Errorf("key rotation: group %s has no valid rotation period: %s", g.Name, g.KeyRotation)
+			continue
+		}
+		cur, err := s.cls.CurrentKey(g.Name)
+		if err != nil {
+			logger.Errorf("key rotation: error getting current key of group %s: %v", g.Name, err)
+			continue
+		}
+		if cur != nil && now.Before(cur.Created.Add(d)) {
+			continue
+		}
+		ek, err := s.cls.RotateKey(g.Name)
+		// the scheduler runs on every node, only one of them creates the new version
+		if errors.Is(err, serror.ErrAlreadyExists) {
+			logger.Infof("key rotation: group %s was rotated by another node", g.Name)
+			continue
+		}
+		if err != nil {
+			logger.Errorf("key rotation: error rotating key of group %s: %v", g.Name, err)
+			continue
+		}
+		logger.Infof("key rotation: group %s rotated to key version %d", g.Name, ek.Version)
+	}
+}
+
+// destroyKeys destroys all keys, where the destruction grace period is elapsed
+func (s *Scheduler) destroyKeys(now time.Time) {
+	ids, err := s.cls.DestroyKeys(now)
+	if err != nil {
+		logger.Errorf("key destruction: error destroying keys: %v", err)
+	}
+	for _, id := range ids {
+		logger.Infof("key destruction: key %s destroyed", id)
+	}
+}
+
+// updateCertMetrics updates the expiry metrics of the issued and the ca certificates
+func (s *Scheduler) updateCertMetrics(now time.Time) {
+	err := s.cls.UpdateCertMetrics(now)
+	if err != nil {
+		logger.Errorf("certificate metrics: error updating metrics: %v", err)
+	}
+}
+
+// updateCRL regenerates the crl of the ca, if the refresh period is elapsed
+func (s *Scheduler) updateCRL(now time.Time) {
+	err := s.cls.UpdateCRL(now)
+	if err != nil {
+		logger.Errorf("crl: error updating crl: %v", err)
+	}
+}
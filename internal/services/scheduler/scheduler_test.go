@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/config"
+	"github.com/willie68/micro-vault/internal/interfaces"
+	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/services/clients"
+	"github.com/willie68/micro-vault/internal/services/keyman"
+	"github.com/willie68/micro-vault/internal/services/storage"
+)
+
+var (
+	stg interfaces.Storage
+	scd *Scheduler
+)
+
+func init() {
+	var err error
+	stg, err = storage.NewMemory()
+	if err != nil {
+		panic(1)
+	}
+	c := config.Config{
+		Service: config.Service{
+			Rootuser:   "root",
+			Rootpwd:    "yxcvb",
+			PrivateKey: "../../../testdata/private.pem",
+			CACert: config.CACert{
+				Certificate: "../../../testdata/crt.pem",
+				Subject: map[string]string{
+					"Country":    "de",
+					"CommonName": "mcs",
+				},
+			},
+		},
+	}
+	c.Provide()
+	_, err = keyman.NewKeyman()
+	if err != nil {
+		panic(1)
+	}
+	_, err = keyman.NewCAService()
+	if err != nil {
+		panic(1)
+	}
+	_, err = clients.NewClients()
+	if err != nil {
+		panic(1)
+	}
+	scd, err = NewScheduler()
+	if err != nil {
+		panic(1)
+	}
+}
+
+func TestRotateGroupKeys(t *testing.T) {
+	ast := assert.New(t)
+	cls := scd.cls
+
+	_, err := stg.AddGroup(model.Group{Name: "rotgroup", KeyRotation: "1d"})
+	ast.Nil(err)
+	_, err = stg.AddGroup(model.Group{Name: "norotgroup"})
+	ast.Nil(err)
+
+	// first run creates the initial key version
+	now := time.Now()
+	scd.rotateGroupKeys(now)
+	cur, err := cls.CurrentKey("rotgroup")
+	ast.Nil(err)
+	ast.NotNil(cur)
+	ast.Equal(1, cur.Version)
+
+	cur, err = cls.CurrentKey("norotgroup")
+	ast.Nil(err)
+	ast.Nil(cur)
+
+	// period not elapsed, nothing to do
+	scd.rotateGroupKeys(now.Add(time.Hour))
+	cur, err = cls.CurrentKey("rotgroup")
+	ast.Nil(err)
+	ast.Equal(1, cur.Version)
+
+	// period elapsed
+	scd.rotateGroupKeys(now.Add(25 * time.Hour))
+	cur, err = cls.CurrentKey("rotgroup")
+	ast.Nil(err)
+	ast.Equal(2, cur.Version)
+}
+
+func TestDestroyKeys(t *testing.T) {
+	ast := assert.New(t)
+	cls := scd.cls
+
+	ek, err := cls.CreateKey("rotgroup", "")
+	ast.Nil(err)
+	ek, err = cls.SetKeyState(ek.ID, model.KeyStateScheduled)
+	ast.Nil(err)
+
+	scd.destroyKeys(time.Now())
+	e, ok := stg.GetEncryptKey(ek.ID)
+	ast.True(ok)
+	ast.Equal(model.KeyStateScheduled, e.State)
+	ast.NotEmpty(e.Key)
+
+	scd.destroyKeys(ek.DestroyAt.Add(time.Minute))
+	e, ok = stg.GetEncryptKey(ek.ID)
+	ast.True(ok)
+	ast.Equal(model.KeyStateDestroyed, e.State)
+	ast.Empty(e.Key)
+}
+
+func TestStop(t *testing.T) {
+	ast := assert.New(t)
+
+	s := Scheduler{
+		stg: stg,
+		cls: scd.cls,
+	}
+	ast.Nil(s.Init())
+
+	done := make(chan bool)
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		ast.Fail("scheduler not stopped")
+	}
+}
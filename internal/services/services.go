@@ -1,14 +1,18 @@
 package services
 
 import (
+	"github.com/samber/do"
 	"github.com/willie68/micro-vault/internal/config"
+	"github.com/willie68/micro-vault/internal/interfaces"
 	"github.com/willie68/micro-vault/internal/logging"
+	"github.com/willie68/micro-vault/internal/services/acme"
 	"github.com/willie68/micro-vault/internal/services/admin"
 	"github.com/willie68/micro-vault/internal/services/clients"
 	"github.com/willie68/micro-vault/internal/services/groups"
 	"github.com/willie68/micro-vault/internal/services/health"
 	"github.com/willie68/micro-vault/internal/services/keyman"
 	"github.com/willie68/micro-vault/internal/services/playbook"
+	"github.com/willie68/micro-vault/internal/services/scheduler"
 	"github.com/willie68/micro-vault/internal/services/shttp"
 	"github.com/willie68/micro-vault/internal/services/storage"
 )
@@ -44,6 +48,11 @@ func InitServices(cfg config.Config) error {
 		return err
 	}
 
+	_, err = acme.NewACME()
+	if err != nil {
+		return err
+	}
+
 	_, err = groups.NewGroups()
 	if err != nil {
 		return err
@@ -66,9 +75,29 @@ func InitServices(cfg config.Config) error {
 		}
 	}
 
+	_, err = scheduler.NewScheduler()
+	if err != nil {
+		return err
+	}
+
 	return InitRESTService(cfg)
 }
 
+// ShutdownServices stopping the scheduler before closing the storage, so no job runs against a closed storage
+func ShutdownServices() {
+	scd, err := do.Invoke[*scheduler.Scheduler](nil)
+	if err == nil {
+		scd.Stop()
+	}
+	stg, err := do.Invoke[interfaces.Storage](nil)
+	if err == nil {
+		err = stg.Close()
+		if err != nil {
+			logger.Errorf("error closing storage: %v", err)
+		}
+	}
+}
+
 // InitHelperServices initialise the helper services like Healthsystem
 func InitHelperServices(cfg config.Config) error {
 	var err error
@@ -1,6 +1,7 @@
 package shttp
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/ed25519"
@@ -237,6 +238,10 @@ func (gc *generateCertificate) GenerateTLSConfig() (*tls.Config, error) {
 
 	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})
 	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	// with an offline root the intermediate is part of the served chain
+	if !bytes.Equal(ca.X509Cert().Raw, ca.RootCert().Raw) {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.X509Cert().Raw})...)
+	}
 	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
 	if err != nil {
 		return nil, err
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
-	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -19,7 +19,6 @@ import (
 type FileStorage struct {
 	path    string
 	db      *badger.DB
-	revokes sync.Map
 	ticker  *time.Ticker
 	tckDone chan bool
 }
@@ -30,6 +29,18 @@ const (
 	nameKey       = "name"
 	encryptionKey = "encryption"
 	dataKey       = "data"
+	kvKey         = "kv"
+	certKey       = "cert"
+	acmeAccKey    = "acmeaccount"
+	acmeOrderKey  = "acmeorder"
+	acmeNonceKey  = "acmenonce"
+	acmeEABKey    = "acmeeab"
+	profileKey    = "profile"
+	revokeKey     = "tkrevoke"
+	beforeKey     = "tkbefore"
+	lastRevKey    = "lastrevocation"
+	gkIndexKey    = "gkindex"  // index of the versions of the group keys
+	schedKey      = "keysched" // index of the keys scheduled for destruction
 )
 
 var _ interfaces.Storage = &FileStorage{}
@@ -60,7 +71,10 @@ func (f *FileStorage) Init() error {
 		return err
 	}
 	f.db = b
-	f.revokes = sync.Map{}
+	err = f.indexEncryptKeys()
+	if err != nil {
+		return err
+	}
 	f.tckDone = make(chan bool)
 	f.ticker = time.NewTicker(1 * time.Minute)
 
@@ -87,31 +101,74 @@ func (f *FileStorage) Close() error {
 	return err
 }
 
+// cleanup removes all expired entries, the revoked tokens are expiring via the ttl of badger
 func (f *FileStorage) cleanup() {
-	f.revokes.Range(func(key, value any) bool {
-		exp := value.(time.Time)
-		if time.Now().After(exp) {
-			f.revokes.Delete(key)
+	f.cleanupData(time.Now())
+	f.cleanupACMEOrders(time.Now())
+}
+
+// cleanupData removes all expired data
+func (f *FileStorage) cleanupData(now time.Time) {
+	ids := make([]string, 0)
+	err := f.ListData(0, math.MaxInt64, func(d model.Data) bool {
+		if d.Expired(now) {
+			ids = append(ids, d.ID)
 		}
 		return true
 	})
+	if err != nil {
+		logger.Errorf("error listing data: %v", err)
+		return
+	}
+	for _, id := range ids {
+		_, err := f.DeleteData(id)
+		if err != nil {
+			logger.Errorf("error deleting expired data %s: %v", id, err)
+		}
+	}
 }
 
-// RevokeToken set this token id to the revoked token
+// RevokeToken set this token id to the revoked token, persisted until the token expires
 func (f *FileStorage) RevokeToken(id string, exp time.Time) error {
-	if time.Now().After(exp) {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
 		return nil
 	}
-	f.revokes.Store(id, exp)
-	return nil
+	return f.updateTTL(revokeKey, id, exp, ttl)
 }
 
 // IsRevoked checking if an token id is already revoked
 func (f *FileStorage) IsRevoked(id string) bool {
-	_, ok := f.revokes.Load(id)
+	var exp time.Time
+	ok, err := f.lookup(revokeKey, id, &exp)
+	if err != nil {
+		logger.Errorf("error checking revoked token: %v", err)
+	}
 	return ok
 }
 
+// RevokeTokensBefore revoking all tokens of the client issued until before, exp is the expiration of the last of these tokens
+func (f *FileStorage) RevokeTokensBefore(n string, before, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	return f.updateTTL(beforeKey, n, tkbefore{Name: n, Before: before, Expires: exp}, ttl)
+}
+
+// TokensRevokedBefore getting the time until all tokens of the client are revoked
+func (f *FileStorage) TokensRevokedBefore(n string) (time.Time, bool) {
+	var tb tkbefore
+	ok, err := f.lookup(beforeKey, n, &tb)
+	if err != nil {
+		logger.Errorf("error checking revoked tokens: %v", err)
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+	return tb.Before, true
+}
+
 // AddGroup adding a group to internal store
 func (f *FileStorage) AddGroup(group model.Group) (string, error) {
 	err := f.update(groupKey, group.Name, group)
@@ -319,13 +376,160 @@ func (f *FileStorage) StoreEncryptKey(encKey model.EncryptKey) error {
 	if encKey.ID == "" {
 		return serror.ErrMissingID
 	}
-	err := f.update(encryptionKey, encKey.ID, encKey)
+	return f.db.Update(func(txn *badger.Txn) error {
+		return storeKeyTxn(txn, encKey)
+	})
+}
+
+// AddGroupKey stores a new version of a group key, serror.ErrAlreadyExists if the group already has this version
+func (f *FileStorage) AddGroupKey(e model.EncryptKey) error {
+	if e.ID == "" || e.Group == "" || e.Version <= 0 {
+		return serror.ErrMissingID
+	}
+	err := f.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(gkIndex(e.Group, e.Version))
+		if err == nil {
+			return serror.ErrAlreadyExists
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		return storeKeyTxn(txn, e)
+	})
+	// a concurrent transaction has added this version
+	if errors.Is(err, badger.ErrConflict) {
+		return serror.ErrAlreadyExists
+	}
+	return err
+}
+
+// ListGroupKeys list all versions of the group key via callback function
+func (f *FileStorage) ListGroupKeys(group string, callback func(e model.EncryptKey) bool) error {
+	ids := make([]string, 0)
+	err := f.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := buildKey(gkIndexKey, group+"/")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			id, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, string(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return f.listKeys(ids, func(e model.EncryptKey) bool {
+		if e.Group != group {
+			return true
+		}
+		return callback(e)
+	})
+}
+
+// ListScheduledKeys list all keys scheduled for destruction until via callback function
+func (f *FileStorage) ListScheduledKeys(until time.Time, callback func(e model.EncryptKey) bool) error {
+	ids := make([]string, 0)
+	err := f.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := buildKey(schedKey, "")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var da time.Time
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &da)
+			})
+			if err != nil {
+				return err
+			}
+			if !until.Before(da) {
+				ids = append(ids, string(it.Item().Key()[len(prefix):]))
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	return f.listKeys(ids, callback)
+}
+
+func (f *FileStorage) listKeys(ids []string, callback func(e model.EncryptKey) bool) error {
+	for _, id := range ids {
+		var e model.EncryptKey
+		ok, err := f.lookup(encryptionKey, id, &e)
+		if err != nil {
+			return err
+		}
+		if ok && !callback(e) {
+			break
+		}
+	}
 	return nil
 }
 
+// indexEncryptKeys adding the index entries of the keys stored before the indexes exist
+func (f *FileStorage) indexEncryptKeys() error {
+	ks := make([]model.EncryptKey, 0)
+	err := f.ListEncryptKeys(0, math.MaxInt64, func(e model.EncryptKey) bool {
+		if e.Version > 0 || e.State == model.KeyStateScheduled {
+			ks = append(ks, e)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range ks {
+		err = f.db.Update(func(txn *badger.Txn) error {
+			return indexKeyTxn(txn, e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeKeyTxn stores the key with its index entries
+func storeKeyTxn(txn *badger.Txn, e model.EncryptKey) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	err = txn.Set(buildKey(encryptionKey, e.ID), v)
+	if err != nil {
+		return err
+	}
+	return indexKeyTxn(txn, e)
+}
+
+// indexKeyTxn sets the index entries of the key, the group key version and the scheduled destruction
+func indexKeyTxn(txn *badger.Txn, e model.EncryptKey) error {
+	if e.Version > 0 {
+		err := txn.Set(gkIndex(e.Group, e.Version), []byte(e.ID))
+		if err != nil {
+			return err
+		}
+	}
+	sk := buildKey(schedKey, e.ID)
+	if e.State != model.KeyStateScheduled {
+		return txn.Delete(sk)
+	}
+	da, err := json.Marshal(e.DestroyAt)
+	if err != nil {
+		return err
+	}
+	return txn.Set(sk, da)
+}
+
+func gkIndex(group string, version int) []byte {
+	return buildKey(gkIndexKey, fmt.Sprintf("%s/%d", group, version))
+}
+
 // GetEncryptKey stores the encrypt keys
 func (f *FileStorage) GetEncryptKey(id string) (*model.EncryptKey, bool) {
 	var e model.EncryptKey
@@ -376,7 +580,24 @@ func (f *FileStorage) ListEncryptKeys(start, length int64, callback func(c model
 
 // DeleteEncryptKey deletes the encrytion key
 func (f *FileStorage) DeleteEncryptKey(id string) (bool, error) {
-	err := f.delete(encryptionKey, id)
+	var e model.EncryptKey
+	ok, err := f.lookup(encryptionKey, id, &e)
+	if err != nil || !ok {
+		return false, err
+	}
+	err = f.db.Update(func(txn *badger.Txn) error {
+		if e.Version > 0 {
+			err := txn.Delete(gkIndex(e.Group, e.Version))
+			if err != nil {
+				return err
+			}
+		}
+		err := txn.Delete(buildKey(schedKey, id))
+		if err != nil {
+			return err
+		}
+		return txn.Delete(buildKey(encryptionKey, id))
+	})
 	if err != nil {
 		return false, err
 	}
@@ -450,6 +671,368 @@ func (f *FileStorage) ListData(start, length int64, callback func(c model.Data)
 	return nil
 }
 
+// StoreKV stores the secret with all versions, if the stored revision is still rev (0 for a new secret),
+// otherwise serror.ErrCASMismatch is returned
+func (f *FileStorage) StoreKV(kv model.KVSecret, rev int) error {
+	if kv.Group == "" || kv.Path == "" {
+		return serror.ErrMissingID
+	}
+	kv.Revision = rev + 1
+	v, err := json.Marshal(kv)
+	if err != nil {
+		return err
+	}
+	k := buildKey(kvKey, kv.ID())
+	err = f.db.Update(func(txn *badger.Txn) error {
+		var old model.KVSecret
+		item, err := txn.Get(k)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			err = item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &old)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if old.Revision != rev {
+			return serror.ErrCASMismatch
+		}
+		return txn.Set(k, v)
+	})
+	// a concurrent transaction has changed the secret
+	if errors.Is(err, badger.ErrConflict) {
+		return serror.ErrCASMismatch
+	}
+	return err
+}
+
+// GetKV retrieving the secret
+func (f *FileStorage) GetKV(group, path string) (*model.KVSecret, bool) {
+	var kv model.KVSecret
+	ok := f.get(kvKey, model.KVID(group, path), &kv)
+	if !ok {
+		return nil, false
+	}
+	return &kv, true
+}
+
+// DeleteKV removes the secret with all versions from storage
+func (f *FileStorage) DeleteKV(group, path string) (bool, error) {
+	id := model.KVID(group, path)
+	if !f.has(kvKey, id) {
+		return false, nil
+	}
+	err := f.delete(kvKey, id)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListKV list all secrets of the group via callback function
+func (f *FileStorage) ListKV(group string, callback func(kv model.KVSecret) bool) error {
+	return f.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := buildKey(kvKey, model.KVID(group, ""))
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var kv model.KVSecret
+			valCopy, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = json.Unmarshal(valCopy, &kv)
+			if err != nil {
+				return err
+			}
+			if kv.Group != group {
+				continue
+			}
+			if !callback(kv) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// StoreCertificate stores the issued certificate
+func (f *FileStorage) StoreCertificate(c model.Certificate) error {
+	if c.Serial == "" {
+		return serror.ErrMissingID
+	}
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return f.db.Update(func(txn *badger.Txn) error {
+		err := txn.Set(buildKey(certKey, c.Serial), v)
+		if err != nil || !c.IsRevoked() {
+			return err
+		}
+		lr, _, err := f.lastRevocation(txn)
+		if err != nil || !c.Revoked.After(lr) {
+			return err
+		}
+		r, err := json.Marshal(c.Revoked)
+		if err != nil {
+			return err
+		}
+		return txn.Set(buildKey(lastRevKey, ""), r)
+	})
+}
+
+// LastRevocation getting the time of the latest revocation of a stored certificate
+func (f *FileStorage) LastRevocation() (time.Time, bool) {
+	var lr time.Time
+	var ok bool
+	err := f.db.View(func(txn *badger.Txn) error {
+		var err error
+		lr, ok, err = f.lastRevocation(txn)
+		return err
+	})
+	if err != nil {
+		logger.Errorf("error getting last revocation: %v", err)
+		return time.Time{}, false
+	}
+	return lr, ok
+}
+
+func (f *FileStorage) lastRevocation(txn *badger.Txn) (time.Time, bool, error) {
+	var lr time.Time
+	item, err := txn.Get(buildKey(lastRevKey, ""))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return lr, false, nil
+	}
+	if err != nil {
+		return lr, false, err
+	}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &lr)
+	})
+	return lr, err == nil, err
+}
+
+// GetCertificate retrieving the certificate with the serial number
+func (f *FileStorage) GetCertificate(sn string) (*model.Certificate, bool) {
+	var c model.Certificate
+	ok := f.get(certKey, sn, &c)
+	if !ok {
+		return nil, false
+	}
+	return &c, true
+}
+
+// ListCertificates list all issued certificates via callback function
+func (f *FileStorage) ListCertificates(callback func(c model.Certificate) bool) error {
+	return f.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := buildKey(certKey, "")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var c model.Certificate
+			valCopy, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = json.Unmarshal(valCopy, &c)
+			if err != nil {
+				return err
+			}
+			if !callback(c) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// StoreACMEAccount stores the acme account
+func (f *FileStorage) StoreACMEAccount(a model.ACMEAccount) error {
+	if a.ID == "" {
+		return serror.ErrMissingID
+	}
+	return f.update(acmeAccKey, a.ID, a)
+}
+
+// GetACMEAccount retrieving the acme account with the id
+func (f *FileStorage) GetACMEAccount(id string) (*model.ACMEAccount, bool) {
+	var a model.ACMEAccount
+	ok := f.get(acmeAccKey, id, &a)
+	if !ok {
+		return nil, false
+	}
+	return &a, true
+}
+
+// StoreACMEOrder stores the acme order
+func (f *FileStorage) StoreACMEOrder(o model.ACMEOrder) error {
+	if o.ID == "" {
+		return serror.ErrMissingID
+	}
+	return f.update(acmeOrderKey, o.ID, o)
+}
+
+// GetACMEOrder retrieving the acme order with the id, expired orders are not returned
+func (f *FileStorage) GetACMEOrder(id string) (*model.ACMEOrder, bool) {
+	var o model.ACMEOrder
+	ok := f.get(acmeOrderKey, id, &o)
+	if !ok || o.Expired(time.Now()) {
+		return nil, false
+	}
+	return &o, true
+}
+
+// cleanupACMEOrders removes all expired acme orders
+func (f *FileStorage) cleanupACMEOrders(now time.Time) {
+	ids := make([]string, 0)
+	err := f.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := buildKey(acmeOrderKey, "")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var o model.ACMEOrder
+			valCopy, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = json.Unmarshal(valCopy, &o)
+			if err != nil {
+				return err
+			}
+			if o.Expired(now) {
+				ids = append(ids, o.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("error listing acme orders: %v", err)
+		return
+	}
+	for _, id := range ids {
+		err := f.delete(acmeOrderKey, id)
+		if err != nil {
+			logger.Errorf("error deleting expired acme order %s: %v", id, err)
+		}
+	}
+}
+
+// StoreACMENonce stores the replay nonce, badger removes the nonce after exp
+func (f *FileStorage) StoreACMENonce(n string, exp time.Time) error {
+	if n == "" {
+		return serror.ErrMissingID
+	}
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	return f.updateTTL(acmeNonceKey, n, exp, ttl)
+}
+
+// UseACMENonce consuming the nonce, false if the nonce is unknown, expired or already used
+func (f *FileStorage) UseACMENonce(n string) bool {
+	var exp time.Time
+	err := f.db.Update(func(txn *badger.Txn) error {
+		k := buildKey(acmeNonceKey, n)
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		err = item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &exp)
+		})
+		if err != nil {
+			return err
+		}
+		return txn.Delete(k)
+	})
+	// a concurrent use of the same nonce fails with a conflict
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) && !errors.Is(err, badger.ErrConflict) {
+			logger.Errorf("error using nonce: %v", err)
+		}
+		return false
+	}
+	return time.Now().Before(exp)
+}
+
+// StoreACMEEAB stores the external account binding credentials
+func (f *FileStorage) StoreACMEEAB(e model.ACMEEAB) error {
+	if e.KID == "" {
+		return serror.ErrMissingID
+	}
+	return f.update(acmeEABKey, e.KID, e)
+}
+
+// GetACMEEAB retrieving the external account binding credentials with the key id
+func (f *FileStorage) GetACMEEAB(kid string) (*model.ACMEEAB, bool) {
+	var e model.ACMEEAB
+	ok := f.get(acmeEABKey, kid, &e)
+	if !ok {
+		return nil, false
+	}
+	return &e, true
+}
+
+// StoreProfile stores the certificate profile
+func (f *FileStorage) StoreProfile(p model.CertProfile) error {
+	if p.Name == "" {
+		return serror.ErrMissingID
+	}
+	return f.update(profileKey, p.Name, p)
+}
+
+// GetProfile retrieving the certificate profile with the name
+func (f *FileStorage) GetProfile(n string) (*model.CertProfile, bool) {
+	var p model.CertProfile
+	ok := f.get(profileKey, n, &p)
+	if !ok {
+		return nil, false
+	}
+	return &p, true
+}
+
+// DeleteProfile removes the certificate profile from storage
+func (f *FileStorage) DeleteProfile(n string) (bool, error) {
+	if !f.has(profileKey, n) {
+		return false, nil
+	}
+	err := f.delete(profileKey, n)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListProfiles list all certificate profiles via callback function
+func (f *FileStorage) ListProfiles(callback func(p model.CertProfile) bool) error {
+	return f.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := buildKey(profileKey, "")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var p model.CertProfile
+			valCopy, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = json.Unmarshal(valCopy, &p)
+			if err != nil {
+				return err
+			}
+			if !callback(p) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
 func (f *FileStorage) update(tenant, key string, payload any) error {
 	v, err := json.Marshal(payload)
 	if err != nil {
@@ -461,6 +1044,37 @@ func (f *FileStorage) update(tenant, key string, payload any) error {
 	})
 }
 
+// updateTTL storing the payload, badger removes the entry after the ttl
+func (f *FileStorage) updateTTL(tenant, key string, payload any, ttl time.Duration) error {
+	v, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return f.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(buildKey(tenant, key), v).WithTTL(ttl))
+	})
+}
+
+// lookup getting the entry, a missing entry is no error
+func (f *FileStorage) lookup(tenant, key string, value any) (bool, error) {
+	err := f.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(buildKey(tenant, key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, value)
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (f *FileStorage) has(tenant, key string) (found bool) {
 	tkey := string(buildKey(tenant, key))
 	err := f.db.View(func(txn *badger.Txn) error {
@@ -472,8 +1086,9 @@ func (f *FileStorage) has(tenant, key string) (found bool) {
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
 			found = string(item.Key()) == tkey
+			// the iterator is closed by the defer, continuing would overwrite found
 			if found {
-				it.Close()
+				break
 			}
 		}
 		return nil
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/willie68/micro-vault/internal/interfaces"
 	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
 	"github.com/willie68/micro-vault/internal/utils"
 )
 
@@ -63,6 +64,49 @@ func TestRevokeTokenFS(t *testing.T) {
 	ast.False(stg.IsRevoked(id))
 }
 
+func TestRevokePersistentFS(t *testing.T) {
+	ast := assert.New(t)
+	testInit(ast)
+
+	id := utils.GenerateID()
+	err := stg.RevokeToken(id, time.Now().Add(1*time.Minute))
+	ast.Nil(err)
+	before := time.Now()
+	err = stg.RevokeTokensBefore("tester", before, time.Now().Add(1*time.Minute))
+	ast.Nil(err)
+	ast.Nil(stg.Close())
+
+	// revocations are surviving a restart
+	stg, err = NewFileStorage("../../../testdata/filestorage")
+	ast.Nil(err)
+	defer stg.Close()
+	ast.True(stg.IsRevoked(id))
+	b, ok := stg.TokensRevokedBefore("tester")
+	ast.True(ok)
+	ast.True(before.Equal(b))
+
+	_, ok = stg.TokensRevokedBefore("unknown")
+	ast.False(ok)
+}
+
+func TestRevokeTokensBeforeFS(t *testing.T) {
+	ast := assert.New(t)
+	testInit(ast)
+	defer stg.Close()
+
+	_, ok := stg.TokensRevokedBefore("tester")
+	ast.False(ok)
+
+	err := stg.RevokeTokensBefore("tester", time.Now(), time.Now().Add(1*time.Second))
+	ast.Nil(err)
+	_, ok = stg.TokensRevokedBefore("tester")
+	ast.True(ok)
+
+	time.Sleep(2 * time.Second)
+	_, ok = stg.TokensRevokedBefore("tester")
+	ast.False(ok)
+}
+
 func TestGroupCRUDFS(t *testing.T) {
 	ast := assert.New(t)
 	testInit(ast)
@@ -256,6 +300,93 @@ func TestStoreEncryptKeyFS(t *testing.T) {
 	ast.Nil(e1)
 }
 
+func TestGroupKeysFS(t *testing.T) {
+	ast := assert.New(t)
+	testInit(ast)
+
+	defer stg.Close()
+
+	now := time.Now()
+	e1 := model.EncryptKey{ID: "gk1", Alg: "AES-256-GCM", Key: "murks1", Created: now, Group: "gkgroup", Version: 1}
+	e2 := model.EncryptKey{ID: "gk2", Alg: "AES-256-GCM", Key: "murks2", Created: now, Group: "gkgroup", Version: 2}
+	ast.Nil(stg.AddGroupKey(e1))
+	ast.Nil(stg.AddGroupKey(e2))
+
+	// a concurrent rotation with the same version
+	err := stg.AddGroupKey(model.EncryptKey{ID: "gk3", Alg: "AES-256-GCM", Key: "murks3", Created: now, Group: "gkgroup", Version: 2})
+	ast.ErrorIs(err, serror.ErrAlreadyExists)
+	ast.False(stg.HasEncryptKey("gk3"))
+
+	ids := make([]string, 0)
+	err = stg.ListGroupKeys("gkgroup", func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.ElementsMatch([]string{"gk1", "gk2"}, ids)
+
+	e1.State = model.KeyStateScheduled
+	e1.DestroyAt = now.Add(time.Hour)
+	ast.Nil(stg.StoreEncryptKey(e1))
+
+	ids = make([]string, 0)
+	err = stg.ListScheduledKeys(now, func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+
+	err = stg.ListScheduledKeys(now.Add(2*time.Hour), func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal([]string{"gk1"}, ids)
+
+	ok, err := stg.DeleteEncryptKey(e1.ID)
+	ast.Nil(err)
+	ast.True(ok)
+	ok, err = stg.DeleteEncryptKey(e2.ID)
+	ast.Nil(err)
+	ast.True(ok)
+
+	ids = make([]string, 0)
+	err = stg.ListGroupKeys("gkgroup", func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+	err = stg.ListScheduledKeys(now.Add(2*time.Hour), func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+}
+
+func TestDeleteDataFS(t *testing.T) {
+	ast := assert.New(t)
+
+	testInit(ast)
+
+	defer stg.Close()
+
+	// the entry to delete isn't the last one of the tenant
+	for _, id := range []string{"del1", "del2", "del3"} {
+		err := stg.StoreData(model.Data{ID: id, Created: time.Now(), Group: "group1", Payload: id})
+		ast.Nil(err)
+	}
+	for _, id := range []string{"del1", "del2", "del3"} {
+		ok, err := stg.DeleteData(id)
+		ast.Nil(err)
+		ast.True(ok)
+		_, ok = stg.GetData(id)
+		ast.False(ok)
+	}
+}
+
 func TestStoreDataCRUDFS(t *testing.T) {
 	ast := assert.New(t)
 
@@ -299,6 +430,120 @@ func TestStoreDataCRUDFS(t *testing.T) {
 	ast.Nil(err)
 }
 
+func TestStoreDataCleanupFS(t *testing.T) {
+	ast := assert.New(t)
+
+	testInit(ast)
+
+	defer stg.Close()
+
+	dm := model.Data{
+		ID:      "12345678",
+		Created: time.Now(),
+		Expires: time.Now().Add(-1 * time.Minute),
+		Group:   "group1",
+		Payload: "dies ist eine Payload",
+	}
+	err := stg.StoreData(dm)
+	ast.Nil(err)
+
+	dm.ID = "87654321"
+	dm.Expires = time.Now().Add(1 * time.Hour)
+	err = stg.StoreData(dm)
+	ast.Nil(err)
+
+	dm.ID = "11223344"
+	dm.Expires = time.Time{}
+	err = stg.StoreData(dm)
+	ast.Nil(err)
+
+	fs, _ := stg.(*FileStorage)
+	fs.cleanupData(time.Now())
+
+	_, ok := stg.GetData("12345678")
+	ast.False(ok)
+	_, ok = stg.GetData("87654321")
+	ast.True(ok)
+	_, ok = stg.GetData("11223344")
+	ast.True(ok)
+}
+
+func TestKVCRUDFS(t *testing.T) {
+	ast := assert.New(t)
+
+	testInit(ast)
+
+	defer stg.Close()
+
+	kv := model.KVSecret{
+		Group:          "group1",
+		Path:           "db/password",
+		CurrentVersion: 1,
+		Created:        time.Now(),
+		CreatedBy:      "tester1",
+		Versions: []model.KVVersion{
+			{Version: 1, Value: "secret", Created: time.Now(), CreatedBy: "tester1"},
+		},
+	}
+
+	err := stg.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kv.Path = "db/user"
+	err = stg.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kv.Group = "group2"
+	err = stg.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	// secrets of another group with the same id prefix are not listed
+	kv.Group = "group1/db"
+	kv.Path = "other"
+	err = stg.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kvs := make([]model.KVSecret, 0)
+	err = stg.ListKV("group1", func(kv model.KVSecret) bool {
+		kvs = append(kvs, kv)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(kvs))
+
+	kv2, ok := stg.GetKV("group1", "db/password")
+	ast.True(ok)
+	ast.Equal("secret", kv2.Versions[0].Value)
+	ast.Equal(1, kv2.CurrentVersion)
+
+	// conditional update on the revision
+	ast.Equal(1, kv2.Revision)
+	err = stg.StoreKV(*kv2, 0)
+	ast.Equal(serror.ErrCASMismatch, err)
+	err = stg.StoreKV(*kv2, kv2.Revision)
+	ast.Nil(err)
+	err = stg.StoreKV(*kv2, kv2.Revision)
+	ast.Equal(serror.ErrCASMismatch, err)
+	kv2, ok = stg.GetKV("group1", "db/password")
+	ast.True(ok)
+	ast.Equal(2, kv2.Revision)
+
+	ok, err = stg.DeleteKV("group1", "db/password")
+	ast.True(ok)
+	ast.Nil(err)
+
+	kv2, ok = stg.GetKV("group1", "db/password")
+	ast.False(ok)
+	ast.Nil(kv2)
+
+	ok, err = stg.DeleteKV("group1", "db/password")
+	ast.False(ok)
+	ast.Nil(err)
+
+	err = stg.StoreKV(model.KVSecret{Group: "group1"}, 0)
+	ast.NotNil(err)
+}
+
 func TestStoreDataErrorsFS(t *testing.T) {
 	ast := assert.New(t)
 
@@ -334,3 +579,188 @@ func dmEqualFS(src, dst model.Data) bool {
 	}
 	return true
 }
+
+func TestCertificateCRUDFS(t *testing.T) {
+	ast := assert.New(t)
+
+	testInit(ast)
+
+	defer stg.Close()
+
+	c := model.Certificate{
+		Serial:    "1a2b3c",
+		Subject:   "CN=tester1",
+		Client:    "tester1",
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+
+	err := stg.StoreCertificate(c)
+	ast.Nil(err)
+
+	c.Serial = "4d5e6f"
+	c.Revoked = time.Now()
+	c.Reason = 1
+	err = stg.StoreCertificate(c)
+	ast.Nil(err)
+
+	lr, ok := stg.LastRevocation()
+	ast.True(ok)
+	ast.True(lr.Equal(c.Revoked))
+
+	c2, ok := stg.GetCertificate("1a2b3c")
+	ast.True(ok)
+	ast.Equal("tester1", c2.Client)
+	ast.False(c2.IsRevoked())
+
+	c2, ok = stg.GetCertificate("4d5e6f")
+	ast.True(ok)
+	ast.True(c2.IsRevoked())
+	ast.Equal(1, c2.Reason)
+
+	c2, ok = stg.GetCertificate("4711")
+	ast.False(ok)
+	ast.Nil(c2)
+
+	cs := make([]model.Certificate, 0)
+	err = stg.ListCertificates(func(c model.Certificate) bool {
+		cs = append(cs, c)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(cs))
+
+	err = stg.StoreCertificate(model.Certificate{})
+	ast.NotNil(err)
+}
+
+func TestACMECRUDFS(t *testing.T) {
+	ast := assert.New(t)
+
+	testInit(ast)
+
+	defer stg.Close()
+
+	e := model.ACMEEAB{
+		KID:     "kid1",
+		Key:     "c2VjcmV0",
+		Client:  "tester1",
+		Created: time.Now(),
+	}
+	err := stg.StoreACMEEAB(e)
+	ast.Nil(err)
+
+	e2, ok := stg.GetACMEEAB("kid1")
+	ast.True(ok)
+	ast.Equal("tester1", e2.Client)
+	ast.Equal("", e2.Account)
+
+	_, ok = stg.GetACMEEAB("kid2")
+	ast.False(ok)
+
+	a := model.ACMEAccount{
+		ID:      "thumb1",
+		Status:  model.ACMEStatusValid,
+		Client:  "tester1",
+		EAB:     "kid1",
+		Created: time.Now(),
+	}
+	err = stg.StoreACMEAccount(a)
+	ast.Nil(err)
+
+	a2, ok := stg.GetACMEAccount("thumb1")
+	ast.True(ok)
+	ast.Equal("kid1", a2.EAB)
+
+	o := model.ACMEOrder{
+		ID:      "order1",
+		Account: "thumb1",
+		Client:  "tester1",
+		Status:  model.ACMEStatusPending,
+		Expires: time.Now().Add(time.Hour),
+		Identifiers: []model.ACMEIdentifier{
+			{Type: "dns", Value: "wkmusicsearch.local"},
+		},
+	}
+	err = stg.StoreACMEOrder(o)
+	ast.Nil(err)
+
+	o.ID = "order2"
+	o.Expires = time.Now().Add(-time.Minute)
+	err = stg.StoreACMEOrder(o)
+	ast.Nil(err)
+
+	o2, ok := stg.GetACMEOrder("order1")
+	ast.True(ok)
+	ast.Equal(1, len(o2.Identifiers))
+	ast.Equal("wkmusicsearch.local", o2.Identifiers[0].Value)
+
+	_, ok = stg.GetACMEOrder("order2")
+	ast.False(ok)
+
+	err = stg.StoreACMENonce("nonce1", time.Now().Add(time.Hour))
+	ast.Nil(err)
+	err = stg.StoreACMENonce("nonce2", time.Now().Add(-time.Minute))
+	ast.Nil(err)
+	ast.True(stg.UseACMENonce("nonce1"))
+	// every nonce can only be used once
+	ast.False(stg.UseACMENonce("nonce1"))
+	ast.False(stg.UseACMENonce("nonce2"))
+	ast.False(stg.UseACMENonce("nonce3"))
+
+	err = stg.StoreACMEOrder(model.ACMEOrder{})
+	ast.NotNil(err)
+	err = stg.StoreACMEAccount(model.ACMEAccount{})
+	ast.NotNil(err)
+	err = stg.StoreACMEEAB(model.ACMEEAB{})
+	ast.NotNil(err)
+}
+
+func TestProfileCRUDFS(t *testing.T) {
+	ast := assert.New(t)
+
+	testInit(ast)
+
+	defer stg.Close()
+
+	p := model.CertProfile{
+		Name:        "server",
+		KeyUsage:    []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage: []string{"serverAuth"},
+		DNS:         []string{".example.com"},
+	}
+	err := stg.StoreProfile(p)
+	ast.Nil(err)
+	p.Name = "client"
+	p.ExtKeyUsage = []string{"clientAuth"}
+	err = stg.StoreProfile(p)
+	ast.Nil(err)
+
+	p2, ok := stg.GetProfile("server")
+	ast.True(ok)
+	ast.Equal([]string{"serverAuth"}, p2.ExtKeyUsage)
+	ast.Equal([]string{".example.com"}, p2.DNS)
+
+	_, ok = stg.GetProfile("codesign")
+	ast.False(ok)
+
+	ps := make([]model.CertProfile, 0)
+	err = stg.ListProfiles(func(p model.CertProfile) bool {
+		ps = append(ps, p)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(ps))
+
+	ok, err = stg.DeleteProfile("client")
+	ast.Nil(err)
+	ast.True(ok)
+	ok, err = stg.DeleteProfile("client")
+	ast.Nil(err)
+	ast.False(ok)
+	_, ok = stg.GetProfile("client")
+	ast.False(ok)
+
+	err = stg.StoreProfile(model.CertProfile{})
+	ast.NotNil(err)
+}
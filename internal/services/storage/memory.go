@@ -16,8 +16,22 @@ type Memory struct {
 	groups  map[string]model.Group
 	clients sync.Map
 	keys    sync.Map
+	keyLock sync.Mutex
+	gkeys   map[string]map[int]string // the ids of the versions of the group keys
+	scheds  sync.Map                  // the keys scheduled for destruction with the time of destruction
 	revokes sync.Map
+	befores sync.Map
 	datas   sync.Map
+	kvs     sync.Map
+	kvLock  sync.Mutex
+	certs   sync.Map
+	revLock sync.Mutex
+	lastRev time.Time // time of the latest revocation of a certificate
+	accs    sync.Map
+	orders  sync.Map
+	nonces  sync.Map
+	eabs    sync.Map
+	prfs    sync.Map
 	ticker  *time.Ticker
 	tckDone chan bool
 }
@@ -40,8 +54,18 @@ func (m *Memory) Init() error {
 	m.groups = make(map[string]model.Group)
 	m.clients = sync.Map{}
 	m.keys = sync.Map{}
+	m.gkeys = make(map[string]map[int]string)
+	m.scheds = sync.Map{}
 	m.revokes = sync.Map{}
+	m.befores = sync.Map{}
 	m.datas = sync.Map{}
+	m.kvs = sync.Map{}
+	m.certs = sync.Map{}
+	m.accs = sync.Map{}
+	m.orders = sync.Map{}
+	m.nonces = sync.Map{}
+	m.eabs = sync.Map{}
+	m.prfs = sync.Map{}
 	m.tckDone = make(chan bool)
 	m.ticker = time.NewTicker(1 * time.Minute)
 
@@ -64,7 +88,10 @@ func (m *Memory) Close() error {
 	m.groups = make(map[string]model.Group)
 	m.clients = sync.Map{}
 	m.keys = sync.Map{}
+	m.gkeys = make(map[string]map[int]string)
+	m.scheds = sync.Map{}
 	m.revokes = sync.Map{}
+	m.befores = sync.Map{}
 	err := do.Shutdown[interfaces.Storage](nil)
 	m.ticker.Stop()
 	m.tckDone <- true
@@ -79,6 +106,33 @@ func (m *Memory) cleanup() {
 		}
 		return true
 	})
+	m.befores.Range(func(key, value any) bool {
+		tb := value.(tkbefore)
+		if time.Now().After(tb.Expires) {
+			m.befores.Delete(key)
+		}
+		return true
+	})
+	m.datas.Range(func(key, value any) bool {
+		d := value.(model.Data)
+		if d.Expired(time.Now()) {
+			m.datas.Delete(key)
+		}
+		return true
+	})
+	m.orders.Range(func(key, value any) bool {
+		o := value.(model.ACMEOrder)
+		if o.Expired(time.Now()) {
+			m.orders.Delete(key)
+		}
+		return true
+	})
+	m.nonces.Range(func(key, value any) bool {
+		if time.Now().After(value.(time.Time)) {
+			m.nonces.Delete(key)
+		}
+		return true
+	})
 }
 
 // RevokeToken set this token id to the revoked token
@@ -96,6 +150,24 @@ func (m *Memory) IsRevoked(id string) bool {
 	return ok
 }
 
+// RevokeTokensBefore revoking all tokens of the client issued until before, exp is the expiration of the last of these tokens
+func (m *Memory) RevokeTokensBefore(n string, before, exp time.Time) error {
+	if time.Now().After(exp) {
+		return nil
+	}
+	m.befores.Store(n, tkbefore{Name: n, Before: before, Expires: exp})
+	return nil
+}
+
+// TokensRevokedBefore getting the time until all tokens of the client are revoked
+func (m *Memory) TokensRevokedBefore(n string) (time.Time, bool) {
+	v, ok := m.befores.Load(n)
+	if !ok {
+		return time.Time{}, false
+	}
+	return v.(tkbefore).Before, true
+}
+
 // AddGroup adding a group to internal store
 func (m *Memory) AddGroup(g model.Group) (string, error) {
 	m.groups[g.Name] = g
@@ -240,10 +312,77 @@ func (m *Memory) StoreEncryptKey(e model.EncryptKey) error {
 	if e.ID == "" {
 		return serror.ErrMissingID
 	}
-	m.keys.Store(e.ID, e)
+	m.keyLock.Lock()
+	defer m.keyLock.Unlock()
+	m.storeKey(e)
+	return nil
+}
+
+// AddGroupKey stores a new version of a group key, serror.ErrAlreadyExists if the group already has this version
+func (m *Memory) AddGroupKey(e model.EncryptKey) error {
+	if e.ID == "" || e.Group == "" || e.Version <= 0 {
+		return serror.ErrMissingID
+	}
+	m.keyLock.Lock()
+	defer m.keyLock.Unlock()
+	if _, ok := m.gkeys[e.Group][e.Version]; ok {
+		return serror.ErrAlreadyExists
+	}
+	m.storeKey(e)
 	return nil
 }
 
+// ListGroupKeys list all versions of the group key via callback function
+func (m *Memory) ListGroupKeys(group string, c func(e model.EncryptKey) bool) error {
+	m.keyLock.Lock()
+	ids := make([]string, 0, len(m.gkeys[group]))
+	for _, id := range m.gkeys[group] {
+		ids = append(ids, id)
+	}
+	m.keyLock.Unlock()
+	for _, id := range ids {
+		e, ok := m.GetEncryptKey(id)
+		if !ok {
+			continue
+		}
+		if !c(*e) {
+			break
+		}
+	}
+	return nil
+}
+
+// ListScheduledKeys list all keys scheduled for destruction until via callback function
+func (m *Memory) ListScheduledKeys(until time.Time, c func(e model.EncryptKey) bool) error {
+	m.scheds.Range(func(key, value any) bool {
+		if until.Before(value.(time.Time)) {
+			return true
+		}
+		e, ok := m.GetEncryptKey(key.(string))
+		if !ok {
+			return true
+		}
+		return c(*e)
+	})
+	return nil
+}
+
+// storeKey stores the key and its index entries, the caller must hold the key lock
+func (m *Memory) storeKey(e model.EncryptKey) {
+	m.keys.Store(e.ID, e)
+	if e.Version > 0 {
+		if _, ok := m.gkeys[e.Group]; !ok {
+			m.gkeys[e.Group] = make(map[int]string)
+		}
+		m.gkeys[e.Group][e.Version] = e.ID
+	}
+	if e.State == model.KeyStateScheduled {
+		m.scheds.Store(e.ID, e.DestroyAt)
+	} else {
+		m.scheds.Delete(e.ID)
+	}
+}
+
 // GetEncryptKey stores the encrypt keys
 func (m *Memory) GetEncryptKey(id string) (*model.EncryptKey, bool) {
 	k, ok := m.keys.Load(id)
@@ -277,8 +416,18 @@ func (m *Memory) ListEncryptKeys(s, l int64, c func(c model.EncryptKey) bool) er
 
 // DeleteEncryptKey deletes the encrytion key
 func (m *Memory) DeleteEncryptKey(id string) (bool, error) {
-	_, ok := m.keys.LoadAndDelete(id)
-	return ok, nil
+	m.keyLock.Lock()
+	defer m.keyLock.Unlock()
+	k, ok := m.keys.LoadAndDelete(id)
+	if !ok {
+		return false, nil
+	}
+	e := k.(model.EncryptKey)
+	if e.Version > 0 && m.gkeys[e.Group][e.Version] == id {
+		delete(m.gkeys[e.Group], e.Version)
+	}
+	m.scheds.Delete(id)
+	return true, nil
 }
 
 // StoreData stores the data
@@ -320,3 +469,206 @@ func (m *Memory) ListData(s, l int64, c func(c model.Data) bool) error {
 	})
 	return nil
 }
+
+// StoreKV stores the secret with all versions, if the stored revision is still rev (0 for a new secret),
+// otherwise serror.ErrCASMismatch is returned
+func (m *Memory) StoreKV(kv model.KVSecret, rev int) error {
+	if kv.Group == "" || kv.Path == "" {
+		return serror.ErrMissingID
+	}
+	m.kvLock.Lock()
+	defer m.kvLock.Unlock()
+	if kvRevision(m.kvs.Load(kv.ID())) != rev {
+		return serror.ErrCASMismatch
+	}
+	kv.Revision = rev + 1
+	m.kvs.Store(kv.ID(), kv)
+	return nil
+}
+
+func kvRevision(v any, ok bool) int {
+	if !ok {
+		return 0
+	}
+	return v.(model.KVSecret).Revision
+}
+
+// GetKV retrieving the secret
+func (m *Memory) GetKV(group, path string) (*model.KVSecret, bool) {
+	k, ok := m.kvs.Load(model.KVID(group, path))
+	if !ok {
+		return nil, false
+	}
+	kv := k.(model.KVSecret)
+	// the versions are changed by the caller, so the stored secret gets its own copy
+	kv.Versions = append([]model.KVVersion(nil), kv.Versions...)
+	return &kv, true
+}
+
+// DeleteKV removes the secret with all versions from storage
+func (m *Memory) DeleteKV(group, path string) (bool, error) {
+	_, ok := m.kvs.LoadAndDelete(model.KVID(group, path))
+	return ok, nil
+}
+
+// ListKV list all secrets of the group via callback function
+func (m *Memory) ListKV(group string, c func(kv model.KVSecret) bool) error {
+	m.kvs.Range(func(key, value any) bool {
+		kv := value.(model.KVSecret)
+		if kv.Group != group {
+			return true
+		}
+		return c(kv)
+	})
+	return nil
+}
+
+// StoreCertificate stores the issued certificate
+func (m *Memory) StoreCertificate(c model.Certificate) error {
+	if c.Serial == "" {
+		return serror.ErrMissingID
+	}
+	m.certs.Store(c.Serial, c)
+	if c.IsRevoked() {
+		m.revLock.Lock()
+		if c.Revoked.After(m.lastRev) {
+			m.lastRev = c.Revoked
+		}
+		m.revLock.Unlock()
+	}
+	return nil
+}
+
+// LastRevocation getting the time of the latest revocation of a stored certificate
+func (m *Memory) LastRevocation() (time.Time, bool) {
+	m.revLock.Lock()
+	defer m.revLock.Unlock()
+	return m.lastRev, !m.lastRev.IsZero()
+}
+
+// GetCertificate retrieving the certificate with the serial number
+func (m *Memory) GetCertificate(sn string) (*model.Certificate, bool) {
+	c, ok := m.certs.Load(sn)
+	if !ok {
+		return nil, false
+	}
+	cr := c.(model.Certificate)
+	return &cr, true
+}
+
+// ListCertificates list all issued certificates via callback function
+func (m *Memory) ListCertificates(c func(c model.Certificate) bool) error {
+	m.certs.Range(func(key, value any) bool {
+		return c(value.(model.Certificate))
+	})
+	return nil
+}
+
+// StoreACMEAccount stores the acme account
+func (m *Memory) StoreACMEAccount(a model.ACMEAccount) error {
+	if a.ID == "" {
+		return serror.ErrMissingID
+	}
+	m.accs.Store(a.ID, a)
+	return nil
+}
+
+// GetACMEAccount retrieving the acme account with the id
+func (m *Memory) GetACMEAccount(id string) (*model.ACMEAccount, bool) {
+	a, ok := m.accs.Load(id)
+	if !ok {
+		return nil, false
+	}
+	ac := a.(model.ACMEAccount)
+	return &ac, true
+}
+
+// StoreACMEOrder stores the acme order
+func (m *Memory) StoreACMEOrder(o model.ACMEOrder) error {
+	if o.ID == "" {
+		return serror.ErrMissingID
+	}
+	m.orders.Store(o.ID, o)
+	return nil
+}
+
+// GetACMEOrder retrieving the acme order with the id, expired orders are not returned
+func (m *Memory) GetACMEOrder(id string) (*model.ACMEOrder, bool) {
+	o, ok := m.orders.Load(id)
+	if !ok {
+		return nil, false
+	}
+	or := o.(model.ACMEOrder)
+	if or.Expired(time.Now()) {
+		m.orders.Delete(id)
+		return nil, false
+	}
+	return &or, true
+}
+
+// StoreACMENonce stores the replay nonce until exp
+func (m *Memory) StoreACMENonce(n string, exp time.Time) error {
+	if n == "" {
+		return serror.ErrMissingID
+	}
+	m.nonces.Store(n, exp)
+	return nil
+}
+
+// UseACMENonce consuming the nonce, false if the nonce is unknown, expired or already used
+func (m *Memory) UseACMENonce(n string) bool {
+	exp, ok := m.nonces.LoadAndDelete(n)
+	return ok && time.Now().Before(exp.(time.Time))
+}
+
+// StoreACMEEAB stores the external account binding credentials
+func (m *Memory) StoreACMEEAB(e model.ACMEEAB) error {
+	if e.KID == "" {
+		return serror.ErrMissingID
+	}
+	m.eabs.Store(e.KID, e)
+	return nil
+}
+
+// GetACMEEAB retrieving the external account binding credentials with the key id
+func (m *Memory) GetACMEEAB(kid string) (*model.ACMEEAB, bool) {
+	e, ok := m.eabs.Load(kid)
+	if !ok {
+		return nil, false
+	}
+	eb := e.(model.ACMEEAB)
+	return &eb, true
+}
+
+// StoreProfile stores the certificate profile
+func (m *Memory) StoreProfile(p model.CertProfile) error {
+	if p.Name == "" {
+		return serror.ErrMissingID
+	}
+	m.prfs.Store(p.Name, p)
+	return nil
+}
+
+// GetProfile retrieving the certificate profile with the name
+func (m *Memory) GetProfile(n string) (*model.CertProfile, bool) {
+	p, ok := m.prfs.Load(n)
+	if !ok {
+		return nil, false
+	}
+	pr := p.(model.CertProfile)
+	return &pr, true
+}
+
+// DeleteProfile removes the certificate profile from storage
+func (m *Memory) DeleteProfile(n string) (bool, error) {
+	_, ok := m.prfs.LoadAndDelete(n)
+	return ok, nil
+}
+
+// ListProfiles list all certificate profiles via callback function
+func (m *Memory) ListProfiles(c func(p model.CertProfile) bool) error {
+	m.prfs.Range(func(key, value any) bool {
+		return c(value.(model.CertProfile))
+	})
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
 	"github.com/willie68/micro-vault/internal/utils"
 )
 
@@ -44,6 +45,31 @@ func TestRevokeToken(t *testing.T) {
 	ast.False(mem.IsRevoked(id))
 }
 
+func TestRevokeTokensBefore(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	err := mem.Init()
+	ast.Nil(err)
+
+	_, ok := mem.TokensRevokedBefore("tester")
+	ast.False(ok)
+
+	before := time.Now()
+	err = mem.RevokeTokensBefore("tester", before, time.Now().Add(1*time.Second))
+	ast.Nil(err)
+
+	b, ok := mem.TokensRevokedBefore("tester")
+	ast.True(ok)
+	ast.True(before.Equal(b))
+
+	time.Sleep(2 * time.Second)
+	mem.cleanup()
+
+	_, ok = mem.TokensRevokedBefore("tester")
+	ast.False(ok)
+}
+
 func TestGroupCRUD(t *testing.T) {
 	ast := assert.New(t)
 
@@ -265,6 +291,72 @@ func TestStoreEncryptKey(t *testing.T) {
 	ast.Nil(e1)
 }
 
+func TestGroupKeys(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	ast.Nil(mem.Init())
+
+	now := time.Now()
+	e1 := model.EncryptKey{ID: "gk1", Alg: "AES-256-GCM", Key: "murks1", Created: now, Group: "gkgroup", Version: 1}
+	e2 := model.EncryptKey{ID: "gk2", Alg: "AES-256-GCM", Key: "murks2", Created: now, Group: "gkgroup", Version: 2}
+	ast.Nil(mem.AddGroupKey(e1))
+	ast.Nil(mem.AddGroupKey(e2))
+
+	// a concurrent rotation with the same version
+	err := mem.AddGroupKey(model.EncryptKey{ID: "gk3", Alg: "AES-256-GCM", Key: "murks3", Created: now, Group: "gkgroup", Version: 2})
+	ast.ErrorIs(err, serror.ErrAlreadyExists)
+	ast.False(mem.HasEncryptKey("gk3"))
+
+	ids := make([]string, 0)
+	err = mem.ListGroupKeys("gkgroup", func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.ElementsMatch([]string{"gk1", "gk2"}, ids)
+
+	e1.State = model.KeyStateScheduled
+	e1.DestroyAt = now.Add(time.Hour)
+	ast.Nil(mem.StoreEncryptKey(e1))
+
+	ids = make([]string, 0)
+	err = mem.ListScheduledKeys(now, func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+
+	err = mem.ListScheduledKeys(now.Add(2*time.Hour), func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal([]string{"gk1"}, ids)
+
+	ok, err := mem.DeleteEncryptKey(e1.ID)
+	ast.Nil(err)
+	ast.True(ok)
+	ok, err = mem.DeleteEncryptKey(e2.ID)
+	ast.Nil(err)
+	ast.True(ok)
+
+	ids = make([]string, 0)
+	err = mem.ListGroupKeys("gkgroup", func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+	err = mem.ListScheduledKeys(now.Add(2*time.Hour), func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+}
+
 func TestStoreDataCRUD(t *testing.T) {
 	ast := assert.New(t)
 
@@ -308,6 +400,43 @@ func TestStoreDataCRUD(t *testing.T) {
 	ast.Nil(err)
 }
 
+func TestStoreDataCleanup(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	err := mem.Init()
+	ast.Nil(err)
+
+	dm := model.Data{
+		ID:      "12345678",
+		Created: time.Now(),
+		Expires: time.Now().Add(-1 * time.Minute),
+		Group:   "group1",
+		Payload: "dies ist eine Payload",
+	}
+	err = mem.StoreData(dm)
+	ast.Nil(err)
+
+	dm.ID = "87654321"
+	dm.Expires = time.Now().Add(1 * time.Hour)
+	err = mem.StoreData(dm)
+	ast.Nil(err)
+
+	dm.ID = "11223344"
+	dm.Expires = time.Time{}
+	err = mem.StoreData(dm)
+	ast.Nil(err)
+
+	mem.cleanup()
+
+	_, ok := mem.GetData("12345678")
+	ast.False(ok)
+	_, ok = mem.GetData("87654321")
+	ast.True(ok)
+	_, ok = mem.GetData("11223344")
+	ast.True(ok)
+}
+
 func TestStoreDataErrors(t *testing.T) {
 	ast := assert.New(t)
 
@@ -325,6 +454,82 @@ func TestStoreDataErrors(t *testing.T) {
 	ast.NotNil(err)
 }
 
+func TestKVCRUD(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	err := mem.Init()
+	ast.Nil(err)
+
+	kv := model.KVSecret{
+		Group:          "group1",
+		Path:           "db/password",
+		CurrentVersion: 1,
+		Created:        time.Now(),
+		CreatedBy:      "tester1",
+		Versions: []model.KVVersion{
+			{Version: 1, Value: "secret", Created: time.Now(), CreatedBy: "tester1"},
+		},
+	}
+
+	err = mem.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kv.Path = "db/user"
+	err = mem.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kv.Group = "group2"
+	err = mem.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	// secrets of another group with the same id prefix are not listed
+	kv.Group = "group1/db"
+	kv.Path = "other"
+	err = mem.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kvs := make([]model.KVSecret, 0)
+	err = mem.ListKV("group1", func(kv model.KVSecret) bool {
+		kvs = append(kvs, kv)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(kvs))
+
+	kv2, ok := mem.GetKV("group1", "db/password")
+	ast.True(ok)
+	ast.Equal("secret", kv2.Versions[0].Value)
+	ast.Equal(1, kv2.CurrentVersion)
+
+	// conditional update on the revision
+	ast.Equal(1, kv2.Revision)
+	err = mem.StoreKV(*kv2, 0)
+	ast.Equal(serror.ErrCASMismatch, err)
+	err = mem.StoreKV(*kv2, kv2.Revision)
+	ast.Nil(err)
+	err = mem.StoreKV(*kv2, kv2.Revision)
+	ast.Equal(serror.ErrCASMismatch, err)
+	kv2, ok = mem.GetKV("group1", "db/password")
+	ast.True(ok)
+	ast.Equal(2, kv2.Revision)
+
+	ok, err = mem.DeleteKV("group1", "db/password")
+	ast.True(ok)
+	ast.Nil(err)
+
+	kv2, ok = mem.GetKV("group1", "db/password")
+	ast.False(ok)
+	ast.Nil(kv2)
+
+	ok, err = mem.DeleteKV("group1", "db/password")
+	ast.False(ok)
+	ast.Nil(err)
+
+	err = mem.StoreKV(model.KVSecret{Group: "group1"}, 0)
+	ast.NotNil(err)
+}
+
 func dmEqual(src, dst model.Data) bool {
 	if src.ID != dst.ID {
 		return false
@@ -343,3 +548,188 @@ func dmEqual(src, dst model.Data) bool {
 	}
 	return true
 }
+
+func TestCertificateCRUD(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	err := mem.Init()
+	ast.Nil(err)
+
+	c := model.Certificate{
+		Serial:    "1a2b3c",
+		Subject:   "CN=tester1",
+		Client:    "tester1",
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+
+	err = mem.StoreCertificate(c)
+	ast.Nil(err)
+
+	c.Serial = "4d5e6f"
+	c.Revoked = time.Now()
+	c.Reason = 1
+	err = mem.StoreCertificate(c)
+	ast.Nil(err)
+
+	lr, ok := mem.LastRevocation()
+	ast.True(ok)
+	ast.True(lr.Equal(c.Revoked))
+
+	c2, ok := mem.GetCertificate("1a2b3c")
+	ast.True(ok)
+	ast.Equal("tester1", c2.Client)
+	ast.False(c2.IsRevoked())
+
+	c2, ok = mem.GetCertificate("4d5e6f")
+	ast.True(ok)
+	ast.True(c2.IsRevoked())
+	ast.Equal(1, c2.Reason)
+
+	c2, ok = mem.GetCertificate("4711")
+	ast.False(ok)
+	ast.Nil(c2)
+
+	cs := make([]model.Certificate, 0)
+	err = mem.ListCertificates(func(c model.Certificate) bool {
+		cs = append(cs, c)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(cs))
+
+	err = mem.StoreCertificate(model.Certificate{})
+	ast.NotNil(err)
+}
+
+func TestACMECRUD(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	err := mem.Init()
+	ast.Nil(err)
+
+	e := model.ACMEEAB{
+		KID:     "kid1",
+		Key:     "c2VjcmV0",
+		Client:  "tester1",
+		Created: time.Now(),
+	}
+	err = mem.StoreACMEEAB(e)
+	ast.Nil(err)
+
+	e2, ok := mem.GetACMEEAB("kid1")
+	ast.True(ok)
+	ast.Equal("tester1", e2.Client)
+	ast.Equal("", e2.Account)
+
+	_, ok = mem.GetACMEEAB("kid2")
+	ast.False(ok)
+
+	a := model.ACMEAccount{
+		ID:      "thumb1",
+		Status:  model.ACMEStatusValid,
+		Client:  "tester1",
+		EAB:     "kid1",
+		Created: time.Now(),
+	}
+	err = mem.StoreACMEAccount(a)
+	ast.Nil(err)
+
+	a2, ok := mem.GetACMEAccount("thumb1")
+	ast.True(ok)
+	ast.Equal("kid1", a2.EAB)
+
+	o := model.ACMEOrder{
+		ID:      "order1",
+		Account: "thumb1",
+		Client:  "tester1",
+		Status:  model.ACMEStatusPending,
+		Expires: time.Now().Add(time.Hour),
+		Identifiers: []model.ACMEIdentifier{
+			{Type: "dns", Value: "wkmusicsearch.local"},
+		},
+	}
+	err = mem.StoreACMEOrder(o)
+	ast.Nil(err)
+
+	o.ID = "order2"
+	o.Expires = time.Now().Add(-time.Minute)
+	err = mem.StoreACMEOrder(o)
+	ast.Nil(err)
+
+	o2, ok := mem.GetACMEOrder("order1")
+	ast.True(ok)
+	ast.Equal(1, len(o2.Identifiers))
+	ast.Equal("wkmusicsearch.local", o2.Identifiers[0].Value)
+
+	_, ok = mem.GetACMEOrder("order2")
+	ast.False(ok)
+
+	err = mem.StoreACMENonce("nonce1", time.Now().Add(time.Hour))
+	ast.Nil(err)
+	err = mem.StoreACMENonce("nonce2", time.Now().Add(-time.Minute))
+	ast.Nil(err)
+	ast.True(mem.UseACMENonce("nonce1"))
+	// every nonce can only be used once
+	ast.False(mem.UseACMENonce("nonce1"))
+	ast.False(mem.UseACMENonce("nonce2"))
+	ast.False(mem.UseACMENonce("nonce3"))
+
+	err = mem.StoreACMEOrder(model.ACMEOrder{})
+	ast.NotNil(err)
+	err = mem.StoreACMEAccount(model.ACMEAccount{})
+	ast.NotNil(err)
+	err = mem.StoreACMEEAB(model.ACMEEAB{})
+	ast.NotNil(err)
+}
+
+func TestProfileCRUD(t *testing.T) {
+	ast := assert.New(t)
+
+	mem := &Memory{}
+	err := mem.Init()
+	ast.Nil(err)
+
+	p := model.CertProfile{
+		Name:        "server",
+		KeyUsage:    []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage: []string{"serverAuth"},
+		DNS:         []string{".example.com"},
+	}
+	err = mem.StoreProfile(p)
+	ast.Nil(err)
+	p.Name = "client"
+	p.ExtKeyUsage = []string{"clientAuth"}
+	err = mem.StoreProfile(p)
+	ast.Nil(err)
+
+	p2, ok := mem.GetProfile("server")
+	ast.True(ok)
+	ast.Equal([]string{"serverAuth"}, p2.ExtKeyUsage)
+	ast.Equal([]string{".example.com"}, p2.DNS)
+
+	_, ok = mem.GetProfile("codesign")
+	ast.False(ok)
+
+	ps := make([]model.CertProfile, 0)
+	err = mem.ListProfiles(func(p model.CertProfile) bool {
+		ps = append(ps, p)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(ps))
+
+	ok, err = mem.DeleteProfile("client")
+	ast.Nil(err)
+	ast.True(ok)
+	ok, err = mem.DeleteProfile("client")
+	ast.Nil(err)
+	ast.False(ok)
+	_, ok = mem.GetProfile("client")
+	ast.False(ok)
+
+	err = mem.StoreProfile(model.CertProfile{})
+	ast.NotNil(err)
+}
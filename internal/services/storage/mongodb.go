@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"sync"
 	"time"
 
@@ -61,6 +62,10 @@ type bobject struct {
 	CID        string             `bson:"cid,omitempty"`
 	Object     string             `bson:"object,omitempty"`
 	Expires    *time.Time         `bson:"expires,omitempty"`
+	Revision   int                `bson:"revision,omitempty"`
+	Group      string             `bson:"group,omitempty"`     // group of an encryption key
+	Version    int                `bson:"version,omitempty"`   // version of a group key
+	DestroyAt  *time.Time         `bson:"destroyat,omitempty"` // scheduled destruction of an encryption key
 }
 
 type tkrevoke struct {
@@ -73,12 +78,21 @@ const (
 	colBlue    = "_blue"
 	colGreen   = "_green"
 	cCTkRevoke = "tkrevoke"
+	cCTkBefore = "tkbefore"
 	cCGroup    = "group"
 	cCClient   = "client"
 	cCClientA  = "clientA"
 	cCClientK  = "clientK"
 	cCCrypt    = "crypt"
 	cCData     = "data"
+	cCKV       = "kv"
+	cCCert     = "cert"
+	cCLastRev  = "lastrevocation"
+	cCACMEAcc  = "acmeaccount"
+	cCACMEOrd  = "acmeorder"
+	cCACMENce  = "acmenonce"
+	cCACMEEAB  = "acmeeab"
+	cCProfile  = "profile"
 
 	cCMasterCrypt     = "master"
 	cMasterKeyMessage = "micro-vault-master-key"
@@ -162,10 +176,22 @@ func (m *MongoStorage) initCollection(n string) error {
 	if err != nil {
 		return err
 	}
+	_, err = m.ensureKVIndex(m.colObj)
+	if err != nil {
+		return err
+	}
+	_, err = m.ensureGroupKeyIndex(m.colObj)
+	if err != nil {
+		return err
+	}
 	err = m.ensureEncryption()
 	if err != nil {
 		return err
 	}
+	err = m.indexEncryptKeys()
+	if err != nil {
+		return err
+	}
 	m.revokes = sync.Map{}
 	return nil
 }
@@ -184,6 +210,64 @@ func (m *MongoStorage) ensureTTLIndex(c *driver.Collection) (bool, error) {
 	return true, nil
 }
 
+// ensureKVIndex an unique index of the secrets, so a new secret can only be inserted once
+func (m *MongoStorage) ensureKVIndex(c *driver.Collection) (bool, error) {
+	idx := c.Indexes()
+	index := driver.IndexModel{
+		Keys: bson.D{{Key: "class", Value: 1}, {Key: "identifier", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("kvidentifier").
+			SetPartialFilterExpression(bson.D{{Key: "class", Value: cCKV}}),
+	}
+
+	_, err := idx.CreateOne(m.ctx, index)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ensureGroupKeyIndex an unique index of the group key versions, so every version can only be created once over all nodes
+func (m *MongoStorage) ensureGroupKeyIndex(c *driver.Collection) (bool, error) {
+	idx := c.Indexes()
+	index := driver.IndexModel{
+		Keys: bson.D{{Key: "class", Value: 1}, {Key: "group", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("groupkeyversion").
+			SetPartialFilterExpression(bson.D{{Key: "version", Value: bson.D{{Key: "$gt", Value: 0}}}}),
+	}
+
+	_, err := idx.CreateOne(m.ctx, index)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// indexEncryptKeys adding the index fields to the keys stored before the indexes exist
+func (m *MongoStorage) indexEncryptKeys() error {
+	flt := bson.D{
+		{Key: "class", Value: cCCrypt},
+		{Key: "identifier", Value: bson.D{{Key: "$ne", Value: cCMasterCrypt}}},
+		{Key: "group", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	ks := make([]model.EncryptKey, 0)
+	err := m.findKeys(flt, func(e model.EncryptKey) bool {
+		if e.Group != "" {
+			ks = append(ks, e)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range ks {
+		err = m.StoreEncryptKey(e)
+		if err != nil {
+			logger.Errorf("error indexing key %s: %v", e.ID, err)
+		}
+	}
+	return nil
+}
+
 func checkForIndex(c *driver.Collection) (bool, error) {
 	idx := c.Indexes()
 	opts := options.ListIndexes().SetMaxTime(2 * time.Second)
@@ -347,6 +431,30 @@ func (m *MongoStorage) IsRevoked(id string) bool {
 	return found
 }
 
+// RevokeTokensBefore revoking all tokens of the client issued until before, exp is the expiration of the last of these tokens.
+// The entry is removed via the ttl index of the collection
+func (m *MongoStorage) RevokeTokensBefore(n string, before, exp time.Time) error {
+	if time.Now().After(exp) {
+		return nil
+	}
+	tb := tkbefore{
+		Name:    n,
+		Before:  before,
+		Expires: exp,
+	}
+	return m.upsert(cCTkBefore, n, &exp, tb)
+}
+
+// TokensRevokedBefore getting the time until all tokens of the client are revoked
+func (m *MongoStorage) TokensRevokedBefore(n string) (time.Time, bool) {
+	var tb tkbefore
+	ok, err := m.one(cCTkBefore, n, &tb)
+	if err != nil || !ok {
+		return time.Time{}, false
+	}
+	return tb.Before, true
+}
+
 // AddGroup adding a group to internal store
 func (m *MongoStorage) AddGroup(g model.Group) (string, error) {
 	err := m.upsert(cCGroup, g.Name, nil, g)
@@ -602,11 +710,106 @@ func (m *MongoStorage) StoreEncryptKey(e model.EncryptKey) error {
 	if e.ID == "" {
 		return serror.ErrMissingID
 	}
-	err := m.upsert(cCCrypt, e.ID, nil, e)
+	obj, err := m.keyObject(e)
 	if err != nil {
 		return err
 	}
-	return nil
+	flt := bson.D{
+		{Key: "class", Value: cCCrypt},
+		{Key: "identifier", Value: e.ID},
+	}
+	_, err = m.colObj.ReplaceOne(m.ctx, flt, obj, options.Replace().SetUpsert(true))
+	if driver.IsDuplicateKeyError(err) {
+		return serror.ErrAlreadyExists
+	}
+	return err
+}
+
+// AddGroupKey stores a new version of a group key, serror.ErrAlreadyExists if the group already has this version.
+// The version is checked by the unique index, so this is consistent over all nodes.
+func (m *MongoStorage) AddGroupKey(e model.EncryptKey) error {
+	if e.ID == "" || e.Group == "" || e.Version <= 0 {
+		return serror.ErrMissingID
+	}
+	obj, err := m.keyObject(e)
+	if err != nil {
+		return err
+	}
+	_, err = m.colObj.InsertOne(m.ctx, obj)
+	if driver.IsDuplicateKeyError(err) {
+		return serror.ErrAlreadyExists
+	}
+	return err
+}
+
+// ListGroupKeys list all versions of the group key via callback function
+func (m *MongoStorage) ListGroupKeys(group string, c func(e model.EncryptKey) bool) error {
+	flt := bson.D{
+		{Key: "class", Value: cCCrypt},
+		{Key: "group", Value: group},
+		{Key: "version", Value: bson.D{{Key: "$gt", Value: 0}}},
+	}
+	return m.findKeys(flt, c)
+}
+
+// ListScheduledKeys list all keys scheduled for destruction until via callback function
+func (m *MongoStorage) ListScheduledKeys(until time.Time, c func(e model.EncryptKey) bool) error {
+	flt := bson.D{
+		{Key: "class", Value: cCCrypt},
+		{Key: "destroyat", Value: bson.D{{Key: "$lte", Value: until}}},
+	}
+	return m.findKeys(flt, c)
+}
+
+// keyObject the document of the key, group, version and the scheduled destruction are unencrypted for the indexes
+func (m *MongoStorage) keyObject(e model.EncryptKey) (*bobject, error) {
+	so, err := m.encrypt(e)
+	if err != nil {
+		return nil, err
+	}
+	obj := bobject{
+		Class:      cCCrypt,
+		Identifier: e.ID,
+		Object:     so,
+		Group:      e.Group,
+		Version:    e.Version,
+	}
+	if e.State == model.KeyStateScheduled {
+		da := e.DestroyAt
+		obj.DestroyAt = &da
+	}
+	return &obj, nil
+}
+
+func (m *MongoStorage) findKeys(flt bson.D, c func(e model.EncryptKey) bool) error {
+	cur, err := m.colObj.Find(m.ctx, flt, options.Find())
+	if err != nil {
+		return err
+	}
+	defer cur.Close(m.ctx)
+
+	for cur.Next(m.ctx) {
+		var result bson.M
+		err := cur.Decode(&result)
+		if err != nil {
+			logger.Errorf("fkeys:"+smpErrLog, err)
+			continue
+		}
+		var e model.EncryptKey
+		res, ok := result["object"].(string)
+		if !ok {
+			continue
+		}
+		err = m.decrypt(res, &e)
+		if err != nil {
+			logger.Errorf("fkeys:"+smpErrLog, err)
+			continue
+		}
+		if !c(e) {
+			break
+		}
+	}
+	return cur.Err()
 }
 
 // GetEncryptKey stores the encrypt keys
@@ -680,7 +883,12 @@ func (m *MongoStorage) StoreData(data model.Data) error {
 	if data.ID == "" {
 		return serror.ErrMissingID
 	}
-	err := m.upsert(cCData, data.ID, nil, data)
+	var exp *time.Time
+	if !data.Expires.IsZero() {
+		// expired data will be removed by the ttl index
+		exp = &data.Expires
+	}
+	err := m.upsert(cCData, data.ID, exp, data)
 	if err != nil {
 		return err
 	}
@@ -743,6 +951,340 @@ func (m *MongoStorage) ListData(s, l int64, c func(c model.Data) bool) error {
 	return cur.Err()
 }
 
+// StoreKV stores the secret with all versions, if the stored revision is still rev (0 for a new secret),
+// otherwise serror.ErrCASMismatch is returned. The revision is checked by the database, so this is consistent over all nodes.
+func (m *MongoStorage) StoreKV(kv model.KVSecret, rev int) error {
+	if kv.Group == "" || kv.Path == "" {
+		return serror.ErrMissingID
+	}
+	kv.Revision = rev + 1
+	so, err := m.encrypt(kv)
+	if err != nil {
+		return err
+	}
+	obj := bobject{
+		Class:      cCKV,
+		Identifier: kv.ID(),
+		Object:     so,
+		Revision:   kv.Revision,
+	}
+	flt := bson.D{
+		{Key: "class", Value: cCKV},
+		{Key: "identifier", Value: kv.ID()},
+		{Key: "revision", Value: rev},
+	}
+	opts := options.Replace()
+	if rev == 0 {
+		// a new secret or a secret stored without revision, a concurrent insert fails with the unique index
+		flt[2] = bson.E{Key: "revision", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}}
+		opts.SetUpsert(true)
+	}
+	res, err := m.colObj.ReplaceOne(m.ctx, flt, obj, opts)
+	if driver.IsDuplicateKeyError(err) {
+		return serror.ErrCASMismatch
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 {
+		return serror.ErrCASMismatch
+	}
+	return nil
+}
+
+// GetKV retrieving the secret
+func (m *MongoStorage) GetKV(group, path string) (*model.KVSecret, bool) {
+	var kv model.KVSecret
+	ok, err := m.one(cCKV, model.KVID(group, path), &kv)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return &kv, true
+}
+
+// DeleteKV removes the secret with all versions from storage
+func (m *MongoStorage) DeleteKV(group, path string) (bool, error) {
+	ok, err := m.delete(cCKV, model.KVID(group, path))
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListKV list all secrets of the group via callback function
+func (m *MongoStorage) ListKV(group string, c func(kv model.KVSecret) bool) error {
+	opts := options.Find().SetSort(bson.D{{Key: "identifier", Value: 1}})
+	obj := bson.D{
+		{Key: "class", Value: cCKV},
+		{Key: "identifier", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(model.KVID(group, ""))}}},
+	}
+	cur, err := m.colObj.Find(m.ctx, obj, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(m.ctx)
+
+	for cur.Next(m.ctx) {
+		var result bson.D
+		err := cur.Decode(&result)
+		if err != nil {
+			logger.Errorf("lkv: error: %v", err)
+			continue
+		}
+		var kv model.KVSecret
+		res, ok := result.Map()["object"].(string)
+		if ok {
+			err = m.decrypt(res, &kv)
+			if err != nil {
+				logger.Errorf("lkv: error: %v", err)
+				continue
+			}
+			if kv.Group != group {
+				continue
+			}
+			if !c(kv) {
+				break
+			}
+		}
+	}
+	return cur.Err()
+}
+
+// StoreCertificate stores the issued certificate
+func (m *MongoStorage) StoreCertificate(c model.Certificate) error {
+	if c.Serial == "" {
+		return serror.ErrMissingID
+	}
+	err := m.upsert(cCCert, c.Serial, nil, c)
+	if err != nil || !c.IsRevoked() {
+		return err
+	}
+	// $max keeps the latest revocation, even with concurrent revocations on different nodes
+	flt := bson.D{
+		{Key: "class", Value: cCLastRev},
+		{Key: "identifier", Value: cCLastRev},
+	}
+	upd := bson.D{{Key: "$max", Value: bson.D{{Key: "revoked", Value: c.Revoked}}}}
+	_, err = m.colObj.UpdateOne(m.ctx, flt, upd, options.Update().SetUpsert(true))
+	return err
+}
+
+// LastRevocation getting the time of the latest revocation of a stored certificate
+func (m *MongoStorage) LastRevocation() (time.Time, bool) {
+	flt := bson.D{
+		{Key: "class", Value: cCLastRev},
+		{Key: "identifier", Value: cCLastRev},
+	}
+	var lr struct {
+		Revoked time.Time `bson:"revoked"`
+	}
+	err := m.colObj.FindOne(m.ctx, flt).Decode(&lr)
+	if err != nil {
+		if err != driver.ErrNoDocuments {
+			logger.Errorf("error getting last revocation: %v", err)
+		}
+		return time.Time{}, false
+	}
+	return lr.Revoked, true
+}
+
+// GetCertificate retrieving the certificate with the serial number
+func (m *MongoStorage) GetCertificate(sn string) (*model.Certificate, bool) {
+	var c model.Certificate
+	ok, err := m.one(cCCert, sn, &c)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return &c, true
+}
+
+// ListCertificates list all issued certificates via callback function
+func (m *MongoStorage) ListCertificates(c func(c model.Certificate) bool) error {
+	obj := bson.D{
+		{Key: "class", Value: cCCert},
+	}
+	cur, err := m.colObj.Find(m.ctx, obj)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(m.ctx)
+
+	for cur.Next(m.ctx) {
+		var result bson.D
+		err := cur.Decode(&result)
+		if err != nil {
+			logger.Errorf("lcrt: error: %v", err)
+			continue
+		}
+		var cr model.Certificate
+		res, ok := result.Map()["object"].(string)
+		if ok {
+			err = m.decrypt(res, &cr)
+			if err != nil {
+				logger.Errorf("lcrt: error: %v", err)
+				continue
+			}
+			if !c(cr) {
+				break
+			}
+		}
+	}
+	return cur.Err()
+}
+
+// StoreACMEAccount stores the acme account
+func (m *MongoStorage) StoreACMEAccount(a model.ACMEAccount) error {
+	if a.ID == "" {
+		return serror.ErrMissingID
+	}
+	return m.upsert(cCACMEAcc, a.ID, nil, a)
+}
+
+// GetACMEAccount retrieving the acme account with the id
+func (m *MongoStorage) GetACMEAccount(id string) (*model.ACMEAccount, bool) {
+	var a model.ACMEAccount
+	ok, err := m.one(cCACMEAcc, id, &a)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return &a, true
+}
+
+// StoreACMEOrder stores the acme order
+func (m *MongoStorage) StoreACMEOrder(o model.ACMEOrder) error {
+	if o.ID == "" {
+		return serror.ErrMissingID
+	}
+	var exp *time.Time
+	if !o.Expires.IsZero() {
+		// expired orders will be removed by the ttl index
+		exp = &o.Expires
+	}
+	return m.upsert(cCACMEOrd, o.ID, exp, o)
+}
+
+// GetACMEOrder retrieving the acme order with the id, expired orders are not returned
+func (m *MongoStorage) GetACMEOrder(id string) (*model.ACMEOrder, bool) {
+	var o model.ACMEOrder
+	ok, err := m.one(cCACMEOrd, id, &o)
+	if err != nil || !ok || o.Expired(time.Now()) {
+		return nil, false
+	}
+	return &o, true
+}
+
+// StoreACMENonce stores the replay nonce, the nonce is removed via the ttl index of the collection
+func (m *MongoStorage) StoreACMENonce(n string, exp time.Time) error {
+	if n == "" {
+		return serror.ErrMissingID
+	}
+	obj := bobject{
+		Class:      cCACMENce,
+		Identifier: n,
+		Expires:    &exp,
+	}
+	_, err := m.colObj.InsertOne(m.ctx, obj)
+	return err
+}
+
+// UseACMENonce consuming the nonce, false if the nonce is unknown, expired or already used
+func (m *MongoStorage) UseACMENonce(n string) bool {
+	flt := bson.D{
+		{Key: "class", Value: cCACMENce},
+		{Key: "identifier", Value: n},
+	}
+	var obj bobject
+	err := m.colObj.FindOneAndDelete(m.ctx, flt).Decode(&obj)
+	if err != nil {
+		if err != driver.ErrNoDocuments {
+			logger.Errorf("error using nonce: %v", err)
+		}
+		return false
+	}
+	// the ttl index removes expired documents only periodically
+	return obj.Expires != nil && time.Now().Before(*obj.Expires)
+}
+
+// StoreACMEEAB stores the external account binding credentials
+func (m *MongoStorage) StoreACMEEAB(e model.ACMEEAB) error {
+	if e.KID == "" {
+		return serror.ErrMissingID
+	}
+	return m.upsert(cCACMEEAB, e.KID, nil, e)
+}
+
+// GetACMEEAB retrieving the external account binding credentials with the key id
+func (m *MongoStorage) GetACMEEAB(kid string) (*model.ACMEEAB, bool) {
+	var e model.ACMEEAB
+	ok, err := m.one(cCACMEEAB, kid, &e)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return &e, true
+}
+
+// StoreProfile stores the certificate profile
+func (m *MongoStorage) StoreProfile(p model.CertProfile) error {
+	if p.Name == "" {
+		return serror.ErrMissingID
+	}
+	return m.upsert(cCProfile, p.Name, nil, p)
+}
+
+// GetProfile retrieving the certificate profile with the name
+func (m *MongoStorage) GetProfile(n string) (*model.CertProfile, bool) {
+	var p model.CertProfile
+	ok, err := m.one(cCProfile, n, &p)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return &p, true
+}
+
+// DeleteProfile removes the certificate profile from storage
+func (m *MongoStorage) DeleteProfile(n string) (bool, error) {
+	ok, err := m.delete(cCProfile, n)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListProfiles list all certificate profiles via callback function
+func (m *MongoStorage) ListProfiles(c func(p model.CertProfile) bool) error {
+	opts := options.Find().SetSort(bson.D{{Key: "identifier", Value: 1}})
+	obj := bson.D{
+		{Key: "class", Value: cCProfile},
+	}
+	cur, err := m.colObj.Find(m.ctx, obj, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(m.ctx)
+
+	for cur.Next(m.ctx) {
+		var result bson.D
+		err := cur.Decode(&result)
+		if err != nil {
+			logger.Errorf("lprf: error: %v", err)
+			continue
+		}
+		var p model.CertProfile
+		res, ok := result.Map()["object"].(string)
+		if ok {
+			err = m.decrypt(res, &p)
+			if err != nil {
+				logger.Errorf("lprf: error: %v", err)
+				continue
+			}
+			if !c(p) {
+				break
+			}
+		}
+	}
+	return cur.Err()
+}
+
 func (m *MongoStorage) clear() error {
 	err := m.colObj.Drop(m.ctx)
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/willie68/micro-vault/internal/config"
 	"github.com/willie68/micro-vault/internal/model"
+	"github.com/willie68/micro-vault/internal/serror"
 	"github.com/willie68/micro-vault/internal/services/keyman"
 	"github.com/willie68/micro-vault/internal/utils"
 )
@@ -288,6 +289,71 @@ func TestStoreEncryptKeyMgo(t *testing.T) {
 	ast.Nil(e1)
 }
 
+func TestGroupKeysMgo(t *testing.T) {
+	ast := assert.New(t)
+
+	mongoInit()
+
+	now := time.Now()
+	e1 := model.EncryptKey{ID: "gk1", Alg: "AES-256-GCM", Key: "murks1", Created: now, Group: "gkgroup", Version: 1}
+	e2 := model.EncryptKey{ID: "gk2", Alg: "AES-256-GCM", Key: "murks2", Created: now, Group: "gkgroup", Version: 2}
+	ast.Nil(mgo.AddGroupKey(e1))
+	ast.Nil(mgo.AddGroupKey(e2))
+
+	// a concurrent rotation with the same version
+	err := mgo.AddGroupKey(model.EncryptKey{ID: "gk3", Alg: "AES-256-GCM", Key: "murks3", Created: now, Group: "gkgroup", Version: 2})
+	ast.ErrorIs(err, serror.ErrAlreadyExists)
+	ast.False(mgo.HasEncryptKey("gk3"))
+
+	ids := make([]string, 0)
+	err = mgo.ListGroupKeys("gkgroup", func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.ElementsMatch([]string{"gk1", "gk2"}, ids)
+
+	e1.State = model.KeyStateScheduled
+	e1.DestroyAt = now.Add(time.Hour)
+	ast.Nil(mgo.StoreEncryptKey(e1))
+
+	ids = make([]string, 0)
+	err = mgo.ListScheduledKeys(now, func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+
+	err = mgo.ListScheduledKeys(now.Add(2*time.Hour), func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal([]string{"gk1"}, ids)
+
+	ok, err := mgo.DeleteEncryptKey(e1.ID)
+	ast.Nil(err)
+	ast.True(ok)
+	ok, err = mgo.DeleteEncryptKey(e2.ID)
+	ast.Nil(err)
+	ast.True(ok)
+
+	ids = make([]string, 0)
+	err = mgo.ListGroupKeys("gkgroup", func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+	err = mgo.ListScheduledKeys(now.Add(2*time.Hour), func(e model.EncryptKey) bool {
+		ids = append(ids, e.ID)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(0, len(ids))
+}
+
 func TestStoreDataCRUDMgo(t *testing.T) {
 	ast := assert.New(t)
 
@@ -330,6 +396,80 @@ func TestStoreDataCRUDMgo(t *testing.T) {
 	ast.Nil(err)
 }
 
+func TestKVCRUDMgo(t *testing.T) {
+	ast := assert.New(t)
+
+	mongoInit()
+
+	kv := model.KVSecret{
+		Group:          "group1",
+		Path:           "db/password",
+		CurrentVersion: 1,
+		Created:        time.Now(),
+		CreatedBy:      "tester1",
+		Versions: []model.KVVersion{
+			{Version: 1, Value: "secret", Created: time.Now(), CreatedBy: "tester1"},
+		},
+	}
+
+	err := mgo.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kv.Path = "db/user"
+	err = mgo.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kv.Group = "group2"
+	err = mgo.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	// secrets of another group with the same id prefix are not listed
+	kv.Group = "group1/db"
+	kv.Path = "other"
+	err = mgo.StoreKV(kv, 0)
+	ast.Nil(err)
+
+	kvs := make([]model.KVSecret, 0)
+	err = mgo.ListKV("group1", func(kv model.KVSecret) bool {
+		kvs = append(kvs, kv)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(kvs))
+
+	kv2, ok := mgo.GetKV("group1", "db/password")
+	ast.True(ok)
+	ast.Equal("secret", kv2.Versions[0].Value)
+	ast.Equal(1, kv2.CurrentVersion)
+
+	// conditional update on the revision
+	ast.Equal(1, kv2.Revision)
+	err = mgo.StoreKV(*kv2, 0)
+	ast.Equal(serror.ErrCASMismatch, err)
+	err = mgo.StoreKV(*kv2, kv2.Revision)
+	ast.Nil(err)
+	err = mgo.StoreKV(*kv2, kv2.Revision)
+	ast.Equal(serror.ErrCASMismatch, err)
+	kv2, ok = mgo.GetKV("group1", "db/password")
+	ast.True(ok)
+	ast.Equal(2, kv2.Revision)
+
+	ok, err = mgo.DeleteKV("group1", "db/password")
+	ast.True(ok)
+	ast.Nil(err)
+
+	kv2, ok = mgo.GetKV("group1", "db/password")
+	ast.False(ok)
+	ast.Nil(kv2)
+
+	ok, err = mgo.DeleteKV("group1", "db/password")
+	ast.False(ok)
+	ast.Nil(err)
+
+	err = mgo.StoreKV(model.KVSecret{Group: "group1"}, 0)
+	ast.NotNil(err)
+}
+
 func TestStoreDataErrorsMgo(t *testing.T) {
 	ast := assert.New(t)
 
@@ -363,3 +503,182 @@ func dmEqualMgo(src, dst model.Data) bool {
 	}
 	return true
 }
+
+func TestCertificateCRUDMgo(t *testing.T) {
+	ast := assert.New(t)
+
+	mongoInit()
+
+	c := model.Certificate{
+		Serial:    "1a2b3c",
+		Subject:   "CN=tester1",
+		Client:    "tester1",
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+
+	err := mgo.StoreCertificate(c)
+	ast.Nil(err)
+
+	c.Serial = "4d5e6f"
+	c.Revoked = time.Now()
+	c.Reason = 1
+	err = mgo.StoreCertificate(c)
+	ast.Nil(err)
+
+	lr, ok := mgo.LastRevocation()
+	ast.True(ok)
+	ast.False(lr.Before(c.Revoked.Truncate(time.Millisecond)))
+
+	c2, ok := mgo.GetCertificate("1a2b3c")
+	ast.True(ok)
+	ast.Equal("tester1", c2.Client)
+	ast.False(c2.IsRevoked())
+
+	c2, ok = mgo.GetCertificate("4d5e6f")
+	ast.True(ok)
+	ast.True(c2.IsRevoked())
+	ast.Equal(1, c2.Reason)
+
+	c2, ok = mgo.GetCertificate("4711")
+	ast.False(ok)
+	ast.Nil(c2)
+
+	cs := make([]model.Certificate, 0)
+	err = mgo.ListCertificates(func(c model.Certificate) bool {
+		cs = append(cs, c)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(cs))
+
+	err = mgo.StoreCertificate(model.Certificate{})
+	ast.NotNil(err)
+}
+
+func TestACMECRUDMgo(t *testing.T) {
+	ast := assert.New(t)
+
+	mongoInit()
+
+	e := model.ACMEEAB{
+		KID:     "kid1",
+		Key:     "c2VjcmV0",
+		Client:  "tester1",
+		Created: time.Now(),
+	}
+	err := mgo.StoreACMEEAB(e)
+	ast.Nil(err)
+
+	e2, ok := mgo.GetACMEEAB("kid1")
+	ast.True(ok)
+	ast.Equal("tester1", e2.Client)
+	ast.Equal("", e2.Account)
+
+	_, ok = mgo.GetACMEEAB("kid2")
+	ast.False(ok)
+
+	a := model.ACMEAccount{
+		ID:      "thumb1",
+		Status:  model.ACMEStatusValid,
+		Client:  "tester1",
+		EAB:     "kid1",
+		Created: time.Now(),
+	}
+	err = mgo.StoreACMEAccount(a)
+	ast.Nil(err)
+
+	a2, ok := mgo.GetACMEAccount("thumb1")
+	ast.True(ok)
+	ast.Equal("kid1", a2.EAB)
+
+	o := model.ACMEOrder{
+		ID:      "order1",
+		Account: "thumb1",
+		Client:  "tester1",
+		Status:  model.ACMEStatusPending,
+		Expires: time.Now().Add(time.Hour),
+		Identifiers: []model.ACMEIdentifier{
+			{Type: "dns", Value: "wkmusicsearch.local"},
+		},
+	}
+	err = mgo.StoreACMEOrder(o)
+	ast.Nil(err)
+
+	o.ID = "order2"
+	o.Expires = time.Now().Add(-time.Minute)
+	err = mgo.StoreACMEOrder(o)
+	ast.Nil(err)
+
+	o2, ok := mgo.GetACMEOrder("order1")
+	ast.True(ok)
+	ast.Equal(1, len(o2.Identifiers))
+	ast.Equal("wkmusicsearch.local", o2.Identifiers[0].Value)
+
+	_, ok = mgo.GetACMEOrder("order2")
+	ast.False(ok)
+
+	err = mgo.StoreACMENonce("nonce1", time.Now().Add(time.Hour))
+	ast.Nil(err)
+	err = mgo.StoreACMENonce("nonce2", time.Now().Add(-time.Minute))
+	ast.Nil(err)
+	ast.True(mgo.UseACMENonce("nonce1"))
+	// every nonce can only be used once
+	ast.False(mgo.UseACMENonce("nonce1"))
+	ast.False(mgo.UseACMENonce("nonce2"))
+	ast.False(mgo.UseACMENonce("nonce3"))
+
+	err = mgo.StoreACMEOrder(model.ACMEOrder{})
+	ast.NotNil(err)
+	err = mgo.StoreACMEAccount(model.ACMEAccount{})
+	ast.NotNil(err)
+	err = mgo.StoreACMEEAB(model.ACMEEAB{})
+	ast.NotNil(err)
+}
+
+func TestProfileCRUDMgo(t *testing.T) {
+	ast := assert.New(t)
+
+	mongoInit()
+
+	p := model.CertProfile{
+		Name:        "server",
+		KeyUsage:    []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage: []string{"serverAuth"},
+		DNS:         []string{".example.com"},
+	}
+	err := mgo.StoreProfile(p)
+	ast.Nil(err)
+	p.Name = "client"
+	p.ExtKeyUsage = []string{"clientAuth"}
+	err = mgo.StoreProfile(p)
+	ast.Nil(err)
+
+	p2, ok := mgo.GetProfile("server")
+	ast.True(ok)
+	ast.Equal([]string{"serverAuth"}, p2.ExtKeyUsage)
+	ast.Equal([]string{".example.com"}, p2.DNS)
+
+	_, ok = mgo.GetProfile("codesign")
+	ast.False(ok)
+
+	ps := make([]model.CertProfile, 0)
+	err = mgo.ListProfiles(func(p model.CertProfile) bool {
+		ps = append(ps, p)
+		return true
+	})
+	ast.Nil(err)
+	ast.Equal(2, len(ps))
+
+	ok, err = mgo.DeleteProfile("client")
+	ast.Nil(err)
+	ast.True(ok)
+	ok, err = mgo.DeleteProfile("client")
+	ast.Nil(err)
+	ast.False(ok)
+	_, ok = mgo.GetProfile("client")
+	ast.False(ok)
+
+	err = mgo.StoreProfile(model.CertProfile{})
+	ast.NotNil(err)
+}
@@ -1,5 +1,16 @@
 package storage
 
-import "github.com/willie68/micro-vault/internal/logging"
+import (
+	"time"
+
+	"github.com/willie68/micro-vault/internal/logging"
+)
 
 var logger = logging.New().WithName("svcStorage")
+
+// tkbefore all tokens of the client issued until before are revoked, the entry expires with the last of these tokens
+type tkbefore struct {
+	Name    string    `json:"name" bson:"identifier"`
+	Before  time.Time `json:"before" bson:"before"`
+	Expires time.Time `json:"expires" bson:"expires"`
+}
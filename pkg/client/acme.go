@@ -0,0 +1,34 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/willie68/micro-vault/internal/logging"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+// ACMEEAB creates new external account binding credentials for the acme server. With these an acme client
+// like certbot, lego or cert-manager can create an acme account, which is bound to this client.
+// The credentials can only be used for one acme account.
+func (c *Client) ACMEEAB() (*pmodel.ACMEEAB, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Post("acme/eab", "application/json", nil)
+	if err != nil {
+		logging.Root.Errorf("eab request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("eab bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var eab pmodel.ACMEEAB
+	err = ReadJSON(res, &eab)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return &eab, nil
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/acme"
+)
+
+func TestACME(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	eab, err := cli.ACMEEAB()
+	ast.Nil(err)
+	ast.NotEmpty(eab.KID)
+	ast.Equal("https://localhost:9543/api/v1/acme/directory", eab.Directory)
+	mac, err := base64.RawURLEncoding.DecodeString(eab.Key)
+	ast.Nil(err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+	ac := &acme.Client{
+		Key:          key,
+		DirectoryURL: eab.Directory,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				// #nosec G402 -- self signed test certificate
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	// without eab no account
+	_, err = ac.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	ast.NotNil(err)
+
+	acc, err := ac.Register(ctx, &acme.Account{
+		ExternalAccountBinding: &acme.ExternalAccountBinding{KID: eab.KID, Key: mac},
+	}, acme.AcceptTOS)
+	ast.Nil(err)
+	ast.Equal(acme.StatusValid, acc.Status)
+
+	_, err = ac.AuthorizeOrder(ctx, acme.DomainIDs("www.example.com"))
+	ast.NotNil(err)
+
+	o, err := ac.AuthorizeOrder(ctx, acme.DomainIDs("wkmusicsearch.local"))
+	ast.Nil(err)
+	ast.Equal(acme.StatusReady, o.Status)
+	for _, u := range o.AuthzURLs {
+		az, err := ac.GetAuthorization(ctx, u)
+		ast.Nil(err)
+		ast.Equal(acme.StatusValid, az.Status)
+	}
+	o, err = ac.WaitOrder(ctx, o.URI)
+	ast.Nil(err)
+
+	ck, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "wkmusicsearch.local"},
+		DNSNames: []string{"wkmusicsearch.local"},
+	}, ck)
+	ast.Nil(err)
+	ders, curl, err := ac.CreateOrderCert(ctx, o.FinalizeURL, csr, true)
+	ast.Nil(err)
+	ast.NotEmpty(curl)
+	ast.Equal(2, len(ders))
+
+	crt, err := x509.ParseCertificate(ders[0])
+	ast.Nil(err)
+	ca, err := x509.ParseCertificate(ders[1])
+	ast.Nil(err)
+	ast.Nil(crt.CheckSignatureFrom(ca))
+	ast.Equal([]string{"wkmusicsearch.local"}, crt.DNSNames)
+
+	err = ac.RevokeCert(ctx, nil, ders[0], acme.CRLReasonSuperseded)
+	ast.Nil(err)
+	// already revoked is no error for the acme client
+	err = ac.RevokeCert(ctx, nil, ders[0], acme.CRLReasonSuperseded)
+	ast.Nil(err)
+
+	der, err := adm.GetCRL()
+	ast.Nil(err)
+	crl, err := x509.ParseRevocationList(der)
+	ast.Nil(err)
+	found := false
+	for _, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(crt.SerialNumber) == 0 {
+			found = true
+			ast.Equal(int(acme.CRLReasonSuperseded), e.ReasonCode)
+		}
+	}
+	ast.True(found)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -88,6 +89,258 @@ func (a *AdminCl) GetCACert() (string, error) {
 	return string(b), nil
 }
 
+// GetCAChain getting the certificate chain of the issuing CA up to the root
+func (a *AdminCl) GetCAChain() (string, error) {
+	res, err := a.clt.Get(fmt.Sprintf("%s/%s", a.url, "ca/cert"))
+	if err != nil {
+		logging.Root.Errorf("get ca chain request failed: %v", err)
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("get ca chain bad response: %d", res.StatusCode)
+		return "", ReadErr(res)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		logging.Root.Errorf("get ca chain request body: %v", err)
+		return "", err
+	}
+	return string(b), nil
+}
+
+// GetCRL getting the actual certificate revocation list of the CA as der
+func (a *AdminCl) GetCRL() ([]byte, error) {
+	res, err := a.clt.Get(fmt.Sprintf("%s/%s", a.url, "ca/crl"))
+	if err != nil {
+		logging.Root.Errorf("get crl request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("get crl bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		logging.Root.Errorf("get crl request body: %v", err)
+		return nil, err
+	}
+	return b, nil
+}
+
+// CertificatesOption options for the certificates methode
+type CertificatesOption func(c *CertificatesOptionContext)
+
+// CertificatesOptionContext holding the options for the certificates methode
+type CertificatesOptionContext struct {
+	client   string
+	expiring string
+	revoked  *bool
+}
+
+// WithClientFilter filter the certificates of a client
+func WithClientFilter(cl string) CertificatesOption {
+	return func(c *CertificatesOptionContext) {
+		c.client = cl
+	}
+}
+
+// WithExpiringFilter filter the valid certificates expiring within the duration, e.g. 30d
+func WithExpiringFilter(d string) CertificatesOption {
+	return func(c *CertificatesOptionContext) {
+		c.expiring = d
+	}
+}
+
+// WithRevokedFilter filter the revoked (true) or the not revoked (false) certificates
+func WithRevokedFilter(r bool) CertificatesOption {
+	return func(c *CertificatesOptionContext) {
+		c.revoked = &r
+	}
+}
+
+// Certificates getting the issued certificates sorted by the expiry
+func (a *AdminCl) Certificates(opts ...CertificatesOption) ([]pmodel.CertificateInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	cOpt := &CertificatesOptionContext{}
+	for _, opt := range opts {
+		opt(cOpt)
+	}
+	q := url.Values{}
+	if cOpt.client != "" {
+		q.Add("client", cOpt.client)
+	}
+	if cOpt.expiring != "" {
+		q.Add("expiring", cOpt.expiring)
+	}
+	if cOpt.revoked != nil {
+		q.Add("revoked", strconv.FormatBool(*cOpt.revoked))
+	}
+	page := "admin/certificates"
+	if qs := q.Encode(); qs != "" {
+		page = page + "?" + qs
+	}
+	res, err := a.Get(page)
+	if err != nil {
+		logging.Root.Errorf("certificates request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("certificates bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	cis := make([]pmodel.CertificateInfo, 0)
+	err = ReadJSON(res, &cis)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return cis, nil
+}
+
+// Certificate getting the issued certificate with the serial number (hex) including the pem
+func (a *AdminCl) Certificate(sn string) (*pmodel.CertificateInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.Get(fmt.Sprintf("admin/certificates/%s", sn))
+	if err != nil {
+		logging.Root.Errorf("certificate request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("certificate bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var ci pmodel.CertificateInfo
+	err = ReadJSON(res, &ci)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// RevokeCertificate revoking an issued certificate with the serial number (hex) and a reason, e.g. keyCompromise
+func (a *AdminCl) RevokeCertificate(sn, reason string) (*pmodel.CertificateInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/certificates/%s/revoke", sn), pmodel.Revocation{Reason: reason})
+	if err != nil {
+		logging.Root.Errorf("revoke request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("revoke bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var ci pmodel.CertificateInfo
+	err = ReadJSON(res, &ci)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// Profiles getting all certificate profiles
+func (a *AdminCl) Profiles() ([]pmodel.CertProfile, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.Get("admin/profiles")
+	if err != nil {
+		logging.Root.Errorf("profiles request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("profiles bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	ps := make([]pmodel.CertProfile, 0)
+	err = ReadJSON(res, &ps)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Profile getting the certificate profile with the name
+func (a *AdminCl) Profile(n string) (*pmodel.CertProfile, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.Get(fmt.Sprintf("admin/profiles/%s", n))
+	if err != nil {
+		logging.Root.Errorf("profile request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("profile bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var p pmodel.CertProfile
+	err = ReadJSON(res, &p)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+// StoreProfile creating or updating a certificate profile
+func (a *AdminCl) StoreProfile(p pmodel.CertProfile) error {
+	err := a.checkToken()
+	if err != nil {
+		return err
+	}
+	res, err := a.PostJSON("admin/profiles", p)
+	if err != nil {
+		logging.Root.Errorf("store profile request failed: %v", err)
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("store profile bad response: %d", res.StatusCode)
+		return ReadErr(res)
+	}
+	return nil
+}
+
+// DeleteProfile deleting the certificate profile with the name
+func (a *AdminCl) DeleteProfile(n string) error {
+	err := a.checkToken()
+	if err != nil {
+		return err
+	}
+	res, err := a.Delete(fmt.Sprintf("admin/profiles/%s", n))
+	if err != nil {
+		logging.Root.Errorf("delete profile request failed: %v", err)
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("delete profile bad response: %d", res.StatusCode)
+		return ReadErr(res)
+	}
+	return nil
+}
+
 // Login logging this client in, getting a token for further requests
 func (a *AdminCl) Login() error {
 	up := struct {
@@ -127,8 +380,17 @@ func (a *AdminCl) Login() error {
 	return nil
 }
 
-// Logout logging out this client
+// Logout logging out this client, the token and the refresh token are revoked on the server
 func (a *AdminCl) Logout() {
+	for _, tk := range []string{a.refreshToken, a.token} {
+		if tk == "" {
+			continue
+		}
+		err := revokeToken(a.Post, tk)
+		if err != nil {
+			logging.Root.Errorf("logout: %v", err)
+		}
+	}
 	a.token = ""
 	a.refreshToken = ""
 }
@@ -313,6 +575,204 @@ func (a *AdminCl) DeleteGroup(n string) error {
 	return nil
 }
 
+// RotateGroupKey rotates the key of the group, returning the new current key
+func (a *AdminCl) RotateGroupKey(g string) (*pmodel.EncryptKeyInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.Post(fmt.Sprintf("admin/groupkeys/%s/rotate", g), "application/json", nil)
+	if err != nil {
+		logging.Root.Errorf("rotate group key request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("rotate group key bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var ek pmodel.EncryptKeyInfo
+	err = ReadJSON(res, &ek)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &ek, nil
+}
+
+// RotateClientKey rotating the key of the client, an empty key type keeps the key type of the client
+func (a *AdminCl) RotateClientKey(n, kt string) (*pmodel.Client, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/clients/%s/key/rotate", n), struct {
+		KeyType string `json:"keytype"`
+	}{
+		KeyType: kt,
+	})
+	if err != nil {
+		logging.Root.Errorf("rotate client key request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("rotate client key bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var cl pmodel.Client
+	err = ReadJSON(res, &cl)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// SetClientState enabling or disabling the client and setting its validity period
+func (a *AdminCl) SetClientState(n string, st pmodel.ClientState) (*pmodel.Client, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/clients/%s/state", n), st)
+	if err != nil {
+		logging.Root.Errorf("client state request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("client state bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var cl pmodel.Client
+	err = ReadJSON(res, &cl)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// SetClientTokenTTL setting the token lifetimes of the client, empty values are using the lifetimes of the groups or the service
+func (a *AdminCl) SetClientTokenTTL(n string, tt pmodel.TokenTTL) (*pmodel.Client, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/clients/%s/tokenttl", n), tt)
+	if err != nil {
+		logging.Root.Errorf("client token ttl request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("client token ttl bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var cl pmodel.Client
+	err = ReadJSON(res, &cl)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &cl, nil
+}
+
+// RevokeClientTokens revoking all access and refresh tokens of the client issued until before, nil is now.
+// Used to invalidate a leaked credential on all nodes.
+func (a *AdminCl) RevokeClientTokens(n string, before *time.Time) error {
+	err := a.checkToken()
+	if err != nil {
+		return err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/clients/%s/tokens/revoke", n), pmodel.TokenRevocation{Before: before})
+	if err != nil {
+		logging.Root.Errorf("revoke client tokens request failed: %v", err)
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("revoke client tokens bad response: %d", res.StatusCode)
+		return ReadErr(res)
+	}
+	return nil
+}
+
+// RotateClientSecret issuing a new secret for the client, the old secrets are still valid until revoked.
+// exp is the optional validity of the new secret, e.g. 90d. The secret is only given once.
+func (a *AdminCl) RotateClientSecret(n, exp string) (*pmodel.ClientSecret, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/clients/%s/secrets/rotate", n), struct {
+		Expires string `json:"expires,omitempty"`
+	}{
+		Expires: exp,
+	})
+	if err != nil {
+		logging.Root.Errorf("rotate client secret request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("rotate client secret bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var s pmodel.ClientSecret
+	err = ReadJSON(res, &s)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ClientSecrets listing the active secrets of the client, without the secrets itself
+func (a *AdminCl) ClientSecrets(n string) ([]pmodel.ClientSecret, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.Get(fmt.Sprintf("admin/clients/%s/secrets", n))
+	if err != nil {
+		logging.Root.Errorf("client secrets request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("client secrets bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	ss := make([]pmodel.ClientSecret, 0)
+	err = ReadJSON(res, &ss)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return ss, nil
+}
+
+// RevokeClientSecret revoking the secret with the id of the client, the last active secret can't be revoked
+func (a *AdminCl) RevokeClientSecret(n, id string) error {
+	err := a.checkToken()
+	if err != nil {
+		return err
+	}
+	res, err := a.Delete(fmt.Sprintf("admin/clients/%s/secrets/%s", n, id))
+	if err != nil {
+		logging.Root.Errorf("revoke client secret request failed: %v", err)
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("revoke client secret bad response: %d", res.StatusCode)
+		return ReadErr(res)
+	}
+	return nil
+}
+
 // ClientsOption options for the clients methode
 type ClientsOption func(c *ClientsOptionContext)
 
@@ -368,6 +828,94 @@ func (a *AdminCl) Clients(opts ...ClientsOption) ([]pmodel.Client, error) {
 	return cs, nil
 }
 
+// Keys getting a list of group keys, WithGroupFilter can be used to filter the keys of a group
+func (a *AdminCl) Keys(opts ...ClientsOption) ([]pmodel.EncryptKeyInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return []pmodel.EncryptKeyInfo{}, err
+	}
+	cOpt := &ClientsOptionContext{}
+	for _, opt := range opts {
+		opt(cOpt)
+	}
+	q := url.Values{}
+	if cOpt.groupFilter != "" {
+		q.Add("group", cOpt.groupFilter)
+	}
+	qs := q.Encode()
+	page := "admin/groupkeys"
+	if qs != "" {
+		page = page + "?" + qs
+	}
+	res, err := a.Get(page)
+	if err != nil {
+		logging.Root.Errorf("keys request failed: %v", err)
+		return []pmodel.EncryptKeyInfo{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("keys bad response: %d", res.StatusCode)
+		return []pmodel.EncryptKeyInfo{}, ReadErr(res)
+	}
+	ks := make([]pmodel.EncryptKeyInfo, 0)
+	err = ReadJSON(res, &ks)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return []pmodel.EncryptKeyInfo{}, err
+	}
+	return ks, nil
+}
+
+// SetKeyState changing the lifecycle state of a key, enabled, disabled or scheduled-for-destruction
+func (a *AdminCl) SetKeyState(id, state string) (*pmodel.EncryptKeyInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.PostJSON(fmt.Sprintf("admin/groupkeys/%s/state", id), map[string]string{"state": state})
+	if err != nil {
+		logging.Root.Errorf("key state request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("key state bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var ek pmodel.EncryptKeyInfo
+	err = ReadJSON(res, &ek)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &ek, nil
+}
+
+// DeleteKey schedules the key for destruction
+func (a *AdminCl) DeleteKey(id string) (*pmodel.EncryptKeyInfo, error) {
+	err := a.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.Delete(fmt.Sprintf("admin/groupkeys/%s", id))
+	if err != nil {
+		logging.Root.Errorf("delete key request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("delete key bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var ek pmodel.EncryptKeyInfo
+	err = ReadJSON(res, &ek)
+	if err != nil {
+		logging.Root.Errorf(errParsingResponse, err)
+		return nil, err
+	}
+	return &ek, nil
+}
+
 // Client getting a single client
 func (a *AdminCl) Client(n string) (*pmodel.Client, error) {
 	err := a.checkToken()
@@ -397,16 +945,24 @@ func (a *AdminCl) Client(n string) (*pmodel.Client, error) {
 
 // NewClient getting a list of groups
 func (a *AdminCl) NewClient(n string, g []string) (*pmodel.Client, error) {
+	return a.NewClientWithKeyType(n, g, "")
+}
+
+// NewClientWithKeyType creating a new client with a key of the key type: RSA-2048, RSA-3072, RSA-4096 (default),
+// ECDSA-P256, ECDSA-P384 or ED25519
+func (a *AdminCl) NewClientWithKeyType(n string, g []string, kt string) (*pmodel.Client, error) {
 	err := a.checkToken()
 	if err != nil {
 		return nil, err
 	}
 	du := struct {
-		Name   string   `json:"name"`
-		Groups []string `json:"groups"`
+		Name    string   `json:"name"`
+		Groups  []string `json:"groups"`
+		KeyType string   `json:"keytype,omitempty"`
 	}{
-		Name:   n,
-		Groups: g,
+		Name:    n,
+		Groups:  g,
+		KeyType: kt,
 	}
 	res, err := a.PostJSON("admin/clients", du)
 	if err != nil {
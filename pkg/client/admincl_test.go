@@ -1,7 +1,14 @@
 package client
 
 import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -10,6 +17,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/willie68/micro-vault/internal/model"
 	"github.com/willie68/micro-vault/pkg/pmodel"
+	"golang.org/x/crypto/ocsp"
 )
 
 var (
@@ -194,6 +202,26 @@ func TestAdmCRUDGroup(t *testing.T) {
 	ast.Equal(len(gs), len(gs2))
 }
 
+func TestAdmRotateGroupKey(t *testing.T) {
+	initAdm()
+	ast := assert.New(t)
+	ast.NotNil(adm)
+
+	_, err := adm.RotateGroupKey("unknowngroup")
+	ast.NotNil(err)
+
+	ek1, err := adm.RotateGroupKey("group1")
+	ast.Nil(err)
+	ast.NotNil(ek1)
+	ast.Equal("group1", ek1.Group)
+	ast.True(ek1.Version > 0)
+
+	ek2, err := adm.RotateGroupKey("group1")
+	ast.Nil(err)
+	ast.Equal(ek1.Version+1, ek2.Version)
+	ast.NotEqual(ek1.ID, ek2.ID)
+}
+
 func TestAdminGetCACert(t *testing.T) {
 	initAdm()
 	ast := assert.New(t)
@@ -209,3 +237,162 @@ func TestAdminGetCACert(t *testing.T) {
 	t.Logf("cert: %v", m)
 	ast.Equal("Hattingen", m["subject"].(map[string]any)["locality"])
 }
+
+func TestAdmRevokeCertificate(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	ast.NotNil(adm)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	csr, err := createCsrPem()
+	ast.Nil(err)
+	crt, err := cli.CreateCertificate(*csr)
+	ast.Nil(err)
+	sn := crt.SerialNumber.Text(16)
+
+	_, err = adm.RevokeCertificate("4711", "keyCompromise")
+	ast.NotNil(err)
+
+	ci, err := adm.RevokeCertificate(sn, "keyCompromise")
+	ast.Nil(err)
+	ast.Equal(sn, ci.Serial)
+	ast.Equal("keyCompromise", ci.Reason)
+	ast.NotNil(ci.Revoked)
+
+	_, err = adm.RevokeCertificate(sn, "keyCompromise")
+	ast.NotNil(err)
+
+	der, err := adm.GetCRL()
+	ast.Nil(err)
+	crl, err := x509.ParseRevocationList(der)
+	ast.Nil(err)
+	found := false
+	for _, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(crt.SerialNumber) == 0 {
+			found = true
+		}
+	}
+	ast.True(found)
+}
+
+func TestAdmOCSP(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	csr, err := createCsrPem()
+	ast.Nil(err)
+	crt, err := cli.CreateCertificate(*csr)
+	ast.Nil(err)
+	ast.Equal(1, len(crt.OCSPServer))
+
+	cp, err := adm.GetCACert()
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(cp))
+	ca, err := x509.ParseCertificate(p.Bytes)
+	ast.Nil(err)
+
+	der, err := ocsp.CreateRequest(crt, ca, nil)
+	ast.Nil(err)
+
+	res, err := adm.clt.Post(localURL+"/api/v1/ca/ocsp", "application/ocsp-request", bytes.NewReader(der))
+	ast.Nil(err)
+	defer res.Body.Close()
+	ast.Equal(http.StatusOK, res.StatusCode)
+	ast.Equal("application/ocsp-response", res.Header.Get("Content-Type"))
+	b, err := io.ReadAll(res.Body)
+	ast.Nil(err)
+	or, err := ocsp.ParseResponseForCert(b, crt, ca)
+	ast.Nil(err)
+	ast.Equal(ocsp.Good, or.Status)
+
+	_, err = adm.RevokeCertificate(crt.SerialNumber.Text(16), "keyCompromise")
+	ast.Nil(err)
+
+	res, err = adm.clt.Get(localURL + "/api/v1/ca/ocsp/" + url.PathEscape(base64.StdEncoding.EncodeToString(der)))
+	ast.Nil(err)
+	defer res.Body.Close()
+	ast.Equal(http.StatusOK, res.StatusCode)
+	b, err = io.ReadAll(res.Body)
+	ast.Nil(err)
+	or, err = ocsp.ParseResponseForCert(b, crt, ca)
+	ast.Nil(err)
+	ast.Equal(ocsp.Revoked, or.Status)
+	ast.Equal(ocsp.KeyCompromise, or.RevocationReason)
+}
+
+func TestAdmCRUDProfile(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+
+	p := pmodel.CertProfile{
+		Name:        "codesign",
+		KeyUsage:    []string{"digitalSignature"},
+		ExtKeyUsage: []string{"codeSigning"},
+		MaxValidity: "90d",
+	}
+	err := adm.StoreProfile(p)
+	ast.Nil(err)
+	err = adm.StoreProfile(pmodel.CertProfile{Name: "wrong", KeyUsage: []string{"signEverything"}})
+	ast.NotNil(err)
+
+	ps, err := adm.Profiles()
+	ast.Nil(err)
+	ast.True(slices.ContainsFunc(ps, func(e pmodel.CertProfile) bool { return e.Name == "codesign" }))
+
+	p1, err := adm.Profile("codesign")
+	ast.Nil(err)
+	ast.Equal(p, *p1)
+
+	// the profile is not assigned to the client
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+	csr, err := createCsrPem()
+	ast.Nil(err)
+	_, err = cli.CreateProfileCertificate(*csr, "codesign")
+	ast.NotNil(err)
+
+	err = adm.DeleteProfile("codesign")
+	ast.Nil(err)
+	_, err = adm.Profile("codesign")
+	ast.NotNil(err)
+	err = adm.DeleteProfile("codesign")
+	ast.NotNil(err)
+}
+
+func TestAdmCertificates(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	csr, err := createCsrPem()
+	ast.Nil(err)
+	crt, err := cli.CreateCertificate(*csr)
+	ast.Nil(err)
+	sn := crt.SerialNumber.Text(16)
+
+	cis, err := adm.Certificates(WithClientFilter("tester1"), WithExpiringFilter("400d"), WithRevokedFilter(false))
+	ast.Nil(err)
+	ast.True(slices.ContainsFunc(cis, func(ci pmodel.CertificateInfo) bool { return ci.Serial == sn }))
+
+	cis, err = adm.Certificates(WithClientFilter("tester2"))
+	ast.Nil(err)
+	ast.False(slices.ContainsFunc(cis, func(ci pmodel.CertificateInfo) bool { return ci.Serial == sn }))
+
+	_, err = adm.Certificates(WithExpiringFilter("soon"))
+	ast.NotNil(err)
+
+	ci, err := adm.Certificate(sn)
+	ast.Nil(err)
+	ast.Equal("tester1", ci.Client)
+	p, _ := pem.Decode([]byte(ci.PEM))
+	ast.NotNil(p)
+	ast.Equal(crt.Raw, p.Bytes)
+}
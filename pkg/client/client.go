@@ -2,9 +2,11 @@ package client
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
@@ -48,7 +50,8 @@ type Client struct {
 	clt             http.Client
 	ctx             context.Context
 	insecure        bool
-	privatekey      *rsa.PrivateKey
+	privatekey      crypto.Signer
+	previouskeys    []crypto.Signer // previous, not retired keys of this client, loaded on demand
 	refreshcallback Refreshcallback
 }
 
@@ -118,10 +121,11 @@ func (c *Client) Login() error {
 	c.refreshToken = ds.RefreshToken
 	c.name = ds.Name
 	c.expired = time.Now().Add(time.Second * time.Duration(ds.ExpiresIn))
-	c.privatekey, err = cry.Pem2Prv(ds.Key)
+	c.privatekey, err = cry.Pem2Key(ds.Key)
 	if err != nil {
 		return err
 	}
+	c.previouskeys = nil
 	return nil
 }
 
@@ -176,8 +180,8 @@ func (c *Client) Name() string {
 	return c.name
 }
 
-// PrivateKey getting the public key of another client by name
-func (c *Client) PrivateKey() (*rsa.PrivateKey, error) {
+// PrivateKey getting the private key of this client, a *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+func (c *Client) PrivateKey() (crypto.Signer, error) {
 	err := c.checkToken()
 	if err != nil {
 		return nil, err
@@ -185,14 +189,79 @@ func (c *Client) PrivateKey() (*rsa.PrivateKey, error) {
 	return c.privatekey, nil
 }
 
-// Logout logging out this client
+// Logout logging out this client, the token and the refresh token are revoked on the server
 func (c *Client) Logout() {
+	for _, tk := range []string{c.refreshToken, c.token} {
+		if tk == "" {
+			continue
+		}
+		err := revokeToken(c.Post, tk)
+		if err != nil {
+			logging.Root.Errorf("logout: %v", err)
+		}
+	}
 	c.token = ""
 	c.refreshToken = ""
 }
 
+// Introspect getting the state and the claims of a token of a client or the admin,
+// e.g. for a gateway validating the tokens of its callers
+func (c *Client) Introspect(tk string) (*pmodel.Introspection, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	fd := url.Values{"token": {tk}}
+	res, err := c.Post("login/introspect", "application/x-www-form-urlencoded", strings.NewReader(fd.Encode()))
+	if err != nil {
+		logging.Root.Errorf("introspect request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("introspect bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var ti pmodel.Introspection
+	err = ReadJSON(res, &ti)
+	if err != nil {
+		logging.Root.Errorf("parsing response failed: %v", err)
+		return nil, err
+	}
+	return &ti, nil
+}
+
+// revokeToken revoking the token on the server with the given post function
+func revokeToken(post func(endpoint, contentType string, body io.Reader) (*http.Response, error), tk string) error {
+	fd := url.Values{"token": {tk}}
+	res, err := post("login/revoke", "application/x-www-form-urlencoded", strings.NewReader(fd.Encode()))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ReadErr(res)
+	}
+	return nil
+}
+
 // CreateCertificate create and sign a new certificate for this client
 func (c *Client) CreateCertificate(template x509.CertificateRequest) (*x509.Certificate, error) {
+	xcs, err := c.CreateCertificateChain(template)
+	if err != nil {
+		return nil, err
+	}
+	return xcs[0], nil
+}
+
+// CreateCertificateChain create and sign a new certificate for this client, returning the certificate followed by the chain of the CA
+func (c *Client) CreateCertificateChain(template x509.CertificateRequest) ([]*x509.Certificate, error) {
+	return c.CreateProfileCertificate(template, "")
+}
+
+// CreateProfileCertificate create and sign a new certificate for this client with the certificate profile,
+// an empty profile is the default profile of the client. Returning the certificate followed by the chain of the CA
+func (c *Client) CreateProfileCertificate(template x509.CertificateRequest, profile string) ([]*x509.Certificate, error) {
 	err := c.checkToken()
 	if err != nil {
 		return nil, err
@@ -203,16 +272,75 @@ func (c *Client) CreateCertificate(template x509.CertificateRequest) (*x509.Cert
 			return nil, err
 		}
 	}
-	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &template, c.privatekey)
+	return c.postCSR(template, c.privatekey, "client", profile)
+}
+
+// CreateKeyCertificate create and sign a new certificate for the own key of the caller, the private key never leaves the caller.
+// An empty profile is the default profile of the client. Returning the certificate followed by the chain of the CA
+func (c *Client) CreateKeyCertificate(template x509.CertificateRequest, key crypto.Signer, profile string) ([]*x509.Certificate, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	return c.postCSR(template, key, "csr", profile)
+}
+
+// CreateCertificateBundle create a new certificate with a key generated by the mv service. The key is only part of the returned,
+// password protected bundle as pem (certificate, chain and encrypted private key) or as pkcs#12.
+func (c *Client) CreateCertificateBundle(template x509.CertificateRequest, br pmodel.CertBundleRequest) ([]byte, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	// the public key of the request is ignored, so an ephemeral key is good enough for the template
+	template.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csrPEM, err := csr(template, ek)
+	if err != nil {
+		return nil, err
+	}
+	br.Template = csrPEM
+	res, err := c.PostJSON("vault/clients/certificate/bundle", br)
 	if err != nil {
+		logging.Root.Errorf(errMsgKeyFailed, err)
 		return nil, err
 	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf(errMsgKeyBadRes, res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// csr creating the pem encoded certificate request signed with the key
+func csr(template x509.CertificateRequest, key crypto.Signer) (string, error) {
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &template, key)
+	if err != nil {
+		return "", err
+	}
 	caPEM := new(bytes.Buffer)
 	err = pem.Encode(caPEM, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
+	if err != nil {
+		return "", err
+	}
+	return caPEM.String(), nil
+}
+
+func (c *Client) postCSR(template x509.CertificateRequest, key crypto.Signer, mode, profile string) ([]*x509.Certificate, error) {
+	csrPEM, err := csr(template, key)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.Post("vault/clients/certificate", "application/x-pem-file", strings.NewReader(caPEM.String()))
+	q := url.Values{}
+	q.Add("mode", mode)
+	if profile != "" {
+		q.Add("profile", profile)
+	}
+	res, err := c.Post("vault/clients/certificate?"+q.Encode(), "application/x-pem-file", strings.NewReader(csrPEM))
 	if err != nil {
 		logging.Root.Errorf(errMsgKeyFailed, err)
 		return nil, err
@@ -228,28 +356,43 @@ func (c *Client) CreateCertificate(template x509.CertificateRequest) (*x509.Cert
 		return nil, err
 	}
 
-	p, _ := pem.Decode(b)
-	if p == nil {
-		return nil, errors.New("no pem block found")
-	}
-	if p.Type != "CERTIFICATE" {
-		return nil, errors.New("wrong pem block found")
+	xcs := make([]*x509.Certificate, 0)
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			break
+		}
+		if p.Type != "CERTIFICATE" {
+			return nil, errors.New("wrong pem block found")
+		}
+		xc, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			logging.Root.Errorf(errMsgHexConvertFailed, err)
+			return nil, err
+		}
+		xcs = append(xcs, xc)
 	}
-	xc, err := x509.ParseCertificate(p.Bytes)
-	if err != nil {
-		logging.Root.Errorf(errMsgHexConvertFailed, err)
-		return nil, err
+	if len(xcs) == 0 {
+		return nil, errors.New("no pem block found")
 	}
-	return xc, nil
+	return xcs, nil
 }
 
 // Encrypt4Group encrypting data string for a group
 func (c *Client) Encrypt4Group(g, dt string) (string, string, error) {
+	return c.Encrypt4GroupAEAD(g, "", dt, nil)
+}
+
+// Encrypt4GroupAEAD encrypt a message for a group with a new key of the algorithm alg.
+// For the aead algorithms AES-256-GCM and XCHACHA20-POLY1305 optional associated data can be given,
+// which is needed for decryption, too. returning the encrypted message and the id of the key
+func (c *Client) Encrypt4GroupAEAD(g, alg, dt string, ad []byte) (string, string, error) {
 	err := c.checkToken()
 	if err != nil {
 		return "", "", err
 	}
-	jr, err := c.createKey4Group(g)
+	jr, err := c.createKey4Group(g, alg)
 	if err != nil {
 		logging.Root.Errorf("key creation failed: %v", err)
 		return "", "", err
@@ -259,7 +402,7 @@ func (c *Client) Encrypt4Group(g, dt string) (string, string, error) {
 		logging.Root.Errorf(errMsgHexConvertFailed, err)
 		return "", "", err
 	}
-	cs, err := cry.Encrypt(b, dt)
+	cs, err := cry.EncryptAlg(jr.Alg, b, jr.ID, dt, ad)
 	if err != nil {
 		logging.Root.Errorf("reconstruct cipher failed: %v", err)
 		return "", "", err
@@ -267,8 +410,224 @@ func (c *Client) Encrypt4Group(g, dt string) (string, string, error) {
 	return cs, jr.ID, err
 }
 
+// GenerateDataKey generates a new data key for envelope encryption with the current key of the group.
+// returning the plaintext data key for local encryption and the wrapped data key, which can be stored with the data
+func (c *Client) GenerateDataKey(g string) ([]byte, string, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, "", err
+	}
+	jd := struct {
+		Group string `json:"group"`
+	}{
+		Group: g,
+	}
+	res, err := c.PostJSON("vault/groups/datakey", jd)
+	if err != nil {
+		logging.Root.Errorf("data key request failed: %v", err)
+		return nil, "", err
+	}
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("data key bad response: %d", res.StatusCode)
+		return nil, "", ReadErr(res)
+	}
+	var dk pmodel.DataKey
+	err = ReadJSON(res, &dk)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, "", err
+	}
+	b, err := hex.DecodeString(dk.Plaintext)
+	if err != nil {
+		logging.Root.Errorf(errMsgHexConvertFailed, err)
+		return nil, "", err
+	}
+	return b, dk.Ciphertext, nil
+}
+
+// UnwrapDataKey unwraps a data key generated with GenerateDataKey, returning the plaintext data key
+func (c *Client) UnwrapDataKey(wk string) ([]byte, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.PostJSON("vault/groups/datakey/unwrap", pmodel.DataKey{Ciphertext: wk})
+	if err != nil {
+		logging.Root.Errorf("unwrap data key request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("unwrap data key bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var dk pmodel.DataKey
+	err = ReadJSON(res, &dk)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	b, err := hex.DecodeString(dk.Plaintext)
+	if err != nil {
+		logging.Root.Errorf(errMsgHexConvertFailed, err)
+		return nil, err
+	}
+	return b, nil
+}
+
+// Rewrap re-encrypts the ciphertext of the key id with the current key of the group, or with the key of tid, if given.
+// returning the new ciphertext and the id of the new key
+func (c *Client) Rewrap(id, tid, ct string) (string, string, error) {
+	err := c.checkToken()
+	if err != nil {
+		return "", "", err
+	}
+	msg := pmodel.RewrapMessage{
+		ID:       id,
+		TargetID: tid,
+		Message:  ct,
+	}
+	res, err := c.PostJSON("vault/groups/rewrap", msg)
+	if err != nil {
+		logging.Root.Errorf("rewrap request failed: %v", err)
+		return "", "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("rewrap bad response: %d", res.StatusCode)
+		return "", "", ReadErr(res)
+	}
+	var jr pmodel.RewrapMessage
+	err = ReadJSON(res, &jr)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return "", "", err
+	}
+	return jr.Message, jr.ID, nil
+}
+
+// RewrapBatch re-encrypts a list of ciphertexts, errors are reported per item in the Error field
+func (c *Client) RewrapBatch(msgs []pmodel.RewrapMessage) ([]pmodel.RewrapMessage, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.PostJSON("vault/groups/rewrap/batch", msgs)
+	if err != nil {
+		logging.Root.Errorf("rewrap batch request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("rewrap batch bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	jr := make([]pmodel.RewrapMessage, 0)
+	err = ReadJSON(res, &jr)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return jr, nil
+}
+
+// NewEncryptWriter4Group creates a writer, encrypting a stream locally with a new key of the group.
+// Algorithm is AES-256-GCM (default) or XCHACHA20-POLY1305. returning the writer and the id of the key
+func (c *Client) NewEncryptWriter4Group(g, alg string, w io.Writer) (io.WriteCloser, string, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if alg == "" {
+		alg = cry.AlgAES256GCM
+	}
+	jr, err := c.createKey4Group(g, alg)
+	if err != nil {
+		logging.Root.Errorf("key creation failed: %v", err)
+		return nil, "", err
+	}
+	b, err := hex.DecodeString(jr.Key)
+	if err != nil {
+		logging.Root.Errorf(errMsgHexConvertFailed, err)
+		return nil, "", err
+	}
+	ew, err := cry.NewEncryptWriter(w, jr.Alg, b, jr.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return ew, jr.ID, nil
+}
+
+// NewDecryptReader4Group creates a reader, decrypting a stream locally, the key is taken from the stream header
+func (c *Client) NewDecryptReader4Group(r io.Reader) (io.Reader, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	return cry.NewDecryptReaderFunc(r, func(kid string) ([]byte, error) {
+		jr, err := c.getKey4ID(kid)
+		if err != nil {
+			return nil, err
+		}
+		return hex.DecodeString(jr.Key)
+	})
+}
+
+// EncryptStream4Group encrypting a stream server side with a new key of the group, returning the id of the key
+func (c *Client) EncryptStream4Group(g, alg string, r io.Reader, w io.Writer) (string, error) {
+	q := url.Values{}
+	q.Set("group", g)
+	if alg != "" {
+		q.Set("alg", alg)
+	}
+	res, err := c.postStream("vault/groups/stream/encrypt?"+q.Encode(), r, w)
+	if err != nil {
+		return "", err
+	}
+	return res.Header.Get("X-Key-Id"), nil
+}
+
+// DecryptStream4Group decrypting a stream server side
+func (c *Client) DecryptStream4Group(r io.Reader, w io.Writer) error {
+	_, err := c.postStream("vault/groups/stream/decrypt", r, w)
+	return err
+}
+
+// postStream posting a binary stream and copying the response into w, without a timeout for large streams
+func (c *Client) postStream(endpoint string, r io.Reader, w io.Writer) (*http.Response, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest(http.MethodPost, endpoint, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	clt := c.clt
+	clt.Timeout = 0
+	res, err := clt.Do(req)
+	if err != nil {
+		logging.Root.Errorf("stream request failed: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("stream bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	_, err = io.Copy(w, res.Body)
+	if err != nil {
+		logging.Root.Errorf("stream copy failed: %v", err)
+		return nil, err
+	}
+	return res, nil
+}
+
 // Decrypt4Group encrypting data string for a group
 func (c *Client) Decrypt4Group(id, dt string) (string, error) {
+	return c.Decrypt4GroupAEAD(id, dt, nil)
+}
+
+// Decrypt4GroupAEAD decrypt a group message with the associated data used for encryption
+func (c *Client) Decrypt4GroupAEAD(id, dt string, ad []byte) (string, error) {
 	err := c.checkToken()
 	if err != nil {
 		return "", err
@@ -282,7 +641,7 @@ func (c *Client) Decrypt4Group(id, dt string) (string, error) {
 		logging.Root.Errorf(errMsgHexConvertFailed, err)
 		return "", err
 	}
-	cs, err := cry.Decrypt(b, dt)
+	cs, err := cry.DecryptAlg(jr.Alg, b, dt, ad)
 	if err != nil {
 		logging.Root.Errorf("reconstruct cipher failed: %v", err)
 		return "", err
@@ -292,6 +651,12 @@ func (c *Client) Decrypt4Group(id, dt string) (string, error) {
 
 // StoreDataSS stores a message for group or client (e.g. json data object)
 func (c *Client) StoreDataSS(n string, p string) (string, error) {
+	return c.StoreDataSSTTL(n, p, 0, false)
+}
+
+// StoreDataSSTTL stores a message for group or client, which expires after the ttl (0 for no expiration).
+// With readOnce the message will be deleted after the first read.
+func (c *Client) StoreDataSSTTL(n string, p string, ttl time.Duration, readOnce bool) (string, error) {
 	err := c.checkToken()
 	if err != nil {
 		return "", err
@@ -302,6 +667,10 @@ func (c *Client) StoreDataSS(n string, p string) (string, error) {
 		Recipient: n,
 		Decrypt:   false,
 		Message:   p,
+		ReadOnce:  readOnce,
+	}
+	if ttl > 0 {
+		m.TTL = ttl.String()
 	}
 	res, err := c.PostJSON("vault/msg", m)
 	if err != nil {
@@ -380,18 +749,181 @@ func (c *Client) Encrypt4Client(n, dt string) (string, error) {
 	return cs, err
 }
 
-// Decrypt4Client encrypting data string for me
+// Decrypt4Client encrypting data string for me, messages for a previous, not retired key are decrypted with that key
 func (c *Client) Decrypt4Client(dt string) (string, error) {
 	err := c.checkToken()
 	if err != nil {
 		return "", err
 	}
 
-	cs, err := cry.DecryptKey(*c.privatekey, dt)
+	cs, err := cry.DecryptPrv(c.privatekey, dt)
+	if err == nil {
+		return cs, nil
+	}
+	if c.previouskeys == nil {
+		perr := c.getPreviousKeys()
+		if perr != nil {
+			return "", perr
+		}
+	}
+	for _, k := range c.previouskeys {
+		cs, perr := cry.DecryptPrv(k, dt)
+		if perr == nil {
+			return cs, nil
+		}
+	}
+	return "", err
+}
+
+// RotateKey rotating the key of this client, an empty key type keeps the key type. The previous key
+// is still valid for the grace period of the service. Returning the kid of the new key.
+func (c *Client) RotateKey(kt string) (string, error) {
+	err := c.checkToken()
 	if err != nil {
 		return "", err
 	}
-	return cs, err
+	res, err := c.PostJSON("vault/clients/key/rotate", struct {
+		KeyType string `json:"keytype"`
+	}{
+		KeyType: kt,
+	})
+	if err != nil {
+		logging.Root.Errorf("rotate key request failed: %v", err)
+		return "", err
+	}
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("rotate key bad response: %d", res.StatusCode)
+		return "", ReadErr(res)
+	}
+	var cl pmodel.Client
+	err = ReadJSON(res, &cl)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return "", err
+	}
+	c.privatekey, err = cry.Pem2Key(cl.Key)
+	if err != nil {
+		return "", err
+	}
+	c.previouskeys = nil
+	return cl.KID, nil
+}
+
+// RotateSecret issuing a new secret for this client, the old secrets are still valid until revoked.
+// exp is the optional validity of the new secret, e.g. 90d. This client uses the new secret for further logins.
+func (c *Client) RotateSecret(exp string) (*pmodel.ClientSecret, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.PostJSON("vault/clients/secrets/rotate", struct {
+		Expires string `json:"expires,omitempty"`
+	}{
+		Expires: exp,
+	})
+	if err != nil {
+		logging.Root.Errorf("rotate secret request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusCreated {
+		logging.Root.Errorf("rotate secret bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var s pmodel.ClientSecret
+	err = ReadJSON(res, &s)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	c.secret = s.Secret
+	return &s, nil
+}
+
+// Secrets listing the active secrets of this client, without the secrets itself
+func (c *Client) Secrets() ([]pmodel.ClientSecret, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Get("vault/clients/secrets")
+	if err != nil {
+		logging.Root.Errorf("secrets request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("secrets bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	ss := make([]pmodel.ClientSecret, 0)
+	err = ReadJSON(res, &ss)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return ss, nil
+}
+
+// RevokeSecret revoking the secret with the id of this client, the last active secret can't be revoked
+func (c *Client) RevokeSecret(id string) error {
+	err := c.checkToken()
+	if err != nil {
+		return err
+	}
+	res, err := c.Delete(fmt.Sprintf("vault/clients/secrets/%s", id))
+	if err != nil {
+		logging.Root.Errorf("revoke secret request failed: %v", err)
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("revoke secret bad response: %d", res.StatusCode)
+		return ReadErr(res)
+	}
+	return nil
+}
+
+// PrivateKeys getting the current and the previous, not retired private keys of this client, the current key first
+func (c *Client) PrivateKeys() ([]pmodel.ClientKey, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Get("login/privatekeys")
+	if err != nil {
+		logging.Root.Errorf(errMsgKeyFailed, err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf(errMsgKeyBadRes, res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	ks := make([]pmodel.ClientKey, 0)
+	err = ReadJSON(res, &ks)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return ks, nil
+}
+
+// getPreviousKeys getting the previous, not retired private keys of this client
+func (c *Client) getPreviousKeys() error {
+	ks, err := c.PrivateKeys()
+	if err != nil {
+		return err
+	}
+	pks := make([]crypto.Signer, 0)
+	for _, k := range ks {
+		if k.Retire.IsZero() {
+			continue
+		}
+		pk, err := cry.Pem2Key(k.Key)
+		if err != nil {
+			return err
+		}
+		pks = append(pks, pk)
+	}
+	c.previouskeys = pks
+	return nil
 }
 
 // HMAC256 building a HMAC256 hash of a string
@@ -401,7 +933,7 @@ func (c *Client) HMAC256(g, dt string) (*pmodel.SignMessage, error) {
 		return nil, err
 	}
 
-	jr, err := c.createKey4Group(g)
+	jr, err := c.createKey4Group(g, "")
 	if err != nil {
 		logging.Root.Errorf("key creation failed: %v", err)
 		return nil, err
@@ -492,6 +1024,36 @@ func (c *Client) Sign(dt string) (*pmodel.SignMessage, error) {
 	return &sm, nil
 }
 
+// SignBatch signing a list of data with the private key on the server side, errors are reported per item in the Error field
+func (c *Client) SignBatch(dts []string) ([]pmodel.SignMessage, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	sms := make([]pmodel.SignMessage, len(dts))
+	for x, dt := range dts {
+		sms[x] = pmodel.SignMessage{
+			Message: dt,
+		}
+	}
+	res, err := c.PostJSON("vault/signature/sign/batch", sms)
+	if err != nil {
+		logging.Root.Errorf("sign batch request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("sign batch bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	jr := make([]pmodel.SignMessage, 0)
+	err = ReadJSON(res, &jr)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return jr, nil
+}
+
 // SignCheck data with the public key
 func (c *Client) SignCheck(n, sig, dt string) (bool, error) {
 	pub, err := c.GetPublicKey(n)
@@ -525,6 +1087,30 @@ func (c *Client) SignCheckSS(smsg pmodel.SignMessage) (bool, error) {
 	return sm.Valid, nil
 }
 
+// SignCheckSSBatch checking a list of signatures on the server side, errors are reported per item in the Error field
+func (c *Client) SignCheckSSBatch(smsgs []pmodel.SignMessage) ([]pmodel.SignMessage, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.PostJSON("vault/signature/check/batch", smsgs)
+	if err != nil {
+		logging.Root.Errorf("check batch request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("check batch bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	jr := make([]pmodel.SignMessage, 0)
+	err = ReadJSON(res, &jr)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return jr, nil
+}
+
 // getPrivateKey getting the private key of this client
 func (c *Client) getPrivateKey() error {
 	err := c.checkToken()
@@ -547,7 +1133,7 @@ func (c *Client) getPrivateKey() error {
 		return err
 	}
 
-	c.privatekey, err = cry.Pem2Prv(string(b))
+	c.privatekey, err = cry.Pem2Key(string(b))
 	if err != nil {
 		return err
 	}
@@ -602,11 +1188,37 @@ func (c *Client) CryptSS(m pmodel.Message) (*pmodel.Message, error) {
 	return &m, nil
 }
 
-func (c *Client) createKey4Group(g string) (*pmodel.EncryptKey, error) {
+// CryptSSBatch doing en/decryption of a list of messages on the server side, errors are reported per item in the Error field
+func (c *Client) CryptSSBatch(ms []pmodel.Message) ([]pmodel.Message, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.PostJSON("vault/groups/crypt/batch", ms)
+	if err != nil {
+		logging.Root.Errorf("crypt batch request failed: %v", err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf("crypt batch bad response: %d", res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	jr := make([]pmodel.Message, 0)
+	err = ReadJSON(res, &jr)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return jr, nil
+}
+
+func (c *Client) createKey4Group(g, alg string) (*pmodel.EncryptKey, error) {
 	jd := struct {
 		Group string `json:"group"`
+		Alg   string `json:"alg,omitempty"`
 	}{
 		Group: g,
+		Alg:   alg,
 	}
 	res, err := c.PostJSON("vault/groups/keys", jd)
 	if err != nil {
@@ -1,17 +1,28 @@
 package client
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"log"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/serror"
+	cry "github.com/willie68/micro-vault/pkg/crypt"
 	"github.com/willie68/micro-vault/pkg/pmodel"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 const (
@@ -67,6 +78,68 @@ func TestCertificate(t *testing.T) {
 	ast.Equal("Organisation", crt.Subject.Organization[0])
 }
 
+func TestCertificateChain(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+
+	defer cli.Logout()
+
+	csr, err := createCsrPem()
+	ast.Nil(err)
+
+	crts, err := cli.CreateCertificateChain(*csr)
+	ast.Nil(err)
+	ast.Equal(2, len(crts))
+	ast.False(crts[0].IsCA)
+	ast.True(crts[1].IsCA)
+	ast.Nil(crts[0].CheckSignatureFrom(crts[1]))
+
+	chain, err := adm.GetCAChain()
+	ast.Nil(err)
+	p, _ := pem.Decode([]byte(chain))
+	ast.NotNil(p)
+	ast.Equal(crts[1].Raw, p.Bytes)
+}
+
+func TestKeyCertificate(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+
+	defer cli.Logout()
+
+	csr, err := createCsrPem()
+	ast.Nil(err)
+	csr.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+
+	// the own key of the caller
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ast.Nil(err)
+	crts, err := cli.CreateKeyCertificate(*csr, pk, "")
+	ast.Nil(err)
+	ast.Equal(2, len(crts))
+	ast.True(pk.PublicKey.Equal(crts[0].PublicKey))
+
+	// a key generated by the service
+	b, err := cli.CreateCertificateBundle(*csr, pmodel.CertBundleRequest{Format: "pkcs12", Password: "geheim"})
+	ast.Nil(err)
+	key, crt, ca, err := pkcs12.DecodeChain(b, "geheim")
+	ast.Nil(err)
+	ek, ok := key.(*ecdsa.PrivateKey)
+	ast.True(ok)
+	ast.True(ek.PublicKey.Equal(crt.PublicKey))
+	ast.Equal(1, len(ca))
+	ast.Equal(crts[1].Raw, ca[0].Raw)
+
+	_, err = cli.CreateCertificateBundle(*csr, pmodel.CertBundleRequest{Format: "pkcs12"})
+	ast.NotNil(err)
+}
+
 func createCsrPem() (*x509.CertificateRequest, error) {
 	emailAddress := "info@wk-music.de"
 	subj := pkix.Name{
@@ -191,6 +264,188 @@ func TestEncryptGroup4(t *testing.T) {
 	t.Logf("encrypt group 4 text: %s", text)
 }
 
+func TestEncryptGroupAEAD(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	cli2, err := LoginClient("87654321", clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli2)
+	defer cli2.Logout()
+
+	orgtxt := "this is an authenticated message"
+	ad := []byte("order-4711")
+	for _, alg := range []string{"AES-256-GCM", "XCHACHA20-POLY1305"} {
+		b, id, err := cli.Encrypt4GroupAEAD("group4", alg, orgtxt, ad)
+		ast.Nil(err)
+		ast.NotEmpty(id)
+
+		text, err := cli2.Decrypt4GroupAEAD(id, b, ad)
+		ast.Nil(err)
+		ast.Equal(orgtxt, text)
+
+		_, err = cli2.Decrypt4GroupAEAD(id, b, []byte("order-4712"))
+		ast.NotNil(err)
+	}
+
+	_, _, err = cli.Encrypt4GroupAEAD("group4", "DES", orgtxt, nil)
+	ast.NotNil(err)
+}
+
+func TestDataKey(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	cli2, err := LoginClient("87654321", clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli2)
+	defer cli2.Logout()
+
+	dk, wk, err := cli.GenerateDataKey("group4")
+	ast.Nil(err)
+	ast.Len(dk, 32)
+	ast.NotEmpty(wk)
+
+	// encrypt local with the data key
+	ct, err := cry.EncryptAEAD(cry.AlgAES256GCM, dk, "local", "a large file", nil)
+	ast.Nil(err)
+
+	udk, err := cli2.UnwrapDataKey(wk)
+	ast.Nil(err)
+	ast.Equal(dk, udk)
+
+	pt, err := cry.DecryptAEAD(cry.AlgAES256GCM, udk, ct, nil)
+	ast.Nil(err)
+	ast.Equal("a large file", pt)
+
+	_, _, err = cli.GenerateDataKey("group3")
+	ast.NotNil(err)
+}
+
+func TestRewrap(t *testing.T) {
+	initCl()
+	initAdm()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	orgtxt := "this message will be rewrapped"
+	ct, id, err := cli.Encrypt4Group("group4", orgtxt)
+	ast.Nil(err)
+
+	ek, err := adm.RotateGroupKey("group4")
+	ast.Nil(err)
+
+	nct, nid, err := cli.Rewrap(id, "", ct)
+	ast.Nil(err)
+	ast.Equal(ek.ID, nid)
+
+	text, err := cli.Decrypt4Group(nid, nct)
+	ast.Nil(err)
+	ast.Equal(orgtxt, text)
+
+	res, err := cli.RewrapBatch([]pmodel.RewrapMessage{
+		{ID: id, Message: ct},
+		{ID: "unknown", Message: ct},
+	})
+	ast.Nil(err)
+	ast.Len(res, 2)
+	ast.Empty(res[0].Error)
+	ast.Equal(ek.ID, res[0].ID)
+	ast.NotEmpty(res[1].Error)
+}
+
+func TestBatch(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	orgtxt := "this message will be encrypted in a batch"
+	ms, err := cli.CryptSSBatch([]pmodel.Message{
+		{Type: "group", Recipient: "group4", Message: orgtxt},
+		{Type: "group", Recipient: "group4", Message: orgtxt},
+		{Type: "group", ID: "unknown", Message: orgtxt, Decrypt: true},
+	})
+	ast.Nil(err)
+	ast.Len(ms, 3)
+	ast.Empty(ms[0].Error)
+	ast.Equal(ms[0].ID, ms[1].ID)
+	ast.NotEmpty(ms[2].Error)
+
+	text, err := cli.Decrypt4Group(ms[1].ID, ms[1].Message)
+	ast.Nil(err)
+	ast.Equal(orgtxt, text)
+
+	sms, err := cli.SignBatch([]string{"message 1", "message 2"})
+	ast.Nil(err)
+	ast.Len(sms, 2)
+	ast.Empty(sms[0].Error)
+	ast.NotEmpty(sms[1].Signature)
+
+	cms, err := cli.SignCheckSSBatch(sms)
+	ast.Nil(err)
+	ast.Len(cms, 2)
+	ast.True(cms[0].Valid)
+	ast.True(cms[1].Valid)
+}
+
+func TestStream(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	pt := make([]byte, 300000)
+	_, err = rand.Read(pt)
+	ast.Nil(err)
+
+	// server side encryption, client side decryption
+	var ct bytes.Buffer
+	id, err := cli.EncryptStream4Group("group4", "", bytes.NewReader(pt), &ct)
+	ast.Nil(err)
+	ast.NotEmpty(id)
+
+	dr, err := cli.NewDecryptReader4Group(bytes.NewReader(ct.Bytes()))
+	ast.Nil(err)
+	res, err := io.ReadAll(dr)
+	ast.Nil(err)
+	ast.True(bytes.Equal(pt, res))
+
+	// client side encryption, server side decryption
+	var ct2 bytes.Buffer
+	ew, id2, err := cli.NewEncryptWriter4Group("group4", cry.AlgXChaCha20Poly1305, &ct2)
+	ast.Nil(err)
+	ast.NotEmpty(id2)
+	_, err = io.Copy(ew, bytes.NewReader(pt))
+	ast.Nil(err)
+	ast.Nil(ew.Close())
+
+	var res2 bytes.Buffer
+	err = cli.DecryptStream4Group(bytes.NewReader(ct2.Bytes()), &res2)
+	ast.Nil(err)
+	ast.True(bytes.Equal(pt, res2.Bytes()))
+
+	// truncated stream
+	var res3 bytes.Buffer
+	err = cli.DecryptStream4Group(bytes.NewReader(ct2.Bytes()[:ct2.Len()-100]), &res3)
+	ast.NotNil(err)
+}
+
 func TestEncryptClient(t *testing.T) {
 	initCl()
 	ast := assert.New(t)
@@ -229,6 +484,218 @@ func TestEncryptClient(t *testing.T) {
 	t.Logf("encrypt client text: %s", text)
 }
 
+func TestKeyTypeClient(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	for _, kt := range []string{cry.KeyTypeECDSAP384, cry.KeyTypeEd25519} {
+		_ = adm.DeleteClient("tester-kt")
+		cl, err := adm.NewClientWithKeyType("tester-kt", []string{"group2"}, kt)
+		ast.Nil(err)
+		ast.Equal(kt, cl.KeyType)
+
+		cli2, err := LoginClient(cl.AccessKey, cl.Secret, localURL)
+		ast.Nil(err)
+
+		// encryption for the client
+		ct, err := cli.Encrypt4Client("tester-kt", "sehrGeheim")
+		ast.Nil(err)
+		pt, err := cli2.Decrypt4Client(ct)
+		ast.Nil(err)
+		ast.Equal("sehrGeheim", pt)
+
+		// server side signature of the client
+		msg, err := cli2.Sign("Dies ist eine Message")
+		ast.Nil(err)
+		ok, err := cli.SignCheck("tester-kt", msg.Signature, msg.Message)
+		ast.Nil(err)
+		ast.True(ok)
+		ok, err = cli.SignCheckSS(*msg)
+		ast.Nil(err)
+		ast.True(ok)
+
+		cli2.Logout()
+	}
+	ast.Nil(adm.DeleteClient("tester-kt"))
+}
+
+func TestRotateClientKey(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	_ = adm.DeleteClient("tester-rotate")
+	cl, err := adm.NewClientWithKeyType("tester-rotate", []string{"group2"}, cry.KeyTypeECDSAP256)
+	ast.Nil(err)
+	cli2, err := LoginClient(cl.AccessKey, cl.Secret, localURL)
+	ast.Nil(err)
+	defer cli2.Logout()
+
+	// message and signature of the old key
+	ct, err := cli.Encrypt4Client("tester-rotate", "sehrGeheim")
+	ast.Nil(err)
+	omsg, err := cli2.Sign("Dies ist eine Message")
+	ast.Nil(err)
+
+	kid, err := cli2.RotateKey("")
+	ast.Nil(err)
+	ast.NotEqual(cl.KID, kid)
+	pk, err := cli2.PrivateKey()
+	ast.Nil(err)
+	pkid, err := cry.GetKID(pk)
+	ast.Nil(err)
+	ast.Equal(kid, pkid)
+
+	ks, err := cli2.PrivateKeys()
+	ast.Nil(err)
+	ast.Len(ks, 2)
+	ast.Equal(kid, ks[0].KID)
+	ast.Equal(cl.KID, ks[1].KID)
+
+	// older messages and signatures are still valid
+	pt, err := cli2.Decrypt4Client(ct)
+	ast.Nil(err)
+	ast.Equal("sehrGeheim", pt)
+	ok, err := cli.SignCheckSS(*omsg)
+	ast.Nil(err)
+	ast.True(ok)
+
+	// new messages and signatures are of the new key
+	msg, err := cli2.Sign("Dies ist eine Message")
+	ast.Nil(err)
+	ast.Equal(kid, msg.KeyInfo.KID)
+	ok, err = cli.SignCheck("tester-rotate", msg.Signature, msg.Message)
+	ast.Nil(err)
+	ast.True(ok)
+	ct, err = cli.Encrypt4Client("tester-rotate", "sehrGeheim")
+	ast.Nil(err)
+	pt, err = cry.DecryptPrv(pk, ct)
+	ast.Nil(err)
+	ast.Equal("sehrGeheim", pt)
+
+	// rotation by the admin
+	rcl, err := adm.RotateClientKey("tester-rotate", cry.KeyTypeEd25519)
+	ast.Nil(err)
+	ast.Equal(cry.KeyTypeEd25519, rcl.KeyType)
+	ast.NotEqual(kid, rcl.KID)
+
+	ast.Nil(adm.DeleteClient("tester-rotate"))
+}
+
+func TestClientSecretRotation(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+
+	_ = adm.DeleteClient("tester-secret")
+	cl, err := adm.NewClient("tester-secret", []string{"group2"})
+	ast.Nil(err)
+	cli, err := LoginClient(cl.AccessKey, cl.Secret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	// self service rotation, the client uses the new secret
+	s, err := cli.RotateSecret("")
+	ast.Nil(err)
+	ast.NotEmpty(s.Secret)
+	ast.Nil(s.Expires)
+	ast.Nil(cli.Login())
+	ss, err := cli.Secrets()
+	ast.Nil(err)
+	ast.Len(ss, 2)
+	ast.Nil(cli.RevokeSecret("primary"))
+	_, err = LoginClient(cl.AccessKey, cl.Secret, localURL)
+	ast.NotNil(err)
+	ast.NotNil(cli.RevokeSecret(s.ID))
+
+	// admin rotation
+	s2, err := adm.RotateClientSecret("tester-secret", "1d")
+	ast.Nil(err)
+	ast.NotNil(s2.Expires)
+	cli2, err := LoginClient(cl.AccessKey, s2.Secret, localURL)
+	ast.Nil(err)
+	cli2.Logout()
+	ss, err = adm.ClientSecrets("tester-secret")
+	ast.Nil(err)
+	ast.Len(ss, 2)
+	ast.Nil(adm.RevokeClientSecret("tester-secret", s2.ID))
+	_, err = LoginClient(cl.AccessKey, s2.Secret, localURL)
+	ast.NotNil(err)
+
+	ast.Nil(adm.DeleteClient("tester-secret"))
+}
+
+func TestClientState(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+
+	_ = adm.DeleteClient("tester-state")
+	cl, err := adm.NewClient("tester-state", []string{"group2"})
+	ast.Nil(err)
+	cli, err := LoginClient(cl.AccessKey, cl.Secret, localURL)
+	ast.Nil(err)
+	defer cli.Logout()
+
+	// a disabled client can't use its token or login
+	cs, err := adm.SetClientState("tester-state", pmodel.ClientState{Disabled: true})
+	ast.Nil(err)
+	ast.True(cs.Disabled)
+	_, err = cli.Sign("Dies ist eine Message")
+	ast.NotNil(err)
+	_, err = LoginClient(cl.AccessKey, cl.Secret, localURL)
+	ast.NotNil(err)
+
+	// enabled with an expiration
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err = adm.SetClientState("tester-state", pmodel.ClientState{ExpiresAt: &exp})
+	ast.Nil(err)
+	c, err := adm.Client("tester-state")
+	ast.Nil(err)
+	ast.False(c.Disabled)
+	ast.True(exp.Equal(*c.ExpiresAt))
+	_, err = cli.Sign("Dies ist eine Message")
+	ast.Nil(err)
+
+	ast.Nil(adm.DeleteClient("tester-state"))
+}
+
+func TestRevokeIntrospect(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+
+	gw, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	defer gw.Logout()
+
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	tk := cli.Token()
+	rt := cli.RefreshToken()
+
+	ti, err := gw.Introspect(tk)
+	ast.Nil(err)
+	ast.True(ti.Active)
+	ast.Equal(clAccess, ti.ClientID)
+
+	ti, err = gw.Introspect(adm.Token())
+	ast.Nil(err)
+	ast.True(ti.Active)
+	ast.Contains(ti.Roles, "mv-admin")
+
+	// after the logout both tokens are revoked
+	cli.Logout()
+	ti, err = gw.Introspect(tk)
+	ast.Nil(err)
+	ast.False(ti.Active)
+	ti, err = gw.Introspect(rt)
+	ast.Nil(err)
+	ast.False(ti.Active)
+}
+
 func TestHMAC(t *testing.T) {
 	initCl()
 	ast := assert.New(t)
@@ -265,6 +732,47 @@ func TestHMAC(t *testing.T) {
 	ast.True(ok)
 }
 
+func TestHMACDisabledKey(t *testing.T) {
+	initCl()
+	initAdm()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	msg, err := cli.HMAC256("group2", "some data to sign")
+	ast.Nil(err)
+
+	ek, err := adm.SetKeyState(msg.KeyInfo.KID, "disabled")
+	ast.Nil(err)
+	ast.Equal("disabled", ek.State)
+
+	ok, err := cli.HMAC256Verify(*msg)
+	ast.NotNil(err)
+	ast.False(ok)
+
+	ek, err = adm.DeleteKey(msg.KeyInfo.KID)
+	ast.Nil(err)
+	ast.Equal("scheduled-for-destruction", ek.State)
+	ast.NotNil(ek.DestroyAt)
+
+	ks, err := adm.Keys(WithGroupFilter("group2"))
+	ast.Nil(err)
+	found := false
+	for _, k := range ks {
+		if k.ID == msg.KeyInfo.KID {
+			found = true
+			ast.Equal("scheduled-for-destruction", k.State)
+		}
+	}
+	ast.True(found)
+
+	ok, err = cli.HMAC256Verify(*msg)
+	ast.NotNil(err)
+	ast.False(ok)
+}
+
 func TestSigning(t *testing.T) {
 	initCl()
 	ast := assert.New(t)
@@ -422,3 +930,36 @@ func TestSSStore(t *testing.T) {
 	ast.NotNil(err)
 	ast.Empty(p)
 }
+
+func TestStoreDataTTL(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	payload := "one time password"
+
+	id, err := cli.StoreDataSSTTL("group2", payload, time.Hour, true)
+	ast.Nil(err)
+	ast.NotEmpty(id)
+
+	p, err := cli.GetDataSS(id)
+	ast.Nil(err)
+	ast.Equal(payload, p)
+
+	// burned after reading
+	_, err = cli.GetDataSS(id)
+	ast.NotNil(err)
+
+	id, err = cli.StoreDataSSTTL("group2", payload, 10*time.Millisecond, false)
+	ast.Nil(err)
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = cli.GetDataSS(id)
+	ast.NotNil(err)
+	serr, ok := err.(*serror.Serr)
+	ast.True(ok)
+	ast.Equal(http.StatusGone, serr.Code)
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/willie68/micro-vault/internal/logging"
+	"github.com/willie68/micro-vault/pkg/pmodel"
+)
+
+const (
+	errMsgKVFailed = "kv request failed: %v"
+	errMsgKVBadRes = "kv bad response: %d"
+)
+
+// KVPut writes a new version of the secret of the group
+func (c *Client) KVPut(g, p, v string) (*pmodel.KVMetadata, error) {
+	return c.kvPut(g, p, pmodel.KVWrite{Value: v})
+}
+
+// KVPutCAS writes a new version of the secret of the group, only if cas is the current version (0 for a new secret)
+func (c *Client) KVPutCAS(g, p, v string, cas int) (*pmodel.KVMetadata, error) {
+	return c.kvPut(g, p, pmodel.KVWrite{Value: v, CAS: &cas})
+}
+
+func (c *Client) kvPut(g, p string, kw pmodel.KVWrite) (*pmodel.KVMetadata, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.PostJSON(kvEndpoint("kv", g, p), kw)
+	if err != nil {
+		logging.Root.Errorf(errMsgKVFailed, err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf(errMsgKVBadRes, res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var md pmodel.KVMetadata
+	err = ReadJSON(res, &md)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return &md, nil
+}
+
+// KVGet reads a version of the secret of the group, version 0 is the current version
+func (c *Client) KVGet(g, p string, v int) (*pmodel.KVSecret, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	ep := kvEndpoint("kv", g, p)
+	if v > 0 {
+		ep = fmt.Sprintf("%s?version=%d", ep, v)
+	}
+	res, err := c.Get(ep)
+	if err != nil {
+		logging.Root.Errorf(errMsgKVFailed, err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf(errMsgKVBadRes, res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var kv pmodel.KVSecret
+	err = ReadJSON(res, &kv)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return &kv, nil
+}
+
+// KVList lists the keys of the group below the prefix, sub folders are ending with a /
+func (c *Client) KVList(g, prefix string) ([]string, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	ep := kvEndpoint("kv", g, prefix)
+	if !strings.HasSuffix(ep, "/") {
+		ep += "/"
+	}
+	res, err := c.Get(ep)
+	if err != nil {
+		logging.Root.Errorf(errMsgKVFailed, err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf(errMsgKVBadRes, res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	ks := make([]string, 0)
+	err = ReadJSON(res, &ks)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return ks, nil
+}
+
+// KVDelete soft deletes versions of the secret, without versions the current version is deleted
+func (c *Client) KVDelete(g, p string, vs ...int) error {
+	err := c.checkToken()
+	if err != nil {
+		return err
+	}
+	ep := kvEndpoint("kv", g, p)
+	if len(vs) > 0 {
+		ss := make([]string, len(vs))
+		for x, v := range vs {
+			ss[x] = strconv.Itoa(v)
+		}
+		ep = fmt.Sprintf("%s?versions=%s", ep, strings.Join(ss, ","))
+	}
+	return c.kvStatus(c.Delete(ep))
+}
+
+// KVUndelete restores soft deleted versions of the secret
+func (c *Client) KVUndelete(g, p string, vs ...int) error {
+	err := c.checkToken()
+	if err != nil {
+		return err
+	}
+	return c.kvStatus(c.PostJSON(kvEndpoint("kvundelete", g, p), pmodel.KVVersions{Versions: vs}))
+}
+
+// KVMetadata reads the metadata of the secret with all versions
+func (c *Client) KVMetadata(g, p string) (*pmodel.KVMetadata, error) {
+	err := c.checkToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Get(kvEndpoint("kvmeta", g, p))
+	if err != nil {
+		logging.Root.Errorf(errMsgKVFailed, err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf(errMsgKVBadRes, res.StatusCode)
+		return nil, ReadErr(res)
+	}
+	var md pmodel.KVMetadata
+	err = ReadJSON(res, &md)
+	if err != nil {
+		logging.Root.Errorf(errMsgJSONFailed, err)
+		return nil, err
+	}
+	return &md, nil
+}
+
+// KVDestroy removes the secret with all versions and the metadata permanently
+func (c *Client) KVDestroy(g, p string) error {
+	err := c.checkToken()
+	if err != nil {
+		return err
+	}
+	return c.kvStatus(c.Delete(kvEndpoint("kvmeta", g, p)))
+}
+
+func (c *Client) kvStatus(res *http.Response, err error) error {
+	if err != nil {
+		logging.Root.Errorf(errMsgKVFailed, err)
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		logging.Root.Errorf(errMsgKVBadRes, res.StatusCode)
+		return ReadErr(res)
+	}
+	return nil
+}
+
+// kvEndpoint builds the endpoint of the secret, escaping every path segment
+func kvEndpoint(e, g, p string) string {
+	ss := strings.Split(p, "/")
+	for x, s := range ss {
+		ss[x] = url.PathEscape(s)
+	}
+	return fmt.Sprintf("vault/%s/%s/%s", e, url.PathEscape(g), strings.Join(ss, "/"))
+}
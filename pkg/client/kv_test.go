@@ -0,0 +1,73 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/internal/serror"
+)
+
+func TestKV(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	cli, err := LoginClient(clAccess, clSecret, localURL)
+	ast.Nil(err)
+	ast.NotNil(cli)
+	defer cli.Logout()
+
+	md, err := cli.KVPut("group1", "app/db password", "secret1")
+	ast.Nil(err)
+	ast.Equal(1, md.CurrentVersion)
+
+	_, err = cli.KVPutCAS("group1", "app/db password", "secret2", 0)
+	ast.NotNil(err)
+	serr, ok := err.(*serror.Serr)
+	ast.True(ok)
+	ast.Equal(http.StatusConflict, serr.Code)
+
+	md, err = cli.KVPutCAS("group1", "app/db password", "secret2", 1)
+	ast.Nil(err)
+	ast.Equal(2, md.CurrentVersion)
+
+	kv, err := cli.KVGet("group1", "app/db password", 0)
+	ast.Nil(err)
+	ast.Equal("secret2", kv.Value)
+
+	kv, err = cli.KVGet("group1", "app/db password", 1)
+	ast.Nil(err)
+	ast.Equal("secret1", kv.Value)
+
+	ks, err := cli.KVList("group1", "app")
+	ast.Nil(err)
+	ast.Contains(ks, "db password")
+
+	ks, err = cli.KVList("group1", "")
+	ast.Nil(err)
+	ast.Contains(ks, "app/")
+
+	err = cli.KVDelete("group1", "app/db password", 1, 2)
+	ast.Nil(err)
+	_, err = cli.KVGet("group1", "app/db password", 0)
+	ast.NotNil(err)
+
+	err = cli.KVUndelete("group1", "app/db password", 2)
+	ast.Nil(err)
+	kv, err = cli.KVGet("group1", "app/db password", 0)
+	ast.Nil(err)
+	ast.Equal("secret2", kv.Value)
+
+	md, err = cli.KVMetadata("group1", "app/db password")
+	ast.Nil(err)
+	ast.Len(md.Versions, 2)
+	ast.NotNil(md.Versions[0].Deleted)
+	ast.Nil(md.Versions[1].Deleted)
+
+	err = cli.KVDestroy("group1", "app/db password")
+	ast.Nil(err)
+	_, err = cli.KVMetadata("group1", "app/db password")
+	ast.NotNil(err)
+
+	_, err = cli.KVGet("group3", "app/db password", 0)
+	ast.NotNil(err)
+}
@@ -1,10 +1,16 @@
 package client
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/willie68/micro-vault/pkg/pmodel"
 )
 
 const (
@@ -97,3 +103,69 @@ func TestAdminBuilder(t *testing.T) {
 	ast.NotNil(adm)
 	adm.Logout()
 }
+
+func TestClientCredentialsGrant(t *testing.T) {
+	initCl()
+	ast := assert.New(t)
+	hc := &http.Client{
+		Transport: &http.Transport{
+			// #nosec G402 -- test server with a self signed certificate
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	tokenRequest := func(fd url.Values, basic bool) (*http.Response, map[string]any) {
+		req, err := http.NewRequest(http.MethodPost, localURL+"/api/v1/login", strings.NewReader(fd.Encode()))
+		ast.Nil(err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basic {
+			req.SetBasicAuth(clAccess, clSecret)
+		}
+		res, err := hc.Do(req)
+		ast.Nil(err)
+		defer res.Body.Close()
+		var js map[string]any
+		ast.Nil(json.NewDecoder(res.Body).Decode(&js))
+		return res, js
+	}
+
+	// credentials as basic authentication
+	res, js := tokenRequest(url.Values{"grant_type": {"client_credentials"}}, true)
+	ast.Equal(http.StatusOK, res.StatusCode)
+	ast.Equal("no-store", res.Header.Get("Cache-Control"))
+	ast.Equal("Bearer", js["token_type"])
+	ast.NotEmpty(js["access_token"])
+	ast.Nil(js["key"])
+
+	// credentials in the body
+	res, js = tokenRequest(url.Values{"grant_type": {"client_credentials"}, "client_id": {clAccess}, "client_secret": {clSecret}}, false)
+	ast.Equal(http.StatusOK, res.StatusCode)
+	rt, ok := js["refresh_token"].(string)
+	ast.True(ok)
+
+	res, js = tokenRequest(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {rt}}, false)
+	ast.Equal(http.StatusOK, res.StatusCode)
+	ast.NotEmpty(js["access_token"])
+
+	// a used refresh token is revoked
+	res, js = tokenRequest(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {rt}}, false)
+	ast.Equal(http.StatusBadRequest, res.StatusCode)
+	ast.Equal("invalid_grant", js["error"])
+
+	res, js = tokenRequest(url.Values{"grant_type": {"client_credentials"}, "client_id": {clAccess}, "client_secret": {"0011"}}, false)
+	ast.Equal(http.StatusUnauthorized, res.StatusCode)
+	ast.Equal("invalid_client", js["error"])
+
+	res, js = tokenRequest(url.Values{"grant_type": {"authorization_code"}}, true)
+	ast.Equal(http.StatusBadRequest, res.StatusCode)
+	ast.Equal("unsupported_grant_type", js["error"])
+
+	res, err := hc.Get(localURL + "/.well-known/oauth-authorization-server")
+	ast.Nil(err)
+	defer res.Body.Close()
+	ast.Equal(http.StatusOK, res.StatusCode)
+	var md pmodel.AuthServerMetadata
+	ast.Nil(json.NewDecoder(res.Body).Decode(&md))
+	ast.True(strings.HasSuffix(md.TokenEndpoint, "/api/v1/login"))
+	ast.Contains(md.GrantTypesSupported, "client_credentials")
+	ast.Contains(md.TokenEndpointAuthMethods, "client_secret_basic")
+}
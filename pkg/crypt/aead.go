@@ -0,0 +1,189 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithms of the symmetric encryption keys
+const (
+	// AlgAES256 legacy AES-256 in CFB mode, not authenticated
+	AlgAES256 = "AES-256"
+	// AlgAES256GCM AES-256 in GCM mode
+	AlgAES256GCM = "AES-256-GCM"
+	// AlgXChaCha20Poly1305 XChaCha20-Poly1305 with extended nonce
+	AlgXChaCha20Poly1305 = "XCHACHA20-POLY1305"
+)
+
+// header of an aead ciphertext: mv1:<alg>:<kid>:<base64 of nonce and sealed data>
+// The header is part of the authenticated data, so alg and kid can't be changed.
+// As ':' is not part of the base64 alphabet, legacy CFB ciphertexts can't be mistaken for aead ciphertexts.
+const (
+	headerVersion = "mv1"
+	headerSep     = ":"
+	hdrAES256GCM  = "A256GCM"
+	hdrXChaCha20  = "XC20P"
+)
+
+var (
+	// ErrUnknownAlg the algorithm is not supported
+	ErrUnknownAlg = errors.New("unknown encryption algorithm")
+	// ErrCiphertextNotValid the ciphertext header is not valid
+	ErrCiphertextNotValid = errors.New("ciphertext not valid")
+	// ErrLegacyAlg the legacy AES-256 is only used for decrypting old ciphertexts
+	ErrLegacyAlg = errors.New("AES-256 (CFB) is only supported for decrypting old ciphertexts, rotate the key")
+	// ErrAlgMismatch the algorithm of the ciphertext header is not the algorithm of the key
+	ErrAlgMismatch = errors.New("ciphertext algorithm doesn't match the key")
+)
+
+// ValidAlg checking if the algorithm is supported for symmetric encryption
+func ValidAlg(alg string) bool {
+	switch alg {
+	case AlgAES256, AlgAES256GCM, AlgXChaCha20Poly1305:
+		return true
+	}
+	return false
+}
+
+// IsAEAD checking if the algorithm is an authenticated encryption
+func IsAEAD(alg string) bool {
+	return alg == AlgAES256GCM || alg == AlgXChaCha20Poly1305
+}
+
+// EncryptAlg encrypting the text with the given algorithm. The legacy AES-256
+// algorithm is not authenticated and only used for decrypting old ciphertexts, see DecryptAlg.
+func EncryptAlg(alg string, key []byte, kid, text string, ad []byte) (string, error) {
+	if alg == AlgAES256 || alg == "" {
+		return "", ErrLegacyAlg
+	}
+	return EncryptAEAD(alg, key, kid, text, ad)
+}
+
+// EncryptAEAD encrypting the text with an authenticated encryption and optional associated data.
+// The result is a header with algorithm and key id followed by the base64 encoded nonce and sealed data.
+func EncryptAEAD(alg string, key []byte, kid, text string, ad []byte) (string, error) {
+	if strings.Contains(kid, headerSep) {
+		return "", fmt.Errorf("kid must not contain %q", headerSep)
+	}
+	aead, hdr, err := newAEAD(alg, key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(text)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	prefix := strings.Join([]string{headerVersion, hdr, kid}, headerSep) + headerSep
+	sealed := aead.Seal(nonce, nonce, []byte(text), authData(prefix, ad))
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptAlg decrypting the text with the algorithm of the key, the counterpart of EncryptAlg.
+// Only the legacy AES-256 algorithm decrypts ciphertexts without a header.
+func DecryptAlg(alg string, key []byte, cryptoText string, ad []byte) (string, error) {
+	if alg == AlgAES256 || alg == "" {
+		if len(ad) > 0 {
+			return "", fmt.Errorf("associated data not supported by %s", AlgAES256)
+		}
+		return Decrypt(key, cryptoText)
+	}
+	return DecryptAEAD(alg, key, cryptoText, ad)
+}
+
+// DecryptAEAD decrypting an aead ciphertext of the expected algorithm with optional associated data.
+// Ciphertexts without a header or with another algorithm in the header are rejected.
+func DecryptAEAD(alg string, key []byte, cryptoText string, ad []byte) (string, error) {
+	if !IsAEAD(alg) {
+		return "", ErrUnknownAlg
+	}
+	if !HasHeader(cryptoText) {
+		return "", ErrCiphertextNotValid
+	}
+	parts := strings.SplitN(cryptoText, headerSep, 4)
+	if len(parts) != 4 {
+		return "", ErrCiphertextNotValid
+	}
+	ha, err := hdr2Alg(parts[1])
+	if err != nil {
+		return "", err
+	}
+	if ha != alg {
+		return "", ErrAlgMismatch
+	}
+	aead, _, err := newAEAD(alg, key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", errors.New("ciphertext too short")
+	}
+	prefix := cryptoText[:len(cryptoText)-len(parts[3])]
+	nonce := sealed[:aead.NonceSize()]
+	pt, err := aead.Open(nil, nonce, sealed[aead.NonceSize():], authData(prefix, ad))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// HasHeader checking if the ciphertext has an aead header
+func HasHeader(cryptoText string) bool {
+	return strings.HasPrefix(cryptoText, headerVersion+headerSep)
+}
+
+// ParseHeader returning the algorithm and the key id of an aead ciphertext
+func ParseHeader(cryptoText string) (alg, kid string, err error) {
+	if !HasHeader(cryptoText) {
+		return "", "", ErrCiphertextNotValid
+	}
+	parts := strings.SplitN(cryptoText, headerSep, 4)
+	if len(parts) != 4 {
+		return "", "", ErrCiphertextNotValid
+	}
+	alg, err = hdr2Alg(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	return alg, parts[2], nil
+}
+
+func newAEAD(alg string, key []byte) (cipher.AEAD, string, error) {
+	switch alg {
+	case AlgAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, "", err
+		}
+		aead, err := cipher.NewGCM(block)
+		return aead, hdrAES256GCM, err
+	case AlgXChaCha20Poly1305:
+		aead, err := chacha20poly1305.NewX(key)
+		return aead, hdrXChaCha20, err
+	}
+	return nil, "", ErrUnknownAlg
+}
+
+func hdr2Alg(h string) (string, error) {
+	switch h {
+	case hdrAES256GCM:
+		return AlgAES256GCM, nil
+	case hdrXChaCha20:
+		return AlgXChaCha20Poly1305, nil
+	}
+	return "", ErrUnknownAlg
+}
+
+func authData(prefix string, ad []byte) []byte {
+	return append([]byte(prefix), ad...)
+}
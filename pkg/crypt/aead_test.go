@@ -0,0 +1,130 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func aeadKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	assert.Nil(t, err)
+	return key
+}
+
+func TestAEAD(t *testing.T) {
+	for _, alg := range []string{AlgAES256GCM, AlgXChaCha20Poly1305} {
+		t.Run(alg, func(t *testing.T) {
+			ast := assert.New(t)
+			key := aeadKey(t)
+			ad := []byte("associated data")
+
+			ct, err := EncryptAEAD(alg, key, "kid1234", encryptMsg, ad)
+			ast.Nil(err)
+			ast.True(HasHeader(ct))
+
+			a, kid, err := ParseHeader(ct)
+			ast.Nil(err)
+			ast.Equal(alg, a)
+			ast.Equal("kid1234", kid)
+
+			pt, err := DecryptAEAD(alg, key, ct, ad)
+			ast.Nil(err)
+			ast.Equal(encryptMsg, pt)
+
+			// wrong associated data
+			_, err = DecryptAEAD(alg, key, ct, []byte("other data"))
+			ast.NotNil(err)
+
+			ct, err = EncryptAlg(alg, key, "kid1234", encryptMsg, nil)
+			ast.Nil(err)
+			pt, err = DecryptAlg(alg, key, ct, nil)
+			ast.Nil(err)
+			ast.Equal(encryptMsg, pt)
+
+			// aead ciphertexts are never decrypted with the legacy algorithm
+			_, err = Decrypt(key, ct)
+			ast.Equal(ErrCiphertextNotValid, err)
+		})
+	}
+}
+
+func TestAEADStrippedHeader(t *testing.T) {
+	for _, alg := range []string{AlgAES256GCM, AlgXChaCha20Poly1305} {
+		t.Run(alg, func(t *testing.T) {
+			ast := assert.New(t)
+			key := aeadKey(t)
+
+			ct, err := EncryptAEAD(alg, key, "kid1234", encryptMsg, nil)
+			ast.Nil(err)
+
+			// the body without header must not fall back to the unauthenticated legacy algorithm
+			body := ct[strings.LastIndex(ct, headerSep)+1:]
+			_, err = DecryptAlg(alg, key, body, nil)
+			ast.Equal(ErrCiphertextNotValid, err)
+			_, err = DecryptAEAD(alg, key, body, nil)
+			ast.Equal(ErrCiphertextNotValid, err)
+		})
+	}
+}
+
+func TestAEADTampered(t *testing.T) {
+	ast := assert.New(t)
+	key := aeadKey(t)
+
+	ct, err := EncryptAEAD(AlgAES256GCM, key, "kid1234", encryptMsg, nil)
+	ast.Nil(err)
+
+	// changed key id in the header
+	_, err = DecryptAEAD(AlgAES256GCM, key, strings.Replace(ct, "kid1234", "kid4321", 1), nil)
+	ast.NotNil(err)
+
+	// changed algorithm in the header
+	_, err = DecryptAEAD(AlgAES256GCM, key, strings.Replace(ct, hdrAES256GCM, hdrXChaCha20, 1), nil)
+	ast.Equal(ErrAlgMismatch, err)
+
+	// another algorithm than the one of the key
+	_, err = DecryptAlg(AlgXChaCha20Poly1305, key, ct, nil)
+	ast.Equal(ErrAlgMismatch, err)
+	_, err = DecryptAlg(AlgAES256, key, ct, nil)
+	ast.Equal(ErrCiphertextNotValid, err)
+
+	// changed payload
+	i := strings.LastIndex(ct, headerSep) + 1
+	b, err := base64.StdEncoding.DecodeString(ct[i:])
+	ast.Nil(err)
+	b[len(b)-1] ^= 0x01
+	_, err = DecryptAEAD(AlgAES256GCM, key, ct[:i]+base64.StdEncoding.EncodeToString(b), nil)
+	ast.NotNil(err)
+}
+
+func TestAEADLegacy(t *testing.T) {
+	ast := assert.New(t)
+	key := aeadKey(t)
+
+	// old ciphertexts can be decrypted, but no new ones created
+	_, err := EncryptAlg(AlgAES256, key, "kid1234", encryptMsg, nil)
+	ast.Equal(ErrLegacyAlg, err)
+
+	ct, err := Encrypt(key, encryptMsg)
+	ast.Nil(err)
+	ast.False(HasHeader(ct))
+
+	pt, err := DecryptAlg(AlgAES256, key, ct, nil)
+	ast.Nil(err)
+	ast.Equal(encryptMsg, pt)
+
+	// a legacy ciphertext is not valid for an aead key
+	_, err = DecryptAlg(AlgAES256GCM, key, ct, nil)
+	ast.Equal(ErrCiphertextNotValid, err)
+
+	_, err = DecryptAlg(AlgAES256, key, ct, []byte("ad"))
+	ast.NotNil(err)
+
+	_, err = EncryptAlg("DES", key, "kid1234", encryptMsg, nil)
+	ast.Equal(ErrUnknownAlg, err)
+}
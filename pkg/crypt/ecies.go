@@ -0,0 +1,177 @@
+package crypt
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// hybrid encryption for ecdsa and ed25519 keys (ecies): an ephemeral ecdh key on the curve of the recipient,
+// the shared secret is derived with hkdf-sha256 into an AES-256-GCM key.
+// ciphertext: base64 of ephemeral public key, nonce and sealed data
+// ed25519 keys are converted to x25519 keys, like libsodium does.
+
+const eciesInfo = "micro-vault ecies"
+
+// p25519 the prime of curve25519, 2^255 - 19
+var p25519 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// EncryptPub encrypting a message with a public key, rsa keys with RSA-OAEP, ecdsa and ed25519 keys with ecies
+func EncryptPub(pub crypto.PublicKey, text string) (string, error) {
+	if rp, ok := pub.(*rsa.PublicKey); ok {
+		return EncryptKey(*rp, text)
+	}
+	rp, err := ecdhPublic(pub)
+	if err != nil {
+		return "", err
+	}
+	ek, err := rp.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	secret, err := ek.ECDH(rp)
+	if err != nil {
+		return "", err
+	}
+	epb := ek.PublicKey().Bytes()
+	aead, err := eciesAEAD(secret, epb, rp.Bytes())
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, len(epb)+aead.NonceSize())
+	copy(b, epb)
+	nonce := b[len(epb):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	b = aead.Seal(b, nonce, []byte(text), nil)
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecryptPrv decrypting a message with a private key, the counterpart of EncryptPub
+func DecryptPrv(k crypto.PrivateKey, dt string) (string, error) {
+	if rk, ok := k.(*rsa.PrivateKey); ok {
+		return DecryptKey(*rk, dt)
+	}
+	pk, err := ecdhPrivate(k)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.StdEncoding.DecodeString(dt)
+	if err != nil {
+		return "", err
+	}
+	// the ephemeral public key has the same encoding as the public key of the recipient
+	n := len(pk.PublicKey().Bytes())
+	if len(b) < n {
+		return "", errors.New("ciphertext too short")
+	}
+	ep, err := pk.Curve().NewPublicKey(b[:n])
+	if err != nil {
+		return "", err
+	}
+	secret, err := pk.ECDH(ep)
+	if err != nil {
+		return "", err
+	}
+	aead, err := eciesAEAD(secret, b[:n], pk.PublicKey().Bytes())
+	if err != nil {
+		return "", err
+	}
+	b = b[n:]
+	if len(b) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	pt, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+func eciesAEAD(secret, epb, rpb []byte) (cipher.AEAD, error) {
+	info := make([]byte, 0, len(eciesInfo)+len(epb)+len(rpb))
+	info = append(info, eciesInfo...)
+	info = append(info, epb...)
+	info = append(info, rpb...)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, info), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func ecdhPublic(pub crypto.PublicKey) (*ecdh.PublicKey, error) {
+	switch p := pub.(type) {
+	case *ecdsa.PublicKey:
+		return p.ECDH()
+	case ed25519.PublicKey:
+		u, err := montgomeryU(p)
+		if err != nil {
+			return nil, err
+		}
+		return ecdh.X25519().NewPublicKey(u)
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnknownKeyType, pub)
+}
+
+func ecdhPrivate(k crypto.PrivateKey) (*ecdh.PrivateKey, error) {
+	switch p := k.(type) {
+	case *ecdsa.PrivateKey:
+		return p.ECDH()
+	case ed25519.PrivateKey:
+		// the x25519 scalar is the (clamped) first half of the hashed seed, same as the ed25519 scalar
+		h := sha512.Sum512(p.Seed())
+		return ecdh.X25519().NewPrivateKey(h[:32])
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnknownKeyType, k)
+}
+
+// montgomeryU converting an ed25519 public key (edwards y) into the x25519 public key u = (1+y)/(1-y)
+func montgomeryU(pub ed25519.PublicKey) ([]byte, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("ed25519 public key with wrong size")
+	}
+	le := make([]byte, len(pub))
+	copy(le, pub)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverse(le))
+	if y.Cmp(p25519) >= 0 {
+		return nil, errors.New("ed25519 public key not valid")
+	}
+	one := big.NewInt(1)
+	d := new(big.Int).Sub(one, y)
+	d.Mod(d, p25519)
+	if d.Sign() == 0 {
+		return nil, errors.New("ed25519 public key not valid")
+	}
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, d.ModInverse(d, p25519))
+	u.Mod(u, p25519)
+	return reverse(u.FillBytes(make([]byte, 32))), nil
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
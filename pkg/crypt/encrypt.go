@@ -4,6 +4,9 @@ import (
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -50,8 +53,11 @@ func Encrypt(key []byte, text string) (string, error) {
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// Decrypt from base64 to decrypted string
+// Decrypt from base64 to decrypted string with the legacy AES-256 CFB, aead ciphertexts are rejected, see DecryptAlg
 func Decrypt(key []byte, cryptoText string) (string, error) {
+	if HasHeader(cryptoText) {
+		return "", ErrCiphertextNotValid
+	}
 	ciphertext, _ := base64.StdEncoding.DecodeString(cryptoText)
 
 	block, err := aes.NewCipher(key)
@@ -75,20 +81,13 @@ func Decrypt(key []byte, cryptoText string) (string, error) {
 	return string(ciphertext), nil
 }
 
-// EncryptPEM string to base64 crypto using PEM File with public key
+// EncryptPEM string to base64 crypto using PEM File with public key, see EncryptPub
 func EncryptPEM(key string, text string) (string, error) {
-	pub, err := Pem2Pub(key)
+	pub, err := Pem2PubKey(key)
 	if err != nil {
 		return "", err
 	}
-
-	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, []byte(text), nil)
-
-	if err != nil {
-		return "", err
-	}
-	// convert to base64
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return EncryptPub(pub, text)
 }
 
 // EncryptKey encrypting a message with a public key
@@ -117,26 +116,45 @@ func DecryptKey(pk rsa.PrivateKey, dt string) (string, error) {
 
 // Sign singing a data part with a private key
 func Sign(pk rsa.PrivateKey, dt string) (string, error) {
-	// Before signing, we need to hash our message
-	// The hash is what we actually sign
-	msgHashSum, err := hashme(dt)
-	if err != nil {
-		return "", err
-	}
+	return SignKey(&pk, dt)
+}
 
-	// In order to generate the signature, we provide a random number generator,
-	// our private key, the hashing algorithm that we used, and the hash sum
-	// of our message
-	signature, err := rsa.SignPSS(rand.Reader, &pk, crypto.SHA256, msgHashSum, nil)
-	if err != nil {
-		return "", err
+// SignKey signing a data part with a private key: RSA-PSS with SHA-256, ECDSA with SHA-256 (SHA-384 for P-384) or Ed25519
+func SignKey(k crypto.Signer, dt string) (string, error) {
+	var signature []byte
+	var err error
+	switch pk := k.(type) {
+	case *rsa.PrivateKey:
+		// Before signing, we need to hash our message
+		// The hash is what we actually sign
+		msgHashSum, err := hashme(dt)
+		if err != nil {
+			return "", err
+		}
+
+		// In order to generate the signature, we provide a random number generator,
+		// our private key, the hashing algorithm that we used, and the hash sum
+		// of our message
+		signature, err = rsa.SignPSS(rand.Reader, pk, crypto.SHA256, msgHashSum, nil)
+		if err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		signature, err = ecdsa.SignASN1(rand.Reader, pk, ecHash(&pk.PublicKey, dt))
+		if err != nil {
+			return "", err
+		}
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(pk, []byte(dt))
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnknownKeyType, k)
 	}
 	return base64.StdEncoding.EncodeToString(signature), nil
 }
 
 // SignCheckPEM check a signature of a data string
 func SignCheckPEM(key string, signature, dt string) (bool, error) {
-	pub, err := Pem2Pub(key)
+	pub, err := Pem2PubKey(key)
 	if err != nil {
 		return false, err
 	}
@@ -144,35 +162,52 @@ func SignCheckPEM(key string, signature, dt string) (bool, error) {
 	return SignCheck(pub, signature, dt)
 }
 
-// SignCheck check a signature of a data string
-func SignCheck(pub *rsa.PublicKey, signature, dt string) (bool, error) {
-	// Before signing, we need to hash our message
-	// The hash is what we actually sign
-	msgHashSum, err := hashme(dt)
-	if err != nil {
-		return false, err
-	}
-
+// SignCheck check a signature of a data string, see SignKey for the algorithms
+func SignCheck(pub crypto.PublicKey, signature, dt string) (bool, error) {
 	sig, err := base64.StdEncoding.DecodeString(signature)
 	if err != nil {
 		return false, err
 	}
 
-	err = rsa.VerifyPSS(pub, crypto.SHA256, msgHashSum, sig, nil)
-	if err != nil {
-		return false, err
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		// Before signing, we need to hash our message
+		// The hash is what we actually sign
+		msgHashSum, err := hashme(dt)
+		if err != nil {
+			return false, err
+		}
+		err = rsa.VerifyPSS(p, crypto.SHA256, msgHashSum, sig, nil)
+		if err != nil {
+			return false, err
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(p, ecHash(p, dt), sig) {
+			return false, errors.New("ecdsa: verification error")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(p, []byte(dt), sig) {
+			return false, errors.New("ed25519: verification error")
+		}
+	default:
+		return false, fmt.Errorf("%w: %T", ErrUnknownKeyType, pub)
 	}
 	return true, nil
 }
 
-// Pem2Pub converts a pem string with a public key into public key
-func Pem2Pub(key string) (*rsa.PublicKey, error) {
-	block, _ := pem.Decode([]byte(key))
-	if block == nil || block.Type != "PUBLIC KEY" {
-		return nil, errors.New("error getting public key")
+// ecHash the hash of the data for an ecdsa signature, SHA-384 for P-384 keys and SHA-256 for all others
+func ecHash(pub *ecdsa.PublicKey, dt string) []byte {
+	if pub.Curve == elliptic.P384() {
+		h := sha512.Sum384([]byte(dt))
+		return h[:]
 	}
+	h := sha256.Sum256([]byte(dt))
+	return h[:]
+}
 
-	p, err := x509.ParsePKIXPublicKey(block.Bytes)
+// Pem2Pub converts a pem string with a rsa public key into public key, see Pem2PubKey for all key types
+func Pem2Pub(key string) (*rsa.PublicKey, error) {
+	p, err := Pem2PubKey(key)
 	if err != nil {
 		return nil, err
 	}
@@ -184,7 +219,7 @@ func Pem2Pub(key string) (*rsa.PublicKey, error) {
 }
 
 // Pub2Pem converts a public key into a pem coded []byte
-func Pub2Pem(k *rsa.PublicKey) ([]byte, error) {
+func Pub2Pem(k crypto.PublicKey) ([]byte, error) {
 	pubbuf, err := x509.MarshalPKIXPublicKey(k)
 	if err != nil {
 		logging.Root.Errorf("create public key failed: %v", err)
@@ -200,37 +235,21 @@ func Pub2Pem(k *rsa.PublicKey) ([]byte, error) {
 	return b, nil
 }
 
-// Pem2Prv converts a pem string into a rsa private key
+// Pem2Prv converts a pem string into a rsa private key, see Pem2Key for all key types
 func Pem2Prv(key string) (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode([]byte(key))
-	if block == nil || ((block.Type != pemBlockPrivateKey) && (block.Type != pemBlockRSAPrivateKey)) {
-		return nil, errors.New("error getting private key")
-	}
-
-	if block.Type == pemBlockPrivateKey {
-		p, err := x509.ParsePKCS8PrivateKey(block.Bytes)
-		if err != nil {
-			return nil, err
-		}
-		prv, ok := p.(*rsa.PrivateKey)
-		if !ok {
-			return nil, errors.New("key is not a rsa private key")
-		}
-		return prv, nil
+	p, err := Pem2Key(key)
+	if err != nil {
+		return nil, err
 	}
-
-	if block.Type == pemBlockRSAPrivateKey {
-		prv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-		if err != nil {
-			return nil, err
-		}
-		return prv, nil
+	prv, ok := p.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not a rsa private key")
 	}
-	return nil, fmt.Errorf("unknown private key format: %s", block.Type)
+	return prv, nil
 }
 
 // Prv2Pem converts a private key to a PEM
-func Prv2Pem(rsk *rsa.PrivateKey) ([]byte, error) {
+func Prv2Pem(rsk crypto.PrivateKey) ([]byte, error) {
 	pubbuf, err := x509.MarshalPKCS8PrivateKey(rsk)
 	if err != nil {
 		return []byte{}, err
@@ -256,8 +275,8 @@ func hashme(dt string) ([]byte, error) {
 	return msgHash.Sum(nil), nil
 }
 
-// GetKID creates an KID for a private key
-func GetKID(rk *rsa.PrivateKey) (string, error) {
+// GetKID creates an KID for a private key, the jwk thumbprint of the key
+func GetKID(rk crypto.PrivateKey) (string, error) {
 	key, err := jwk.FromRaw(rk)
 	if err != nil {
 		return "", err
@@ -272,7 +291,7 @@ func GetKID(rk *rsa.PrivateKey) (string, error) {
 
 // GetKIDOfPEM creates an KID for a private key
 func GetKIDOfPEM(p string) (string, error) {
-	rsk, err := Pem2Prv(p)
+	rsk, err := Pem2Key(p)
 	if err != nil {
 		return "", err
 	}
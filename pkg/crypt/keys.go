@@ -0,0 +1,133 @@
+package crypt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key types of the asymmetric client keys
+const (
+	KeyTypeRSA2048   = "RSA-2048"
+	KeyTypeRSA3072   = "RSA-3072"
+	KeyTypeRSA4096   = "RSA-4096"
+	KeyTypeECDSAP256 = "ECDSA-P256"
+	KeyTypeECDSAP384 = "ECDSA-P384"
+	KeyTypeEd25519   = "ED25519"
+	// DefaultKeyType the key type of new clients without a key type
+	DefaultKeyType = KeyTypeRSA4096
+)
+
+const pemBlockECPrivateKey = "EC PRIVATE KEY"
+
+// ErrUnknownKeyType the key type is not supported
+var ErrUnknownKeyType = errors.New("unknown key type")
+
+// ValidKeyType checking if the key type is supported, an empty key type is the default key type
+func ValidKeyType(kt string) bool {
+	switch strings.ToUpper(kt) {
+	case "", KeyTypeRSA2048, KeyTypeRSA3072, KeyTypeRSA4096, KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeEd25519:
+		return true
+	}
+	return false
+}
+
+// GenerateKey generating a new private key of the key type, an empty key type is the default key type
+func GenerateKey(kt string) (crypto.Signer, error) {
+	switch strings.ToUpper(kt) {
+	case "":
+		return GenerateKey(DefaultKeyType)
+	case KeyTypeRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case KeyTypeRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyTypeEd25519:
+		_, pk, err := ed25519.GenerateKey(rand.Reader)
+		return pk, err
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownKeyType, kt)
+}
+
+// KeyType getting the key type of a public key
+func KeyType(pub crypto.PublicKey) (string, error) {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", p.N.BitLen()), nil
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + strings.ReplaceAll(p.Curve.Params().Name, "-", ""), nil
+	case ed25519.PublicKey:
+		return KeyTypeEd25519, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnknownKeyType, pub)
+}
+
+// KeyTypeOfPEM getting the key type of a pem encoded private key
+func KeyTypeOfPEM(p string) (string, error) {
+	k, err := Pem2Key(p)
+	if err != nil {
+		return "", err
+	}
+	return KeyType(k.Public())
+}
+
+// SigAlg the jws name of the signature algorithm of the key, RS256 for the RSA-PSS signatures of rsa keys
+func SigAlg(pub crypto.PublicKey) string {
+	switch p := pub.(type) {
+	case *ecdsa.PublicKey:
+		if p.Curve == elliptic.P384() {
+			return "ES384"
+		}
+		return "ES256"
+	case ed25519.PublicKey:
+		return "EdDSA"
+	}
+	return "RS256"
+}
+
+// Pem2Key converts a pem string into a private key, supported are rsa, ecdsa and ed25519 keys
+func Pem2Key(key string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("error getting private key")
+	}
+	switch block.Type {
+	case pemBlockPrivateKey:
+		p, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := p.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrUnknownKeyType, p)
+		}
+		return s, nil
+	case pemBlockRSAPrivateKey:
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case pemBlockECPrivateKey:
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unknown private key format: %s", block.Type)
+}
+
+// Pem2PubKey converts a pem string with a public key into a public key, supported are rsa, ecdsa and ed25519 keys
+func Pem2PubKey(key string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("error getting public key")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
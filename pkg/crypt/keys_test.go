@@ -0,0 +1,102 @@
+package crypt
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var keyTypes = []string{KeyTypeRSA2048, KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeEd25519}
+
+func TestKeyTypes(t *testing.T) {
+	ast := assert.New(t)
+
+	for _, kt := range keyTypes {
+		k, err := GenerateKey(kt)
+		ast.Nil(err, kt)
+		ktp, err := KeyType(k.Public())
+		ast.Nil(err)
+		ast.Equal(kt, ktp)
+
+		pp, err := Prv2Pem(k)
+		ast.Nil(err)
+		pk, err := Pem2Key(string(pp))
+		ast.Nil(err)
+		pub, err := Pub2Pem(k.Public())
+		ast.Nil(err)
+		pub2, err := Pub2Pem(pk.Public())
+		ast.Nil(err)
+		ast.Equal(pub, pub2)
+		ktp, err = KeyTypeOfPEM(string(pp))
+		ast.Nil(err)
+		ast.Equal(kt, ktp)
+
+		kid, err := GetKIDOfPEM(string(pp))
+		ast.Nil(err)
+		ast.NotEmpty(kid)
+
+		_, err = Pem2Prv(string(pp))
+		ast.Equal(kt == KeyTypeRSA2048, err == nil)
+	}
+	_, err := GenerateKey("DSA")
+	ast.ErrorIs(err, ErrUnknownKeyType)
+	ast.False(ValidKeyType("DSA"))
+	ast.True(ValidKeyType("ed25519"))
+}
+
+func TestSignKeyTypes(t *testing.T) {
+	ast := assert.New(t)
+
+	for _, kt := range keyTypes {
+		k, err := GenerateKey(kt)
+		ast.Nil(err)
+		sig, err := SignKey(k, encryptMsg)
+		ast.Nil(err, kt)
+
+		pub, err := Pub2Pem(k.Public())
+		ast.Nil(err)
+		ok, err := SignCheckPEM(string(pub), sig, encryptMsg)
+		ast.Nil(err, kt)
+		ast.True(ok)
+
+		ok, err = SignCheck(k.Public(), sig, encryptMsg+"x")
+		ast.NotNil(err)
+		ast.False(ok)
+	}
+}
+
+func TestEncryptKeyTypes(t *testing.T) {
+	ast := assert.New(t)
+
+	for _, kt := range keyTypes {
+		k, err := GenerateKey(kt)
+		ast.Nil(err)
+		pub, err := Pub2Pem(k.Public())
+		ast.Nil(err)
+
+		ct, err := EncryptPEM(string(pub), encryptMsg)
+		ast.Nil(err, kt)
+		pt, err := DecryptPrv(k, ct)
+		ast.Nil(err, kt)
+		ast.Equal(encryptMsg, pt)
+
+		// another key can't decrypt the message
+		ok, err := GenerateKey(kt)
+		ast.Nil(err)
+		_, err = DecryptPrv(ok, ct)
+		ast.NotNil(err)
+	}
+}
+
+func TestEd25519X25519(t *testing.T) {
+	ast := assert.New(t)
+
+	k, err := GenerateKey(KeyTypeEd25519)
+	ast.Nil(err)
+	xp, err := ecdhPrivate(k)
+	ast.Nil(err)
+	u, err := montgomeryU(k.Public().(ed25519.PublicKey))
+	ast.Nil(err)
+	ast.Equal(xp.PublicKey().Bytes(), u)
+}
@@ -0,0 +1,290 @@
+package crypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// streaming format:
+// header: "MVS1" | alg (1 byte) | segment size (4 bytes) | length of kid (1 byte) | kid | nonce prefix
+// followed by the sealed segments, every segment contains segment size bytes of plaintext, only the last one may be shorter.
+// The nonce of a segment is the nonce prefix, the segment counter (4 bytes) and the final flag (1 byte),
+// so segments can't be reordered, and a truncated stream is detected, because the final flag is missing.
+// The header is the associated data of every segment.
+const (
+	streamMagic = "MVS1"
+	// DefaultSegmentSize default size of the plaintext segments of a stream
+	DefaultSegmentSize = 64 * 1024
+	maxSegmentSize     = 16 * 1024 * 1024
+	streamAlgAES256GCM = byte(1)
+	streamAlgXChaCha20 = byte(2)
+	// counter and final flag
+	nonceSuffixSize = 5
+)
+
+var (
+	// ErrStreamNotValid the stream header is not valid
+	ErrStreamNotValid = errors.New("stream not valid")
+	// ErrStreamTruncated the stream ends without the final segment
+	ErrStreamTruncated = errors.New("stream truncated")
+	// ErrStreamClosed the stream is already closed
+	ErrStreamClosed = errors.New("stream already closed")
+)
+
+// StreamKeyFunc returns the key for the kid of a stream
+type StreamKeyFunc func(kid string) ([]byte, error)
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	hdr    []byte
+	prefix []byte
+	nonce  []byte
+	buf    []byte
+	out    []byte
+	ctr    uint32
+	start  bool
+	closed bool
+	err    error
+}
+
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	hdr    []byte
+	prefix []byte
+	nonce  []byte
+	in     []byte
+	plain  []byte
+	out    []byte
+	ctr    uint32
+	final  bool
+	err    error
+}
+
+// NewEncryptWriter creates a writer, encrypting all written data segment by segment into w.
+// Only AES-256-GCM and XCHACHA20-POLY1305 are supported. The header is written with the first segment.
+// Close must be called to write the final segment, the underlying writer will not be closed.
+func NewEncryptWriter(w io.Writer, alg string, key []byte, kid string) (io.WriteCloser, error) {
+	return newEncryptWriter(w, alg, key, kid, DefaultSegmentSize)
+}
+
+func newEncryptWriter(w io.Writer, alg string, key []byte, kid string, size int) (*encryptWriter, error) {
+	if len(kid) > math.MaxUint8 {
+		return nil, fmt.Errorf("kid too long, max %d bytes", math.MaxUint8)
+	}
+	if size <= 0 || size > maxSegmentSize {
+		return nil, fmt.Errorf("segment size not valid, max %d bytes", maxSegmentSize)
+	}
+	var a byte
+	switch alg {
+	case AlgAES256GCM:
+		a = streamAlgAES256GCM
+	case AlgXChaCha20Poly1305:
+		a = streamAlgXChaCha20
+	default:
+		return nil, ErrUnknownAlg
+	}
+	aead, _, err := newAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, aead.NonceSize()-nonceSuffixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, 0, len(streamMagic)+6+len(kid)+len(prefix))
+	hdr = append(hdr, streamMagic...)
+	hdr = append(hdr, a)
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(size))
+	hdr = append(hdr, byte(len(kid)))
+	hdr = append(hdr, kid...)
+	hdr = append(hdr, prefix...)
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		hdr:    hdr,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		buf:    make([]byte, 0, size),
+		out:    make([]byte, 0, size+aead.Overhead()),
+	}, nil
+}
+
+// Write encrypting the data, a segment is only written, if the next segment has data
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.closed {
+		return 0, ErrStreamClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(e.buf) == cap(e.buf) {
+			if err := e.flush(false); err != nil {
+				e.err = err
+				return n, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close writing the final segment
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	if e.err != nil {
+		return e.err
+	}
+	e.closed = true
+	return e.flush(true)
+}
+
+func (e *encryptWriter) flush(final bool) error {
+	if !final && e.ctr == math.MaxUint32 {
+		return errors.New("stream too long")
+	}
+	if !e.start {
+		if _, err := e.w.Write(e.hdr); err != nil {
+			return err
+		}
+		e.start = true
+	}
+	segmentNonce(e.nonce, e.prefix, e.ctr, final)
+	e.out = e.aead.Seal(e.out[:0], e.nonce, e.buf, e.hdr)
+	if _, err := e.w.Write(e.out); err != nil {
+		return err
+	}
+	e.ctr++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// NewDecryptReader creates a reader, decrypting a stream written by an encrypt writer
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	return NewDecryptReaderFunc(r, func(string) ([]byte, error) {
+		return key, nil
+	})
+}
+
+// NewDecryptReaderFunc creates a reader, decrypting a stream written by an encrypt writer.
+// The key is taken from the key function with the kid of the stream header.
+func NewDecryptReaderFunc(r io.Reader, kf StreamKeyFunc) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, len(streamMagic)+6)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, ErrStreamNotValid
+	}
+	if string(hdr[:len(streamMagic)]) != streamMagic {
+		return nil, ErrStreamNotValid
+	}
+	var alg string
+	switch hdr[len(streamMagic)] {
+	case streamAlgAES256GCM:
+		alg = AlgAES256GCM
+	case streamAlgXChaCha20:
+		alg = AlgXChaCha20Poly1305
+	default:
+		return nil, ErrUnknownAlg
+	}
+	size := int(binary.BigEndian.Uint32(hdr[len(streamMagic)+1:]))
+	if size <= 0 || size > maxSegmentSize {
+		return nil, ErrStreamNotValid
+	}
+	kid := make([]byte, int(hdr[len(hdr)-1]))
+	if _, err := io.ReadFull(br, kid); err != nil {
+		return nil, ErrStreamNotValid
+	}
+	key, err := kf(string(kid))
+	if err != nil {
+		return nil, err
+	}
+	aead, _, err := newAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, aead.NonceSize()-nonceSuffixSize)
+	if _, err := io.ReadFull(br, prefix); err != nil {
+		return nil, ErrStreamNotValid
+	}
+	hdr = append(hdr, kid...)
+	hdr = append(hdr, prefix...)
+	return &decryptReader{
+		r:      br,
+		aead:   aead,
+		hdr:    hdr,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		in:     make([]byte, size+aead.Overhead()),
+		plain:  make([]byte, 0, size),
+	}, nil
+}
+
+// Read decrypting the next segment, if needed
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	if d.final {
+		return io.EOF
+	}
+	n, err := io.ReadFull(d.r, d.in)
+	switch {
+	case err == io.EOF:
+		return ErrStreamTruncated
+	case err == io.ErrUnexpectedEOF:
+		d.final = true
+	case err != nil:
+		return err
+	default:
+		_, err = d.r.Peek(1)
+		if err == io.EOF {
+			d.final = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if !d.final && d.ctr == math.MaxUint32 {
+		return errors.New("stream too long")
+	}
+	segmentNonce(d.nonce, d.prefix, d.ctr, d.final)
+	pt, err := d.aead.Open(d.plain[:0], d.nonce, d.in[:n], d.hdr)
+	if err != nil {
+		return fmt.Errorf("segment %d: %w", d.ctr, err)
+	}
+	d.ctr++
+	d.out = pt
+	return nil
+}
+
+func segmentNonce(nonce, prefix []byte, ctr uint32, final bool) {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], ctr)
+	nonce[len(nonce)-1] = 0
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+}
@@ -0,0 +1,123 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	for _, alg := range []string{AlgAES256GCM, AlgXChaCha20Poly1305} {
+		for _, l := range []int{0, 1, 1023, 1024, 1025, 5000} {
+			ast := assert.New(t)
+			key := aeadKey(t)
+			pt := make([]byte, l)
+			_, err := rand.Read(pt)
+			ast.Nil(err)
+
+			var ct bytes.Buffer
+			w, err := newEncryptWriter(&ct, alg, key, "kid1234", 1024)
+			ast.Nil(err)
+			_, err = w.Write(pt)
+			ast.Nil(err)
+			ast.Nil(w.Close())
+
+			var kid string
+			r, err := NewDecryptReaderFunc(bytes.NewReader(ct.Bytes()), func(k string) ([]byte, error) {
+				kid = k
+				return key, nil
+			})
+			ast.Nil(err)
+			ast.Equal("kid1234", kid)
+			res, err := io.ReadAll(r)
+			ast.Nil(err, "%s %d", alg, l)
+			ast.True(bytes.Equal(pt, res), "%s %d", alg, l)
+		}
+	}
+}
+
+func TestStreamDefault(t *testing.T) {
+	ast := assert.New(t)
+	key := aeadKey(t)
+	pt := make([]byte, 3*DefaultSegmentSize+17)
+	_, err := rand.Read(pt)
+	ast.Nil(err)
+
+	var ct bytes.Buffer
+	w, err := NewEncryptWriter(&ct, AlgAES256GCM, key, "kid")
+	ast.Nil(err)
+	// write in small pieces
+	_, err = io.CopyBuffer(w, bytes.NewReader(pt), make([]byte, 1000))
+	ast.Nil(err)
+	ast.Nil(w.Close())
+	_, err = w.Write([]byte("closed"))
+	ast.Equal(ErrStreamClosed, err)
+
+	r, err := NewDecryptReader(&ct, key)
+	ast.Nil(err)
+	res, err := io.ReadAll(r)
+	ast.Nil(err)
+	ast.True(bytes.Equal(pt, res))
+}
+
+func TestStreamTampered(t *testing.T) {
+	ast := assert.New(t)
+	key := aeadKey(t)
+	pt := make([]byte, 4096)
+
+	var ct bytes.Buffer
+	w, err := newEncryptWriter(&ct, AlgXChaCha20Poly1305, key, "kid", 1024)
+	ast.Nil(err)
+	_, err = w.Write(pt)
+	ast.Nil(err)
+	ast.Nil(w.Close())
+	b := ct.Bytes()
+	seg := 1024 + 16
+	hdr := len(b) - 4*seg
+
+	// truncated at a segment boundary
+	r, err := NewDecryptReader(bytes.NewReader(b[:hdr+3*seg]), key)
+	ast.Nil(err)
+	_, err = io.ReadAll(r)
+	ast.NotNil(err)
+
+	// truncated in the middle of a segment
+	r, err = NewDecryptReader(bytes.NewReader(b[:len(b)-10]), key)
+	ast.Nil(err)
+	_, err = io.ReadAll(r)
+	ast.NotNil(err)
+
+	// reordered segments
+	re := append([]byte{}, b[:hdr]...)
+	re = append(re, b[hdr+seg:hdr+2*seg]...)
+	re = append(re, b[hdr:hdr+seg]...)
+	re = append(re, b[hdr+2*seg:]...)
+	r, err = NewDecryptReader(bytes.NewReader(re), key)
+	ast.Nil(err)
+	_, err = io.ReadAll(r)
+	ast.NotNil(err)
+
+	// changed header
+	ch := append([]byte{}, b...)
+	ch[len(streamMagic)+6] = 'x'
+	r, err = NewDecryptReader(bytes.NewReader(ch), key)
+	ast.Nil(err)
+	_, err = io.ReadAll(r)
+	ast.NotNil(err)
+
+	// wrong key
+	r, err = NewDecryptReader(bytes.NewReader(b), aeadKey(t))
+	ast.Nil(err)
+	_, err = io.ReadAll(r)
+	ast.NotNil(err)
+
+	// no stream
+	_, err = NewDecryptReader(bytes.NewReader([]byte("no stream")), key)
+	ast.Equal(ErrStreamNotValid, err)
+
+	_, err = NewEncryptWriter(&ct, AlgAES256, key, "kid")
+	ast.Equal(ErrUnknownAlg, err)
+}
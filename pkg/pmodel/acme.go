@@ -0,0 +1,9 @@
+package pmodel
+
+// ACMEEAB credentials of an external account binding for the acme server,
+// with these an acme account bound to the client can be created
+type ACMEEAB struct {
+	KID       string `json:"kid"`
+	Key       string `json:"key"` // base64url encoded hmac key
+	Directory string `json:"directory"`
+}
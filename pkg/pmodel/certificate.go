@@ -0,0 +1,30 @@
+package pmodel
+
+import "time"
+
+// CertificateInfo information about an issued certificate
+type CertificateInfo struct {
+	Serial    string     `json:"serial"`
+	Subject   string     `json:"subject"`
+	Client    string     `json:"client"`
+	Profile   string     `json:"profile,omitempty"`
+	NotBefore time.Time  `json:"notbefore"`
+	NotAfter  time.Time  `json:"notafter"`
+	Revoked   *time.Time `json:"revoked,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	PEM       string     `json:"pem,omitempty"` // the certificate, only for a single certificate
+}
+
+// Revocation revoking a certificate with a reason, e.g. keyCompromise
+type Revocation struct {
+	Reason string `json:"reason"`
+}
+
+// CertBundleRequest requesting a certificate with a server side generated key
+type CertBundleRequest struct {
+	Template string `json:"template"`          // certificate request as pem, the public key of the request is ignored
+	Profile  string `json:"profile,omitempty"` // certificate profile, empty for the default profile of the client
+	KeyType  string `json:"keytype,omitempty"` // ECDSA-P256 (default), ECDSA-P384, RSA-2048, RSA-3072 or RSA-4096
+	Format   string `json:"format,omitempty"`  // pem (default), pkcs12 or pkcs12-legacy
+	Password string `json:"password"`          // password of the private key in the bundle
+}
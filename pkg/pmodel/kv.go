@@ -0,0 +1,44 @@
+package pmodel
+
+import "time"
+
+// KVSecret a version of a secret of the key/value store
+type KVSecret struct {
+	Group     string    `json:"group"`
+	Path      string    `json:"path"`
+	Version   int       `json:"version"`
+	Value     string    `json:"value"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"createdby"`
+}
+
+// KVWrite writing a new version of a secret
+type KVWrite struct {
+	Value string `json:"value"`
+	CAS   *int   `json:"cas,omitempty"` // check-and-set, the write only succeeds if this is the current version, 0 for a new secret
+}
+
+// KVVersions versions of a secret to delete or undelete
+type KVVersions struct {
+	Versions []int `json:"versions"`
+}
+
+// KVMetadata the metadata of a secret with all versions, without the values
+type KVMetadata struct {
+	Group          string          `json:"group"`
+	Path           string          `json:"path"`
+	CurrentVersion int             `json:"currentversion"`
+	Created        time.Time       `json:"created"`
+	CreatedBy      string          `json:"createdby"`
+	Updated        time.Time       `json:"updated"`
+	UpdatedBy      string          `json:"updatedby"`
+	Versions       []KVVersionInfo `json:"versions"`
+}
+
+// KVVersionInfo the metadata of a single version
+type KVVersionInfo struct {
+	Version   int        `json:"version"`
+	Created   time.Time  `json:"created"`
+	CreatedBy string     `json:"createdby"`
+	Deleted   *time.Time `json:"deleted,omitempty"`
+}
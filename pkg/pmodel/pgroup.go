@@ -4,30 +4,73 @@ import "time"
 
 // Group the public group model
 type Group struct {
-	Name     string            `json:"name"`
-	Label    map[string]string `json:"label"`
-	IsClient bool              `json:"isclient"`
+	Name        string            `json:"name"`
+	Label       map[string]string `json:"label"`
+	IsClient    bool              `json:"isclient"`
+	KeyRotation string            `json:"keyrotation,omitempty"`
+	TokenTTL    string            `json:"tokenttl,omitempty"`
+	RefreshTTL  string            `json:"refreshttl,omitempty"`
 }
 
 // Client the public client model
 type Client struct {
-	Name      string         `json:"name"`
-	AccessKey string         `json:"accesskey"`
-	Secret    string         `json:"secret"`
-	Groups    []string       `json:"groups"`
-	KID       string         `json:"kid,omitempty"`
-	Key       string         `json:"key,omitempty"`
-	Crt       map[string]any `json:"crt,omitempty"`
+	Name       string         `json:"name"`
+	AccessKey  string         `json:"accesskey"`
+	Secret     string         `json:"secret"`
+	Groups     []string       `json:"groups"`
+	KID        string         `json:"kid,omitempty"`
+	Key        string         `json:"key,omitempty"`
+	KeyType    string         `json:"keytype,omitempty"` // RSA-2048, RSA-3072, RSA-4096 (default), ECDSA-P256, ECDSA-P384 or ED25519
+	Crt        map[string]any `json:"crt,omitempty"`
+	Disabled   bool           `json:"disabled,omitempty"`
+	NotBefore  *time.Time     `json:"notbefore,omitempty"`
+	ExpiresAt  *time.Time     `json:"expiresat,omitempty"`
+	TokenTTL   string         `json:"tokenttl,omitempty"`
+	RefreshTTL string         `json:"refreshttl,omitempty"`
+}
+
+// ClientState the state of a client, a disabled client or a client outside its validity period can't login
+type ClientState struct {
+	Disabled  bool       `json:"disabled"`
+	NotBefore *time.Time `json:"notbefore,omitempty"` // nil for no restriction
+	ExpiresAt *time.Time `json:"expiresat,omitempty"` // nil for no expiration
+}
+
+// TokenTTL the token lifetimes of a client, empty values are using the lifetimes of the groups or the service
+type TokenTTL struct {
+	TokenTTL   string `json:"tokenttl"`
+	RefreshTTL string `json:"refreshttl"`
+}
+
+// ClientKey a private key of a client, the current or a previous key after a key rotation
+type ClientKey struct {
+	KID     string    `json:"kid"`
+	Key     string    `json:"key"`
+	KeyType string    `json:"keytype,omitempty"`
+	Retire  time.Time `json:"retire,omitempty"` // the previous key is retired after this time, zero for the current key
+}
+
+// ClientSecret a secret of a client, the secret itself is only given once after the creation
+type ClientSecret struct {
+	ID      string     `json:"id"`
+	Secret  string     `json:"secret,omitempty"`
+	Created *time.Time `json:"created,omitempty"` // not known for the primary secret
+	Expires *time.Time `json:"expires,omitempty"`
 }
 
 // Message this is a message for a en/decrypting request
 type Message struct {
-	Type      string `json:"type"`      // The type of message means group for group messages or private for a private message
-	Origin    string `json:"origin"`    // who sends this message, group name or client name
-	Recipient string `json:"recipient"` // who should receive this message, group name or client name
-	ID        string `json:"id"`        // only set when the AES key is already created
-	Decrypt   bool   `json:"decrypt"`   // True for message decryption and false for message encryption
-	Message   string `json:"message"`   // the message to en/decrypt
+	Type      string `json:"type"`               // The type of message means group for group messages or private for a private message
+	Origin    string `json:"origin"`             // who sends this message, group name or client name
+	Recipient string `json:"recipient"`          // who should receive this message, group name or client name
+	ID        string `json:"id"`                 // only set when the AES key is already created
+	Decrypt   bool   `json:"decrypt"`            // True for message decryption and false for message encryption
+	Message   string `json:"message"`            // the message to en/decrypt
+	Alg       string `json:"alg,omitempty"`      // algorithm of a new group key: AES-256-GCM (default) or XCHACHA20-POLY1305
+	AD        string `json:"ad,omitempty"`       // optional associated data, only for AES-256-GCM and XCHACHA20-POLY1305
+	Error     string `json:"error,omitempty"`    // only on batch results, the error of this item
+	TTL       string `json:"ttl,omitempty"`      // only for stored data, time to live e.g. 1h or 7d, empty for no expiration
+	ReadOnce  bool   `json:"readonce,omitempty"` // only for stored data, the data will be deleted after the first read
 }
 
 // SignMessage this is a message for a en/decrypting request
@@ -36,6 +79,25 @@ type SignMessage struct {
 	Message   string  `json:"message"`   // the message to sign
 	Signature string  `json:"signature"` // the signature
 	Valid     bool    `json:"valid"`
+	Error     string  `json:"error,omitempty"` // only on batch results, the error of this item
+}
+
+// RewrapMessage a ciphertext to re-encrypt with a newer key
+type RewrapMessage struct {
+	ID       string `json:"id"`                 // id of the key of the ciphertext, in the result the id of the new key
+	Message  string `json:"message"`            // the ciphertext
+	TargetID string `json:"targetid,omitempty"` // optional id of the new key, default is the current key of the group
+	AD       string `json:"ad,omitempty"`       // optional associated data of the ciphertext
+	Error    string `json:"error,omitempty"`    // only on batch results, the error of this item
+}
+
+// DataKey a data key for envelope encryption
+type DataKey struct {
+	KID        string `json:"kid"`                 // id of the group key wrapping the data key
+	Group      string `json:"group"`               // the group of the wrapping key
+	Alg        string `json:"alg"`                 // algorithm the data key is intended for
+	Plaintext  string `json:"plaintext,omitempty"` // the hex encoded data key
+	Ciphertext string `json:"ciphertext"`          // the data key wrapped with the group key
 }
 
 // KeyInfo some information about the used key
@@ -46,14 +108,17 @@ type KeyInfo struct {
 
 // EncryptKeyInfo some information about the used key
 type EncryptKeyInfo struct {
-	Alg     string    `json:"alg"`
-	ID      string    `json:"kid"`
-	Group   string    `json:"group"`
-	Key     string    `json:"key"`
-	Created time.Time `json:"created"`
+	Alg       string     `json:"alg"`
+	ID        string     `json:"kid"`
+	Group     string     `json:"group"`
+	Key       string     `json:"key"`
+	Created   time.Time  `json:"created"`
+	Version   int        `json:"version"`
+	State     string     `json:"state"`
+	DestroyAt *time.Time `json:"destroyat,omitempty"`
 }
 
-//EncryptKey the key for en/decryption
+// EncryptKey the key for en/decryption
 type EncryptKey struct {
 	ID  string `json:"id"`
 	Alg string `json:"alg"`
@@ -0,0 +1,33 @@
+package pmodel
+
+// CertProfile a certificate profile, controlling the usage, the validity and the names of the issued certificates
+type CertProfile struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	KeyUsage    []string          `json:"keyusage"`
+	ExtKeyUsage []string          `json:"extkeyusage,omitempty"`
+	MaxValidity string            `json:"maxvalidity,omitempty"`
+	DNS         []string          `json:"dns,omitempty"`
+	Wildcards   bool              `json:"wildcards,omitempty"`
+	IPs         []string          `json:"ips,omitempty"`
+	URIs        []string          `json:"uris,omitempty"`
+	Emails      []string          `json:"emails,omitempty"`
+	Subject     CertSubjectPolicy `json:"subject"`
+	CA          *CertCAPolicy     `json:"ca,omitempty"`
+}
+
+// CertSubjectPolicy the policy for the subject of the issued certificates
+type CertSubjectPolicy struct {
+	CommonNames []string          `json:"cn,omitempty"`
+	RequireCN   bool              `json:"requirecn,omitempty"`
+	Fixed       map[string]string `json:"fixed,omitempty"`
+}
+
+// CertCAPolicy the basic and the name constraints of a sub ca certificate
+type CertCAPolicy struct {
+	MaxPathLen   int      `json:"maxpathlen"`
+	PermittedDNS []string `json:"permitteddns,omitempty"`
+	ExcludedDNS  []string `json:"excludeddns,omitempty"`
+	PermittedIPs []string `json:"permittedips,omitempty"`
+	ExcludedIPs  []string `json:"excludedips,omitempty"`
+}
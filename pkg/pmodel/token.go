@@ -0,0 +1,37 @@
+package pmodel
+
+import "time"
+
+// TokenRevocation revoking all tokens of a client issued until before, an empty before is now
+type TokenRevocation struct {
+	Before *time.Time `json:"before,omitempty"`
+}
+
+// Introspection the introspection response of a token (RFC 7662), an invalid, expired or revoked token is only active: false
+type Introspection struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"` // Bearer for an access token, refresh_token for a refresh token
+	ClientID  string   `json:"client_id,omitempty"`  // the access key of the client
+	Username  string   `json:"username,omitempty"`   // the name of the client or the admin user
+	Sub       string   `json:"sub,omitempty"`
+	Aud       string   `json:"aud,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// AuthServerMetadata the OAuth 2.0 authorization server metadata (RFC 8414)
+type AuthServerMetadata struct {
+	Issuer                   string   `json:"issuer"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	RevocationEndpoint       string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint    string   `json:"introspection_endpoint"`
+	ResponseTypesSupported   []string `json:"response_types_supported"`
+	GrantTypesSupported      []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionAuthMethods []string `json:"introspection_endpoint_auth_methods_supported"`
+	RevocationAuthMethods    []string `json:"revocation_endpoint_auth_methods_supported"`
+}